@@ -2,7 +2,6 @@ package main
 
 import (
 	"log"
-	"strconv"
 
 	"github.com/jandauz/go-msmq"
 )
@@ -63,12 +62,7 @@ func main() {
 			log.Fatal(err)
 		}
 
-		opts := []msmq.PeekOption{
-			msmq.PeekWithWantDestinationQueue(true),
-			msmq.PeekWithWantBody(true),
-			msmq.PeekWithTimeout(1),
-			msmq.PeekWithWantConnectorType(true),
-		}
+		opts := msmq.FullPeek(msmq.PeekWithTimeout(1))
 		msg, err := queue.Peek(opts...)
 		if err != nil {
 			log.Fatal(err)
@@ -115,11 +109,7 @@ func main() {
 		if err != nil {
 			log.Fatal(err)
 		}
-		s, err := msg.LookupID()
-		if err != nil {
-			log.Fatal(err)
-		}
-		id, err := strconv.ParseUint(s, 10, 64)
+		id, err := msg.LookupID()
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -134,7 +124,7 @@ func main() {
 		if err != nil {
 			log.Fatal(err)
 		}
-		s, err = msg.Body()
+		s, err := msg.Body()
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -170,11 +160,7 @@ func main() {
 		}
 		log.Printf("Peek next by lookup id: %s", s)
 
-		s, err = msg.LookupID()
-		if err != nil {
-			log.Fatal(err)
-		}
-		id, err = strconv.ParseUint(s, 10, 64)
+		id, err = msg.LookupID()
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -208,18 +194,13 @@ func main() {
 			log.Fatal(err)
 		}
 
-		opts := []msmq.PeekOption{
-			msmq.PeekWithWantDestinationQueue(true),
-			msmq.PeekWithWantBody(true),
-			msmq.PeekWithTimeout(1),
-			msmq.PeekWithWantConnectorType(true),
-		}
+		opts := msmq.FullPeek(msmq.PeekWithTimeout(1))
 		msg, err := queue.Peek(opts...)
 		if err != nil {
 			log.Fatal(err)
 		}
 
-		if (msmq.Message{}) != msg {
+		if !msg.IsZero() {
 			log.Fatal("Queue not purged")
 		} else {
 			log.Println("Queue is purged")
@@ -241,13 +222,10 @@ func main() {
 			log.Fatal(err)
 		}
 
-		opts := []msmq.ReceiveOption{
+		opts := msmq.FullReceive(
 			msmq.ReceiveWithTransaction(msmq.NoTransaction),
-			msmq.ReceiveWithWantDestinationQueue(true),
-			msmq.ReceiveWithWantBody(true),
 			msmq.ReceiveWithTimeout(1),
-			msmq.ReceiveWithWantConnectorType(true),
-		}
+		)
 		msg, err := queue.Receive(opts...)
 		if err != nil {
 			log.Fatal(err)
@@ -292,11 +270,7 @@ func main() {
 		if err != nil {
 			log.Fatal(err)
 		}
-		s, err := msg.LookupID()
-		if err != nil {
-			log.Fatal(err)
-		}
-		id, err := strconv.ParseUint(s, 10, 64)
+		id, err := msg.LookupID()
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -323,7 +297,7 @@ func main() {
 			log.Fatal(err)
 		}
 
-		s, err = msg.Body()
+		s, err := msg.Body()
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -356,11 +330,7 @@ func main() {
 			log.Fatal(err)
 		}
 
-		s, err = msg.LookupID()
-		if err != nil {
-			log.Fatal(err)
-		}
-		id, err = strconv.ParseUint(s, 10, 64)
+		id, err = msg.LookupID()
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -382,11 +352,7 @@ func main() {
 			log.Fatal(err)
 		}
 
-		s, err = msg.LookupID()
-		if err != nil {
-			log.Fatal(err)
-		}
-		id, err = strconv.ParseUint(s, 10, 64)
+		id, err = msg.LookupID()
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -445,13 +411,10 @@ func main() {
 			log.Fatal(err)
 		}
 
-		opts := []msmq.ReceiveOption{
+		opts := msmq.FullReceive(
 			msmq.ReceiveWithTransaction(msmq.SingleMessage),
-			msmq.ReceiveWithWantDestinationQueue(true),
-			msmq.ReceiveWithWantBody(true),
 			msmq.ReceiveWithTimeout(1),
-			msmq.ReceiveWithWantConnectorType(true),
-		}
+		)
 		msg, err := queue.Receive(opts...)
 		if err != nil {
 			log.Fatal(err)
@@ -496,11 +459,7 @@ func main() {
 		if err != nil {
 			log.Fatal(err)
 		}
-		s, err := msg.LookupID()
-		if err != nil {
-			log.Fatal(err)
-		}
-		id, err := strconv.ParseUint(s, 10, 64)
+		id, err := msg.LookupID()
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -527,7 +486,7 @@ func main() {
 			log.Fatal(err)
 		}
 
-		s, err = msg.Body()
+		s, err := msg.Body()
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -560,11 +519,7 @@ func main() {
 			log.Fatal(err)
 		}
 
-		s, err = msg.LookupID()
-		if err != nil {
-			log.Fatal(err)
-		}
-		id, err = strconv.ParseUint(s, 10, 64)
+		id, err = msg.LookupID()
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -586,11 +541,7 @@ func main() {
 			log.Fatal(err)
 		}
 
-		s, err = msg.LookupID()
-		if err != nil {
-			log.Fatal(err)
-		}
-		id, err = strconv.ParseUint(s, 10, 64)
+		id, err = msg.LookupID()
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -650,11 +601,12 @@ func main() {
 	}
 	log.Printf("IsOpen: %v", b)
 
-	queueInfo, err = queue.QueueInfo()
+	openedInfo, err := queue.QueueInfo()
 	if err != nil {
 		log.Fatal(err)
 	}
-	s, err := queueInfo.FormatName()
+	s, err := openedInfo.FormatName()
+	openedInfo.Close()
 	if err != nil {
 		log.Fatal(err)
 	}
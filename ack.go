@@ -0,0 +1,351 @@
+package msmq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrAckTimeout is the error of an AckFuture when no acknowledgment
+	// arrived within the timeout of its AckListener. With AckNackReachQueue
+	// and AckNackReceive, which only request negative acknowledgments, it
+	// means that the message was presumably delivered.
+	ErrAckTimeout = errors.New("go-msmq: no acknowledgment received")
+
+	// ErrAckListenerClosed is the error of the AckFutures that are pending
+	// when their AckListener is closed.
+	ErrAckListenerClosed = errors.New("go-msmq: acknowledgment listener closed")
+)
+
+// NackError is the error of an AckFuture resolved by a negative
+// acknowledgment.
+type NackError struct {
+	// Class explains why the message was not delivered.
+	Class MessageClass
+}
+
+// Error implements the error interface.
+func (e *NackError) Error() string {
+	return fmt.Sprintf("go-msmq: message not delivered: class %#x", int32(e.Class))
+}
+
+// Ack is the acknowledgment of a message tracked by an AckListener.
+type Ack struct {
+	// MessageID is the identifier of the acknowledged message.
+	MessageID []byte
+
+	// Class is the class of the acknowledgment, or ClassNormal if none
+	// arrived.
+	Class MessageClass
+
+	// Err is nil if the message was delivered, a *NackError if it was not,
+	// and ErrAckTimeout or ErrAckListenerClosed if no acknowledgment
+	// arrived.
+	Err error
+}
+
+// AckFuture is the pending acknowledgment of a message.
+type AckFuture struct {
+	tracked time.Time
+	done    chan struct{}
+	ack     Ack
+}
+
+// Done returns a channel that is closed when the acknowledgment arrives.
+func (f *AckFuture) Done() <-chan struct{} {
+	return f.done
+}
+
+// Wait waits for the acknowledgment until ctx is done and returns it with its
+// error.
+func (f *AckFuture) Wait(ctx context.Context) (Ack, error) {
+	select {
+	case <-f.done:
+		return f.ack, f.ack.Err
+	case <-ctx.Done():
+		return Ack{}, fmt.Errorf("go-msmq: Wait() failed to wait for acknowledgment: %w", ctx.Err())
+	}
+}
+
+// AckListener turns the acknowledgments MSMQ sends to an administration
+// queue into delivery confirmations. Messages sent with Send request
+// acknowledgments, and Run reads the administration queue and resolves the
+// AckFuture of each message with the acknowledgment whose CorrelationID is
+// the identifier of the message:
+//   admin, err := msmq.Open(`.\private$\orders-admin`, msmq.Options{})
+//   ...
+//   l, err := msmq.NewAckListener(admin, msmq.AckListenerWithTimeout(time.Minute))
+//   ...
+//   go l.Run(ctx)
+//
+//   f, err := l.Send(&msg, orders, msmq.AckFullReachQueue)
+//   ...
+//   ack, err := f.Wait(ctx)
+//
+// The first acknowledgment of a message resolves its AckFuture; later ones
+// are only passed to the callback set with AckListenerWithOnAck. The
+// administration queue must not be transactional.
+type AckListener struct {
+	admin   *Queue
+	adminQI *QueueInfo
+	options *ackListenerOptions
+
+	// mu guards pending, the AckFutures by message identifier, and orphans,
+	// the acknowledgments that arrived before their message was tracked.
+	mu      sync.Mutex
+	pending map[string]*AckFuture
+	orphans map[string]orphanAck
+}
+
+// orphanAck is an acknowledgment that arrived before its message was tracked.
+type orphanAck struct {
+	ack      Ack
+	received time.Time
+}
+
+// orphanTTL is how long an acknowledgment is kept for a message that is not
+// tracked yet, which happens when it arrives before Send returns.
+const orphanTTL = time.Minute
+
+// NewAckListener returns a pointer to an AckListener reading admin, which
+// must be opened with Receive AccessMode, configured by the options.
+func NewAckListener(admin *Queue, opts ...AckListenerOption) (*AckListener, error) {
+	options := &ackListenerOptions{
+		receiveTimeout: time.Second,
+	}
+	for _, o := range opts {
+		o.set(options)
+	}
+
+	qi, err := NewQueueInfo(WithFormatName(admin.formatName()))
+	if err != nil {
+		return nil, fmt.Errorf("go-msmq: NewAckListener() failed to create admin queue info: %w", err)
+	}
+
+	return &AckListener{
+		admin:   admin,
+		adminQI: qi,
+		options: options,
+		pending: make(map[string]*AckFuture),
+		orphans: make(map[string]orphanAck),
+	}, nil
+}
+
+// Request configures msg, before it is sent, to send the acknowledgments of
+// level to the administration queue of the AckListener. The message must
+// then be tracked with Track once it is sent.
+func (l *AckListener) Request(msg *Message, level AckLevel) error {
+	if err := msg.SetAdminQueueInfo(l.adminQI); err != nil {
+		return fmt.Errorf("go-msmq: Request() failed to request acknowledgments: %w", err)
+	}
+	if err := msg.SetAck(level); err != nil {
+		return fmt.Errorf("go-msmq: Request() failed to request acknowledgments: %w", err)
+	}
+
+	return nil
+}
+
+// Track returns the AckFuture of the message with the identifier id, which
+// was configured with Request and sent.
+func (l *AckListener) Track(id []byte) *AckFuture {
+	f := &AckFuture{
+		tracked: time.Now(),
+		done:    make(chan struct{}),
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if o, ok := l.orphans[string(id)]; ok {
+		delete(l.orphans, string(id))
+		f.ack = o.ack
+		close(f.done)
+		return f
+	}
+
+	l.pending[string(id)] = f
+	return f
+}
+
+// Send requests the acknowledgments of level for msg, sends it to queue and
+// returns its AckFuture.
+func (l *AckListener) Send(msg *Message, queue *Queue, level AckLevel, opts ...SendOption) (*AckFuture, error) {
+	if err := l.Request(msg, level); err != nil {
+		return nil, fmt.Errorf("go-msmq: Send() failed to send message: %w", err)
+	}
+	if err := msg.Send(queue, opts...); err != nil {
+		return nil, err
+	}
+
+	id, err := msg.ID()
+	if err != nil {
+		return nil, fmt.Errorf("go-msmq: Send() failed to track message: %w", err)
+	}
+
+	return l.Track(id), nil
+}
+
+// Run reads the administration queue until ctx is done, and returns nil, or
+// until the queue cannot be read, and returns the error.
+func (l *AckListener) Run(ctx context.Context) error {
+	timeout := int(l.options.receiveTimeout / time.Millisecond)
+	failures := 0
+	for ctx.Err() == nil {
+		l.expire(time.Now())
+
+		msg, err := l.admin.Receive(ReceiveWithTransaction(NoTransaction), ReceiveWithTimeout(timeout), ReceiveWithWantBody(false))
+		if err != nil {
+			if !IsTransient(err) {
+				return fmt.Errorf("go-msmq: Run() failed to read acknowledgments: %w", err)
+			}
+
+			failures++
+			if !sleep(ctx, (*RetryPolicy)(nil).backoff(failures)) {
+				return nil
+			}
+			continue
+		}
+		failures = 0
+		if msg.dispatch == nil {
+			continue
+		}
+
+		ack, err := acknowledgment(msg)
+		msg.release()
+		if err != nil {
+			return fmt.Errorf("go-msmq: Run() failed to read acknowledgment: %w", err)
+		}
+
+		if l.options.onAck != nil {
+			l.options.onAck(ack)
+		}
+		l.resolve(ack)
+	}
+
+	return nil
+}
+
+// Close resolves the pending AckFutures with ErrAckListenerClosed and
+// releases the administration queue info. It does not close the
+// administration queue.
+func (l *AckListener) Close() error {
+	l.mu.Lock()
+	for id, f := range l.pending {
+		delete(l.pending, id)
+		f.ack = Ack{MessageID: []byte(id), Err: ErrAckListenerClosed}
+		close(f.done)
+	}
+	l.mu.Unlock()
+
+	return l.adminQI.Close()
+}
+
+// resolve resolves the AckFuture of the message acknowledged by ack, or keeps
+// ack until the message is tracked.
+func (l *AckListener) resolve(ack Ack) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	f, ok := l.pending[string(ack.MessageID)]
+	if !ok {
+		l.orphans[string(ack.MessageID)] = orphanAck{ack: ack, received: time.Now()}
+		return
+	}
+
+	delete(l.pending, string(ack.MessageID))
+	f.ack = ack
+	close(f.done)
+}
+
+// expire resolves the AckFutures tracked for longer than the timeout with
+// ErrAckTimeout, and forgets the acknowledgments of untracked messages that
+// are older than orphanTTL.
+func (l *AckListener) expire(now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.options.timeout > 0 {
+		for id, f := range l.pending {
+			if now.Sub(f.tracked) >= l.options.timeout {
+				delete(l.pending, id)
+				f.ack = Ack{MessageID: []byte(id), Err: ErrAckTimeout}
+				close(f.done)
+			}
+		}
+	}
+
+	for id, o := range l.orphans {
+		if now.Sub(o.received) >= orphanTTL {
+			delete(l.orphans, id)
+		}
+	}
+}
+
+// acknowledgment returns the Ack of the acknowledgment message msg.
+func acknowledgment(msg Message) (Ack, error) {
+	class, err := msg.Class()
+	if err != nil {
+		return Ack{}, err
+	}
+
+	id, err := msg.CorrelationID()
+	if err != nil {
+		return Ack{}, err
+	}
+
+	ack := Ack{MessageID: id, Class: class}
+	if class.IsNack() {
+		ack.Err = &NackError{Class: class}
+	}
+
+	return ack, nil
+}
+
+// AckListenerOption represents an option to configure an AckListener.
+type AckListenerOption struct {
+	set func(opts *ackListenerOptions)
+}
+
+// ackListenerOptions contains all the options to configure an AckListener.
+type ackListenerOptions struct {
+	timeout        time.Duration
+	receiveTimeout time.Duration
+	onAck          func(ack Ack)
+}
+
+// AckListenerWithTimeout returns an AckListenerOption that configures how
+// long an AckFuture waits for an acknowledgment before it is resolved with
+// ErrAckTimeout. It should be set when only negative acknowledgments are
+// requested. The default is to wait indefinitely.
+func AckListenerWithTimeout(timeout time.Duration) AckListenerOption {
+	return AckListenerOption{
+		set: func(opts *ackListenerOptions) {
+			opts.timeout = timeout
+		},
+	}
+}
+
+// AckListenerWithReceiveTimeout returns an AckListenerOption that configures
+// how long Run waits for an acknowledgment before checking whether it is
+// stopping and expiring AckFutures. The default is 1 second.
+func AckListenerWithReceiveTimeout(timeout time.Duration) AckListenerOption {
+	return AckListenerOption{
+		set: func(opts *ackListenerOptions) {
+			opts.receiveTimeout = timeout
+		},
+	}
+}
+
+// AckListenerWithOnAck returns an AckListenerOption that configures the
+// callback invoked with every acknowledgment read by Run, including those of
+// messages that are not tracked. The default is no callback.
+func AckListenerWithOnAck(fn func(ack Ack)) AckListenerOption {
+	return AckListenerOption{
+		set: func(opts *ackListenerOptions) {
+			opts.onAck = fn
+		},
+	}
+}
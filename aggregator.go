@@ -0,0 +1,276 @@
+package msmq
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// BatchHandler processes a batch of messages received by an Aggregator. The
+// messages are only valid until the handler returns.
+type BatchHandler func(ctx context.Context, msgs []Message) error
+
+// Aggregator receives messages from a transactional queue in batches, for
+// consumers that write to batch-oriented sinks such as bulk database inserts
+// or files:
+//   agg := msmq.NewAggregator(queue,
+//       msmq.AggregatorWithMaxMessages(500),
+//       msmq.AggregatorWithMaxWait(2*time.Second),
+//   )
+//   err := agg.Run(ctx, func(ctx context.Context, msgs []msmq.Message) error {
+//       ...
+//   })
+//
+// A batch is collected until it holds the maximum number of messages or the
+// maximum wait elapsed since its first message arrived. The messages of a
+// batch are received in a single internal transaction, which is committed
+// when the handler succeeds and aborted when it fails, returning every
+// message of the batch to the queue. The transaction is available to the
+// handler with TransactionFromContext.
+type Aggregator struct {
+	queue   *Queue
+	options *aggregatorOptions
+}
+
+// NewAggregator returns a pointer to an Aggregator of queue configured by the
+// options. The queue must be transactional and opened with Receive
+// AccessMode.
+func NewAggregator(queue *Queue, opts ...AggregatorOption) *Aggregator {
+	options := &aggregatorOptions{
+		maxMessages:    100,
+		maxWait:        time.Second,
+		receiveTimeout: time.Second,
+	}
+	for _, o := range opts {
+		o.set(options)
+	}
+	if options.maxMessages < 1 {
+		options.maxMessages = 1
+	}
+
+	return &Aggregator{
+		queue:   queue,
+		options: options,
+	}
+}
+
+// Run collects batches and passes them to handler until ctx is done, and
+// returns nil, or until a message cannot be received, and returns the error.
+// When ctx is done, the batch being collected is handled before Run returns.
+// Transient receive errors and handler errors are retried with the backoff
+// of the retry policy.
+func (a *Aggregator) Run(ctx context.Context, handler BatchHandler) error {
+	failures := 0
+	for ctx.Err() == nil {
+		err := a.next(ctx, handler)
+		if err == nil {
+			failures = 0
+			continue
+		}
+
+		if !IsTransient(err) && !isBatchError(err) {
+			return fmt.Errorf("go-msmq: Run() failed to receive messages: %w", err)
+		}
+
+		failures++
+		if !sleep(ctx, a.options.retry.backoff(failures)) {
+			return nil
+		}
+	}
+
+	return nil
+}
+
+// batchError is the error of a batch whose handler failed or whose
+// transaction could not be committed.
+type batchError struct {
+	err error
+}
+
+// Error implements the error interface.
+func (e *batchError) Error() string {
+	return e.err.Error()
+}
+
+// isBatchError reports whether err is a *batchError.
+func isBatchError(err error) bool {
+	_, ok := err.(*batchError)
+	return ok
+}
+
+// next collects a batch and handles it.
+func (a *Aggregator) next(ctx context.Context, handler BatchHandler) error {
+	tx, err := BeginTransaction()
+	if err != nil {
+		return err
+	}
+
+	msgs, err := a.collect(ctx, tx)
+	defer func() {
+		for i := range msgs {
+			msgs[i].release()
+		}
+	}()
+	if err != nil {
+		tx.Abort()
+		return err
+	}
+	if len(msgs) == 0 {
+		return tx.Abort()
+	}
+
+	handlerCtx := context.WithValue(context.WithoutCancel(ctx), transactionKey{}, tx)
+	start := time.Now()
+	err = handler(handlerCtx, msgs)
+	observeHandling(Handling{
+		Queue:    a.queue.formatName(),
+		Handler:  a.options.name,
+		Duration: time.Since(start),
+		Err:      err,
+	})
+	if err != nil {
+		a.fail(msgs, err)
+		if err := tx.Abort(); err != nil {
+			a.fail(msgs, err)
+		}
+		return &batchError{err: err}
+	}
+
+	if err := tx.Commit(); err != nil {
+		a.fail(msgs, err)
+		return &batchError{err: err}
+	}
+
+	return nil
+}
+
+// collect receives the messages of a batch in tx. It waits up to the receive
+// timeout for the first message, and then up to the maximum wait for the
+// others, or until ctx is done.
+func (a *Aggregator) collect(ctx context.Context, tx *Transaction) ([]Message, error) {
+	var (
+		msgs     []Message
+		deadline time.Time
+	)
+	for len(msgs) < a.options.maxMessages {
+		timeout := a.options.receiveTimeout
+		if len(msgs) > 0 {
+			if ctx.Err() != nil {
+				break
+			}
+
+			timeout = time.Until(deadline)
+			if timeout <= 0 {
+				break
+			}
+		}
+
+		msg, err := a.queue.Receive(ReceiveInTransaction(tx), ReceiveWithTimeout(int(timeout/time.Millisecond)))
+		if err != nil {
+			return msgs, err
+		}
+		if msg.dispatch == nil {
+			if len(msgs) == 0 {
+				// No message yet: let the caller check whether to stop.
+				break
+			}
+			continue
+		}
+
+		if len(msgs) == 0 {
+			deadline = time.Now().Add(a.options.maxWait)
+		}
+		msgs = append(msgs, msg)
+	}
+
+	return msgs, nil
+}
+
+// fail reports that the batch msgs could not be handled.
+func (a *Aggregator) fail(msgs []Message, err error) {
+	if a.options.onError != nil {
+		a.options.onError(msgs, err)
+	}
+}
+
+// AggregatorOption represents an option to configure an Aggregator.
+type AggregatorOption struct {
+	set func(opts *aggregatorOptions)
+}
+
+// aggregatorOptions contains all the options to configure an Aggregator.
+type aggregatorOptions struct {
+	name           string
+	maxMessages    int
+	maxWait        time.Duration
+	receiveTimeout time.Duration
+	retry          *RetryPolicy
+	onError        func(msgs []Message, err error)
+}
+
+// AggregatorWithName returns an AggregatorOption that configures the name of
+// the batch handler, which identifies it in the metrics reported to
+// HandlerObservers. The default is no name.
+func AggregatorWithName(name string) AggregatorOption {
+	return AggregatorOption{
+		set: func(opts *aggregatorOptions) {
+			opts.name = name
+		},
+	}
+}
+
+// AggregatorWithMaxMessages returns an AggregatorOption that configures the
+// maximum number of messages in a batch. The default is 100.
+func AggregatorWithMaxMessages(n int) AggregatorOption {
+	return AggregatorOption{
+		set: func(opts *aggregatorOptions) {
+			opts.maxMessages = n
+		},
+	}
+}
+
+// AggregatorWithMaxWait returns an AggregatorOption that configures how long
+// a batch is collected after its first message arrived. The default is 1
+// second.
+func AggregatorWithMaxWait(d time.Duration) AggregatorOption {
+	return AggregatorOption{
+		set: func(opts *aggregatorOptions) {
+			opts.maxWait = d
+		},
+	}
+}
+
+// AggregatorWithReceiveTimeout returns an AggregatorOption that configures
+// how long the Aggregator waits for the first message of a batch before
+// checking whether it is stopping. The default is 1 second.
+func AggregatorWithReceiveTimeout(timeout time.Duration) AggregatorOption {
+	return AggregatorOption{
+		set: func(opts *aggregatorOptions) {
+			opts.receiveTimeout = timeout
+		},
+	}
+}
+
+// AggregatorWithRetry returns an AggregatorOption that configures the backoff
+// between failed batches and transient receive failures. Failed batches are
+// always retried, since their messages return to the queue. The default is
+// the default backoff of RetryPolicy.
+func AggregatorWithRetry(policy *RetryPolicy) AggregatorOption {
+	return AggregatorOption{
+		set: func(opts *aggregatorOptions) {
+			opts.retry = policy
+		},
+	}
+}
+
+// AggregatorWithOnError returns an AggregatorOption that configures the
+// callback invoked with a batch whose handler failed, or whose transaction
+// could not be committed or aborted, and with the error. The default is to
+// drop the error.
+func AggregatorWithOnError(fn func(msgs []Message, err error)) AggregatorOption {
+	return AggregatorOption{
+		set: func(opts *aggregatorOptions) {
+			opts.onError = fn
+		},
+	}
+}
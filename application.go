@@ -0,0 +1,311 @@
+package msmq
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/go-ole/go-ole"
+)
+
+// Application provides information about the queue manager of a computer.
+type Application struct {
+	dispatch *ole.IDispatch
+	machine  string
+}
+
+// NewApplication returns a pointer to an Application for the queue manager of
+// the local computer, or of a remote computer specified with
+// ApplicationWithMachine:
+//   app, err := msmq.NewApplication(msmq.ApplicationWithMachine("host1"))
+//
+// See: https://docs.microsoft.com/en-us/previous-versions/windows/desktop/legacy/ms705225(v=vs.85)
+func NewApplication(opts ...ApplicationOption) (*Application, error) {
+	dispatch, err := createObject("MSMQ.MSMQApplication")
+	if err != nil {
+		return nil, err
+	}
+
+	app := &Application{
+		dispatch: dispatch,
+	}
+
+	for _, o := range opts {
+		err = o.set(app)
+		if err != nil {
+			app.Close()
+			return nil, fmt.Errorf("go-msmq: failed to create new Application: %w", err)
+		}
+	}
+
+	return app, nil
+}
+
+// ApplicationOption represents an option to configure Application.
+type ApplicationOption struct {
+	set func(a *Application) error
+}
+
+// ApplicationWithMachine returns an ApplicationOption that configures
+// Application with the specified Machine value.
+func ApplicationWithMachine(name string) ApplicationOption {
+	return ApplicationOption{
+		set: func(a *Application) error {
+			return a.SetMachine(name)
+		},
+	}
+}
+
+// Close releases the underlying application object. The Application cannot
+// be used after it is closed. Calling Close more than once has no effect.
+func (a *Application) Close() error {
+	if a.dispatch != nil {
+		release(a.dispatch)
+		a.dispatch = nil
+	}
+
+	return nil
+}
+
+// Application satisfies io.Closer so that it can be managed alongside other
+// resources.
+var _ io.Closer = (*Application)(nil)
+
+// Connect reconnects the queue manager to the network and the directory
+// service after it was taken offline with Disconnect. Messages held in
+// outgoing queues are sent once the queue manager is online.
+//
+// See: https://docs.microsoft.com/en-us/previous-versions/windows/desktop/legacy/ms704974(v=vs.85)
+func (a *Application) Connect() error {
+	_, err := callMethod(a.dispatch, "Connect")
+	if err != nil {
+		return fmt.Errorf("go-msmq: Connect() failed to connect queue manager: %w", err)
+	}
+
+	return nil
+}
+
+// Disconnect takes the queue manager offline. Messages sent while the queue
+// manager is offline are held in outgoing queues and incoming messages are
+// not accepted until Connect is called.
+//
+// See: https://docs.microsoft.com/en-us/previous-versions/windows/desktop/legacy/ms699853(v=vs.85)
+func (a *Application) Disconnect() error {
+	_, err := callMethod(a.dispatch, "Disconnect")
+	if err != nil {
+		return fmt.Errorf("go-msmq: Disconnect() failed to disconnect queue manager: %w", err)
+	}
+
+	return nil
+}
+
+// Tidy releases the message files of the queue manager that no longer contain
+// messages, reclaiming disk space without waiting for the periodic cleanup.
+//
+// See: https://docs.microsoft.com/en-us/previous-versions/windows/desktop/legacy/ms706223(v=vs.85)
+func (a *Application) Tidy() error {
+	_, err := callMethod(a.dispatch, "Tidy")
+	if err != nil {
+		return fmt.Errorf("go-msmq: Tidy() failed to release empty message files: %w", err)
+	}
+
+	return nil
+}
+
+// ActiveQueues returns the format names of the active queues on the computer.
+// A queue is active when it contains messages or is opened by an application.
+//
+// See: https://docs.microsoft.com/en-us/previous-versions/windows/desktop/legacy/ms700291(v=vs.85)
+func (a *Application) ActiveQueues() ([]string, error) {
+	res, err := getProperty(a.dispatch, "ActiveQueues")
+	if err != nil {
+		return nil, fmt.Errorf("go-msmq: ActiveQueues() failed to get ActiveQueues: %w", err)
+	}
+	defer res.Clear()
+
+	return toStringSlice(res), nil
+}
+
+// PrivateQueues returns the path names of the private queues on the computer.
+// Depending on the version of MSMQ, the path names may omit the computer name,
+// for example private$\orders.
+//
+// See: https://docs.microsoft.com/en-us/previous-versions/windows/desktop/legacy/ms699804(v=vs.85)
+func (a *Application) PrivateQueues() ([]string, error) {
+	res, err := getProperty(a.dispatch, "PrivateQueues")
+	if err != nil {
+		return nil, fmt.Errorf("go-msmq: PrivateQueues() failed to get PrivateQueues: %w", err)
+	}
+	defer res.Clear()
+
+	return toStringSlice(res), nil
+}
+
+// Machine returns the name of the computer whose queue manager is represented
+// by Application.
+func (a *Application) Machine() (string, error) {
+	res, err := getProperty(a.dispatch, "Machine")
+	if err != nil {
+		return "", fmt.Errorf("go-msmq: failed to get Machine: %w", err)
+	}
+	defer res.Clear()
+
+	return variantString(res, "Machine")
+}
+
+// SetMachine sets the computer whose queue manager is represented by
+// Application. The queue manager of a remote computer can only be managed if
+// the caller has administrative privileges on the remote computer.
+func (a *Application) SetMachine(name string) error {
+	_, err := putProperty(a.dispatch, "Machine", name)
+	if err != nil {
+		return fmt.Errorf("go-msmq: SetMachine(%s) failed to set Machine: %w", name, err)
+	}
+
+	a.machine = name
+	return nil
+}
+
+// BytesInAllQueues returns the number of bytes used by the messages in all the
+// queues of the computer. Comparing it against Quota allows applications to
+// detect that the computer is about to start rejecting messages.
+//
+// See: https://docs.microsoft.com/en-us/previous-versions/windows/desktop/legacy/ms705208(v=vs.85)
+func (a *Application) BytesInAllQueues() (uint64, error) {
+	res, err := getProperty(a.dispatch, "BytesInAllQueues")
+	if err != nil {
+		return 0, fmt.Errorf("go-msmq: BytesInAllQueues() failed to get BytesInAllQueues: %w", err)
+	}
+	defer res.Clear()
+
+	return toUint64(res), nil
+}
+
+// machineCacheKey is the registry key that holds the computer-wide storage
+// limits of MSMQ. They are not exposed by the COM object model.
+const machineCacheKey = `SOFTWARE\Microsoft\MSMQ\Parameters\MachineCache`
+
+// Quota returns the maximum size, in kilobytes, of all the messages stored on
+// the computer. 0 is returned if no quota is configured, in which case the
+// default of the installed version of MSMQ applies.
+func (a *Application) Quota() (uint32, error) {
+	quota, err := a.machineQuota("MachineQuota")
+	if err != nil {
+		return 0, fmt.Errorf("go-msmq: Quota() failed to get MachineQuota: %w", err)
+	}
+
+	return quota, nil
+}
+
+// SetQuota sets the maximum size, in kilobytes, of all the messages stored on
+// the computer. The Message Queuing service must be restarted for the change
+// to take effect. Setting the quota requires administrative privileges on the
+// computer.
+func (a *Application) SetQuota(kb uint32) error {
+	err := a.setMachineQuota("MachineQuota", kb)
+	if err != nil {
+		return fmt.Errorf("go-msmq: SetQuota(%d) failed to set MachineQuota: %w", kb, err)
+	}
+
+	return nil
+}
+
+// JournalQuota returns the maximum size, in kilobytes, of all the messages
+// stored in the computer journal and dead-letter queues. 0 is returned if no
+// quota is configured, in which case the default of the installed version of
+// MSMQ applies.
+func (a *Application) JournalQuota() (uint32, error) {
+	quota, err := a.machineQuota("MachineJournalQuota")
+	if err != nil {
+		return 0, fmt.Errorf("go-msmq: JournalQuota() failed to get MachineJournalQuota: %w", err)
+	}
+
+	return quota, nil
+}
+
+// SetJournalQuota sets the maximum size, in kilobytes, of all the messages
+// stored in the computer journal and dead-letter queues. See SetQuota.
+func (a *Application) SetJournalQuota(kb uint32) error {
+	err := a.setMachineQuota("MachineJournalQuota", kb)
+	if err != nil {
+		return fmt.Errorf("go-msmq: SetJournalQuota(%d) failed to set MachineJournalQuota: %w", kb, err)
+	}
+
+	return nil
+}
+
+// ListPrivateQueues returns a QueueInfo for each private queue on the
+// specified computer. An empty machine name refers to the local computer.
+// Private queues are not registered in the directory service, so they cannot
+// be located with Query.LookupQueue.
+//
+// The returned QueueInfo reference the queues by direct format name, which
+// allows them to be opened even when the queues are on a remote computer.
+func ListPrivateQueues(machine string) ([]*QueueInfo, error) {
+	app, err := NewApplication()
+	if err != nil {
+		return nil, fmt.Errorf("go-msmq: ListPrivateQueues(%s) failed to list private queues: %w", machine, err)
+	}
+	defer app.Close()
+
+	if machine != "" {
+		err = app.SetMachine(machine)
+		if err != nil {
+			return nil, fmt.Errorf("go-msmq: ListPrivateQueues(%s) failed to list private queues: %w", machine, err)
+		}
+	}
+
+	names, err := app.PrivateQueues()
+	if err != nil {
+		return nil, fmt.Errorf("go-msmq: ListPrivateQueues(%s) failed to list private queues: %w", machine, err)
+	}
+
+	host := machine
+	if host == "" {
+		host = "."
+	}
+
+	queues := make([]*QueueInfo, 0, len(names))
+	for _, name := range names {
+		// The path names are relative to the computer, for example
+		// private$\orders.
+		if i := strings.Index(name, `\`); i >= 0 && !strings.EqualFold(name[:i], "private$") {
+			name = name[i+1:]
+		}
+
+		qi, err := NewQueueInfo(WithFormatName(`DIRECT=OS:` + host + `\` + name))
+		if err != nil {
+			for _, q := range queues {
+				q.Close()
+			}
+			return nil, fmt.Errorf("go-msmq: ListPrivateQueues(%s) failed to list private queues: %w", machine, err)
+		}
+
+		queues = append(queues, qi)
+	}
+
+	return queues, nil
+}
+
+// toStringSlice converts a VARIANT that holds an array of strings, or an
+// array of VARIANT that hold strings, to a string slice.
+func toStringSlice(v *ole.VARIANT) []string {
+	if v.VT&ole.VT_ARRAY == 0 {
+		return nil
+	}
+
+	arr := v.ToArray()
+	vt, err := arr.GetType()
+	if err == nil && ole.VT(vt) == ole.VT_BSTR {
+		return arr.ToStringArray()
+	}
+
+	var ss []string
+	for _, value := range arr.ToValueArray() {
+		if s, ok := value.(string); ok {
+			ss = append(ss, s)
+		}
+	}
+
+	return ss
+}
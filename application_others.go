@@ -0,0 +1,11 @@
+// +build !windows
+
+package msmq
+
+func (a *Application) machineQuota(name string) (uint32, error) {
+	return 0, ErrUnsupportedPlatform
+}
+
+func (a *Application) setMachineQuota(name string, kb uint32) error {
+	return ErrUnsupportedPlatform
+}
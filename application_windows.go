@@ -0,0 +1,53 @@
+// +build windows
+
+package msmq
+
+import (
+	"golang.org/x/sys/windows/registry"
+)
+
+// machineQuota reads the named value of machineCacheKey.
+func (a *Application) machineQuota(name string) (uint32, error) {
+	key, err := a.openMachineCache(registry.QUERY_VALUE)
+	if err != nil {
+		return 0, err
+	}
+	defer key.Close()
+
+	v, _, err := key.GetIntegerValue(name)
+	if err == registry.ErrNotExist {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	return uint32(v), nil
+}
+
+// setMachineQuota writes the named value of machineCacheKey.
+func (a *Application) setMachineQuota(name string, kb uint32) error {
+	key, err := a.openMachineCache(registry.SET_VALUE)
+	if err != nil {
+		return err
+	}
+	defer key.Close()
+
+	return key.SetDWordValue(name, kb)
+}
+
+// openMachineCache opens machineCacheKey on the computer of the Application.
+func (a *Application) openMachineCache(access uint32) (registry.Key, error) {
+	root := registry.LOCAL_MACHINE
+	if a.machine != "" && a.machine != "." {
+		remote, err := registry.OpenRemoteKey(a.machine, registry.LOCAL_MACHINE)
+		if err != nil {
+			return 0, err
+		}
+		defer remote.Close()
+
+		root = remote
+	}
+
+	return registry.OpenKey(root, machineCacheKey, access)
+}
@@ -0,0 +1,274 @@
+package msmq
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/go-ole/go-ole"
+)
+
+// backupFormat identifies the files written by Export.
+const backupFormat = "go-msmq-backup"
+
+// backupVersion is the version of the format written by Export.
+const backupVersion = 1
+
+// BackupHeader is the first record of a backup.
+type BackupHeader struct {
+	// Format is always "go-msmq-backup".
+	Format string `json:"format"`
+
+	// Version is the version of the format.
+	Version int `json:"version"`
+
+	// Queue is the format name of the exported queue.
+	Queue string `json:"queue"`
+
+	// Created is when the backup was started.
+	Created time.Time `json:"created"`
+}
+
+// BackupMessage is a message in a backup.
+type BackupMessage struct {
+	Label string `json:"label"`
+
+	// Body is the body of the message. BodyIsString reports whether it was
+	// a string, as set with Message.SetBody, rather than bytes.
+	Body         []byte `json:"body"`
+	BodyIsString bool   `json:"bodyIsString,omitempty"`
+
+	AppSpecific   int32        `json:"appSpecific,omitempty"`
+	CorrelationID []byte       `json:"correlationId,omitempty"`
+	Extension     []byte       `json:"extension,omitempty"`
+	Priority      int32        `json:"priority"`
+	Delivery      DeliveryMode `json:"delivery"`
+
+	// The following properties are informational: they are assigned by
+	// MSMQ and cannot be restored.
+	ID          []byte    `json:"id,omitempty"`
+	LookupID    LookupID  `json:"lookupId,omitempty"`
+	SentTime    time.Time `json:"sentTime"`
+	ArrivedTime time.Time `json:"arrivedTime"`
+}
+
+// Export writes every message of source to w without removing them, and
+// returns the number of messages written. source must be opened with Peek or
+// Receive AccessMode:
+//   f, err := os.Create("orders.backup")
+//   ...
+//   n, err := msmq.Export(f, queue)
+//
+// A backup is a stream of JSON records, one per line: a BackupHeader
+// followed by a BackupMessage per message in the order of the queue, so it
+// can be inspected and processed with standard tools. Messages that arrive
+// during the export may or may not be included.
+func Export(w io.Writer, source *Queue) (int, error) {
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+
+	err := enc.Encode(BackupHeader{
+		Format:  backupFormat,
+		Version: backupVersion,
+		Queue:   source.formatName(),
+		Created: time.Now(),
+	})
+	if err != nil {
+		return 0, fmt.Errorf("go-msmq: Export() failed to write header: %w", err)
+	}
+
+	n := 0
+	var id LookupID
+	for {
+		var msg Message
+		if id == 0 {
+			msg, err = source.PeekFirstByLookupID()
+		} else {
+			msg, err = source.PeekNextByLookupID(id)
+		}
+		if err != nil {
+			return n, fmt.Errorf("go-msmq: Export() failed to export messages: %w", err)
+		}
+		if msg.dispatch == nil {
+			break
+		}
+
+		b, err := backupMessage(&msg)
+		msg.release()
+		if err != nil {
+			return n, fmt.Errorf("go-msmq: Export() failed to export messages: %w", err)
+		}
+		if err := enc.Encode(b); err != nil {
+			return n, fmt.Errorf("go-msmq: Export() failed to write message: %w", err)
+		}
+
+		id = b.LookupID
+		n++
+	}
+
+	if err := bw.Flush(); err != nil {
+		return n, fmt.Errorf("go-msmq: Export() failed to write messages: %w", err)
+	}
+
+	return n, nil
+}
+
+// Import sends the messages of the backup read from r to dest, which must be
+// opened with Send AccessMode, and returns the number of messages sent. The
+// messages keep their body, label, application-specific information,
+// correlation identifier, extension, priority and delivery mode; MSMQ assigns
+// them new identifiers.
+func Import(r io.Reader, dest *Queue, opts ...ImportOption) (int, error) {
+	options := &importOptions{
+		level: MTS,
+	}
+	for _, o := range opts {
+		o.set(options)
+	}
+
+	dec := json.NewDecoder(bufio.NewReader(r))
+
+	var header BackupHeader
+	if err := dec.Decode(&header); err != nil {
+		return 0, fmt.Errorf("go-msmq: Import() failed to read header: %w", err)
+	}
+	if header.Format != backupFormat || header.Version != backupVersion {
+		return 0, fmt.Errorf("go-msmq: Import() failed to read header: unsupported format %q version %d", header.Format, header.Version)
+	}
+
+	n := 0
+	for {
+		var b BackupMessage
+		err := dec.Decode(&b)
+		if errors.Is(err, io.EOF) {
+			return n, nil
+		}
+		if err != nil {
+			return n, fmt.Errorf("go-msmq: Import() failed to read message %d: %w", n+1, err)
+		}
+
+		if err := b.send(dest, SendWithTransaction(options.level)); err != nil {
+			return n, fmt.Errorf("go-msmq: Import() failed to send message %d: %w", n+1, err)
+		}
+		n++
+	}
+}
+
+// backupMessage returns the BackupMessage of msg.
+func backupMessage(msg *Message) (BackupMessage, error) {
+	var b BackupMessage
+
+	body, err := getProperty(msg.dispatch, "Body")
+	if err != nil {
+		return b, err
+	}
+	if body.VT&ole.VT_ARRAY != 0 {
+		b.Body = body.ToArray().ToByteArray()
+	} else if s, ok := body.Value().(string); ok {
+		b.Body = []byte(s)
+		b.BodyIsString = true
+	}
+	body.Clear()
+
+	if b.Label, err = msg.Label(); err != nil {
+		return b, err
+	}
+	if b.AppSpecific, err = msg.AppSpecific(); err != nil {
+		return b, err
+	}
+	if b.CorrelationID, err = msg.CorrelationID(); err != nil {
+		return b, err
+	}
+	if b.Extension, err = msg.Extension(); err != nil {
+		return b, err
+	}
+	if b.Priority, err = msg.Priority(); err != nil {
+		return b, err
+	}
+	if b.Delivery, err = msg.Delivery(); err != nil {
+		return b, err
+	}
+	if b.ID, err = msg.ID(); err != nil {
+		return b, err
+	}
+	if b.LookupID, err = msg.LookupID(); err != nil {
+		return b, err
+	}
+	if b.SentTime, err = msg.SentTime(); err != nil {
+		return b, err
+	}
+	if b.ArrivedTime, err = msg.ArrivedTime(); err != nil {
+		return b, err
+	}
+
+	return b, nil
+}
+
+// send sends the message b to dest with the specified SendOption value.
+func (b *BackupMessage) send(dest *Queue, opt SendOption) error {
+	msg, err := NewMessage()
+	if err != nil {
+		return err
+	}
+	defer msg.release()
+
+	if b.BodyIsString {
+		err = msg.SetBody(string(b.Body))
+	} else {
+		err = msg.SetBodyBytes(b.Body)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := msg.SetLabel(b.Label); err != nil {
+		return err
+	}
+	if err := msg.SetAppSpecific(b.AppSpecific); err != nil {
+		return err
+	}
+	if len(b.CorrelationID) > 0 {
+		if err := msg.SetCorrelationID(b.CorrelationID); err != nil {
+			return err
+		}
+	}
+	if len(b.Extension) > 0 {
+		if err := msg.SetExtension(b.Extension); err != nil {
+			return err
+		}
+	}
+	if err := msg.SetPriority(b.Priority); err != nil {
+		return err
+	}
+	if err := msg.SetDelivery(b.Delivery); err != nil {
+		return err
+	}
+
+	return msg.Send(dest, opt)
+}
+
+// ImportOption represents an option to import messages.
+type ImportOption struct {
+	set func(opts *importOptions)
+}
+
+// importOptions contains all the options to import messages.
+type importOptions struct {
+	level TransactionLevel
+}
+
+// ImportWithTransaction returns an ImportOption that configures sending the
+// imported messages with the specified level value. Use SingleMessage to
+// import into a transactional queue.
+//
+// The default is MTS.
+func ImportWithTransaction(level TransactionLevel) ImportOption {
+	return ImportOption{
+		set: func(opts *importOptions) {
+			opts.level = level
+		},
+	}
+}
@@ -0,0 +1,257 @@
+package msmq
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+	"unicode/utf16"
+
+	"github.com/go-ole/go-ole"
+)
+
+// BodyType is the type of the body of a message as a VARTYPE. It tells
+// receivers how the sender serialized the body; .NET System.Messaging sets it
+// according to the formatter of the message.
+//
+// See: https://docs.microsoft.com/en-us/windows/win32/msmq/pid-m-body-type
+type BodyType uint32
+
+const (
+	// BodyTypeNone is the body type of messages sent by the native API
+	// without a body type, and of messages formatted by the
+	// XmlMessageFormatter of System.Messaging, whose body is an XML
+	// document. See DecodeXMLBody.
+	BodyTypeNone BodyType = BodyType(ole.VT_EMPTY)
+
+	BodyTypeInt16   BodyType = BodyType(ole.VT_I2)
+	BodyTypeInt32   BodyType = BodyType(ole.VT_I4)
+	BodyTypeFloat32 BodyType = BodyType(ole.VT_R4)
+	BodyTypeFloat64 BodyType = BodyType(ole.VT_R8)
+
+	// BodyTypeCurrency is a 64-bit integer of ten-thousandths.
+	BodyTypeCurrency BodyType = BodyType(ole.VT_CY)
+
+	// BodyTypeDate is an OLE Automation date.
+	BodyTypeDate BodyType = BodyType(ole.VT_DATE)
+
+	// BodyTypeString is a UTF-16 string, as sent by the COM object model.
+	BodyTypeString BodyType = BodyType(ole.VT_BSTR)
+
+	BodyTypeBool   BodyType = BodyType(ole.VT_BOOL)
+	BodyTypeInt8   BodyType = BodyType(ole.VT_I1)
+	BodyTypeUint8  BodyType = BodyType(ole.VT_UI1)
+	BodyTypeUint16 BodyType = BodyType(ole.VT_UI2)
+	BodyTypeUint32 BodyType = BodyType(ole.VT_UI4)
+	BodyTypeInt64  BodyType = BodyType(ole.VT_I8)
+	BodyTypeUint64 BodyType = BodyType(ole.VT_UI8)
+
+	// BodyTypeANSIString is a string in the code page of the sender, as
+	// sent by the ActiveXMessageFormatter for char arrays.
+	BodyTypeANSIString BodyType = BodyType(ole.VT_LPSTR)
+
+	// BodyTypeUnicodeString is a UTF-16 string, as sent by the
+	// ActiveXMessageFormatter for strings.
+	BodyTypeUnicodeString BodyType = BodyType(ole.VT_LPWSTR)
+
+	// BodyTypeStreamedObject and BodyTypeStoredObject are serialized COM
+	// objects, as sent by the ActiveXMessageFormatter for streams.
+	BodyTypeStreamedObject BodyType = 0x44
+	BodyTypeStoredObject   BodyType = 0x45
+
+	// BodyTypeBytes is a byte array, as sent by the COM object model and the
+	// ActiveXMessageFormatter for byte arrays.
+	BodyTypeBytes BodyType = BodyType(ole.VT_VECTOR | ole.VT_UI1)
+
+	// BodyTypeBinaryFormatter is an object serialized by the
+	// BinaryMessageFormatter of System.Messaging.
+	BodyTypeBinaryFormatter BodyType = 0x300
+)
+
+// ErrUnsupportedBodyType is returned when a body cannot be decoded or encoded
+// because of its type, such as bodies serialized by the .NET
+// BinaryFormatter.
+var ErrUnsupportedBodyType = errors.New("go-msmq: unsupported body type")
+
+// BodyType returns the type of the body of the message. The COM object model
+// does not expose the body type itself but converts the body according to
+// it, so the type is inferred from the converted body: bodies of unknown
+// types, such as those of the BinaryMessageFormatter and the XmlMessageFormatter,
+// are reported as BodyTypeBytes like byte arrays. Use a NativeQueue to get the
+// exact type of received messages.
+func (m *Message) BodyType() (BodyType, error) {
+	if m.IsZero() {
+		return BodyTypeNone, nil
+	}
+
+	if m.native != nil {
+		return m.native.BodyType, nil
+	}
+
+	res, err := getProperty(m.dispatch, "Body")
+	if err != nil {
+		return 0, fmt.Errorf("go-msmq: BodyType() failed to get Body: %w", err)
+	}
+	defer res.Clear()
+
+	if res.VT&ole.VT_ARRAY != 0 {
+		return BodyTypeBytes, nil
+	}
+
+	return BodyType(res.VT), nil
+}
+
+// Value returns the body of the message decoded according to its body type.
+// See DecodeBody.
+func (m *NativeMessage) Value() (interface{}, error) {
+	return DecodeBody(m.BodyType, m.Body)
+}
+
+// DecodeBody returns body decoded according to t, so that messages sent by
+// .NET System.Messaging with the ActiveXMessageFormatter are read as the
+// values they were sent as:
+//   - a string for BodyTypeString, BodyTypeUnicodeString and
+//     BodyTypeANSIString, without a terminating NUL;
+//   - a []byte for BodyTypeNone and BodyTypeBytes;
+//   - a bool, an integer or floating-point type of the same size, or a
+//     time.Time for the other scalar types. Currencies are returned as
+//     float64.
+//
+// Bodies of the XmlMessageFormatter have the BodyTypeNone type and are
+// decoded with DecodeXMLBody. Other types, such as serialized objects,
+// return an error wrapping ErrUnsupportedBodyType.
+func DecodeBody(t BodyType, body []byte) (interface{}, error) {
+	size := map[BodyType]int{
+		BodyTypeInt8: 1, BodyTypeUint8: 1,
+		BodyTypeInt16: 2, BodyTypeUint16: 2, BodyTypeBool: 2,
+		BodyTypeInt32: 4, BodyTypeUint32: 4, BodyTypeFloat32: 4,
+		BodyTypeInt64: 8, BodyTypeUint64: 8, BodyTypeFloat64: 8, BodyTypeCurrency: 8, BodyTypeDate: 8,
+	}
+	if n, ok := size[t]; ok && len(body) < n {
+		return nil, fmt.Errorf("go-msmq: DecodeBody(%d) failed to decode body: %d bytes is too short", t, len(body))
+	}
+
+	le := binary.LittleEndian
+	switch t {
+	case BodyTypeNone, BodyTypeBytes:
+		return body, nil
+	case BodyTypeString, BodyTypeUnicodeString:
+		u := make([]uint16, len(body)/2)
+		for i := range u {
+			u[i] = le.Uint16(body[2*i:])
+		}
+		for len(u) > 0 && u[len(u)-1] == 0 {
+			u = u[:len(u)-1]
+		}
+		return string(utf16.Decode(u)), nil
+	case BodyTypeANSIString:
+		return string(bytes.TrimRight(body, "\x00")), nil
+	case BodyTypeBool:
+		return le.Uint16(body) != 0, nil
+	case BodyTypeInt8:
+		return int8(body[0]), nil
+	case BodyTypeUint8:
+		return body[0], nil
+	case BodyTypeInt16:
+		return int16(le.Uint16(body)), nil
+	case BodyTypeUint16:
+		return le.Uint16(body), nil
+	case BodyTypeInt32:
+		return int32(le.Uint32(body)), nil
+	case BodyTypeUint32:
+		return le.Uint32(body), nil
+	case BodyTypeInt64:
+		return int64(le.Uint64(body)), nil
+	case BodyTypeUint64:
+		return le.Uint64(body), nil
+	case BodyTypeFloat32:
+		return math.Float32frombits(le.Uint32(body)), nil
+	case BodyTypeFloat64:
+		return math.Float64frombits(le.Uint64(body)), nil
+	case BodyTypeCurrency:
+		return float64(int64(le.Uint64(body))) / 10000, nil
+	case BodyTypeDate:
+		return dateFromVariant(math.Float64frombits(le.Uint64(body))), nil
+	default:
+		return nil, fmt.Errorf("go-msmq: DecodeBody(%d) failed to decode body: %w", t, ErrUnsupportedBodyType)
+	}
+}
+
+// EncodeBody returns v encoded as the body of a message and its body type,
+// so that receivers using the ActiveXMessageFormatter of .NET
+// System.Messaging read the value it was sent as. v is a string, a []byte, a
+// bool, a sized integer or floating-point type, or a time.Time:
+//   bodyType, body, err := msmq.EncodeBody(int32(42))
+//   ...
+//   err = queue.Send(&msmq.NativeMessage{Body: body, BodyType: bodyType}, msmq.SingleMessage)
+func EncodeBody(v interface{}) (BodyType, []byte, error) {
+	var b bytes.Buffer
+	le := binary.LittleEndian
+	switch v := v.(type) {
+	case string:
+		for _, u := range utf16.Encode([]rune(v)) {
+			binary.Write(&b, le, u)
+		}
+		return BodyTypeUnicodeString, b.Bytes(), nil
+	case []byte:
+		return BodyTypeBytes, v, nil
+	case bool:
+		// VARIANT_TRUE is -1.
+		var n int16
+		if v {
+			n = -1
+		}
+		binary.Write(&b, le, n)
+		return BodyTypeBool, b.Bytes(), nil
+	case time.Time:
+		binary.Write(&b, le, variantDate(v))
+		return BodyTypeDate, b.Bytes(), nil
+	}
+
+	types := map[string]BodyType{
+		"int8": BodyTypeInt8, "uint8": BodyTypeUint8,
+		"int16": BodyTypeInt16, "uint16": BodyTypeUint16,
+		"int32": BodyTypeInt32, "uint32": BodyTypeUint32,
+		"int64": BodyTypeInt64, "uint64": BodyTypeUint64,
+		"float32": BodyTypeFloat32, "float64": BodyTypeFloat64,
+	}
+	t, ok := types[fmt.Sprintf("%T", v)]
+	if !ok {
+		return 0, nil, fmt.Errorf("go-msmq: EncodeBody(%T) failed to encode body: %w", v, ErrUnsupportedBodyType)
+	}
+	binary.Write(&b, le, v)
+
+	return t, b.Bytes(), nil
+}
+
+// DecodeXMLBody decodes the body of a message formatted by the
+// XmlMessageFormatter of .NET System.Messaging into v, like xml.Unmarshal.
+// The formatter serializes a string as a string element, which is decoded
+// into a *string:
+//   var s string
+//   err := msmq.DecodeXMLBody(body, &s)
+func DecodeXMLBody(body []byte, v interface{}) error {
+	// XmlSerializer writes a UTF-8 byte order mark.
+	body = bytes.TrimPrefix(body, []byte("\xEF\xBB\xBF"))
+	if err := xml.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("go-msmq: DecodeXMLBody() failed to decode body: %w", err)
+	}
+
+	return nil
+}
+
+// dateFromVariant returns the time of the OLE Automation date d, the inverse
+// of variantDate.
+func dateFromVariant(d float64) time.Time {
+	epoch := time.Date(1899, time.December, 30, 0, 0, 0, 0, time.UTC)
+	// Negative dates count days backwards but their fraction forwards. OLE
+	// Automation dates are precise to the millisecond.
+	days, frac := math.Modf(d)
+	ms := math.Round(math.Abs(frac) * 24 * 60 * 60 * 1000)
+	wall := epoch.AddDate(0, 0, int(days)).Add(time.Duration(ms) * time.Millisecond)
+
+	return time.Date(wall.Year(), wall.Month(), wall.Day(), wall.Hour(), wall.Minute(), wall.Second(), wall.Nanosecond(), time.Local)
+}
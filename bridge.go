@@ -0,0 +1,311 @@
+package msmq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// ErrSkipMessage is returned by the transform of a Bridge to drop a message:
+// the message is removed from the source queue without being forwarded.
+var ErrSkipMessage = errors.New("go-msmq: skip message")
+
+// Bridge moves messages from a source queue to a destination queue, which
+// may be on another computer, for example while a service migrates between
+// machines or queues are reorganized:
+//   bridge := msmq.NewBridge(src, dst,
+//       msmq.BridgeWithBatchSize(50),
+//       msmq.BridgeWithRate(200),
+//   )
+//   err := bridge.Run(ctx)
+//
+// By default, both queues are transactional and each batch of messages is
+// received from the source and sent to the destination in the same internal
+// transaction, so that every message is forwarded exactly once. With
+// BridgeWithTransactional(false), a message is peeked, sent and then removed
+// from the source, so that a failure forwards it at least once.
+//
+// A Bridge created with NewSinkBridge forwards messages out of MSMQ to a
+// Sink, such as another message broker, to migrate off MSMQ gradually.
+type Bridge struct {
+	src       *Queue
+	dst       *Queue
+	sink      Sink
+	options   *bridgeOptions
+	forwarded atomic.Uint64
+}
+
+// Sink is a destination outside of MSMQ that a Bridge forwards messages to.
+type Sink interface {
+	// Forward delivers msg to the destination. It must only return nil
+	// once the destination has durably accepted the message, since the
+	// Bridge then removes it from the source queue. A Sink cannot take
+	// part in the transaction of the Bridge, so that a failure between
+	// Forward and the commit forwards the message again: destinations
+	// should tolerate duplicates.
+	Forward(ctx context.Context, msg *Message) error
+}
+
+// NewBridge returns a pointer to a Bridge from src, opened with Receive
+// AccessMode, to dst, opened with Send AccessMode, configured by the options.
+func NewBridge(src, dst *Queue, opts ...BridgeOption) *Bridge {
+	options := &bridgeOptions{
+		transactional:  true,
+		batchSize:      1,
+		receiveTimeout: time.Second,
+	}
+	for _, o := range opts {
+		o.set(options)
+	}
+	if options.batchSize < 1 {
+		options.batchSize = 1
+	}
+
+	return &Bridge{
+		src:     src,
+		dst:     dst,
+		options: options,
+	}
+}
+
+// NewSinkBridge returns a pointer to a Bridge from src, opened with Receive
+// AccessMode, to sink, configured by the options. By default the source
+// queue must be transactional, and a batch of messages is removed from it
+// once every message of the batch is forwarded.
+func NewSinkBridge(src *Queue, sink Sink, opts ...BridgeOption) *Bridge {
+	b := NewBridge(src, nil, opts...)
+	b.sink = sink
+	return b
+}
+
+// Forwarded returns the number of messages forwarded since the Bridge was
+// created.
+func (b *Bridge) Forwarded() uint64 {
+	return b.forwarded.Load()
+}
+
+// Run forwards messages until ctx is done, and returns nil, or until a
+// message cannot be forwarded, and returns the error. Transient errors are
+// retried with the default backoff.
+func (b *Bridge) Run(ctx context.Context) error {
+	var (
+		next     time.Time
+		failures int
+	)
+	for ctx.Err() == nil {
+		// Throttle to the configured rate by spacing out the batches.
+		if b.options.rate > 0 {
+			if !sleep(ctx, time.Until(next)) {
+				return nil
+			}
+		}
+
+		n, err := b.forward(ctx)
+		if err != nil {
+			if !IsTransient(err) {
+				return fmt.Errorf("go-msmq: Run() failed to forward messages: %w", err)
+			}
+
+			failures++
+			if !sleep(ctx, (*RetryPolicy)(nil).backoff(failures)) {
+				return nil
+			}
+			continue
+		}
+		failures = 0
+
+		if b.options.rate > 0 {
+			next = time.Now().Add(time.Duration(float64(n) / b.options.rate * float64(time.Second)))
+		}
+	}
+
+	return nil
+}
+
+// forward forwards up to a batch of messages and returns the number of
+// messages removed from the source queue.
+func (b *Bridge) forward(ctx context.Context) (int, error) {
+	if !b.options.transactional {
+		return b.forwardOne(ctx)
+	}
+
+	tx, err := BeginTransaction()
+	if err != nil {
+		return 0, err
+	}
+
+	timeout := int(b.options.receiveTimeout / time.Millisecond)
+	n, sent := 0, 0
+	for n < b.options.batchSize {
+		msg, err := b.src.Receive(ReceiveInTransaction(tx), ReceiveWithTimeout(timeout))
+		if err != nil {
+			tx.Abort()
+			return 0, err
+		}
+		if msg.dispatch == nil {
+			break
+		}
+		// Only wait for the first message of a batch.
+		timeout = 0
+		n++
+
+		ok, err := b.send(ctx, &msg, SendInTransaction(tx))
+		msg.release()
+		if err != nil {
+			tx.Abort()
+			return 0, err
+		}
+		if ok {
+			sent++
+		}
+	}
+
+	if n == 0 {
+		return 0, tx.Abort()
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	b.forwarded.Add(uint64(sent))
+	return n, nil
+}
+
+// forwardOne forwards the first message of the source queue without a
+// transaction.
+func (b *Bridge) forwardOne(ctx context.Context) (int, error) {
+	timeout := int(b.options.receiveTimeout / time.Millisecond)
+	msg, err := b.src.Peek(PeekWithTimeout(timeout))
+	if err != nil {
+		return 0, err
+	}
+	if msg.dispatch == nil {
+		return 0, nil
+	}
+	defer msg.release()
+
+	id, err := msg.LookupID()
+	if err != nil {
+		return 0, err
+	}
+
+	ok, err := b.send(ctx, &msg, SendWithTransaction(NoTransaction))
+	if err != nil {
+		return 0, err
+	}
+
+	received, err := b.src.ReceiveByLookupID(id, ReceiveByLookupIDWithTransaction(NoTransaction), ReceiveByLookupIDWithWantBody(false))
+	if err != nil {
+		return 0, err
+	}
+	received.release()
+
+	if ok {
+		b.forwarded.Add(1)
+	}
+	return 1, nil
+}
+
+// send transforms msg and sends it to the destination queue or sink. It
+// reports whether the message was sent, as opposed to skipped by the
+// transform.
+func (b *Bridge) send(ctx context.Context, msg *Message, opts ...SendOption) (bool, error) {
+	if b.options.transform != nil {
+		err := b.options.transform(msg)
+		if errors.Is(err, ErrSkipMessage) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+	}
+
+	if b.sink != nil {
+		if err := b.sink.Forward(ctx, msg); err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+
+	if err := msg.Send(b.dst, opts...); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// BridgeOption represents an option to configure a Bridge.
+type BridgeOption struct {
+	set func(opts *bridgeOptions)
+}
+
+// bridgeOptions contains all the options to configure a Bridge.
+type bridgeOptions struct {
+	transactional  bool
+	batchSize      int
+	rate           float64
+	receiveTimeout time.Duration
+	transform      func(msg *Message) error
+}
+
+// BridgeWithTransactional returns a BridgeOption that configures whether
+// messages are forwarded in internal transactions. Both queues must be
+// transactional if true, or only the source queue when forwarding to a Sink.
+// The default is true.
+func BridgeWithTransactional(transactional bool) BridgeOption {
+	return BridgeOption{
+		set: func(opts *bridgeOptions) {
+			opts.transactional = transactional
+		},
+	}
+}
+
+// BridgeWithBatchSize returns a BridgeOption that configures the maximum
+// number of messages forwarded in a single transaction. Larger batches
+// increase throughput. It has no effect without transactions. The default is
+// 1.
+func BridgeWithBatchSize(size int) BridgeOption {
+	return BridgeOption{
+		set: func(opts *bridgeOptions) {
+			opts.batchSize = size
+		},
+	}
+}
+
+// BridgeWithRate returns a BridgeOption that limits the number of messages
+// forwarded per second, so that the destination is not flooded when a large
+// backlog is moved. The default is no limit.
+func BridgeWithRate(perSecond float64) BridgeOption {
+	return BridgeOption{
+		set: func(opts *bridgeOptions) {
+			opts.rate = perSecond
+		},
+	}
+}
+
+// BridgeWithReceiveTimeout returns a BridgeOption that configures how long
+// the Bridge waits for a message before checking whether it is stopping. The
+// default is 1 second.
+func BridgeWithReceiveTimeout(timeout time.Duration) BridgeOption {
+	return BridgeOption{
+		set: func(opts *bridgeOptions) {
+			opts.receiveTimeout = timeout
+		},
+	}
+}
+
+// BridgeWithTransform returns a BridgeOption that configures a function
+// called with each message before it is forwarded, for example to change its
+// label or body. Returning ErrSkipMessage drops the message, and any other
+// error stops the Bridge, leaving the message in the source queue when
+// forwarding transactionally.
+func BridgeWithTransform(fn func(msg *Message) error) BridgeOption {
+	return BridgeOption{
+		set: func(opts *bridgeOptions) {
+			opts.transform = fn
+		},
+	}
+}
@@ -0,0 +1,266 @@
+// Command msmqbench sends and receives messages with parallel workers and
+// reports the throughput and latency percentiles, to validate deployments and
+// catch performance regressions in the package.
+//
+// Usage:
+//   msmqbench -queue <queue> [-mode both|send|receive] [-count n] [-size bytes]
+//             [-workers n] [-transactional] [-timeout duration]
+//
+// In both mode, the default, the messages are received while they are sent
+// and the end-to-end latency of each message is reported as well. The queue
+// is referenced by its path name or format name and should be empty, since
+// the messages received are counted regardless of who sent them.
+package main
+
+import (
+	"encoding/binary"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jandauz/go-msmq"
+)
+
+func main() {
+	queue := flag.String("queue", "", "path name or format name of the queue")
+	mode := flag.String("mode", "both", "operations to benchmark: both, send or receive")
+	count := flag.Int("count", 10000, "number of messages")
+	size := flag.Int("size", 1024, "size of the message bodies in bytes")
+	workers := flag.Int("workers", 4, "number of parallel senders and of parallel receivers")
+	transactional := flag.Bool("transactional", false, "send and receive in single-message transactions")
+	timeout := flag.Duration("timeout", 5*time.Second, "how long a receiver waits for a message before giving up")
+	flag.Parse()
+
+	if *queue == "" || *count < 1 || *workers < 1 || *size < 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	send := *mode == "both" || *mode == "send"
+	receive := *mode == "both" || *mode == "receive"
+	if !send && !receive {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	level := msmq.NoTransaction
+	if *transactional {
+		level = msmq.SingleMessage
+	}
+
+	b := &bench{
+		count:   *count,
+		size:    *size,
+		workers: *workers,
+		level:   level,
+		timeout: *timeout,
+		// The end-to-end latency requires the send time in the body.
+		stamp: *mode == "both" && *size >= 8,
+	}
+
+	var (
+		wg              sync.WaitGroup
+		sent, received  *result
+		sendErr, rcvErr error
+	)
+	start := time.Now()
+	if send {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sent, sendErr = b.send(*queue)
+		}()
+	}
+	if receive {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			received, rcvErr = b.receive(*queue)
+		}()
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	if sent != nil {
+		sent.report("send", b.size)
+	}
+	if received != nil {
+		received.report("receive", b.size)
+		if b.stamp {
+			fmt.Printf("end-to-end latency: %s\n", percentiles(received.endToEnd))
+		}
+	}
+	fmt.Printf("total: %s\n", elapsed.Round(time.Millisecond))
+
+	for _, err := range []error{sendErr, rcvErr} {
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "msmqbench: %v\n", err)
+			os.Exit(1)
+		}
+	}
+}
+
+// bench is the configuration of a benchmark.
+type bench struct {
+	count   int
+	size    int
+	workers int
+	level   msmq.TransactionLevel
+	timeout time.Duration
+	stamp   bool
+}
+
+// result is the outcome of the send or receive side of a benchmark.
+type result struct {
+	n         int
+	elapsed   time.Duration
+	latencies []time.Duration
+
+	// endToEnd are the latencies from send to receive.
+	endToEnd []time.Duration
+}
+
+// report prints the throughput and latency percentiles of r.
+func (r *result) report(op string, size int) {
+	secs := r.elapsed.Seconds()
+	fmt.Printf("%s: %d messages in %s, %.0f msg/s, %.2f MB/s\n",
+		op, r.n, r.elapsed.Round(time.Millisecond), float64(r.n)/secs, float64(r.n*size)/secs/1e6)
+	fmt.Printf("%s latency: %s\n", op, percentiles(r.latencies))
+}
+
+// percentiles formats the 50th, 90th, 99th percentiles and maximum of d.
+func percentiles(d []time.Duration) string {
+	if len(d) == 0 {
+		return "no samples"
+	}
+
+	sort.Slice(d, func(i, j int) bool { return d[i] < d[j] })
+	p := func(q float64) time.Duration {
+		return d[int(q*float64(len(d)-1))]
+	}
+
+	return fmt.Sprintf("p50=%s p90=%s p99=%s max=%s", p(0.5), p(0.9), p(0.99), d[len(d)-1])
+}
+
+// run runs fn in the workers until fn returns false, and merges the results
+// of the workers.
+func (b *bench) run(fn func(r *result) (bool, error)) (*result, error) {
+	var (
+		mu       sync.Mutex
+		total    result
+		firstErr error
+		wg       sync.WaitGroup
+	)
+	start := time.Now()
+	for i := 0; i < b.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			var r result
+			var err error
+			for {
+				var more bool
+				more, err = fn(&r)
+				if !more || err != nil {
+					break
+				}
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			total.n += r.n
+			total.latencies = append(total.latencies, r.latencies...)
+			total.endToEnd = append(total.endToEnd, r.endToEnd...)
+			if err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}()
+	}
+	wg.Wait()
+	total.elapsed = time.Since(start)
+
+	return &total, firstErr
+}
+
+// send sends the messages to the queue name.
+func (b *bench) send(name string) (*result, error) {
+	q, err := msmq.Open(name, msmq.Options{AccessMode: msmq.Send})
+	if err != nil {
+		return nil, err
+	}
+	defer q.Close()
+
+	var next atomic.Int64
+	return b.run(func(r *result) (bool, error) {
+		if next.Add(1) > int64(b.count) {
+			return false, nil
+		}
+
+		msg, err := msmq.NewMessage()
+		if err != nil {
+			return false, err
+		}
+		defer msg.Close()
+
+		body := make([]byte, b.size)
+		start := time.Now()
+		if b.stamp {
+			binary.BigEndian.PutUint64(body, uint64(start.UnixNano()))
+		}
+		if err := msg.SetBodyBytes(body); err != nil {
+			return false, err
+		}
+		if err := msg.Send(q, msmq.SendWithTransaction(b.level)); err != nil {
+			return false, err
+		}
+
+		r.n++
+		r.latencies = append(r.latencies, time.Since(start))
+		return true, nil
+	})
+}
+
+// receive receives the messages from the queue name.
+func (b *bench) receive(name string) (*result, error) {
+	q, err := msmq.Open(name, msmq.Options{AccessMode: msmq.Receive})
+	if err != nil {
+		return nil, err
+	}
+	defer q.Close()
+
+	var next atomic.Int64
+	timeout := int(b.timeout / time.Millisecond)
+	return b.run(func(r *result) (bool, error) {
+		if next.Add(1) > int64(b.count) {
+			return false, nil
+		}
+
+		start := time.Now()
+		msg, err := q.Receive(msmq.ReceiveWithTransaction(b.level), msmq.ReceiveWithTimeout(timeout))
+		if err != nil {
+			return false, err
+		}
+		if msg.IsZero() {
+			return false, fmt.Errorf("no message received within %s", b.timeout)
+		}
+		defer msg.Close()
+
+		body, err := msg.BodyBytes()
+		if err != nil {
+			return false, err
+		}
+
+		now := time.Now()
+		r.n++
+		r.latencies = append(r.latencies, now.Sub(start))
+		if b.stamp && len(body) >= 8 {
+			sent := time.Unix(0, int64(binary.BigEndian.Uint64(body)))
+			r.endToEnd = append(r.endToEnd, now.Sub(sent))
+		}
+		return true, nil
+	})
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/jandauz/go-msmq"
+)
+
+// export writes the messages of a queue to a backup file, or to the standard
+// output.
+func export(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	output := fs.String("o", "", "file to write the backup to; the default is the standard output")
+	name, err := parse(fs, args)
+	if err != nil {
+		return err
+	}
+
+	q, err := msmq.Open(name, msmq.Options{AccessMode: msmq.Peek})
+	if err != nil {
+		return err
+	}
+	defer q.Close()
+
+	var w io.Writer = os.Stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	n, err := msmq.Export(w, q)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "exported %d messages\n", n)
+	return nil
+}
+
+// restore sends the messages of a backup file, or of the standard input, to
+// a queue.
+func restore(args []string) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	input := fs.String("i", "", "file to read the backup from; the default is the standard input")
+	transactional := fs.Bool("transactional", false, "send the messages to a transactional queue")
+	name, err := parse(fs, args)
+	if err != nil {
+		return err
+	}
+
+	q, err := msmq.Open(name, msmq.Options{AccessMode: msmq.Send})
+	if err != nil {
+		return err
+	}
+	defer q.Close()
+
+	var r io.Reader = os.Stdin
+	if *input != "" {
+		f, err := os.Open(*input)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	level := msmq.NoTransaction
+	if *transactional {
+		level = msmq.SingleMessage
+	}
+
+	n, err := msmq.Import(r, q, msmq.ImportWithTransaction(level))
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "imported %d messages\n", n)
+	return nil
+}
@@ -0,0 +1,377 @@
+package main
+
+import (
+	"bufio"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+	"unicode/utf8"
+
+	"github.com/jandauz/go-msmq"
+)
+
+// errQuit is returned when the operator quits the browser.
+var errQuit = errors.New("quit")
+
+// maxBody is the number of bytes of a body shown by the browser.
+const maxBody = 4096
+
+// browser is an interactive terminal UI to browse queues and their messages.
+// It only peeks at messages, except when the operator deletes or moves them.
+type browser struct {
+	in       *bufio.Scanner
+	out      io.Writer
+	machine  string
+	pageSize int
+}
+
+// browseQueues lists the private queues of a computer and lets the operator
+// browse the messages of a queue, or browses a single queue.
+func browseQueues(args []string) error {
+	fs := flag.NewFlagSet("browse", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	machine := fs.String("machine", "", "computer to list the queues of; the default is the local computer")
+	pageSize := fs.Int("page", 20, "number of messages per page")
+	if err := fs.Parse(args); err != nil || fs.NArg() > 1 || *pageSize < 1 {
+		return errUsage
+	}
+
+	b := &browser{
+		in:       bufio.NewScanner(os.Stdin),
+		out:      os.Stdout,
+		machine:  *machine,
+		pageSize: *pageSize,
+	}
+
+	var err error
+	if fs.NArg() == 1 {
+		err = b.messages(fs.Arg(0))
+	} else {
+		err = b.queues()
+	}
+	if errors.Is(err, errQuit) {
+		return nil
+	}
+
+	return err
+}
+
+// prompt prints the available commands and returns the fields of the line
+// typed by the operator.
+func (b *browser) prompt(commands string) ([]string, error) {
+	fmt.Fprintf(b.out, "\n%s\n> ", commands)
+	if !b.in.Scan() {
+		if err := b.in.Err(); err != nil {
+			return nil, err
+		}
+		return nil, errQuit
+	}
+
+	return strings.Fields(b.in.Text()), nil
+}
+
+// clear clears the terminal.
+func (b *browser) clear() {
+	fmt.Fprint(b.out, "\x1b[H\x1b[2J")
+}
+
+// queues shows the queue list with the depth of each queue until the
+// operator quits.
+func (b *browser) queues() error {
+	status := ""
+	for {
+		qis, err := msmq.ListPrivateQueues(b.machine)
+		if err != nil {
+			return err
+		}
+
+		names := make([]string, 0, len(qis))
+		b.clear()
+		w := tabwriter.NewWriter(b.out, 0, 4, 2, ' ', 0)
+		fmt.Fprintf(w, "#\tQUEUE\tMESSAGES\t(%s)\n", time.Now().Format(time.TimeOnly))
+		for i, qi := range qis {
+			name, err := qi.FormatName()
+			if err != nil {
+				name = "?"
+			}
+			names = append(names, name)
+
+			count := "-"
+			if n, err := qi.MessageCount(); err == nil {
+				count = fmt.Sprint(n)
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\t\n", i+1, name, count)
+			qi.Close()
+		}
+		w.Flush()
+		fmt.Fprint(b.out, status)
+		status = ""
+
+		fields, err := b.prompt("<n> browse queue n, <enter> refresh, q quit")
+		if err != nil {
+			return err
+		}
+		if len(fields) == 0 {
+			continue
+		}
+		if fields[0] == "q" {
+			return errQuit
+		}
+
+		i, err := strconv.Atoi(fields[0])
+		if err != nil || i < 1 || i > len(names) {
+			status = "\nunknown queue " + fields[0] + "\n"
+			continue
+		}
+
+		err = b.messages(names[i-1])
+		if errors.Is(err, errQuit) {
+			return err
+		}
+		if err != nil {
+			status = fmt.Sprintf("\n%v\n", err)
+		}
+	}
+}
+
+// messages pages through the messages of the queue name until the operator
+// goes back.
+func (b *browser) messages(name string) error {
+	q, err := msmq.Open(name, msmq.Options{AccessMode: msmq.Receive})
+	if err != nil {
+		return err
+	}
+	defer q.Close()
+
+	// starts holds the lookup identifier each page starts after.
+	starts := []msmq.LookupID{msmq.FirstLookupID}
+	status := ""
+	for {
+		page, err := q.Browse(starts[len(starts)-1], b.pageSize)
+		if err != nil {
+			return err
+		}
+
+		b.clear()
+		fmt.Fprintf(b.out, "%s - page %d\n\n", name, len(starts))
+		w := tabwriter.NewWriter(b.out, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "#\tLOOKUP ID\tLABEL\tSIZE\tARRIVED\t")
+		for i, m := range page {
+			fmt.Fprintf(w, "%d\t%d\t%s\t%d\t%s\t\n", i+1, m.LookupID, m.Label, m.Size, m.ArrivedTime.Format(time.DateTime))
+		}
+		w.Flush()
+		if len(page) == 0 {
+			fmt.Fprintln(b.out, "no messages")
+		}
+		fmt.Fprint(b.out, status)
+		status = ""
+
+		fields, err := b.prompt("n next page, p previous page, v <n> view, d <n> delete, m <n> <queue> move, <enter> refresh, b back, q quit")
+		if err != nil {
+			return err
+		}
+		if len(fields) == 0 {
+			continue
+		}
+
+		switch fields[0] {
+		case "q":
+			return errQuit
+		case "b":
+			return nil
+		case "n":
+			if len(page) == b.pageSize {
+				starts = append(starts, page[len(page)-1].LookupID)
+			}
+			continue
+		case "p":
+			if len(starts) > 1 {
+				starts = starts[:len(starts)-1]
+			}
+			continue
+		}
+
+		var m *msmq.MessageSummary
+		if len(fields) > 1 {
+			if i, err := strconv.Atoi(fields[1]); err == nil && i >= 1 && i <= len(page) {
+				m = &page[i-1]
+			}
+		}
+		if m == nil {
+			status = "\nunknown command or message\n"
+			continue
+		}
+
+		switch {
+		case fields[0] == "v":
+			err = b.view(q, m.LookupID)
+			if errors.Is(err, errQuit) {
+				return err
+			}
+		case fields[0] == "d":
+			err = deleteMessage(q, m.LookupID)
+			if err == nil {
+				status = fmt.Sprintf("\ndeleted message %d\n", m.LookupID)
+			}
+		case fields[0] == "m" && len(fields) == 3:
+			err = moveMessage(q, m.LookupID, fields[2])
+			if err == nil {
+				status = fmt.Sprintf("\nmoved message %d to %s\n", m.LookupID, fields[2])
+			}
+		default:
+			status = "\nunknown command\n"
+		}
+		if err != nil {
+			status = fmt.Sprintf("\n%v\n", err)
+		}
+	}
+}
+
+// view shows the properties and body of the message id until the operator
+// goes back.
+func (b *browser) view(q *msmq.Queue, id msmq.LookupID) error {
+	msg, err := q.PeekByLookupID(id)
+	if err != nil {
+		return err
+	}
+	defer msg.Close()
+
+	b.clear()
+	w := tabwriter.NewWriter(b.out, 0, 4, 2, ' ', 0)
+	row := func(name string, v interface{}, err error) {
+		if err != nil {
+			v = "-"
+		}
+		switch t := v.(type) {
+		case time.Time:
+			v = t.Format(time.RFC3339)
+		case []byte:
+			v = hex.EncodeToString(t)
+		}
+		fmt.Fprintf(w, "%s\t%v\n", name, v)
+	}
+
+	fmt.Fprintf(w, "LookupID\t%d\n", id)
+	v, err := msg.Label()
+	row("Label", v, err)
+	bs, err := msg.ID()
+	row("ID", bs, err)
+	bs, err = msg.CorrelationID()
+	row("CorrelationID", bs, err)
+	n, err := msg.AppSpecific()
+	row("AppSpecific", n, err)
+	n, err = msg.Priority()
+	row("Priority", n, err)
+	d, err := msg.Delivery()
+	row("Delivery", d, err)
+	c, err := msg.Class()
+	row("Class", c, err)
+	bs, err = msg.Extension()
+	row("Extension", bs, err)
+	t, err := msg.SentTime()
+	row("SentTime", t, err)
+	t, err = msg.ArrivedTime()
+	row("ArrivedTime", t, err)
+	w.Flush()
+
+	body, err := msg.BodyBytes()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(b.out, "\nBody (%d bytes):\n", len(body))
+	truncated := len(body) > maxBody
+	if truncated {
+		body = body[:maxBody]
+	}
+	if utf8.Valid(body) {
+		fmt.Fprintln(b.out, string(body))
+	} else {
+		fmt.Fprint(b.out, hex.Dump(body))
+	}
+	if truncated {
+		fmt.Fprintln(b.out, "...")
+	}
+
+	fields, err := b.prompt("<enter> back, q quit")
+	if err != nil {
+		return err
+	}
+	if len(fields) > 0 && fields[0] == "q" {
+		return errQuit
+	}
+
+	return nil
+}
+
+// deleteMessage removes the message id from q.
+func deleteMessage(q *msmq.Queue, id msmq.LookupID) error {
+	level, err := transactionLevel(q)
+	if err != nil {
+		return err
+	}
+
+	msg, err := q.ReceiveByLookupID(id, msmq.ReceiveByLookupIDWithTransaction(level), msmq.ReceiveByLookupIDWithWantBody(false))
+	if err != nil {
+		return err
+	}
+
+	return msg.Close()
+}
+
+// moveMessage moves the message id from q to the queue referenced by name.
+// A message of a transactional queue is moved in a single transaction;
+// otherwise it is sent before it is removed, so that it is never lost.
+func moveMessage(q *msmq.Queue, id msmq.LookupID, name string) error {
+	dst, err := msmq.Open(name, msmq.Options{AccessMode: msmq.Send})
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+
+	level, err := transactionLevel(q)
+	if err != nil {
+		return err
+	}
+	if level == msmq.SingleMessage {
+		return msmq.MoveTransactional(q, dst, id)
+	}
+
+	msg, err := q.PeekByLookupID(id)
+	if err != nil {
+		return err
+	}
+	defer msg.Close()
+
+	if err := msg.Send(dst, msmq.SendWithTransaction(msmq.NoTransaction)); err != nil {
+		return err
+	}
+
+	return deleteMessage(q, id)
+}
+
+// transactionLevel returns the transaction level to receive messages from q
+// with.
+func transactionLevel(q *msmq.Queue) (msmq.TransactionLevel, error) {
+	qi, err := q.QueueInfo()
+	if err != nil {
+		return 0, err
+	}
+	defer qi.Close()
+
+	transactional, err := qi.IsTransactional()
+	if err != nil {
+		return 0, err
+	}
+	if transactional {
+		return msmq.SingleMessage, nil
+	}
+
+	return msmq.NoTransaction, nil
+}
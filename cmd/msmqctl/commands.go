@@ -0,0 +1,277 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jandauz/go-msmq"
+)
+
+// queueInfo returns a QueueInfo for the queue referenced by name, which is
+// either a format name or a path name.
+func queueInfo(name string) (*msmq.QueueInfo, error) {
+	if strings.Contains(name, "=") {
+		return msmq.NewQueueInfo(msmq.WithFormatName(name))
+	}
+
+	qi, err := msmq.NewQueueInfo(msmq.WithPathName(name))
+	if err != nil {
+		return nil, err
+	}
+
+	// Look up the queue to resolve its format name.
+	if err := qi.Refresh(); err != nil {
+		qi.Close()
+		return nil, err
+	}
+
+	return qi, nil
+}
+
+// create creates a queue.
+func create(args []string) error {
+	fs := flag.NewFlagSet("create", flag.ContinueOnError)
+	transactional := fs.Bool("transactional", false, "create a transactional queue")
+	worldReadable := fs.Bool("world-readable", false, "allow everyone to read messages")
+	label := fs.String("label", "", "label of the queue")
+	sddl := fs.String("security", "", "DACL of the queue in SDDL")
+	path, err := parse(fs, args)
+	if err != nil {
+		return err
+	}
+
+	qi, err := msmq.NewQueueInfo(msmq.WithPathName(path), msmq.WithLabel(*label))
+	if err != nil {
+		return err
+	}
+	defer qi.Close()
+
+	opts := []msmq.CreateQueueOption{
+		msmq.CreateQueueWithTransactional(*transactional),
+		msmq.CreateQueueWithWorldReadable(*worldReadable),
+	}
+	if *sddl != "" {
+		opts = append(opts, msmq.CreateQueueWithSecurity(*sddl))
+	}
+
+	return qi.Create(opts...)
+}
+
+// remove deletes a queue.
+func remove(args []string) error {
+	name, err := parse(flag.NewFlagSet("delete", flag.ContinueOnError), args)
+	if err != nil {
+		return err
+	}
+
+	qi, err := queueInfo(name)
+	if err != nil {
+		return err
+	}
+	defer qi.Close()
+
+	return qi.Delete()
+}
+
+// purge deletes the messages of a queue.
+func purge(args []string) error {
+	name, err := parse(flag.NewFlagSet("purge", flag.ContinueOnError), args)
+	if err != nil {
+		return err
+	}
+
+	q, err := msmq.Open(name, msmq.Options{AccessMode: msmq.Receive})
+	if err != nil {
+		return err
+	}
+	defer q.Close()
+
+	return q.Purge()
+}
+
+// list prints the private queues of a computer with their message counts.
+func list(args []string) error {
+	fs := flag.NewFlagSet("list", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	machine := fs.String("machine", "", "computer to list the queues of; the default is the local computer")
+	if err := fs.Parse(args); err != nil || fs.NArg() != 0 {
+		return errUsage
+	}
+
+	qis, err := msmq.ListPrivateQueues(*machine)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "QUEUE\tMESSAGES")
+	for _, qi := range qis {
+		name, err := qi.FormatName()
+		if err != nil {
+			qi.Close()
+			return err
+		}
+
+		count := "-"
+		if n, err := qi.MessageCount(); err == nil {
+			count = fmt.Sprint(n)
+		}
+		fmt.Fprintf(w, "%s\t%s\n", name, count)
+		qi.Close()
+	}
+
+	return w.Flush()
+}
+
+// show prints the properties and counters of a queue. Properties that cannot
+// be read, such as the properties of remote private queues, are printed as
+// "-".
+func show(args []string) error {
+	name, err := parse(flag.NewFlagSet("show", flag.ContinueOnError), args)
+	if err != nil {
+		return err
+	}
+
+	qi, err := queueInfo(name)
+	if err != nil {
+		return err
+	}
+	defer qi.Close()
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	row := func(name string, v interface{}, err error) {
+		if err != nil {
+			v = "-"
+		}
+		if t, ok := v.(time.Time); ok {
+			v = t.Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%s\t%v\n", name, v)
+	}
+
+	v, err := qi.PathName()
+	row("PathName", v, err)
+	v, err = qi.FormatName()
+	row("FormatName", v, err)
+	v, err = qi.Label()
+	row("Label", v, err)
+	b, err := qi.IsTransactional()
+	row("Transactional", b, err)
+	b, err = qi.IsWorldReadable()
+	row("WorldReadable", b, err)
+	b, err = qi.Journal()
+	row("Journal", b, err)
+	n, err := qi.Quota()
+	row("Quota", n, err)
+	n, err = qi.JournalQuota()
+	row("JournalQuota", n, err)
+	n, err = qi.BasePriority()
+	row("BasePriority", n, err)
+	t, err := qi.CreateTime()
+	row("CreateTime", t, err)
+	t, err = qi.ModifyTime()
+	row("ModifyTime", t, err)
+
+	m, err := qi.Management()
+	if err == nil {
+		defer m.Close()
+
+		n, err := m.MessageCount()
+		row("Messages", n, err)
+		u, err := m.BytesInQueue()
+		row("BytesInQueue", u, err)
+		n, err = m.JournalMessageCount()
+		row("JournalMessages", n, err)
+		u, err = m.BytesInJournal()
+		row("BytesInJournal", u, err)
+	} else {
+		// QueueInfo.MessageCount reports inactive queues as empty.
+		n, err := qi.MessageCount()
+		row("Messages", n, err)
+	}
+
+	return w.Flush()
+}
+
+// set updates the properties of a queue.
+func set(args []string) error {
+	fs := flag.NewFlagSet("set", flag.ContinueOnError)
+	label := fs.String("label", "", "label of the queue")
+	quota := fs.Int("quota", -1, "maximum size of the queue in kilobytes")
+	journal := fs.String("journal", "", "whether received messages are journaled: true or false")
+	journalQuota := fs.Int("journal-quota", -1, "maximum size of the journal in kilobytes")
+	name, err := parse(fs, args)
+	if err != nil {
+		return err
+	}
+
+	qi, err := queueInfo(name)
+	if err != nil {
+		return err
+	}
+	defer qi.Close()
+
+	var updates []error
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "label":
+			updates = append(updates, qi.SetLabel(*label))
+		case "quota":
+			updates = append(updates, qi.SetQuota(int32(*quota)))
+		case "journal-quota":
+			updates = append(updates, qi.SetJournalQuota(int32(*journalQuota)))
+		case "journal":
+			switch *journal {
+			case "true":
+				updates = append(updates, qi.SetJournal(true))
+			case "false":
+				updates = append(updates, qi.SetJournal(false))
+			default:
+				updates = append(updates, errUsage)
+			}
+		}
+	})
+	if len(updates) == 0 {
+		return errUsage
+	}
+	for _, err := range updates {
+		if err != nil {
+			return err
+		}
+	}
+
+	return qi.Update()
+}
+
+// security prints or replaces the DACL of a queue.
+func security(args []string) error {
+	fs := flag.NewFlagSet("security", flag.ContinueOnError)
+	sddl := fs.String("set", "", "DACL to set in SDDL")
+	name, err := parse(fs, args)
+	if err != nil {
+		return err
+	}
+
+	qi, err := queueInfo(name)
+	if err != nil {
+		return err
+	}
+	defer qi.Close()
+
+	if *sddl != "" {
+		return qi.SetSecurity(*sddl)
+	}
+
+	s, err := qi.Security()
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(s)
+	return nil
+}
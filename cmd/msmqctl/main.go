@@ -0,0 +1,125 @@
+// Command msmqctl manages MSMQ queues from the command line, so that
+// operators can script queue administration without PowerShell COM
+// one-liners.
+//
+// Usage:
+//   msmqctl create [-transactional] [-world-readable] [-label label] [-security sddl] <path>
+//   msmqctl delete <queue>
+//   msmqctl purge <queue>
+//   msmqctl list [-machine name]
+//   msmqctl show <queue>
+//   msmqctl set [-label label] [-quota kb] [-journal true|false] [-journal-quota kb] <queue>
+//   msmqctl security [-set sddl] <queue>
+//   msmqctl export [-o file] <queue>
+//   msmqctl import [-i file] [-transactional] <queue>
+//   msmqctl browse [-machine name] [-page n] [queue]
+//   msmqctl send [-delim line|nul|json] [-label label] [-transactional] <queue>
+//   msmqctl receive [-delim line|nul] [-n count] [-timeout duration] <queue>
+//   msmqctl tail [-delim line|nul] [-interval duration] <queue>
+//   msmqctl migrate [-move] [-batch n] [-source-transactional] [-dest-transactional] [-state file] <source> <dest>
+//
+// The browse command is an interactive terminal UI that lists the queues
+// with their depth, pages through the messages of a queue without removing
+// them, shows their properties and bodies, and deletes or moves selected
+// messages.
+//
+// The send, receive and tail commands let queues participate in shell
+// pipelines: send sends a message per body read from the standard input,
+// receive removes messages and writes their bodies to the standard output,
+// and tail writes the bodies without removing the messages and follows new
+// ones, like tail -f:
+//   jq -c '.orders[]' orders.json | msmqctl send -delim json .\private$\orders
+//   msmqctl receive -n 10 .\private$\orders | grep pending
+//
+// The migrate command copies or moves every message of a queue to another
+// queue, possibly on another computer, in batches that are transactions when
+// the queues are transactional. A copy with -state saves its progress after
+// every batch and resumes from it when run again; a move is resumed by
+// running it again.
+//
+// A queue is referenced by its path name, such as .\private$\orders, or by
+// its format name, such as DIRECT=OS:server\private$\orders.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// command is a subcommand of msmqctl.
+type command struct {
+	name  string
+	usage string
+	run   func(args []string) error
+}
+
+var commands = []command{
+	{"create", "create [-transactional] [-world-readable] [-label label] [-security sddl] <path>", create},
+	{"delete", "delete <queue>", remove},
+	{"purge", "purge <queue>", purge},
+	{"list", "list [-machine name]", list},
+	{"show", "show <queue>", show},
+	{"set", "set [-label label] [-quota kb] [-journal true|false] [-journal-quota kb] <queue>", set},
+	{"security", "security [-set sddl] <queue>", security},
+	{"export", "export [-o file] <queue>", export},
+	{"import", "import [-i file] [-transactional] <queue>", restore},
+	{"browse", "browse [-machine name] [-page n] [queue]", browseQueues},
+	{"send", "send [-delim line|nul|json] [-label label] [-transactional] <queue>", send},
+	{"receive", "receive [-delim line|nul] [-n count] [-timeout duration] <queue>", receive},
+	{"tail", "tail [-delim line|nul] [-interval duration] <queue>", tail},
+	{"migrate", "migrate [-move] [-batch n] [-source-transactional] [-dest-transactional] [-state file] <source> <dest>", migrate},
+}
+
+// errUsage is returned by commands called with invalid arguments.
+var errUsage = errors.New("invalid arguments")
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	for _, c := range commands {
+		if c.name != os.Args[1] {
+			continue
+		}
+
+		err := c.run(os.Args[2:])
+		if errors.Is(err, errUsage) {
+			fmt.Fprintf(os.Stderr, "usage: msmqctl %s\n", c.usage)
+			os.Exit(2)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "msmqctl %s: %v\n", c.name, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	usage()
+	os.Exit(2)
+}
+
+// usage prints the usage of every command.
+func usage() {
+	var b strings.Builder
+	b.WriteString("usage:\n")
+	for _, c := range commands {
+		fmt.Fprintf(&b, "  msmqctl %s\n", c.usage)
+	}
+	fmt.Fprint(os.Stderr, b.String())
+}
+
+// parse parses the flags of a command and returns its single queue argument.
+func parse(fs *flag.FlagSet, args []string) (string, error) {
+	fs.SetOutput(io.Discard)
+	if err := fs.Parse(args); err != nil || fs.NArg() != 1 {
+		return "", errUsage
+	}
+
+	return fs.Arg(0), nil
+}
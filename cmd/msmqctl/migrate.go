@@ -0,0 +1,98 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/jandauz/go-msmq"
+)
+
+// migrate copies or moves the messages of a queue to another queue. A copy
+// saves its progress to the -state file after every batch, and resumes from
+// it when run again.
+func migrate(args []string) error {
+	fs := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	move := fs.Bool("move", false, "remove the messages from the source queue")
+	batch := fs.Int("batch", 100, "number of messages per batch")
+	sourceTransactional := fs.Bool("source-transactional", false, "the source queue is transactional")
+	destTransactional := fs.Bool("dest-transactional", false, "the destination queue is transactional")
+	state := fs.String("state", "", "file to save the progress of a copy to, and to resume it from")
+	if err := fs.Parse(args); err != nil || fs.NArg() != 2 || *batch < 1 {
+		return errUsage
+	}
+
+	access := msmq.Peek
+	if *move {
+		access = msmq.Receive
+	}
+	source, err := msmq.Open(fs.Arg(0), msmq.Options{AccessMode: access})
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	dest, err := msmq.Open(fs.Arg(1), msmq.Options{AccessMode: msmq.Send})
+	if err != nil {
+		return err
+	}
+	defer dest.Close()
+
+	opts := []msmq.MigrateOption{
+		msmq.MigrateWithMove(*move),
+		msmq.MigrateWithBatchSize(*batch),
+		msmq.MigrateWithTransactional(*sourceTransactional, *destTransactional),
+	}
+
+	var saveErr error
+	if *state != "" && !*move {
+		resume, err := readState(*state)
+		if err != nil {
+			return err
+		}
+		if resume != 0 {
+			fmt.Fprintf(os.Stderr, "resuming after message %d\n", resume)
+		}
+		opts = append(opts, msmq.MigrateWithResume(resume))
+		opts = append(opts, msmq.MigrateWithOnBatch(func(migrated int, id msmq.LookupID) {
+			if err := os.WriteFile(*state, []byte(strconv.FormatUint(uint64(id), 10)), 0o644); err != nil && saveErr == nil {
+				saveErr = err
+			}
+			fmt.Fprintf(os.Stderr, "migrated %d messages\n", migrated)
+		}))
+	} else {
+		opts = append(opts, msmq.MigrateWithOnBatch(func(migrated int, _ msmq.LookupID) {
+			fmt.Fprintf(os.Stderr, "migrated %d messages\n", migrated)
+		}))
+	}
+
+	n, err := msmq.Migrate(source, dest, opts...)
+	if err != nil {
+		return err
+	}
+	if saveErr != nil {
+		return saveErr
+	}
+
+	fmt.Fprintf(os.Stderr, "migrated %d messages in total\n", n)
+	return nil
+}
+
+// readState returns the lookup identifier saved to the state file name, or 0
+// if the file does not exist.
+func readState(name string) (msmq.LookupID, error) {
+	b, err := os.ReadFile(name)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	return msmq.ParseLookupID(strings.TrimSpace(string(b)))
+}
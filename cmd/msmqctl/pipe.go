@@ -0,0 +1,223 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"io"
+	"os"
+	"time"
+
+	"github.com/jandauz/go-msmq"
+)
+
+// delimiters maps the values of the -delim flag to the byte separating
+// message bodies.
+var delimiters = map[string]byte{
+	"line": '\n',
+	"nul":  0,
+}
+
+// send sends a message per body read from the standard input. Bodies are
+// separated by newlines or NUL bytes, or are JSON values sent in their
+// compact encoding, such as the output of jq -c.
+func send(args []string) error {
+	fs := flag.NewFlagSet("send", flag.ContinueOnError)
+	delim := fs.String("delim", "line", "how bodies are separated: line, nul or json")
+	label := fs.String("label", "", "label of the messages")
+	transactional := fs.Bool("transactional", false, "send the messages to a transactional queue")
+	name, err := parse(fs, args)
+	if err != nil {
+		return err
+	}
+
+	var next func() ([]byte, error)
+	in := bufio.NewReader(os.Stdin)
+	if *delim == "json" {
+		dec := json.NewDecoder(in)
+		next = func() ([]byte, error) {
+			var v json.RawMessage
+			if err := dec.Decode(&v); err != nil {
+				return nil, err
+			}
+			var b bytes.Buffer
+			err := json.Compact(&b, v)
+			return b.Bytes(), err
+		}
+	} else {
+		d, ok := delimiters[*delim]
+		if !ok {
+			return errUsage
+		}
+		next = func() ([]byte, error) {
+			body, err := in.ReadBytes(d)
+			if errors.Is(err, io.EOF) && len(body) > 0 {
+				// The last body is not terminated.
+				return body, nil
+			}
+			return bytes.TrimSuffix(body, []byte{d}), err
+		}
+	}
+
+	q, err := msmq.Open(name, msmq.Options{AccessMode: msmq.Send})
+	if err != nil {
+		return err
+	}
+	defer q.Close()
+
+	level := msmq.NoTransaction
+	if *transactional {
+		level = msmq.SingleMessage
+	}
+
+	for {
+		body, err := next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := sendBody(q, body, *label, level); err != nil {
+			return err
+		}
+	}
+}
+
+// sendBody sends a message with body and label to q.
+func sendBody(q *msmq.Queue, body []byte, label string, level msmq.TransactionLevel) error {
+	msg, err := msmq.NewMessage()
+	if err != nil {
+		return err
+	}
+	defer msg.Close()
+
+	if err := msg.SetBodyBytes(body); err != nil {
+		return err
+	}
+	if label != "" {
+		if err := msg.SetLabel(label); err != nil {
+			return err
+		}
+	}
+
+	return msg.Send(q, msmq.SendWithTransaction(level))
+}
+
+// receive removes messages from a queue and writes their bodies to the
+// standard output, each followed by the delimiter. It stops after -n
+// messages, or when no message arrives within -timeout.
+func receive(args []string) error {
+	fs := flag.NewFlagSet("receive", flag.ContinueOnError)
+	delim := fs.String("delim", "line", "what follows each body: line or nul")
+	n := fs.Int("n", 0, "number of messages to receive; 0 receives until the timeout")
+	timeout := fs.Duration("timeout", time.Second, "how long to wait for a message")
+	name, err := parse(fs, args)
+	if err != nil {
+		return err
+	}
+	d, ok := delimiters[*delim]
+	if !ok || *n < 0 {
+		return errUsage
+	}
+
+	q, err := msmq.Open(name, msmq.Options{AccessMode: msmq.Receive})
+	if err != nil {
+		return err
+	}
+	defer q.Close()
+
+	level, err := transactionLevel(q)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(os.Stdout)
+	defer w.Flush()
+	for i := 0; *n == 0 || i < *n; i++ {
+		msg, err := q.Receive(msmq.ReceiveWithTransaction(level), msmq.ReceiveWithTimeout(int(*timeout/time.Millisecond)))
+		if err != nil {
+			return err
+		}
+		if msg.IsZero() {
+			return nil
+		}
+
+		err = writeBody(w, &msg, d)
+		msg.Close()
+		if err != nil {
+			return err
+		}
+		// Flush every message so that a pipeline sees it right away.
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// tail writes the bodies of the messages of a queue to the standard output
+// without removing them, and keeps writing the bodies of new messages as
+// they arrive until interrupted.
+func tail(args []string) error {
+	fs := flag.NewFlagSet("tail", flag.ContinueOnError)
+	delim := fs.String("delim", "line", "what follows each body: line or nul")
+	interval := fs.Duration("interval", time.Second, "how often to look for new messages")
+	name, err := parse(fs, args)
+	if err != nil {
+		return err
+	}
+	d, ok := delimiters[*delim]
+	if !ok || *interval <= 0 {
+		return errUsage
+	}
+
+	q, err := msmq.Open(name, msmq.Options{AccessMode: msmq.Peek})
+	if err != nil {
+		return err
+	}
+	defer q.Close()
+
+	w := bufio.NewWriter(os.Stdout)
+	id := msmq.FirstLookupID
+	for {
+		msg, err := q.PeekNextByLookupID(id)
+		if err != nil {
+			return err
+		}
+		if msg.IsZero() {
+			if err := w.Flush(); err != nil {
+				return err
+			}
+			time.Sleep(*interval)
+			continue
+		}
+
+		err = writeBody(w, &msg, d)
+		if err == nil {
+			id, err = msg.LookupID()
+		}
+		msg.Close()
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// writeBody writes the body of msg to w followed by the delimiter d.
+func writeBody(w *bufio.Writer, msg *msmq.Message, d byte) error {
+	body, err := msg.BodyBytes()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(body); err != nil {
+		return err
+	}
+
+	return w.WriteByte(d)
+}
+
@@ -0,0 +1,242 @@
+package msmq
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+
+	"github.com/go-ole/go-ole"
+	"github.com/go-ole/go-ole/oleutil"
+)
+
+// rpcEChangedMode is the RPC_E_CHANGED_MODE HRESULT which is returned when COM
+// was already initialized on the thread with a different concurrency model.
+const rpcEChangedMode = 0x80010106
+
+// ApartmentModel is the COM concurrency model in which the objects of the
+// package live.
+type ApartmentModel int
+
+const (
+	// MTA places the objects in the multithreaded apartment of the process.
+	// They can be called from any goroutine concurrently. This is the
+	// default.
+	MTA ApartmentModel = iota
+
+	// STA places the objects in a single-threaded apartment owned by a
+	// dedicated thread of the package, which runs a message pump so that
+	// apartment-sensitive features such as event notifications work even
+	// when the host application has no message loop. Every call on an
+	// object is marshaled to that thread, so calls are serialized: a
+	// blocking Receive delays every other call until it returns.
+	STA
+)
+
+// String returns the name of the model.
+func (m ApartmentModel) String() string {
+	switch m {
+	case MTA:
+		return "MTA"
+	case STA:
+		return "STA"
+	default:
+		return fmt.Sprintf("ApartmentModel(%d)", int(m))
+	}
+}
+
+// InitOption represents an option to initialize COM.
+type InitOption struct {
+	set func(o *initOptions)
+}
+
+// initOptions contains all the options to initialize COM.
+type initOptions struct {
+	model ApartmentModel
+}
+
+// InitWithApartmentModel returns an InitOption that configures the apartment
+// in which the objects of the package live.
+//
+// The default is MTA.
+func InitWithApartmentModel(model ApartmentModel) InitOption {
+	return InitOption{
+		set: func(o *initOptions) {
+			o.model = model
+		},
+	}
+}
+
+// apartment keeps the apartment of the package alive. In the MTA model, the
+// objects of the package live in the multithreaded apartment, so they can be
+// used from any goroutine regardless of the OS thread it runs on, as long as
+// the MTA exists. In the STA model, they live in the apartment of sta.
+var apartment struct {
+	mu    sync.Mutex
+	model ApartmentModel
+	stop  chan struct{}
+	done  chan struct{}
+}
+
+// Init initializes COM for the package. In the default MTA model, it starts
+// an OS thread that joins the multithreaded apartment and keeps it alive until
+// Shutdown is called, so that goroutines moving between threads can use MSMQ
+// objects without "CoInitialize has not been called" failures. In the STA
+// model, it starts the thread that owns the single-threaded apartment of the
+// package:
+//   err := msmq.Init(msmq.InitWithApartmentModel(msmq.STA))
+//
+// Calling Init is optional: it is called implicitly with the default options
+// when the first MSMQ object is created. Calling it explicitly at startup
+// reports initialization errors early and is required to select STA. Calling
+// Init more than once has no effect, but selecting a different apartment
+// model than the one in use is an error.
+//
+// On platforms other than Windows, Init returns ErrUnsupportedPlatform.
+func Init(opts ...InitOption) error {
+	if runtime.GOOS != "windows" {
+		return ErrUnsupportedPlatform
+	}
+
+	options := &initOptions{
+		model: MTA,
+	}
+	for _, o := range opts {
+		o.set(options)
+	}
+
+	apartment.mu.Lock()
+	defer apartment.mu.Unlock()
+
+	if apartment.stop != nil {
+		if len(opts) > 0 && options.model != apartment.model {
+			return fmt.Errorf("go-msmq: Init() failed to initialize COM: already initialized with %v", apartment.model)
+		}
+		return nil
+	}
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	var err error
+	switch options.model {
+	case MTA:
+		err = keepMTA(stop, done)
+	case STA:
+		err = startSTA(stop, done)
+	default:
+		err = fmt.Errorf("unknown %v", options.model)
+	}
+	if err != nil {
+		return fmt.Errorf("go-msmq: Init() failed to initialize COM: %w", err)
+	}
+
+	apartment.model = options.model
+	apartment.stop = stop
+	apartment.done = done
+	return nil
+}
+
+// keepMTA starts an OS thread that joins the multithreaded apartment and
+// keeps it alive until stop is closed. done is closed when the thread exits.
+func keepMTA(stop, done chan struct{}) error {
+	errs := make(chan error, 1)
+	go func() {
+		defer close(done)
+
+		runtime.LockOSThread()
+		defer runtime.UnlockOSThread()
+
+		err := ole.CoInitializeEx(0, ole.COINIT_MULTITHREADED)
+		if err != nil && !isSFalse(err) {
+			errs <- err
+			return
+		}
+		defer ole.CoUninitialize()
+
+		errs <- nil
+		<-stop
+	}()
+
+	return <-errs
+}
+
+// Shutdown releases the apartment kept alive by Init. Every MSMQ object must
+// be closed before calling Shutdown. If leak tracking is enabled, the objects
+// that were not closed are reported. The package can be used again after
+// calling Init.
+func Shutdown() {
+	apartment.mu.Lock()
+	defer apartment.mu.Unlock()
+
+	if apartment.stop == nil {
+		return
+	}
+
+	reportLeaks()
+	close(apartment.stop)
+	<-apartment.done
+	apartment.stop = nil
+	apartment.done = nil
+}
+
+// createObject creates the COM object identified by progID and returns its
+// IDispatch interface. In the STA model, the object is created on the thread
+// of the apartment. Otherwise, the calling thread joins the multithreaded
+// apartment if it has not initialized COM yet; the thread remains in the
+// apartment afterwards so that the object can be used from it.
+func createObject(progID string) (*ole.IDispatch, error) {
+	err := Init()
+	if err != nil {
+		return nil, err
+	}
+
+	var dispatch *ole.IDispatch
+	inApartment(func() {
+		dispatch, err = createObjectInApartment(progID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return track(dispatch, progID), nil
+}
+
+// createObjectInApartment creates the COM object identified by progID in the
+// apartment of the calling thread.
+func createObjectInApartment(progID string) (*ole.IDispatch, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	// S_FALSE reports that the thread is already in the MTA and
+	// RPC_E_CHANGED_MODE that the thread is in a single-threaded apartment,
+	// either the one of the STA model or one initialized by the application,
+	// in which case the object is created in that apartment.
+	err := ole.CoInitializeEx(0, ole.COINIT_MULTITHREADED)
+	if err != nil && !isSFalse(err) && !isHRESULT(err, rpcEChangedMode) {
+		return nil, err
+	}
+
+	unknown, err := oleutil.CreateObject(progID)
+	if err != nil {
+		if err.Error() == "Invalid class string" {
+			return nil, ErrMSMQNotInstalled
+		}
+
+		return nil, err
+	}
+	defer unknown.Release()
+
+	return unknown.QueryInterface(ole.IID_IDispatch)
+}
+
+// isSFalse returns whether err is the S_FALSE HRESULT that CoInitializeEx
+// returns when COM is already initialized on the thread.
+func isSFalse(err error) bool {
+	return isHRESULT(err, 1)
+}
+
+// isHRESULT returns whether err is an *ole.OleError with the specified code.
+func isHRESULT(err error, code uintptr) bool {
+	var oleErr *ole.OleError
+	return errors.As(err, &oleErr) && oleErr.Code() == code
+}
@@ -0,0 +1,267 @@
+package msmq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// errLeaseLost is returned by Coordinator.consume when the queue is no
+// longer held.
+var errLeaseLost = errors.New("go-msmq: lease on queue lost")
+
+// CoordinationMode specifies how the processes of a Coordinator share a
+// queue.
+type CoordinationMode int
+
+const (
+	// Exclusive lets a single process consume the queue at a time. The
+	// queue is opened with DenyReceive ShareMode, which MSMQ grants to a
+	// single process; the other processes stand by and take over when it
+	// stops.
+	Exclusive CoordinationMode = iota
+
+	// Shared lets every process consume the queue concurrently. Each
+	// message is received by a single process.
+	Shared
+)
+
+// Coordinator lets several processes consume the same queue safely, for
+// example the instances of a service deployed on several computers:
+//   coord, err := msmq.NewCoordinator(`DIRECT=OS:server\private$\orders`,
+//       msmq.CoordinatorWithMode(msmq.Exclusive),
+//       msmq.CoordinatorWithOnAcquire(func() { log.Print("active") }),
+//   )
+//   ...
+//   err = coord.Run(ctx, handler)
+//
+// In Exclusive mode, the process that opens the queue first holds it and the
+// others retry every lease interval. The lease is held by the queue handle,
+// which the operating system releases when the process exits or crashes, so a
+// standby takes over within a lease interval without any external lock. The
+// active process renews its lease every interval by checking that its handle
+// is still open, and goes back to standby when it is not, for example after
+// the MSMQ service restarted.
+//
+// In Shared mode, every process consumes the queue, and each one is
+// identified by its instance name in the metrics reported to
+// HandlerObservers.
+type Coordinator struct {
+	name    string
+	options *coordinatorOptions
+	active  atomic.Bool
+}
+
+// NewCoordinator returns a pointer to a Coordinator of the queue referenced
+// by name, which is either a format name or a path name, configured by the
+// options.
+func NewCoordinator(name string, opts ...CoordinatorOption) (*Coordinator, error) {
+	host, _ := os.Hostname()
+	options := &coordinatorOptions{
+		mode:     Exclusive,
+		instance: host + ":" + strconv.Itoa(os.Getpid()),
+		lease:    5 * time.Second,
+	}
+	for _, o := range opts {
+		o.set(options)
+	}
+	if options.lease <= 0 {
+		return nil, fmt.Errorf("go-msmq: NewCoordinator(%s) failed to create coordinator: %w", name, invalidOption("CoordinatorWithLease", options.lease, "must be positive"))
+	}
+
+	return &Coordinator{
+		name:    name,
+		options: options,
+	}, nil
+}
+
+// Instance returns the name identifying the process. The default is the
+// host name and the process identifier.
+func (c *Coordinator) Instance() string {
+	return c.options.instance
+}
+
+// Active reports whether the process currently consumes the queue.
+func (c *Coordinator) Active() bool {
+	return c.active.Load()
+}
+
+// Run competes for the queue and consumes it with a Consumer passing the
+// messages to handler, until ctx is done, and returns nil, or until the queue
+// cannot be consumed, and returns the error. Losing the lease on the queue is
+// not an error: the Coordinator competes for the queue again.
+func (c *Coordinator) Run(ctx context.Context, handler Handler) error {
+	for {
+		q, err := c.acquire(ctx)
+		if err != nil {
+			return fmt.Errorf("go-msmq: Run() failed to open queue %s: %w", c.name, err)
+		}
+		if q == nil {
+			return nil
+		}
+
+		c.active.Store(true)
+		if c.options.onAcquire != nil {
+			c.options.onAcquire()
+		}
+
+		err = c.consume(ctx, q, handler)
+		q.Close()
+
+		c.active.Store(false)
+		if c.options.onRelease != nil {
+			c.options.onRelease()
+		}
+
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil && !errors.Is(err, errLeaseLost) {
+			return fmt.Errorf("go-msmq: Run() failed to consume queue %s: %w", c.name, err)
+		}
+	}
+}
+
+// acquire opens the queue, retrying every lease interval while it is held by
+// another process or fails with a transient error. It returns a nil Queue if
+// ctx is done first.
+func (c *Coordinator) acquire(ctx context.Context) (*Queue, error) {
+	shareMode := DenyNone
+	if c.options.mode == Exclusive {
+		shareMode = DenyReceive
+	}
+
+	for ctx.Err() == nil {
+		q, err := Open(c.name, Options{AccessMode: Receive, ShareMode: shareMode})
+		if err == nil {
+			return q, nil
+		}
+		if !errors.Is(err, ErrSharingViolation) && !IsTransient(err) {
+			return nil, err
+		}
+
+		if !sleep(ctx, c.options.lease) {
+			break
+		}
+	}
+
+	return nil, nil
+}
+
+// consume consumes q until ctx is done or the lease on q is lost.
+func (c *Coordinator) consume(ctx context.Context, q *Queue, handler Handler) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var lost atomic.Bool
+	go func() {
+		ticker := time.NewTicker(c.options.lease)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			if open, err := q.IsOpen(); err != nil || !open {
+				lost.Store(true)
+				cancel()
+				return
+			}
+		}
+	}()
+
+	opts := append([]ConsumerOption{ConsumerWithName(c.options.instance)}, c.options.consumerOptions...)
+	err := NewConsumer(q, opts...).Start(ctx, handler)
+	if lost.Load() {
+		return errLeaseLost
+	}
+
+	return err
+}
+
+// CoordinatorOption represents an option to configure a Coordinator.
+type CoordinatorOption struct {
+	set func(opts *coordinatorOptions)
+}
+
+// coordinatorOptions contains all the options to configure a Coordinator.
+type coordinatorOptions struct {
+	mode            CoordinationMode
+	instance        string
+	lease           time.Duration
+	consumerOptions []ConsumerOption
+	onAcquire       func()
+	onRelease       func()
+}
+
+// CoordinatorWithMode returns a CoordinatorOption that configures how the
+// processes share the queue. The default is Exclusive.
+func CoordinatorWithMode(mode CoordinationMode) CoordinatorOption {
+	return CoordinatorOption{
+		set: func(opts *coordinatorOptions) {
+			opts.mode = mode
+		},
+	}
+}
+
+// CoordinatorWithInstance returns a CoordinatorOption that configures the
+// name identifying the process. It is used as the name of the Consumer unless
+// ConsumerWithName is passed with CoordinatorWithConsumerOptions. The default
+// is the host name and the process identifier.
+func CoordinatorWithInstance(instance string) CoordinatorOption {
+	return CoordinatorOption{
+		set: func(opts *coordinatorOptions) {
+			opts.instance = instance
+		},
+	}
+}
+
+// CoordinatorWithLease returns a CoordinatorOption that configures how often
+// a standby process tries to take over the queue and the active process
+// renews its lease. The interval must be positive. The default is 5 seconds.
+func CoordinatorWithLease(interval time.Duration) CoordinatorOption {
+	return CoordinatorOption{
+		set: func(opts *coordinatorOptions) {
+			opts.lease = interval
+		},
+	}
+}
+
+// CoordinatorWithConsumerOptions returns a CoordinatorOption that configures
+// the Consumer of the queue, for example its workers and transactions.
+func CoordinatorWithConsumerOptions(opts ...ConsumerOption) CoordinatorOption {
+	return CoordinatorOption{
+		set: func(o *coordinatorOptions) {
+			o.consumerOptions = append(o.consumerOptions, opts...)
+		},
+	}
+}
+
+// CoordinatorWithOnAcquire returns a CoordinatorOption that configures the
+// callback invoked when the process starts consuming the queue. The default
+// is no callback.
+func CoordinatorWithOnAcquire(fn func()) CoordinatorOption {
+	return CoordinatorOption{
+		set: func(opts *coordinatorOptions) {
+			opts.onAcquire = fn
+		},
+	}
+}
+
+// CoordinatorWithOnRelease returns a CoordinatorOption that configures the
+// callback invoked when the process stops consuming the queue, because ctx is
+// done or the lease was lost. The default is no callback.
+func CoordinatorWithOnRelease(fn func()) CoordinatorOption {
+	return CoordinatorOption{
+		set: func(opts *coordinatorOptions) {
+			opts.onRelease = fn
+		},
+	}
+}
@@ -0,0 +1,321 @@
+// Package config defines queues, and the consumers and producers that use
+// them, in configuration rather than code, for deployments that keep their
+// settings in files and the environment:
+//   {
+//       "queues": {
+//           "orders": {
+//               "path": ".\\private$\\orders",
+//               "access": "Receive",
+//               "transactional": true,
+//               "create": true,
+//               "consumer": {"workers": 8, "receiveTimeout": "5s"}
+//           }
+//       }
+//   }
+//
+// Load reads a JSON file, and Config.ApplyEnv overrides it from environment
+// variables:
+//   cfg, err := config.Load("msmq.json")
+//   ...
+//   err = cfg.ApplyEnv("MSMQ")
+//   ...
+//   orders, err := cfg.Queue("orders")
+//   ...
+//   queue, err := orders.Open()
+//   ...
+//   consumer := orders.NewConsumer(queue, msmq.ConsumerWithOnError(logError))
+//
+// The types also carry yaml tags, and enums and durations implement
+// encoding.TextUnmarshaler, so that YAML libraries such as gopkg.in/yaml.v3
+// can decode a Config directly.
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/jandauz/go-msmq"
+)
+
+// ErrUnknownQueue is returned by Config.Queue for a queue that is not
+// defined.
+var ErrUnknownQueue = errors.New("config: unknown queue")
+
+// Config contains the queue definitions of an application.
+type Config struct {
+	// Queues are the queues by name. The names are only used to look up the
+	// queues in the configuration.
+	Queues map[string]*Queue `json:"queues" yaml:"queues"`
+}
+
+// Queue defines a queue endpoint and, optionally, the Consumer and Producer
+// that use it.
+type Queue struct {
+	// Path is the path name of the queue, such as `.\private$\orders`.
+	// Either Path or FormatName must be set.
+	Path string `json:"path,omitempty" yaml:"path,omitempty"`
+
+	// FormatName is the format name of the queue, such as
+	// `DIRECT=OS:server\private$\orders`. It takes precedence over Path.
+	FormatName string `json:"formatName,omitempty" yaml:"formatName,omitempty"`
+
+	// Access is the AccessMode the queue is opened with, such as "Send".
+	// The default is Receive.
+	Access msmq.AccessMode `json:"access,omitempty" yaml:"access,omitempty"`
+
+	// Share is the ShareMode the queue is opened with. The default is
+	// DenyNone.
+	Share msmq.ShareMode `json:"share,omitempty" yaml:"share,omitempty"`
+
+	// Transactional is whether the queue is transactional. It applies to
+	// the queue created by Open and to the Consumer and Producer.
+	Transactional bool `json:"transactional,omitempty" yaml:"transactional,omitempty"`
+
+	// Create is whether Open creates the queue at Path if it does not
+	// exist.
+	Create bool `json:"create,omitempty" yaml:"create,omitempty"`
+
+	// Retry retries opening the queue. The default is not to retry.
+	Retry *Retry `json:"retry,omitempty" yaml:"retry,omitempty"`
+
+	// Consumer configures the Consumer returned by NewConsumer.
+	Consumer *Consumer `json:"consumer,omitempty" yaml:"consumer,omitempty"`
+
+	// Producer configures the Producer returned by NewProducer.
+	Producer *Producer `json:"producer,omitempty" yaml:"producer,omitempty"`
+}
+
+// Consumer configures an msmq.Consumer. Zero fields keep the defaults of
+// the msmq package.
+type Consumer struct {
+	Workers        int      `json:"workers,omitempty" yaml:"workers,omitempty"`
+	ReceiveTimeout Duration `json:"receiveTimeout,omitempty" yaml:"receiveTimeout,omitempty"`
+	Retry          *Retry   `json:"retry,omitempty" yaml:"retry,omitempty"`
+}
+
+// Producer configures an msmq.Producer. Zero fields keep the defaults of
+// the msmq package.
+type Producer struct {
+	Workers    int    `json:"workers,omitempty" yaml:"workers,omitempty"`
+	BufferSize int    `json:"bufferSize,omitempty" yaml:"bufferSize,omitempty"`
+	BatchSize  int    `json:"batchSize,omitempty" yaml:"batchSize,omitempty"`
+	Retry      *Retry `json:"retry,omitempty" yaml:"retry,omitempty"`
+}
+
+// Retry configures an msmq.RetryPolicy. Zero fields keep the defaults of
+// msmq.RetryPolicy.
+type Retry struct {
+	MaxAttempts    int      `json:"maxAttempts,omitempty" yaml:"maxAttempts,omitempty"`
+	InitialBackoff Duration `json:"initialBackoff,omitempty" yaml:"initialBackoff,omitempty"`
+	MaxBackoff     Duration `json:"maxBackoff,omitempty" yaml:"maxBackoff,omitempty"`
+	Multiplier     float64  `json:"multiplier,omitempty" yaml:"multiplier,omitempty"`
+	Jitter         float64  `json:"jitter,omitempty" yaml:"jitter,omitempty"`
+}
+
+// Policy returns the msmq.RetryPolicy configured by r, or nil if r is nil.
+func (r *Retry) Policy() *msmq.RetryPolicy {
+	if r == nil {
+		return nil
+	}
+
+	return &msmq.RetryPolicy{
+		MaxAttempts:    r.MaxAttempts,
+		InitialBackoff: time.Duration(r.InitialBackoff),
+		MaxBackoff:     time.Duration(r.MaxBackoff),
+		Multiplier:     r.Multiplier,
+		Jitter:         r.Jitter,
+	}
+}
+
+// Duration is a time.Duration that is written as a string such as "1m30s",
+// as accepted by time.ParseDuration.
+type Duration time.Duration
+
+// MarshalText implements encoding.TextMarshaler.
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (d *Duration) UnmarshalText(text []byte) error {
+	v, err := time.ParseDuration(string(text))
+	if err != nil {
+		return fmt.Errorf("config: invalid duration: %w", err)
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// Load returns the Config read from the JSON file at path.
+func Load(path string) (*Config, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("config: Load(%s) failed to open file: %w", path, err)
+	}
+	defer f.Close()
+
+	cfg, err := Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("config: Load(%s) failed: %w", path, err)
+	}
+
+	return cfg, nil
+}
+
+// Decode returns the Config read from the JSON document in r. Unknown fields
+// are rejected, so that misspelled settings are not silently ignored.
+func Decode(r io.Reader) (*Config, error) {
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+
+	var cfg Config
+	if err := dec.Decode(&cfg); err != nil {
+		return nil, fmt.Errorf("config: Decode() failed to decode JSON: %w", err)
+	}
+
+	return &cfg, nil
+}
+
+// Queue returns the queue defined as name.
+func (c *Config) Queue(name string) (*Queue, error) {
+	q, ok := c.Queues[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownQueue, name)
+	}
+
+	return q, nil
+}
+
+// name returns the format name of q, or its path name.
+func (q *Queue) name() (string, error) {
+	switch {
+	case q.FormatName != "":
+		return q.FormatName, nil
+	case q.Path != "":
+		return q.Path, nil
+	default:
+		return "", errors.New("config: queue has neither path nor formatName")
+	}
+}
+
+// QueueInfo returns a pointer to a QueueInfo of the queue.
+func (q *Queue) QueueInfo() (*msmq.QueueInfo, error) {
+	if q.FormatName != "" {
+		return msmq.NewQueueInfo(msmq.WithFormatName(q.FormatName))
+	}
+	if q.Path != "" {
+		return msmq.NewQueueInfo(msmq.WithPathName(q.Path))
+	}
+
+	_, err := q.name()
+	return nil, err
+}
+
+// Open opens the queue, after creating it if Create is set and it does not
+// exist.
+func (q *Queue) Open() (*msmq.Queue, error) {
+	name, err := q.name()
+	if err != nil {
+		return nil, err
+	}
+
+	if q.Create {
+		if err := q.create(); err != nil {
+			return nil, fmt.Errorf("config: Open(%s) failed to create queue: %w", name, err)
+		}
+	}
+
+	queue, err := msmq.Open(name, msmq.Options{
+		AccessMode: q.Access,
+		ShareMode:  q.Share,
+		Retry:      q.Retry.Policy(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("config: Open(%s) failed: %w", name, err)
+	}
+
+	return queue, nil
+}
+
+// create creates the queue at Path unless it exists.
+func (q *Queue) create() error {
+	if q.Path == "" {
+		return errors.New("config: create requires a path")
+	}
+
+	qi, err := msmq.NewQueueInfo(msmq.WithPathName(q.Path))
+	if err != nil {
+		return err
+	}
+	defer qi.Close()
+
+	err = qi.Create(msmq.CreateQueueWithTransactional(q.Transactional))
+	if err != nil && !errors.Is(err, msmq.ErrQueueExists) {
+		return err
+	}
+
+	return nil
+}
+
+// ConsumerOptions returns the options configured by the Consumer settings of
+// the queue.
+func (q *Queue) ConsumerOptions() []msmq.ConsumerOption {
+	opts := []msmq.ConsumerOption{msmq.ConsumerWithTransactional(q.Transactional)}
+	c := q.Consumer
+	if c == nil {
+		return opts
+	}
+
+	if c.Workers > 0 {
+		opts = append(opts, msmq.ConsumerWithWorkers(c.Workers))
+	}
+	if c.ReceiveTimeout > 0 {
+		opts = append(opts, msmq.ConsumerWithReceiveTimeout(time.Duration(c.ReceiveTimeout)))
+	}
+	if c.Retry != nil {
+		opts = append(opts, msmq.ConsumerWithRetry(c.Retry.Policy()))
+	}
+
+	return opts
+}
+
+// NewConsumer returns a pointer to a Consumer of queue, opened from q,
+// configured by the Consumer settings of q followed by opts.
+func (q *Queue) NewConsumer(queue *msmq.Queue, opts ...msmq.ConsumerOption) *msmq.Consumer {
+	return msmq.NewConsumer(queue, append(q.ConsumerOptions(), opts...)...)
+}
+
+// ProducerOptions returns the options configured by the Producer settings of
+// the queue.
+func (q *Queue) ProducerOptions() []msmq.ProducerOption {
+	opts := []msmq.ProducerOption{msmq.ProducerWithTransactional(q.Transactional)}
+	p := q.Producer
+	if p == nil {
+		return opts
+	}
+
+	if p.Workers > 0 {
+		opts = append(opts, msmq.ProducerWithWorkers(p.Workers))
+	}
+	if p.BufferSize > 0 {
+		opts = append(opts, msmq.ProducerWithBufferSize(p.BufferSize))
+	}
+	if p.BatchSize > 0 {
+		opts = append(opts, msmq.ProducerWithBatchSize(p.BatchSize))
+	}
+	if p.Retry != nil {
+		opts = append(opts, msmq.ProducerWithSendOptions(msmq.SendWithRetry(p.Retry.Policy())))
+	}
+
+	return opts
+}
+
+// NewProducer returns a pointer to a Producer for queue, opened from q,
+// configured by the Producer settings of q followed by opts.
+func (q *Queue) NewProducer(queue *msmq.Queue, opts ...msmq.ProducerOption) *msmq.Producer {
+	return msmq.NewProducer(queue, append(q.ProducerOptions(), opts...)...)
+}
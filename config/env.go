@@ -0,0 +1,144 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// envFields are the settings of a queue that can be set from the
+// environment, by the suffix of their variable.
+var envFields = []struct {
+	suffix string
+	set    func(q *Queue, v string) error
+}{
+	{"PATH", func(q *Queue, v string) error {
+		q.Path = v
+		return nil
+	}},
+	{"FORMAT_NAME", func(q *Queue, v string) error {
+		q.FormatName = v
+		return nil
+	}},
+	{"ACCESS", func(q *Queue, v string) error {
+		return q.Access.UnmarshalText([]byte(v))
+	}},
+	{"SHARE", func(q *Queue, v string) error {
+		return q.Share.UnmarshalText([]byte(v))
+	}},
+	{"TRANSACTIONAL", func(q *Queue, v string) (err error) {
+		q.Transactional, err = strconv.ParseBool(v)
+		return err
+	}},
+	{"CREATE", func(q *Queue, v string) (err error) {
+		q.Create, err = strconv.ParseBool(v)
+		return err
+	}},
+	{"RETRY_MAX_ATTEMPTS", func(q *Queue, v string) (err error) {
+		if q.Retry == nil {
+			q.Retry = &Retry{}
+		}
+		q.Retry.MaxAttempts, err = strconv.Atoi(v)
+		return err
+	}},
+	{"CONSUMER_WORKERS", func(q *Queue, v string) (err error) {
+		q.Consumer = consumerOf(q)
+		q.Consumer.Workers, err = strconv.Atoi(v)
+		return err
+	}},
+	{"CONSUMER_RECEIVE_TIMEOUT", func(q *Queue, v string) error {
+		q.Consumer = consumerOf(q)
+		return q.Consumer.ReceiveTimeout.UnmarshalText([]byte(v))
+	}},
+	{"PRODUCER_WORKERS", func(q *Queue, v string) (err error) {
+		q.Producer = producerOf(q)
+		q.Producer.Workers, err = strconv.Atoi(v)
+		return err
+	}},
+	{"PRODUCER_BUFFER_SIZE", func(q *Queue, v string) (err error) {
+		q.Producer = producerOf(q)
+		q.Producer.BufferSize, err = strconv.Atoi(v)
+		return err
+	}},
+	{"PRODUCER_BATCH_SIZE", func(q *Queue, v string) (err error) {
+		q.Producer = producerOf(q)
+		q.Producer.BatchSize, err = strconv.Atoi(v)
+		return err
+	}},
+}
+
+// consumerOf returns the Consumer settings of q, or new ones.
+func consumerOf(q *Queue) *Consumer {
+	if q.Consumer == nil {
+		return &Consumer{}
+	}
+	return q.Consumer
+}
+
+// producerOf returns the Producer settings of q, or new ones.
+func producerOf(q *Queue) *Producer {
+	if q.Producer == nil {
+		return &Producer{}
+	}
+	return q.Producer
+}
+
+// ApplyEnv overrides the settings of the queues with the environment
+// variables named prefix_QUEUE_SETTING, and defines the queues that are only
+// in the environment:
+//   MSMQ_ORDERS_PATH=.\private$\orders
+//   MSMQ_ORDERS_ACCESS=Receive
+//   MSMQ_ORDERS_CONSUMER_WORKERS=8
+//   MSMQ_ORDERS_CONSUMER_RECEIVE_TIMEOUT=5s
+// QUEUE matches the name of a queue ignoring case; a new queue is named in
+// lower case. The settings are PATH, FORMAT_NAME, ACCESS, SHARE,
+// TRANSACTIONAL, CREATE, RETRY_MAX_ATTEMPTS, CONSUMER_WORKERS,
+// CONSUMER_RECEIVE_TIMEOUT, PRODUCER_WORKERS, PRODUCER_BUFFER_SIZE and
+// PRODUCER_BATCH_SIZE.
+func (c *Config) ApplyEnv(prefix string) error {
+	return c.applyEnv(prefix, os.Environ())
+}
+
+// applyEnv applies the variables of env, in the form key=value.
+func (c *Config) applyEnv(prefix string, env []string) error {
+	prefix = strings.ToUpper(prefix) + "_"
+	for _, kv := range env {
+		key, value, ok := strings.Cut(kv, "=")
+		if !ok || !strings.HasPrefix(strings.ToUpper(key), prefix) {
+			continue
+		}
+
+		rest := strings.ToUpper(key[len(prefix):])
+		for _, f := range envFields {
+			name, ok := strings.CutSuffix(rest, "_"+f.suffix)
+			if !ok || name == "" {
+				continue
+			}
+
+			if err := f.set(c.queue(name), value); err != nil {
+				return fmt.Errorf("config: ApplyEnv() failed to apply %s: %w", key, err)
+			}
+			break
+		}
+	}
+
+	return nil
+}
+
+// queue returns the queue whose name matches name ignoring case, after
+// defining it if there is none.
+func (c *Config) queue(name string) *Queue {
+	for n, q := range c.Queues {
+		if strings.EqualFold(n, name) {
+			return q
+		}
+	}
+
+	if c.Queues == nil {
+		c.Queues = make(map[string]*Queue)
+	}
+	q := &Queue{}
+	c.Queues[strings.ToLower(name)] = q
+	return q
+}
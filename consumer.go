@@ -0,0 +1,347 @@
+package msmq
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Handler processes a message received by a Consumer. The message is only
+// valid until the handler returns.
+type Handler func(ctx context.Context, msg Message) error
+
+// Consumer receives messages from a queue with a pool of workers and passes
+// each message to a Handler. It is the receive loop of a service:
+//   consumer := msmq.NewConsumer(queue,
+//       msmq.ConsumerWithWorkers(8),
+//       msmq.ConsumerWithTransactional(true),
+//       msmq.ConsumerWithRetry(&msmq.RetryPolicy{MaxAttempts: 5}),
+//   )
+//   err := consumer.Start(ctx, func(ctx context.Context, msg msmq.Message) error {
+//       ...
+//   })
+//
+// With ConsumerWithTransactional, each message is received in its own
+// internal transaction, which is committed when the handler succeeds and
+// aborted when it fails, returning the message to the queue until a
+// PoisonPolicy quarantines it. Otherwise the message is removed from the
+// queue when it is received, and a message whose handler fails is passed to
+// the callback set with ConsumerWithOnError.
+type Consumer struct {
+	queue   *Queue
+	options *consumerOptions
+}
+
+// NewConsumer returns a pointer to a Consumer of queue configured by the
+// options. The queue must be opened with Receive AccessMode and must not be
+// closed before the Consumer stops. It can be a MessageQueue wrapped with
+// WrapMessageQueue, such as an in-memory queue of the msmqfake package.
+func NewConsumer(queue *Queue, opts ...ConsumerOption) *Consumer {
+	options := &consumerOptions{
+		workers:        1,
+		receiveTimeout: time.Second,
+	}
+	for _, o := range opts {
+		o.set(options)
+	}
+	if options.workers < 1 {
+		options.workers = 1
+	}
+	if options.priority != nil && options.workers < 2 {
+		// Each band needs at least one worker.
+		options.workers = 2
+	}
+
+	return &Consumer{
+		queue:   queue,
+		options: options,
+	}
+}
+
+// Start runs the workers of the Consumer and blocks until ctx is done or a
+// worker fails to receive a message. When ctx is done, the workers stop
+// receiving, the messages being handled are allowed to complete, and Start
+// returns nil. The context passed to handler is not cancelled with ctx, so
+// that in-flight messages drain gracefully.
+//
+// Receive errors that are transient are retried with the backoff of the
+// retry policy. Any other receive error stops every worker and is returned.
+func (c *Consumer) Start(ctx context.Context, handler Handler) error {
+	handler = Chain(handler, c.options.middleware...)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+	)
+	for i := 0; i < c.options.workers; i++ {
+		receive := c.receive
+		if c.options.priority != nil {
+			receive = c.bandReceiver(i).receive
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			err := c.work(ctx, handler, receive)
+			if err != nil {
+				errOnce.Do(func() {
+					firstErr = err
+					cancel()
+				})
+			}
+		}()
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return fmt.Errorf("go-msmq: Start() failed to consume messages: %w", firstErr)
+	}
+
+	return nil
+}
+
+// work receives messages with receive and handles them until ctx is done.
+func (c *Consumer) work(ctx context.Context, handler Handler, receive receiveFunc) error {
+	receiveFailures := 0
+	for ctx.Err() == nil {
+		err := c.next(ctx, handler, receive)
+		if err == nil {
+			receiveFailures = 0
+			continue
+		}
+
+		if !IsTransient(err) {
+			return err
+		}
+
+		receiveFailures++
+		if !sleep(ctx, c.options.retry.backoff(receiveFailures)) {
+			return nil
+		}
+	}
+
+	return nil
+}
+
+// receiveFunc receives the next message for a worker, in tx unless tx is nil,
+// waiting up to the receive timeout. The returned message is empty if the
+// timeout expires.
+type receiveFunc func(ctx context.Context, tx *Transaction) (Message, error)
+
+// receive is the receiveFunc of the workers that accept any message.
+func (c *Consumer) receive(ctx context.Context, tx *Transaction) (Message, error) {
+	timeout := int(c.options.receiveTimeout / time.Millisecond)
+	if tx == nil {
+		return c.queue.Receive(ReceiveWithTransaction(NoTransaction), ReceiveWithTimeout(timeout))
+	}
+
+	return c.queue.Receive(ReceiveInTransaction(tx), ReceiveWithTimeout(timeout))
+}
+
+// next receives a message with receive and handles it. An error is only
+// returned if the message could not be received.
+func (c *Consumer) next(ctx context.Context, handler Handler, receive receiveFunc) error {
+	if !c.options.transactional {
+		msg, err := receive(ctx, nil)
+		if err != nil || msg.IsZero() {
+			return err
+		}
+		defer msg.release()
+
+		if err := c.handle(ctx, handler, msg); err != nil {
+			c.fail(msg, err)
+		}
+		return nil
+	}
+
+	tx, err := BeginTransaction()
+	if err != nil {
+		return err
+	}
+
+	msg, err := receive(ctx, tx)
+	if err != nil || msg.IsZero() {
+		tx.Abort()
+		return err
+	}
+	defer msg.release()
+
+	if err := c.handle(context.WithValue(ctx, transactionKey{}, tx), handler, msg); err != nil {
+		c.reject(tx, msg, err)
+		return nil
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.fail(msg, err)
+		return nil
+	}
+	c.succeeded(msg)
+	return nil
+}
+
+// handle passes msg to handler, retrying according to the retry policy, and
+// returns the last error of handler. Retries stop when ctx is done.
+func (c *Consumer) handle(ctx context.Context, handler Handler, msg Message) error {
+	policy := c.options.retry
+	attempts := 1
+	retryable := func(error) bool { return true }
+	if policy != nil {
+		attempts = policy.MaxAttempts
+		if attempts <= 0 {
+			attempts = 3
+		}
+		if policy.Retryable != nil {
+			retryable = policy.Retryable
+		}
+	}
+
+	handlerCtx := context.WithoutCancel(ctx)
+	var err error
+	for attempt := 1; ; attempt++ {
+		start := time.Now()
+		err = handler(handlerCtx, msg)
+		observeHandling(Handling{
+			Queue:    c.queue.formatName(),
+			Handler:  c.options.name,
+			Duration: time.Since(start),
+			Retries:  attempt - 1,
+			Err:      err,
+		})
+		if err == nil || attempt >= attempts || !retryable(err) {
+			return err
+		}
+
+		if !sleep(ctx, policy.backoff(attempt)) {
+			return err
+		}
+	}
+}
+
+// transactionKey is the context key of the transaction of the message being
+// handled.
+type transactionKey struct{}
+
+// TransactionFromContext returns the internal transaction in which a
+// transactional Consumer received the message being handled, so that the
+// handler can send messages in the same transaction:
+//   if tx, ok := msmq.TransactionFromContext(ctx); ok {
+//       err = reply.Send(queue, msmq.SendInTransaction(tx))
+//   }
+// The messages are then only sent if the message is consumed. The handler
+// must not commit or abort the transaction.
+func TransactionFromContext(ctx context.Context) (*Transaction, bool) {
+	tx, ok := ctx.Value(transactionKey{}).(*Transaction)
+	return tx, ok
+}
+
+// fail reports that msg could not be handled.
+func (c *Consumer) fail(msg Message, err error) {
+	if c.options.onError != nil {
+		c.options.onError(msg, err)
+	}
+}
+
+// sleep waits for d or until ctx is done, and reports whether it waited for
+// d.
+func sleep(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// ConsumerOption represents an option to configure a Consumer.
+type ConsumerOption struct {
+	set func(opts *consumerOptions)
+}
+
+// consumerOptions contains all the options to configure a Consumer.
+type consumerOptions struct {
+	name           string
+	workers        int
+	transactional  bool
+	receiveTimeout time.Duration
+	retry          *RetryPolicy
+	poison         *PoisonPolicy
+	priority       *PriorityBands
+	middleware     []Middleware
+	onError        func(msg Message, err error)
+}
+
+// ConsumerWithName returns a ConsumerOption that configures the name of the
+// handler of the Consumer, which identifies it in the metrics reported to
+// HandlerObservers. The default is no name.
+func ConsumerWithName(name string) ConsumerOption {
+	return ConsumerOption{
+		set: func(opts *consumerOptions) {
+			opts.name = name
+		},
+	}
+}
+
+// ConsumerWithWorkers returns a ConsumerOption that configures the number of
+// messages handled concurrently. The default is 1.
+func ConsumerWithWorkers(workers int) ConsumerOption {
+	return ConsumerOption{
+		set: func(opts *consumerOptions) {
+			opts.workers = workers
+		},
+	}
+}
+
+// ConsumerWithTransactional returns a ConsumerOption that configures whether
+// each message is received and handled in its own internal transaction. The
+// queue must be transactional. The default is false.
+func ConsumerWithTransactional(transactional bool) ConsumerOption {
+	return ConsumerOption{
+		set: func(opts *consumerOptions) {
+			opts.transactional = transactional
+		},
+	}
+}
+
+// ConsumerWithReceiveTimeout returns a ConsumerOption that configures how
+// long a worker waits for a message before checking whether the Consumer is
+// stopping. The default is 1 second.
+func ConsumerWithReceiveTimeout(timeout time.Duration) ConsumerOption {
+	return ConsumerOption{
+		set: func(opts *consumerOptions) {
+			opts.receiveTimeout = timeout
+		},
+	}
+}
+
+// ConsumerWithRetry returns a ConsumerOption that configures how a message
+// is retried when its handler fails. Every handler error is retried unless
+// the Retryable field of policy is set. The backoff of policy is also used
+// between transient receive failures. The default is not to retry.
+func ConsumerWithRetry(policy *RetryPolicy) ConsumerOption {
+	return ConsumerOption{
+		set: func(opts *consumerOptions) {
+			opts.retry = policy
+		},
+	}
+}
+
+// ConsumerWithOnError returns a ConsumerOption that configures the callback
+// invoked with a message whose handler failed after its retries, and with
+// the last error. The callback is also invoked when the transaction of the
+// message cannot be committed or aborted. The default is to drop the error.
+func ConsumerWithOnError(fn func(msg Message, err error)) ConsumerOption {
+	return ConsumerOption{
+		set: func(opts *consumerOptions) {
+			opts.onError = fn
+		},
+	}
+}
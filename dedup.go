@@ -0,0 +1,398 @@
+package msmq
+
+import (
+	"bufio"
+	"container/list"
+	"context"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DedupStore records the keys of the messages that were handled, so that
+// DedupMiddleware can skip redeliveries. Implementations must be safe for
+// concurrent use.
+type DedupStore interface {
+	// Contains reports whether key was added and has not expired.
+	Contains(ctx context.Context, key string) (bool, error)
+
+	// Add records key until ttl elapses.
+	Add(ctx context.Context, key string, ttl time.Duration) error
+}
+
+// DedupMiddleware returns a Middleware that makes a handler idempotent under
+// at-least-once delivery, for example when a transactional Consumer
+// redelivers a message after its transaction aborted. Messages whose key is
+// in store are acknowledged without calling the handler, and the key of a
+// message is added to store once the handler succeeds:
+//   consumer := msmq.NewConsumer(queue, msmq.ConsumerWithMiddleware(
+//       msmq.DedupMiddleware(msmq.NewMemoryDedupStore(100000)),
+//   ))
+//
+// The key of a message is its ID by default. A message handled concurrently
+// by two workers, or whose key could not be added after it was handled, may
+// still be handled twice.
+func DedupMiddleware(store DedupStore, opts ...DedupOption) Middleware {
+	options := &dedupOptions{
+		ttl: 24 * time.Hour,
+		key: MessageIDKey,
+	}
+	for _, o := range opts {
+		o.set(options)
+	}
+
+	return func(next Handler) Handler {
+		return func(ctx context.Context, msg Message) error {
+			key, err := options.key(msg)
+			if err != nil {
+				return fmt.Errorf("go-msmq: failed to get deduplication key: %w", err)
+			}
+
+			seen, err := store.Contains(ctx, key)
+			if err != nil {
+				return fmt.Errorf("go-msmq: failed to look up deduplication key: %w", err)
+			}
+			if seen {
+				return nil
+			}
+
+			if err := next(ctx, msg); err != nil {
+				return err
+			}
+
+			if err := store.Add(ctx, key, options.ttl); err != nil {
+				return fmt.Errorf("go-msmq: failed to record deduplication key: %w", err)
+			}
+			return nil
+		}
+	}
+}
+
+// MessageIDKey returns the ID of msg in hexadecimal. It is the default key
+// of DedupMiddleware.
+func MessageIDKey(msg Message) (string, error) {
+	id, err := msg.ID()
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(id), nil
+}
+
+// CorrelationIDKey returns the correlation identifier of msg in hexadecimal.
+// It deduplicates messages that are resent by their producer with a new ID
+// but the same correlation identifier.
+func CorrelationIDKey(msg Message) (string, error) {
+	id, err := msg.CorrelationID()
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(id), nil
+}
+
+// DedupOption represents an option to configure DedupMiddleware.
+type DedupOption struct {
+	set func(opts *dedupOptions)
+}
+
+// dedupOptions contains all the options to configure DedupMiddleware.
+type dedupOptions struct {
+	ttl time.Duration
+	key func(Message) (string, error)
+}
+
+// DedupWithTTL returns a DedupOption that configures how long the key of a
+// handled message is remembered. The default is 24 hours.
+func DedupWithTTL(ttl time.Duration) DedupOption {
+	return DedupOption{
+		set: func(opts *dedupOptions) {
+			opts.ttl = ttl
+		},
+	}
+}
+
+// DedupWithKey returns a DedupOption that configures how the key of a message
+// is computed, such as MessageIDKey or CorrelationIDKey. The default is
+// MessageIDKey.
+func DedupWithKey(fn func(Message) (string, error)) DedupOption {
+	return DedupOption{
+		set: func(opts *dedupOptions) {
+			opts.key = fn
+		},
+	}
+}
+
+// MemoryDedupStore is a DedupStore that keeps up to a fixed number of keys in
+// memory, evicting the least recently added key when it is full.
+type MemoryDedupStore struct {
+	capacity int
+
+	mu    sync.Mutex
+	order *list.List
+	keys  map[string]*list.Element
+}
+
+// dedupEntry is a key of a MemoryDedupStore.
+type dedupEntry struct {
+	key     string
+	expires time.Time
+}
+
+// NewMemoryDedupStore returns a pointer to a MemoryDedupStore that holds up
+// to capacity keys.
+func NewMemoryDedupStore(capacity int) *MemoryDedupStore {
+	return &MemoryDedupStore{
+		capacity: capacity,
+		order:    list.New(),
+		keys:     make(map[string]*list.Element),
+	}
+}
+
+// Contains implements DedupStore.
+func (s *MemoryDedupStore) Contains(ctx context.Context, key string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.keys[key]
+	if !ok {
+		return false, nil
+	}
+
+	if time.Now().After(e.Value.(*dedupEntry).expires) {
+		s.order.Remove(e)
+		delete(s.keys, key)
+		return false, nil
+	}
+
+	return true, nil
+}
+
+// Add implements DedupStore.
+func (s *MemoryDedupStore) Add(ctx context.Context, key string, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e, ok := s.keys[key]; ok {
+		e.Value.(*dedupEntry).expires = time.Now().Add(ttl)
+		s.order.MoveToBack(e)
+		return nil
+	}
+
+	s.keys[key] = s.order.PushBack(&dedupEntry{key: key, expires: time.Now().Add(ttl)})
+	for s.capacity > 0 && s.order.Len() > s.capacity {
+		oldest := s.order.Front()
+		s.order.Remove(oldest)
+		delete(s.keys, oldest.Value.(*dedupEntry).key)
+	}
+
+	return nil
+}
+
+// FileDedupStore is a DedupStore that persists its keys to a file, so that
+// they survive restarts of the process. The keys are also kept in memory.
+// Each added key is appended to the file, which is compacted when the store
+// is opened.
+type FileDedupStore struct {
+	mu   sync.Mutex
+	file *os.File
+	keys map[string]time.Time
+}
+
+// OpenFileDedupStore returns a pointer to a FileDedupStore backed by the file
+// at path, which is created if it does not exist. Expired keys are dropped
+// from the file.
+func OpenFileDedupStore(path string) (*FileDedupStore, error) {
+	keys := make(map[string]time.Time)
+
+	f, err := os.Open(path)
+	switch {
+	case err == nil:
+		now := time.Now()
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			key, expires, ok := strings.Cut(scanner.Text(), "\t")
+			if !ok {
+				continue
+			}
+			ns, err := strconv.ParseInt(expires, 10, 64)
+			if err != nil {
+				continue
+			}
+			if t := time.Unix(0, ns); t.After(now) {
+				keys[key] = t
+			}
+		}
+		f.Close()
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("go-msmq: OpenFileDedupStore(%s) failed to read keys: %w", path, err)
+		}
+
+	case !os.IsNotExist(err):
+		return nil, fmt.Errorf("go-msmq: OpenFileDedupStore(%s) failed to read keys: %w", path, err)
+	}
+
+	if err := compactKeys(path, keys); err != nil {
+		return nil, fmt.Errorf("go-msmq: OpenFileDedupStore(%s) failed to compact keys: %w", path, err)
+	}
+
+	f, err = os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		return nil, fmt.Errorf("go-msmq: OpenFileDedupStore(%s) failed to open keys: %w", path, err)
+	}
+
+	return &FileDedupStore{
+		file: f,
+		keys: keys,
+	}, nil
+}
+
+// compactKeys replaces the file at path with one containing only keys. The
+// keys are written to a temporary file that is renamed over the original
+// once synced, so that a crash leaves either the old or the new keys.
+func compactKeys(path string, keys map[string]time.Time) error {
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o666)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(f)
+	for key, t := range keys {
+		fmt.Fprintf(w, "%s\t%d\n", key, t.UnixNano())
+	}
+	err = w.Flush()
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	return nil
+}
+
+// Contains implements DedupStore.
+func (s *FileDedupStore) Contains(ctx context.Context, key string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.keys[key]
+	if !ok {
+		return false, nil
+	}
+
+	if time.Now().After(t) {
+		delete(s.keys, key)
+		return false, nil
+	}
+
+	return true, nil
+}
+
+// Add implements DedupStore. The key is written to the file before Add
+// returns. Keys must not contain tabs or newlines.
+func (s *FileDedupStore) Add(ctx context.Context, key string, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t := time.Now().Add(ttl)
+	if _, err := fmt.Fprintf(s.file, "%s\t%d\n", key, t.UnixNano()); err != nil {
+		return err
+	}
+	if err := s.file.Sync(); err != nil {
+		return err
+	}
+
+	s.keys[key] = t
+	return nil
+}
+
+// Close closes the file of the store.
+func (s *FileDedupStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.file.Close()
+}
+
+// SQLDedupStore is a DedupStore that keeps its keys in a table of a SQL
+// database, so that they are shared by every process consuming the queue.
+// The table must have a unique key column and an expiry column:
+//   CREATE TABLE msmq_dedup (
+//       dedup_key  VARCHAR(128) PRIMARY KEY,
+//       expires_at BIGINT NOT NULL
+//   )
+type SQLDedupStore struct {
+	// DB is the database holding the table.
+	DB *sql.DB
+
+	// Table is the name of the table.
+	Table string
+
+	// Placeholder returns the placeholder of the nth argument of a
+	// statement, starting at 1. The default returns "?", as used by MySQL
+	// and SQLite; PostgreSQL requires "$1", "$2" and so on.
+	Placeholder func(n int) string
+}
+
+// Contains implements DedupStore.
+func (s *SQLDedupStore) Contains(ctx context.Context, key string) (bool, error) {
+	query := fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE dedup_key = %s AND expires_at > %s", s.Table, s.placeholder(1), s.placeholder(2))
+
+	var n int
+	err := s.DB.QueryRowContext(ctx, query, key, time.Now().UnixNano()).Scan(&n)
+	if err != nil {
+		return false, err
+	}
+
+	return n > 0, nil
+}
+
+// Add implements DedupStore. An existing row for key is replaced.
+func (s *SQLDedupStore) Add(ctx context.Context, key string, ttl time.Duration) error {
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s WHERE dedup_key = %s", s.Table, s.placeholder(1)), key)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, fmt.Sprintf("INSERT INTO %s (dedup_key, expires_at) VALUES (%s, %s)", s.Table, s.placeholder(1), s.placeholder(2)), key, time.Now().Add(ttl).UnixNano())
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Purge deletes the expired keys from the table.
+func (s *SQLDedupStore) Purge(ctx context.Context) error {
+	_, err := s.DB.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s WHERE expires_at <= %s", s.Table, s.placeholder(1)), time.Now().UnixNano())
+	return err
+}
+
+// placeholder returns the placeholder of the nth argument.
+func (s *SQLDedupStore) placeholder(n int) string {
+	if s.Placeholder != nil {
+		return s.Placeholder(n)
+	}
+
+	return "?"
+}
@@ -0,0 +1,80 @@
+package msmq
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// DefaultOptions contains the defaults of the options of Peek, Receive and
+// Send, which the options passed to each call override. SetDefaults replaces
+// all of them, so start from the current defaults:
+//   d := msmq.Defaults()
+//   d.ReceiveTimeout = 5 * time.Second
+//   d.TransactionLevel = msmq.SingleMessage
+//   msmq.SetDefaults(d)
+type DefaultOptions struct {
+	// ReceiveTimeout is how long Peek and Receive wait for a message to
+	// arrive. A negative timeout waits forever. The default is -1.
+	ReceiveTimeout time.Duration
+
+	// TransactionLevel is the transaction level of Send and Receive. The
+	// default is MTS.
+	TransactionLevel TransactionLevel
+
+	// WantBody is whether Peek and Receive retrieve the body of messages.
+	// The default is true.
+	WantBody bool
+
+	// WantDestinationQueue is whether Peek and Receive retrieve the
+	// Message.DestinationQueueInfo of messages. The default is false.
+	WantDestinationQueue bool
+
+	// WantConnectorType is whether Peek and Receive retrieve the connector
+	// type of messages. The default is false.
+	WantConnectorType bool
+
+	// Retry is the retry policy of Send and Receive. The default is nil,
+	// which does not retry.
+	Retry *RetryPolicy
+}
+
+// initialDefaults are the defaults until SetDefaults is called.
+var initialDefaults = DefaultOptions{
+	ReceiveTimeout:   -1,
+	TransactionLevel: MTS,
+	WantBody:         true,
+}
+
+// packageDefaults are the DefaultOptions installed by SetDefaults.
+var packageDefaults atomic.Pointer[DefaultOptions]
+
+// SetDefaults installs d as the DefaultOptions of the package. It affects the
+// calls that start afterwards.
+func SetDefaults(d DefaultOptions) {
+	packageDefaults.Store(&d)
+}
+
+// Defaults returns the DefaultOptions of the package.
+func Defaults() DefaultOptions {
+	return *currentDefaults()
+}
+
+// currentDefaults returns the DefaultOptions of the package.
+func currentDefaults() *DefaultOptions {
+	if d := packageDefaults.Load(); d != nil {
+		return d
+	}
+
+	return &initialDefaults
+}
+
+// timeoutMillis returns the receive timeout of d in milliseconds, as MSMQ
+// expects it.
+func (d *DefaultOptions) timeoutMillis() int {
+	const infinite = 1<<31 - 1
+	if d.ReceiveTimeout < 0 || d.ReceiveTimeout/time.Millisecond > infinite {
+		return infinite
+	}
+
+	return int(d.ReceiveTimeout / time.Millisecond)
+}
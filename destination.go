@@ -0,0 +1,233 @@
+package msmq
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/go-ole/go-ole"
+)
+
+// Destination represents one or more queues that messages can be sent to with
+// a single Message.SendTo. Unlike a Queue, a Destination can reference:
+//   - a multiple-element format name, such as
+//     "DIRECT=OS:host1\private$\orders,DIRECT=OS:host2\private$\orders"
+//   - an Active Directory distribution list, by ADsPath or DL= format name
+// Messages can only be sent to a Destination; it cannot be used to peek at or
+// retrieve messages.
+//
+// See: https://docs.microsoft.com/en-us/previous-versions/windows/desktop/legacy/ms700288(v=vs.85)
+type Destination struct {
+	dispatch *ole.IDispatch
+}
+
+// NewDestination returns a pointer to a Destination. One of the ADsPath,
+// FormatName or PathName must be set before sending messages:
+//   dest, err := msmq.NewDestination(msmq.DestinationWithQueues(orders, audit))
+//   ...
+//   defer dest.Close()
+//   err = msg.SendTo(dest)
+func NewDestination(opts ...DestinationOption) (*Destination, error) {
+	dispatch, err := createObject("MSMQ.MSMQDestination")
+	if err != nil {
+		return nil, err
+	}
+
+	dest := &Destination{
+		dispatch: dispatch,
+	}
+
+	for _, o := range opts {
+		err = o.set(dest)
+		if err != nil {
+			dest.Close()
+			return nil, fmt.Errorf("go-msmq: failed to create new Destination: %w", err)
+		}
+	}
+
+	return dest, nil
+}
+
+// DestinationOption represents an option to configure Destination.
+type DestinationOption struct {
+	set func(d *Destination) error
+}
+
+// DestinationWithADsPath returns a DestinationOption that configures
+// Destination with the specified ADsPath value.
+func DestinationWithADsPath(path string) DestinationOption {
+	return DestinationOption{
+		set: func(d *Destination) error {
+			return d.SetADsPath(path)
+		},
+	}
+}
+
+// DestinationWithFormatName returns a DestinationOption that configures
+// Destination with the specified FormatName value.
+func DestinationWithFormatName(name string) DestinationOption {
+	return DestinationOption{
+		set: func(d *Destination) error {
+			return d.SetFormatName(name)
+		},
+	}
+}
+
+// DestinationWithPathName returns a DestinationOption that configures
+// Destination with the specified PathName value.
+func DestinationWithPathName(name string) DestinationOption {
+	return DestinationOption{
+		set: func(d *Destination) error {
+			return d.SetPathName(name)
+		},
+	}
+}
+
+// DestinationWithQueues returns a DestinationOption that configures
+// Destination with a multiple-element format name made of the FormatName of
+// each of the specified queues.
+func DestinationWithQueues(queues ...*QueueInfo) DestinationOption {
+	return DestinationOption{
+		set: func(d *Destination) error {
+			names := make([]string, 0, len(queues))
+			for _, qi := range queues {
+				name, err := qi.FormatName()
+				if err != nil {
+					return err
+				}
+
+				names = append(names, name)
+			}
+
+			return d.SetFormatName(strings.Join(names, ","))
+		},
+	}
+}
+
+// Open opens the destination for sending messages. Calling Open is optional
+// as the destination is opened by the first Message.SendTo, but it allows
+// errors such as an unknown distribution list to be reported early. Calling
+// Open on an open Destination has no effect.
+//
+// See: https://docs.microsoft.com/en-us/previous-versions/windows/desktop/legacy/ms706032(v=vs.85)
+func (d *Destination) Open() error {
+	open, err := d.IsOpen()
+	if err != nil || open {
+		return err
+	}
+
+	_, err = callMethod(d.dispatch, "Open")
+	if err != nil {
+		return fmt.Errorf("go-msmq: Open() failed to open destination: %w", err)
+	}
+
+	return nil
+}
+
+// Close closes the destination if it is open and releases the underlying
+// destination object. The Destination cannot be used after it is closed.
+// Calling Close more than once has no effect.
+func (d *Destination) Close() error {
+	if d.dispatch == nil {
+		return nil
+	}
+
+	open, err := d.IsOpen()
+	if err == nil && open {
+		_, err = callMethod(d.dispatch, "Close")
+	}
+	if err != nil {
+		return fmt.Errorf("go-msmq: Close() failed to close destination: %w", err)
+	}
+
+	release(d.dispatch)
+	d.dispatch = nil
+	return nil
+}
+
+// Destination satisfies io.Closer so that it can be managed alongside other
+// resources.
+var _ io.Closer = (*Destination)(nil)
+
+// IsOpen returns whether the destination is open.
+func (d *Destination) IsOpen() (bool, error) {
+	if d.dispatch == nil {
+		return false, nil
+	}
+
+	res, err := getProperty(d.dispatch, "IsOpen")
+	if err != nil {
+		return false, fmt.Errorf("go-msmq: IsOpen() failed to get IsOpen: %w", err)
+	}
+	defer res.Clear()
+
+	return variantBool(res, "IsOpen")
+}
+
+// ADsPath returns the Active Directory path of the destination.
+func (d *Destination) ADsPath() (string, error) {
+	res, err := getProperty(d.dispatch, "ADsPath")
+	if err != nil {
+		return "", fmt.Errorf("go-msmq: failed to get ADsPath: %w", err)
+	}
+	defer res.Clear()
+
+	return variantString(res, "ADsPath")
+}
+
+// SetADsPath sets the Active Directory path of the destination, such as the
+// path of a queue, queue alias or distribution list. The destination must be
+// closed.
+func (d *Destination) SetADsPath(path string) error {
+	_, err := putProperty(d.dispatch, "ADsPath", path)
+	if err != nil {
+		return fmt.Errorf("go-msmq: SetADsPath(%s) failed to set ADsPath: %w", path, err)
+	}
+
+	return nil
+}
+
+// FormatName returns the format name of the destination.
+func (d *Destination) FormatName() (string, error) {
+	res, err := getProperty(d.dispatch, "FormatName")
+	if err != nil {
+		return "", fmt.Errorf("go-msmq: failed to get FormatName: %w", err)
+	}
+	defer res.Clear()
+
+	return variantString(res, "FormatName")
+}
+
+// SetFormatName sets the format name of the destination. Unlike QueueInfo,
+// multiple-element format names separated by commas are supported. The
+// destination must be closed.
+func (d *Destination) SetFormatName(name string) error {
+	_, err := putProperty(d.dispatch, "FormatName", name)
+	if err != nil {
+		return fmt.Errorf("go-msmq: SetFormatName(%s) failed to set FormatName: %w", name, err)
+	}
+
+	return nil
+}
+
+// PathName returns the path name of the destination.
+func (d *Destination) PathName() (string, error) {
+	res, err := getProperty(d.dispatch, "PathName")
+	if err != nil {
+		return "", fmt.Errorf("go-msmq: failed to get PathName: %w", err)
+	}
+	defer res.Clear()
+
+	return variantString(res, "PathName")
+}
+
+// SetPathName sets the path name of the destination. The destination must be
+// closed.
+func (d *Destination) SetPathName(name string) error {
+	_, err := putProperty(d.dispatch, "PathName", name)
+	if err != nil {
+		return fmt.Errorf("go-msmq: SetPathName(%s) failed to set PathName: %w", name, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,145 @@
+package msmq
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+	"unsafe"
+
+	"github.com/go-ole/go-ole"
+)
+
+// getProperty gets the property name of dispatch. Errors are returned as
+// *Error.
+func getProperty(dispatch *ole.IDispatch, name string, params ...interface{}) (*ole.VARIANT, error) {
+	return invoke(dispatch, name, ole.DISPATCH_PROPERTYGET, params)
+}
+
+// putProperty sets the property name of dispatch. Errors are returned as
+// *Error.
+func putProperty(dispatch *ole.IDispatch, name string, params ...interface{}) (*ole.VARIANT, error) {
+	return invoke(dispatch, name, ole.DISPATCH_PROPERTYPUT, params)
+}
+
+// putPropertyRef sets the property name of dispatch to a reference to an
+// object. Errors are returned as *Error.
+func putPropertyRef(dispatch *ole.IDispatch, name string, params ...interface{}) (*ole.VARIANT, error) {
+	return invoke(dispatch, name, ole.DISPATCH_PROPERTYPUTREF, params)
+}
+
+// callMethod calls the method name on dispatch. Errors are returned as *Error.
+func callMethod(dispatch *ole.IDispatch, name string, params ...interface{}) (*ole.VARIANT, error) {
+	return invoke(dispatch, name, ole.DISPATCH_METHOD, params)
+}
+
+// callMethodWithOptionalArgs calls the method name on dispatch, passing nil
+// arguments as omitted optional parameters, in the apartment of the package
+// objects. See invokeWithOptionalArgs.
+func callMethodWithOptionalArgs(dispatch *ole.IDispatch, name string, params ...interface{}) (*ole.VARIANT, error) {
+	if dispatch == nil {
+		return nil, fmt.Errorf("%w: %s", ErrNilObject, name)
+	}
+
+	start := time.Now()
+	var res *ole.VARIANT
+	var err error
+	inApartment(func() {
+		res, err = invokeWithOptionalArgs(dispatch, name, params...)
+	})
+	if l := currentTracer(); l != nil {
+		trace(l, dispatch, name, ole.DISPATCH_METHOD, params, start, res, err)
+	}
+
+	return res, err
+}
+
+// invoke invokes the member name of dispatch using the DISPID cached by
+// dispID, in the apartment of the package objects.
+func invoke(dispatch *ole.IDispatch, name string, kind int16, params []interface{}) (*ole.VARIANT, error) {
+	if dispatch == nil {
+		return nil, fmt.Errorf("%w: %s", ErrNilObject, name)
+	}
+
+	start := time.Now()
+	var res *ole.VARIANT
+	var err error
+	inApartment(func() {
+		var dispid int32
+		dispid, err = dispID(dispatch, name)
+		if err != nil {
+			return
+		}
+
+		if len(params) == 0 {
+			res, err = dispatch.Invoke(dispid, kind)
+		} else {
+			res, err = dispatch.Invoke(dispid, kind, params...)
+		}
+	})
+	err = comError(err)
+	if l := currentTracer(); l != nil {
+		trace(l, dispatch, name, kind, params, start, res, err)
+	}
+
+	return res, err
+}
+
+// dispidKey identifies a member of a COM interface. Objects of the same COM
+// class share their vtable, so the vtable pointer identifies the
+// implementation whose DISPIDs are cached.
+type dispidKey struct {
+	vtable uintptr
+	name   string
+}
+
+// dispids caches the DISPIDs resolved by dispID.
+var dispids sync.Map
+
+// dispID returns the DISPID of the member name of dispatch. The DISPID is
+// resolved with GetIDsOfNames once per interface and member, which saves a
+// cross-apartment call for every subsequent invocation.
+func dispID(dispatch *ole.IDispatch, name string) (int32, error) {
+	key := dispidKey{vtable: uintptr(unsafe.Pointer(dispatch.RawVTable)), name: name}
+	if id, ok := dispids.Load(key); ok {
+		return id.(int32), nil
+	}
+
+	id, err := dispatch.GetSingleIDOfName(name)
+	if err != nil {
+		return 0, err
+	}
+
+	dispids.Store(key, id)
+	return id, nil
+}
+
+// variantDate returns t as an OLE Automation date, which is the number of days
+// since midnight, 30 December 1899. Like the dates returned by go-ole, the
+// value represents the local wall clock time of t.
+func variantDate(t time.Time) float64 {
+	t = t.Local()
+	wall := time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+	epoch := time.Date(1899, time.December, 30, 0, 0, 0, 0, time.UTC)
+	return wall.Sub(epoch).Hours() / 24
+}
+
+// hresult returns the HRESULT reported by err. If the COM call failed with an
+// exception, the HRESULT of the exception is returned.
+func hresult(err error) uint32 {
+	var msmqErr *Error
+	if errors.As(err, &msmqErr) {
+		return msmqErr.Code
+	}
+
+	var oleErr *ole.OleError
+	if !errors.As(err, &oleErr) {
+		return 0
+	}
+
+	if excepInfo, ok := oleErr.SubError().(ole.EXCEPINFO); ok && excepInfo.SCODE() != 0 {
+		return excepInfo.SCODE()
+	}
+
+	return uint32(oleErr.Code())
+}
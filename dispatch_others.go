@@ -0,0 +1,11 @@
+// +build !windows
+
+package msmq
+
+import (
+	"github.com/go-ole/go-ole"
+)
+
+func invokeWithOptionalArgs(dispatch *ole.IDispatch, name string, params ...interface{}) (*ole.VARIANT, error) {
+	return nil, ErrUnsupportedPlatform
+}
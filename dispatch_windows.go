@@ -0,0 +1,92 @@
+// +build windows
+
+package msmq
+
+import (
+	"fmt"
+	"math"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"github.com/go-ole/go-ole"
+)
+
+// dispParams mirrors the layout of ole.DISPPARAMS, whose fields are not
+// exported.
+type dispParams struct {
+	rgvarg            uintptr
+	rgdispidNamedArgs uintptr
+	cArgs             uint32
+	cNamedArgs        uint32
+}
+
+// dispEParamNotFound is the DISP_E_PARAMNOTFOUND HRESULT which marks an
+// optional parameter as omitted.
+const dispEParamNotFound = 0x80020004
+
+// dispEException is the DISP_E_EXCEPTION HRESULT which indicates that the
+// actual error is described by the EXCEPINFO returned from Invoke.
+const dispEException = 0x80020009
+
+// invokeWithOptionalArgs calls the method name on dispatch. Unlike
+// ole.IDispatch.CallMethod, a nil argument is passed as an omitted optional
+// parameter instead of VT_NULL, which allows leading optional parameters to be
+// skipped. Only string, int32, time.Time and nil arguments are supported.
+func invokeWithOptionalArgs(dispatch *ole.IDispatch, name string, params ...interface{}) (*ole.VARIANT, error) {
+	dispid, err := dispID(dispatch, name)
+	if err != nil {
+		return nil, comError(err)
+	}
+
+	var dp dispParams
+	vargs := make([]ole.VARIANT, len(params))
+	for i, p := range params {
+		// Arguments are passed in reverse order.
+		n := len(params) - i - 1
+		switch v := p.(type) {
+		case nil:
+			vargs[n] = ole.NewVariant(ole.VT_ERROR, dispEParamNotFound)
+		case string:
+			vargs[n] = ole.NewVariant(ole.VT_BSTR, int64(uintptr(unsafe.Pointer(ole.SysAllocStringLen(v)))))
+			defer ole.VariantClear(&vargs[n])
+		case int32:
+			vargs[n] = ole.NewVariant(ole.VT_I4, int64(v))
+		case time.Time:
+			vargs[n] = ole.NewVariant(ole.VT_DATE, int64(math.Float64bits(variantDate(v))))
+		default:
+			return nil, fmt.Errorf("go-msmq: unsupported argument type %T", p)
+		}
+	}
+
+	if len(vargs) > 0 {
+		dp.rgvarg = uintptr(unsafe.Pointer(&vargs[0]))
+		dp.cArgs = uint32(len(vargs))
+	}
+
+	result := new(ole.VARIANT)
+	ole.VariantInit(result)
+
+	var excepInfo ole.EXCEPINFO
+	hr, _, _ := syscall.Syscall9(
+		dispatch.VTable().Invoke,
+		9,
+		uintptr(unsafe.Pointer(dispatch)),
+		uintptr(dispid),
+		uintptr(unsafe.Pointer(ole.IID_NULL)),
+		uintptr(ole.GetUserDefaultLCID()),
+		uintptr(ole.DISPATCH_METHOD),
+		uintptr(unsafe.Pointer(&dp)),
+		uintptr(unsafe.Pointer(result)),
+		uintptr(unsafe.Pointer(&excepInfo)),
+		0)
+	if hr != 0 {
+		if hr == dispEException {
+			return nil, comError(ole.NewErrorWithSubError(hr, excepInfo.Error(), excepInfo))
+		}
+
+		return nil, comError(ole.NewError(hr))
+	}
+
+	return result, nil
+}
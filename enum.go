@@ -0,0 +1,200 @@
+package msmq
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrUnknownName is returned when parsing a name that does not match any
+// value of an enum, such as an AccessMode.
+var ErrUnknownName = errors.New("go-msmq: unknown name")
+
+// accessModeNames are the names of the AccessMode values.
+var accessModeNames = []struct {
+	v    AccessMode
+	name string
+}{
+	{Receive, "Receive"},
+	{Send, "Send"},
+	{Move, "Move"},
+	{Peek, "Peek"},
+	{PeekAndAdmin, "PeekAndAdmin"},
+	{ReceiveAndAdmin, "ReceiveAndAdmin"},
+}
+
+// String returns the name of the mode.
+func (m AccessMode) String() string {
+	for _, n := range accessModeNames {
+		if n.v == m {
+			return n.name
+		}
+	}
+	return fmt.Sprintf("AccessMode(%d)", int(m))
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (m AccessMode) MarshalText() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler with ParseAccessMode.
+func (m *AccessMode) UnmarshalText(text []byte) error {
+	v, err := ParseAccessMode(string(text))
+	if err != nil {
+		return err
+	}
+	*m = v
+	return nil
+}
+
+// ParseAccessMode returns the AccessMode named s, ignoring case, such as
+// "Receive" or "PeekAndAdmin".
+func ParseAccessMode(s string) (AccessMode, error) {
+	for _, n := range accessModeNames {
+		if strings.EqualFold(n.name, s) {
+			return n.v, nil
+		}
+	}
+	return 0, fmt.Errorf("%w: AccessMode %q", ErrUnknownName, s)
+}
+
+// shareModeNames are the names of the ShareMode values.
+var shareModeNames = []struct {
+	v    ShareMode
+	name string
+}{
+	{DenyNone, "DenyNone"},
+	{DenyReceive, "DenyReceive"},
+}
+
+// String returns the name of the mode.
+func (m ShareMode) String() string {
+	for _, n := range shareModeNames {
+		if n.v == m {
+			return n.name
+		}
+	}
+	return fmt.Sprintf("ShareMode(%d)", int(m))
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (m ShareMode) MarshalText() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler with ParseShareMode.
+func (m *ShareMode) UnmarshalText(text []byte) error {
+	v, err := ParseShareMode(string(text))
+	if err != nil {
+		return err
+	}
+	*m = v
+	return nil
+}
+
+// ParseShareMode returns the ShareMode named s, ignoring case, such as
+// "DenyNone".
+func ParseShareMode(s string) (ShareMode, error) {
+	for _, n := range shareModeNames {
+		if strings.EqualFold(n.name, s) {
+			return n.v, nil
+		}
+	}
+	return 0, fmt.Errorf("%w: ShareMode %q", ErrUnknownName, s)
+}
+
+// privLevelNames are the names of the PrivLevel values.
+var privLevelNames = []struct {
+	v    PrivLevel
+	name string
+}{
+	{NonPrivate, "NonPrivate"},
+	{OptionalPrivate, "OptionalPrivate"},
+	{OnlyPrivate, "OnlyPrivate"},
+}
+
+// String returns the name of the level.
+func (l PrivLevel) String() string {
+	for _, n := range privLevelNames {
+		if n.v == l {
+			return n.name
+		}
+	}
+	return fmt.Sprintf("PrivLevel(%d)", int(l))
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (l PrivLevel) MarshalText() ([]byte, error) {
+	return []byte(l.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler with ParsePrivLevel.
+func (l *PrivLevel) UnmarshalText(text []byte) error {
+	v, err := ParsePrivLevel(string(text))
+	if err != nil {
+		return err
+	}
+	*l = v
+	return nil
+}
+
+// ParsePrivLevel returns the PrivLevel named s, ignoring case, such as
+// "OptionalPrivate".
+func ParsePrivLevel(s string) (PrivLevel, error) {
+	for _, n := range privLevelNames {
+		if strings.EqualFold(n.name, s) {
+			return n.v, nil
+		}
+	}
+	return 0, fmt.Errorf("%w: PrivLevel %q", ErrUnknownName, s)
+}
+
+// transactionLevelNames are the names of the TransactionLevel values.
+var transactionLevelNames = []struct {
+	v    TransactionLevel
+	name string
+}{
+	{NoTransaction, "NoTransaction"},
+	{MTS, "MTS"},
+	{XA, "XA"},
+	{SingleMessage, "SingleMessage"},
+	{MTSRequired, "MTSRequired"},
+}
+
+// String returns the name of the level.
+func (l TransactionLevel) String() string {
+	for _, n := range transactionLevelNames {
+		if n.v == l {
+			return n.name
+		}
+	}
+	return fmt.Sprintf("TransactionLevel(%d)", int(l))
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (l TransactionLevel) MarshalText() ([]byte, error) {
+	return []byte(l.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler with
+// ParseTransactionLevel.
+func (l *TransactionLevel) UnmarshalText(text []byte) error {
+	v, err := ParseTransactionLevel(string(text))
+	if err != nil {
+		return err
+	}
+	*l = v
+	return nil
+}
+
+// ParseTransactionLevel returns the TransactionLevel named s, ignoring case,
+// such as "SingleMessage".
+func ParseTransactionLevel(s string) (TransactionLevel, error) {
+	for _, n := range transactionLevelNames {
+		if strings.EqualFold(n.name, s) {
+			return n.v, nil
+		}
+	}
+	return 0, fmt.Errorf("%w: TransactionLevel %q", ErrUnknownName, s)
+}
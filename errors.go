@@ -0,0 +1,208 @@
+package msmq
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unsafe"
+
+	"github.com/go-ole/go-ole"
+)
+
+// Error is an error reported by MSMQ. It is identified by the HRESULT of the
+// failed COM call, which is usually one of the MQ_ERROR codes. The sentinel
+// errors of the package are *Error values, so errors can be tested with
+// errors.Is regardless of their description:
+//   _, err := queueInfo.Open(msmq.Receive, msmq.DenyNone)
+//   if errors.Is(err, msmq.ErrQueueNotFound) {
+//       ...
+//   }
+//
+// See: https://docs.microsoft.com/en-us/windows/win32/msmq/message-queuing-error-and-information-codes
+type Error struct {
+	// Code is the HRESULT of the error.
+	Code uint32
+
+	// Description describes the error. When the COM call failed with an
+	// exception, this is the description reported by MSMQ.
+	Description string
+
+	// Source is the source of the exception, usually the ProgID of the
+	// MSMQ object that raised it. It is empty if the COM call did not fail
+	// with an exception.
+	Source string
+
+	err error
+}
+
+// Error implements the error interface.
+func (e *Error) Error() string {
+	if e.Source != "" {
+		return fmt.Sprintf("%s (%s, 0x%08X)", e.Description, e.Source, e.Code)
+	}
+
+	return fmt.Sprintf("%s (0x%08X)", e.Description, e.Code)
+}
+
+// Is reports whether target is an *Error with the same Code.
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code
+}
+
+// Unwrap returns the underlying *ole.OleError, if any.
+func (e *Error) Unwrap() error {
+	return e.err
+}
+
+// ErrUnsupportedPlatform is returned on platforms other than Windows, where
+// the package compiles but MSMQ is not available.
+var ErrUnsupportedPlatform = errors.New("go-msmq: MSMQ is only supported on Windows")
+
+// ErrNilObject is returned when a property or method is used on an object
+// that is nil or was released, such as the empty Message returned when a
+// receive times out.
+var ErrNilObject = errors.New("go-msmq: the object is not initialized or was released")
+
+// ErrNotInitialized is an alias of ErrNilObject. It is returned by the
+// methods of a zero-value Queue, Message or QueueInfo, which must instead be
+// created by the package, for example with Open, NewMessage or
+// NewQueueInfo.
+var ErrNotInitialized = ErrNilObject
+
+// The sentinel errors of the common MSMQ error codes.
+var (
+	// ErrQueueNotFound is MQ_ERROR_QUEUE_NOT_FOUND.
+	ErrQueueNotFound = &Error{Code: 0xC00E0003, Description: "the queue does not exist"}
+
+	// ErrQueueNotActive is MQ_ERROR_QUEUE_NOT_ACTIVE.
+	ErrQueueNotActive = &Error{Code: 0xC00E0004, Description: "the queue is not open and contains no messages"}
+
+	// ErrQueueExists is MQ_ERROR_QUEUE_EXISTS.
+	ErrQueueExists = &Error{Code: 0xC00E0005, Description: "a queue with the same path name already exists"}
+
+	// ErrInvalidHandle is MQ_ERROR_INVALID_HANDLE.
+	ErrInvalidHandle = &Error{Code: 0xC00E0007, Description: "the queue handle is invalid"}
+
+	// ErrSharingViolation is MQ_ERROR_SHARING_VIOLATION.
+	ErrSharingViolation = &Error{Code: 0xC00E0009, Description: "the queue is already opened to receive messages with DenyReceive"}
+
+	// ErrServiceNotAvailable is MQ_ERROR_SERVICE_NOT_AVAILABLE.
+	ErrServiceNotAvailable = &Error{Code: 0xC00E000B, Description: "the Message Queuing service is not available"}
+
+	// ErrNoDS is MQ_ERROR_NO_DS.
+	ErrNoDS = &Error{Code: 0xC00E0013, Description: "the directory service is not available"}
+
+	// ErrIllegalQueuePathName is MQ_ERROR_ILLEGAL_QUEUE_PATHNAME.
+	ErrIllegalQueuePathName = &Error{Code: 0xC00E0014, Description: "the queue path name is invalid"}
+
+	// ErrIOTimeout is MQ_ERROR_IO_TIMEOUT.
+	ErrIOTimeout = &Error{Code: 0xC00E001B, Description: "the timeout expired before a message arrived"}
+
+	// ErrIllegalFormatName is MQ_ERROR_ILLEGAL_FORMATNAME.
+	ErrIllegalFormatName = &Error{Code: 0xC00E001E, Description: "the format name is invalid"}
+
+	// ErrUnsupportedFormatNameOperation is
+	// MQ_ERROR_UNSUPPORTED_FORMATNAME_OPERATION.
+	ErrUnsupportedFormatNameOperation = &Error{Code: 0xC00E0020, Description: "the operation is not supported for the format name"}
+
+	// ErrAccessDenied is MQ_ERROR_ACCESS_DENIED.
+	ErrAccessDenied = &Error{Code: 0xC00E0025, Description: "access to the queue is denied"}
+
+	// ErrInsufficientResources is MQ_ERROR_INSUFFICIENT_RESOURCES, which is
+	// returned when the quota of a queue or of the computer is exceeded.
+	ErrInsufficientResources = &Error{Code: 0xC00E0027, Description: "insufficient resources, the queue or computer quota may be exceeded"}
+
+	// ErrQuotaExceeded is an alias of ErrInsufficientResources.
+	ErrQuotaExceeded = ErrInsufficientResources
+
+	// ErrTransactionUsage is MQ_ERROR_TRANSACTION_USAGE.
+	ErrTransactionUsage = &Error{Code: 0xC00E0050, Description: "the transaction level does not match the queue"}
+
+	// ErrStaleHandle is MQ_ERROR_STALE_HANDLE.
+	ErrStaleHandle = &Error{Code: 0xC00E0056, Description: "the queue handle was obtained in a previous session of the queue manager"}
+
+	// ErrQueueDeleted is MQ_ERROR_QUEUE_DELETED.
+	ErrQueueDeleted = &Error{Code: 0xC00E005A, Description: "the queue was deleted"}
+
+	// ErrRemoteMachineNotAvailable is MQ_ERROR_REMOTE_MACHINE_NOT_AVAILABLE.
+	ErrRemoteMachineNotAvailable = &Error{Code: 0xC00E0069, Description: "the remote computer is not available"}
+
+	// ErrMessageNotFound is MQ_ERROR_MESSAGE_NOT_FOUND.
+	ErrMessageNotFound = &Error{Code: 0xC00E0088, Description: "the message does not exist"}
+)
+
+// knownErrors are the sentinel errors by code.
+var knownErrors = map[uint32]*Error{}
+
+func init() {
+	for _, e := range []*Error{
+		ErrQueueNotFound, ErrQueueNotActive, ErrQueueExists, ErrInvalidHandle,
+		ErrSharingViolation, ErrServiceNotAvailable, ErrNoDS, ErrIllegalQueuePathName,
+		ErrIOTimeout, ErrIllegalFormatName, ErrUnsupportedFormatNameOperation,
+		ErrAccessDenied, ErrInsufficientResources, ErrTransactionUsage,
+		ErrStaleHandle, ErrQueueDeleted, ErrRemoteMachineNotAvailable,
+		ErrMessageNotFound,
+	} {
+		knownErrors[e.Code] = e
+	}
+}
+
+// comError converts an *ole.OleError returned by a COM call to an *Error.
+// Other errors are returned as is.
+//
+// go-ole reports most exceptions raised by MSMQ as "Exception occurred.", so
+// the description and source are taken from the EXCEPINFO of the exception
+// when available.
+func comError(err error) error {
+	var oleErr *ole.OleError
+	if err == nil || !errors.As(err, &oleErr) {
+		return err
+	}
+
+	e := &Error{
+		Code:        hresult(oleErr),
+		Description: oleErr.Error(),
+		err:         oleErr,
+	}
+	if known, ok := knownErrors[e.Code]; ok {
+		e.Description = known.Description
+	}
+
+	if ei, ok := oleErr.SubError().(ole.EXCEPINFO); ok {
+		source, description := exceptionDetails(ei)
+		e.Source = source
+		if description != "" {
+			e.Description = description
+		}
+	}
+
+	return e
+}
+
+// excepInfo mirrors the layout of ole.EXCEPINFO, whose source is not exposed.
+type excepInfo struct {
+	wCode             uint16
+	wReserved         uint16
+	bstrSource        *uint16
+	bstrDescription   *uint16
+	bstrHelpFile      *uint16
+	dwHelpContext     uint32
+	pvReserved        uintptr
+	pfnDeferredFillIn uintptr
+	scode             uint32
+}
+
+// exceptionDetails returns the source and description of ei. Either is empty
+// if not set by the object that raised the exception.
+func exceptionDetails(ei ole.EXCEPINFO) (source, description string) {
+	e := (*excepInfo)(unsafe.Pointer(&ei))
+	if e.bstrSource != nil {
+		source = strings.TrimSpace(ole.BstrToString(e.bstrSource))
+	}
+	if e.bstrDescription != nil {
+		description = strings.TrimSpace(ole.BstrToString(e.bstrDescription))
+	}
+
+	return source, description
+}
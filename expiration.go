@@ -0,0 +1,227 @@
+package msmq
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Expiration reports a message that MSMQ could not deliver, for example
+// because its time-to-reach-queue or time-to-be-received expired.
+type Expiration struct {
+	// MessageID is the identifier of the original message, as returned by
+	// Message.ID after it was sent.
+	MessageID []byte
+
+	// Class is the negative acknowledgment explaining why the message was
+	// not delivered. Class.IsExpiration reports whether a timeout expired.
+	Class MessageClass
+
+	// Label is the label of the original message.
+	Label string
+
+	// Body is the body of the original message.
+	Body []byte
+
+	// ArrivedTime is when the acknowledgment arrived in the administration
+	// queue.
+	ArrivedTime time.Time
+}
+
+// ExpirationWatcher lets producers learn about the messages that expire or
+// cannot be delivered, instead of losing them silently. Messages passed to
+// Watch request negative acknowledgments into an administration queue, and
+// Run reads the acknowledgments and reports them as Expirations:
+//   admin, err := msmq.Open(`.\private$\orders-admin`, msmq.Options{})
+//   ...
+//   w, err := msmq.NewExpirationWatcher(admin,
+//       msmq.ExpirationWatcherWithOnExpired(func(e msmq.Expiration) {
+//           log.Printf("message %x expired: %#x", e.MessageID, e.Class)
+//       }),
+//   )
+//   ...
+//   go w.Run(ctx)
+//
+//   err = w.Watch(&msg, time.Minute, time.Hour)
+//   ...
+//   err = msg.Send(orders)
+//
+// Without a callback, Expirations are sent to the channel returned by
+// Expirations. The administration queue must not be transactional.
+type ExpirationWatcher struct {
+	admin   *Queue
+	adminQI *QueueInfo
+	ch      chan Expiration
+	options *expirationWatcherOptions
+}
+
+// NewExpirationWatcher returns a pointer to an ExpirationWatcher reading
+// admin, which must be opened with Receive AccessMode, configured by the
+// options.
+func NewExpirationWatcher(admin *Queue, opts ...ExpirationWatcherOption) (*ExpirationWatcher, error) {
+	options := &expirationWatcherOptions{
+		receiveTimeout: time.Second,
+	}
+	for _, o := range opts {
+		o.set(options)
+	}
+
+	qi, err := NewQueueInfo(WithFormatName(admin.formatName()))
+	if err != nil {
+		return nil, fmt.Errorf("go-msmq: NewExpirationWatcher() failed to create admin queue info: %w", err)
+	}
+
+	return &ExpirationWatcher{
+		admin:   admin,
+		adminQI: qi,
+		ch:      make(chan Expiration, 64),
+		options: options,
+	}, nil
+}
+
+// Watch configures msg, before it is sent, to expire if it does not reach its
+// queue within reachQueue or is not received within receive, and to report
+// when it is not delivered. A negative duration means no limit.
+func (w *ExpirationWatcher) Watch(msg *Message, reachQueue, receive time.Duration) error {
+	level := AckNackReceive
+	if receive < 0 {
+		level = AckNackReachQueue
+	}
+
+	if err := msg.SetMaxTimeToReachQueue(reachQueue); err != nil {
+		return fmt.Errorf("go-msmq: Watch() failed to watch message: %w", err)
+	}
+	if err := msg.SetMaxTimeToReceive(receive); err != nil {
+		return fmt.Errorf("go-msmq: Watch() failed to watch message: %w", err)
+	}
+	if err := msg.SetAdminQueueInfo(w.adminQI); err != nil {
+		return fmt.Errorf("go-msmq: Watch() failed to watch message: %w", err)
+	}
+	if err := msg.SetAck(level); err != nil {
+		return fmt.Errorf("go-msmq: Watch() failed to watch message: %w", err)
+	}
+
+	return nil
+}
+
+// Expirations returns the channel Run sends Expirations to when no callback
+// is set with ExpirationWatcherWithOnExpired. The channel is closed when Run
+// returns.
+func (w *ExpirationWatcher) Expirations() <-chan Expiration {
+	return w.ch
+}
+
+// Run reads the administration queue until ctx is done, and returns nil, or
+// until the queue cannot be read, and returns the error. Positive
+// acknowledgments are discarded. Run must only be called once.
+func (w *ExpirationWatcher) Run(ctx context.Context) error {
+	defer close(w.ch)
+
+	timeout := int(w.options.receiveTimeout / time.Millisecond)
+	failures := 0
+	for ctx.Err() == nil {
+		msg, err := w.admin.Receive(ReceiveWithTransaction(NoTransaction), ReceiveWithTimeout(timeout))
+		if err != nil {
+			if !IsTransient(err) {
+				return fmt.Errorf("go-msmq: Run() failed to read acknowledgments: %w", err)
+			}
+
+			failures++
+			if !sleep(ctx, (*RetryPolicy)(nil).backoff(failures)) {
+				return nil
+			}
+			continue
+		}
+		failures = 0
+		if msg.dispatch == nil {
+			continue
+		}
+
+		e, ok, err := expiration(msg)
+		msg.release()
+		if err != nil {
+			return fmt.Errorf("go-msmq: Run() failed to read acknowledgment: %w", err)
+		}
+		if !ok {
+			continue
+		}
+
+		if w.options.onExpired != nil {
+			w.options.onExpired(e)
+			continue
+		}
+
+		select {
+		case w.ch <- e:
+		case <-ctx.Done():
+		}
+	}
+
+	return nil
+}
+
+// Close releases the administration queue info. It does not close the
+// administration queue.
+func (w *ExpirationWatcher) Close() error {
+	return w.adminQI.Close()
+}
+
+// expiration returns the Expiration reported by the acknowledgment msg, and
+// whether msg is a negative acknowledgment.
+func expiration(msg Message) (Expiration, bool, error) {
+	class, err := msg.Class()
+	if err != nil || !class.IsNack() {
+		return Expiration{}, false, err
+	}
+
+	e := Expiration{Class: class}
+	if e.MessageID, err = msg.CorrelationID(); err != nil {
+		return Expiration{}, false, err
+	}
+	if e.Label, err = msg.Label(); err != nil {
+		return Expiration{}, false, err
+	}
+	if e.Body, err = msg.BodyBytes(); err != nil {
+		return Expiration{}, false, err
+	}
+	if e.ArrivedTime, err = msg.ArrivedTime(); err != nil {
+		return Expiration{}, false, err
+	}
+
+	return e, true, nil
+}
+
+// ExpirationWatcherOption represents an option to configure an
+// ExpirationWatcher.
+type ExpirationWatcherOption struct {
+	set func(opts *expirationWatcherOptions)
+}
+
+// expirationWatcherOptions contains all the options to configure an
+// ExpirationWatcher.
+type expirationWatcherOptions struct {
+	receiveTimeout time.Duration
+	onExpired      func(e Expiration)
+}
+
+// ExpirationWatcherWithOnExpired returns an ExpirationWatcherOption that
+// configures the callback invoked with each Expiration. The default is to
+// send the Expirations to the channel returned by Expirations.
+func ExpirationWatcherWithOnExpired(fn func(e Expiration)) ExpirationWatcherOption {
+	return ExpirationWatcherOption{
+		set: func(opts *expirationWatcherOptions) {
+			opts.onExpired = fn
+		},
+	}
+}
+
+// ExpirationWatcherWithReceiveTimeout returns an ExpirationWatcherOption that
+// configures how long Run waits for an acknowledgment before checking
+// whether it is stopping. The default is 1 second.
+func ExpirationWatcherWithReceiveTimeout(timeout time.Duration) ExpirationWatcherOption {
+	return ExpirationWatcherOption{
+		set: func(opts *expirationWatcherOptions) {
+			opts.receiveTimeout = timeout
+		},
+	}
+}
@@ -0,0 +1,122 @@
+package msmq
+
+import (
+	"expvar"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Stats publishes statistics about the operations of the package with the
+// expvar package, so they are served along with the other variables of the
+// program at /debug/vars. Stats implements Observer and HandlerObserver and
+// must be installed with SetObserver:
+//   stats, err := msmq.PublishStats("msmq")
+//   if err != nil {
+//       ...
+//   }
+//   msmq.SetObserver(stats)
+//
+// The statistics are grouped by the format name of the queue. Operations that
+// are not bound to an open queue, such as sending to a Destination, are
+// grouped under the empty name.
+type Stats struct {
+	vars *expvar.Map
+
+	mu     sync.Mutex
+	queues map[string]*queueStats
+}
+
+// queueStats are the statistics of a single queue.
+type queueStats struct {
+	sent      expvar.Int
+	received  expvar.Int
+	peeked    expvar.Int
+	purged    expvar.Int
+	errors    expvar.Int
+	lastError expvar.String
+
+	handled        expvar.Int
+	handlerFailed  expvar.Int
+	handlerRetries expvar.Int
+}
+
+// PublishStats returns a pointer to Stats published under the expvar name
+// namespace. An error is returned if a variable with that name is already
+// published.
+func PublishStats(namespace string) (*Stats, error) {
+	if expvar.Get(namespace) != nil {
+		return nil, fmt.Errorf("go-msmq: PublishStats(%q) failed to publish statistics: variable already published", namespace)
+	}
+
+	s := &Stats{
+		vars:   new(expvar.Map),
+		queues: make(map[string]*queueStats),
+	}
+	expvar.Publish(namespace, s.vars)
+	return s, nil
+}
+
+// Observe implements Observer.
+func (s *Stats) Observe(op Operation) {
+	qs := s.queue(op.Queue)
+
+	if op.Err != nil {
+		qs.errors.Add(1)
+		qs.lastError.Set(op.Err.Error())
+		return
+	}
+
+	switch {
+	case op.Name == "Purge":
+		qs.purged.Add(1)
+	case !op.Message:
+	case strings.HasPrefix(op.Name, "Send"):
+		qs.sent.Add(1)
+	case strings.HasPrefix(op.Name, "Receive"):
+		qs.received.Add(1)
+	case strings.HasPrefix(op.Name, "Peek"):
+		qs.peeked.Add(1)
+	}
+}
+
+// ObserveHandling implements HandlerObserver.
+func (s *Stats) ObserveHandling(h Handling) {
+	qs := s.queue(h.Queue)
+
+	qs.handled.Add(1)
+	if h.Retries > 0 {
+		qs.handlerRetries.Add(1)
+	}
+	if h.Err != nil {
+		qs.handlerFailed.Add(1)
+		qs.lastError.Set(h.Err.Error())
+	}
+}
+
+// queue returns the statistics of the queue name, publishing them on first
+// use.
+func (s *Stats) queue(name string) *queueStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	qs, ok := s.queues[name]
+	if !ok {
+		qs = &queueStats{}
+		vars := new(expvar.Map)
+		vars.Set("sent", &qs.sent)
+		vars.Set("received", &qs.received)
+		vars.Set("peeked", &qs.peeked)
+		vars.Set("purged", &qs.purged)
+		vars.Set("errors", &qs.errors)
+		vars.Set("last_error", &qs.lastError)
+		vars.Set("handled", &qs.handled)
+		vars.Set("handler_failed", &qs.handlerFailed)
+		vars.Set("handler_retries", &qs.handlerRetries)
+
+		s.queues[name] = qs
+		s.vars.Set(name, vars)
+	}
+
+	return qs
+}
@@ -1,5 +1,8 @@
 module github.com/jandauz/go-msmq
 
-go 1.16
+go 1.23
 
-require github.com/go-ole/go-ole v1.2.5
+require (
+	github.com/go-ole/go-ole v1.2.5
+	golang.org/x/sys v0.0.0-20190916202348-b4ddaad3f8a3
+)
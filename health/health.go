@@ -0,0 +1,176 @@
+// Package health checks the health of services that consume or produce
+// messages with go-msmq. The checks verify that a queue can be opened, that
+// it can be peeked without blocking and that its depth is below a limit, and
+// return structured results that can be served by the health endpoints of a
+// service:
+//   http.Handle("/healthz", health.Handler(health.Liveness(name)...))
+//   http.Handle("/readyz", health.Handler(health.Readiness(name, 10000)...))
+package health
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jandauz/go-msmq"
+)
+
+// Status is the status of a check or a report.
+type Status string
+
+const (
+	// StatusUp indicates that a check passed.
+	StatusUp Status = "up"
+
+	// StatusDown indicates that a check failed.
+	StatusDown Status = "down"
+)
+
+// Check is a named health check. Func returns nil if the check passes.
+type Check struct {
+	Name string
+	Func func(ctx context.Context) error
+}
+
+// Result is the result of a single Check.
+type Result struct {
+	Name     string        `json:"name"`
+	Status   Status        `json:"status"`
+	Error    string        `json:"error,omitempty"`
+	Duration time.Duration `json:"duration"`
+}
+
+// Report is the result of running a set of checks. Its status is StatusDown
+// if any check failed.
+type Report struct {
+	Status Status   `json:"status"`
+	Checks []Result `json:"checks"`
+}
+
+// Run runs checks in order and returns their results. A check that does not
+// return before ctx is done fails with the error of ctx.
+func Run(ctx context.Context, checks ...Check) Report {
+	report := Report{
+		Status: StatusUp,
+		Checks: make([]Result, 0, len(checks)),
+	}
+
+	for _, c := range checks {
+		r := run(ctx, c)
+		if r.Status == StatusDown {
+			report.Status = StatusDown
+		}
+		report.Checks = append(report.Checks, r)
+	}
+
+	return report
+}
+
+// run runs c, giving up when ctx is done. The COM calls made by the checks
+// cannot be cancelled, so a check that gives up keeps running in the
+// background until it returns.
+func run(ctx context.Context, c Check) Result {
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		done <- c.Func(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	r := Result{
+		Name:     c.Name,
+		Status:   StatusUp,
+		Duration: time.Since(start),
+	}
+	if err != nil {
+		r.Status = StatusDown
+		r.Error = err.Error()
+	}
+
+	return r
+}
+
+// Liveness returns the checks that a service is alive: the queue referenced
+// by name can be opened.
+func Liveness(name string) []Check {
+	return []Check{QueueOpen(name)}
+}
+
+// Readiness returns the checks that a service is ready to process messages:
+// the queue referenced by name can be opened and peeked without waiting, and
+// holds no more than maxMessages messages.
+func Readiness(name string, maxMessages int32) []Check {
+	return []Check{
+		QueueOpen(name),
+		QueuePeek(name),
+		QueueDepth(name, maxMessages),
+	}
+}
+
+// QueueOpen returns a Check that the queue referenced by name, which is
+// either a format name or a path name, can be opened for peeking.
+func QueueOpen(name string) Check {
+	return Check{
+		Name: "queue_open",
+		Func: func(ctx context.Context) error {
+			return withQueue(name, func(*msmq.Queue) error {
+				return nil
+			})
+		},
+	}
+}
+
+// QueuePeek returns a Check that the first message of the queue referenced
+// by name can be peeked with a zero timeout. An empty queue passes.
+func QueuePeek(name string) Check {
+	return Check{
+		Name: "queue_peek",
+		Func: func(ctx context.Context) error {
+			return withQueue(name, func(q *msmq.Queue) error {
+				msg, ok, err := q.TryPeek(msmq.PeekWithWantBody(false))
+				if ok {
+					msg.Close()
+				}
+				return err
+			})
+		},
+	}
+}
+
+// QueueDepth returns a Check that the queue referenced by name holds no more
+// than maxMessages messages.
+func QueueDepth(name string, maxMessages int32) Check {
+	return Check{
+		Name: "queue_depth",
+		Func: func(ctx context.Context) error {
+			return withQueue(name, func(q *msmq.Queue) error {
+				n, err := q.MessageCount()
+				if err != nil {
+					return err
+				}
+				if n > maxMessages {
+					return fmt.Errorf("health: queue holds %d messages, more than %d", n, maxMessages)
+				}
+				return nil
+			})
+		},
+	}
+}
+
+// withQueue opens the queue referenced by name for peeking, calls fn and
+// closes the queue.
+func withQueue(name string, fn func(q *msmq.Queue) error) error {
+	q, err := msmq.Open(name, msmq.Options{AccessMode: msmq.Peek})
+	if err != nil {
+		return err
+	}
+	defer q.Close()
+
+	return fn(q)
+}
@@ -0,0 +1,26 @@
+package health
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Handler returns an http.Handler that runs checks on every request and
+// writes the Report as JSON. The status code is 200 if every check passed
+// and 503 otherwise, so the handler can be used directly as the liveness or
+// readiness endpoint of a service. The checks are cancelled when the request
+// is.
+func Handler(checks ...Check) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		report := Run(r.Context(), checks...)
+
+		status := http.StatusOK
+		if report.Status != StatusUp {
+			status = http.StatusServiceUnavailable
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(report)
+	})
+}
@@ -0,0 +1,42 @@
+package msmq
+
+import "strings"
+
+// HTTPFormatName returns the direct format name used to send messages to a
+// queue over HTTP, or HTTPS when secure is true. queueName is the name of the
+// queue relative to host, for example "orders" or `private$\orders`:
+//   name := msmq.HTTPFormatName("mq.example.com", `private$\orders`, true)
+//   // DIRECT=HTTPS://mq.example.com/msmq/private$/orders
+//   queueInfo, err := msmq.NewQueueInfo(msmq.WithFormatName(name))
+//   ...
+//   queue, err := queueInfo.Open(msmq.Send, msmq.DenyNone)
+//
+// Messages sent to HTTP format names are transferred with SRMP, which allows
+// them to cross firewalls that only permit HTTP traffic. Message.SetSOAPHeader
+// and Message.SetSOAPBody add elements to their SOAP envelopes, and
+// Message.SRMP returns the envelopes of received messages. Queues referenced by
+// HTTP format names can only be opened with Send AccessMode.
+//
+// HTTP messages are acknowledged by the remote queue manager, so the delivery
+// semantics of Message.SetUseDeadLetterQueue and Message.SetUseJournalQueue
+// apply to the sending computer: undeliverable non-transactional messages are
+// only kept in its dead-letter queue when UseDeadLetterQueue is set, and a
+// copy of each message is kept in its journal once the remote queue manager
+// acknowledges receipt when UseJournalQueue is set.
+//
+// See: https://docs.microsoft.com/en-us/previous-versions/windows/desktop/legacy/ms701477(v=vs.85)
+func HTTPFormatName(host, queueName string, secure bool) string {
+	protocol := "HTTP"
+	if secure {
+		protocol = "HTTPS"
+	}
+
+	return "DIRECT=" + protocol + "://" + host + "/msmq/" + strings.ReplaceAll(queueName, `\`, "/")
+}
+
+// IsHTTPFormatName returns whether name is a DIRECT=HTTP or DIRECT=HTTPS format
+// name.
+func IsHTTPFormatName(name string) bool {
+	upper := strings.ToUpper(name)
+	return strings.HasPrefix(upper, "DIRECT=HTTP://") || strings.HasPrefix(upper, "DIRECT=HTTPS://")
+}
@@ -0,0 +1,120 @@
+package msmq
+
+import (
+	"fmt"
+	"io"
+	"runtime/debug"
+	"sort"
+	"sync"
+
+	"github.com/go-ole/go-ole"
+)
+
+// Leak describes an MSMQ object that was created but not released.
+type Leak struct {
+	// Object is the name of the COM object, such as "MSMQ.MSMQMessage".
+	Object string
+
+	// Stack is the stack trace of the goroutine that created the object.
+	Stack string
+}
+
+// leaks keeps track of the objects that have not been released when leak
+// tracking is enabled.
+var leaks struct {
+	mu      sync.Mutex
+	w       io.Writer
+	objects map[*ole.IDispatch]Leak
+}
+
+// SetLeakTracking enables leak tracking when w is not nil. While it is
+// enabled, every MSMQ object created by the package is recorded with the
+// stack trace of its creation until it is released by Close, and Shutdown
+// writes a report of the objects that were not released to w:
+//   msmq.SetLeakTracking(os.Stderr)
+//   defer msmq.Shutdown()
+// Leak tracking captures a stack trace for every object, so it is intended for
+// tests and diagnostics rather than production. Objects created before leak
+// tracking is enabled are not tracked.
+func SetLeakTracking(w io.Writer) {
+	leaks.mu.Lock()
+	defer leaks.mu.Unlock()
+
+	leaks.w = w
+	if w == nil {
+		leaks.objects = nil
+	} else if leaks.objects == nil {
+		leaks.objects = map[*ole.IDispatch]Leak{}
+	}
+}
+
+// Leaks returns the objects that have not been released since leak tracking
+// was enabled.
+func Leaks() []Leak {
+	leaks.mu.Lock()
+	defer leaks.mu.Unlock()
+
+	result := make([]Leak, 0, len(leaks.objects))
+	for _, l := range leaks.objects {
+		result = append(result, l)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Object < result[j].Object
+	})
+
+	return result
+}
+
+// reportLeaks writes the objects that have not been released to the writer
+// passed to SetLeakTracking.
+func reportLeaks() {
+	leaks.mu.Lock()
+	w := leaks.w
+	leaks.mu.Unlock()
+
+	if w == nil {
+		return
+	}
+
+	l := Leaks()
+	if len(l) == 0 {
+		return
+	}
+
+	fmt.Fprintf(w, "go-msmq: %d MSMQ objects were not released:\n", len(l))
+	for _, leak := range l {
+		fmt.Fprintf(w, "\n%s created at:\n%s", leak.Object, leak.Stack)
+	}
+}
+
+// track records dispatch as created when leak tracking is enabled and returns
+// it.
+func track(dispatch *ole.IDispatch, object string) *ole.IDispatch {
+	if dispatch == nil {
+		return nil
+	}
+
+	leaks.mu.Lock()
+	defer leaks.mu.Unlock()
+
+	if leaks.objects != nil {
+		leaks.objects[dispatch] = Leak{
+			Object: object,
+			Stack:  string(debug.Stack()),
+		}
+	}
+
+	return dispatch
+}
+
+// release releases dispatch and removes it from the tracked objects.
+func release(dispatch *ole.IDispatch) {
+	leaks.mu.Lock()
+	delete(leaks.objects, dispatch)
+	leaks.mu.Unlock()
+
+	inApartment(func() {
+		dispatch.Release()
+	})
+}
@@ -0,0 +1,62 @@
+package msmq
+
+import (
+	"context"
+	"log/slog"
+	"sync/atomic"
+	"time"
+)
+
+// Logger receives the log records of the package. *slog.Logger implements
+// Logger, so a structured logger can be installed directly:
+//   msmq.SetLogger(slog.Default())
+// Operations are logged at slog.LevelDebug with the queue, the duration of
+// the operation and, for receives, the lookup identifier of the message.
+// Failed operations are logged at slog.LevelError with the error.
+type Logger interface {
+	Log(ctx context.Context, level slog.Level, msg string, args ...any)
+}
+
+// NewSlogLogger returns a Logger that writes to h.
+func NewSlogLogger(h slog.Handler) Logger {
+	return slog.New(h)
+}
+
+// packageLogger is the Logger installed by SetLogger.
+var packageLogger atomic.Pointer[Logger]
+
+// SetLogger installs l as the Logger of the package. Queues log to l unless
+// a Logger is set with Queue.SetLogger. A nil Logger disables logging, which
+// is the default.
+func SetLogger(l Logger) {
+	if l == nil {
+		packageLogger.Store(nil)
+		return
+	}
+
+	packageLogger.Store(&l)
+}
+
+// currentLogger returns the Logger of the package, or nil.
+func currentLogger() Logger {
+	if l := packageLogger.Load(); l != nil {
+		return *l
+	}
+
+	return nil
+}
+
+// logOperation logs the outcome of the operation op that started at start.
+func logOperation(l Logger, op string, start time.Time, err error, args ...any) {
+	if l == nil {
+		return
+	}
+
+	args = append(args, slog.String("op", op), slog.Duration("duration", time.Since(start)))
+	if err != nil {
+		l.Log(context.Background(), slog.LevelError, "go-msmq: "+op+" failed", append(args, slog.Any("error", err))...)
+		return
+	}
+
+	l.Log(context.Background(), slog.LevelDebug, "go-msmq: "+op, args...)
+}
@@ -0,0 +1,81 @@
+package msmq
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// LookupID is the lookup identifier of a message, which MSMQ assigns when the
+// message arrives in a queue. Lookup identifiers increase in the order of the
+// messages in the queue and are never reused within a queue, so that they
+// reference a message reliably while the queue is navigated.
+type LookupID uint64
+
+const (
+	// FirstLookupID references the first message of a queue. It is never
+	// the lookup identifier of a message, and the *ByLookupID methods of
+	// Queue resolve it: PeekByLookupID(FirstLookupID) peeks the first
+	// message, and so does PeekNextByLookupID(FirstLookupID), so that a
+	// loop navigating a queue can start from it:
+	//   id := msmq.FirstLookupID
+	//   for {
+	//       msg, err := queue.PeekNextByLookupID(id)
+	//       ...
+	//       id, err = msg.LookupID()
+	//       ...
+	//   }
+	FirstLookupID LookupID = 0
+
+	// LastLookupID references the last message of a queue.
+	// PeekByLookupID(LastLookupID) and PeekPreviousByLookupID(LastLookupID)
+	// peek the last message, and the receive methods behave alike.
+	LastLookupID LookupID = 1<<64 - 1
+)
+
+// String returns the decimal lookup identifier, or "First" or "Last" for the
+// sentinels.
+func (id LookupID) String() string {
+	switch id {
+	case FirstLookupID:
+		return "First"
+	case LastLookupID:
+		return "Last"
+	default:
+		return strconv.FormatUint(uint64(id), 10)
+	}
+}
+
+// ParseLookupID returns the LookupID in the decimal string s.
+func ParseLookupID(s string) (LookupID, error) {
+	v, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("go-msmq: ParseLookupID(%s) failed to parse lookup id: %w", s, err)
+	}
+
+	return LookupID(v), nil
+}
+
+// sentinelActions are the methods that the *ByLookupID methods call
+// instead when passed FirstLookupID or LastLookupID.
+var sentinelActions = map[string]struct{ first, last string }{
+	"PeekByLookupID":            {"PeekFirstByLookupID", "PeekLastByLookupID"},
+	"PeekNextByLookupID":        {"PeekFirstByLookupID", ""},
+	"PeekPreviousByLookupID":    {"", "PeekLastByLookupID"},
+	"ReceiveByLookupID":         {"ReceiveFirstByLookupID", "ReceiveLastByLookupID"},
+	"ReceiveNextByLookupID":     {"ReceiveFirstByLookupID", ""},
+	"ReceivePreviousByLookupID": {"", "ReceiveLastByLookupID"},
+}
+
+// resolveLookupID returns the method that action calls for id, and whether
+// the method takes id.
+func resolveLookupID(action string, id LookupID) (string, bool) {
+	sentinel := sentinelActions[action]
+	switch {
+	case id == FirstLookupID && sentinel.first != "":
+		return sentinel.first, false
+	case id == LastLookupID && sentinel.last != "":
+		return sentinel.last, false
+	default:
+		return action, true
+	}
+}
@@ -0,0 +1,417 @@
+package msmq
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/go-ole/go-ole"
+)
+
+// QueueManagement provides statistics about a single queue, such as the number
+// of messages in the queue, without having to open the queue and read through
+// its messages.
+type QueueManagement struct {
+	dispatch *ole.IDispatch
+}
+
+// NewQueueManagement returns a pointer to a QueueManagement for the queue
+// referenced by the options. Either the FormatName or the PathName of the
+// queue must be specified:
+//   mgmt, err := msmq.NewQueueManagement(msmq.QueueManagementWithFormatName(name))
+//
+// See: https://docs.microsoft.com/en-us/previous-versions/windows/desktop/legacy/ms703224(v=vs.85)
+func NewQueueManagement(opts ...QueueManagementOption) (*QueueManagement, error) {
+	options := &queueManagementOptions{}
+	for _, o := range opts {
+		o.set(options)
+	}
+
+	dispatch, err := createObject("MSMQ.MSMQManagement")
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = callMethodWithOptionalArgs(dispatch, "Init", optional(options.machine), optional(options.pathName), optional(options.formatName))
+	if err != nil {
+		release(dispatch)
+		return nil, fmt.Errorf("go-msmq: failed to create new QueueManagement: %w", err)
+	}
+
+	// The statistics that are specific to queues such as the journal message
+	// count are only exposed by IMSMQQueueManagement and not by the default
+	// IMSMQManagement interface of the object.
+	var queueDispatch *ole.IDispatch
+	inApartment(func() {
+		queueDispatch, err = dispatch.QueryInterface(iidIMSMQQueueManagement)
+	})
+	if err == nil {
+		release(dispatch)
+		dispatch = track(queueDispatch, "MSMQ.MSMQManagement")
+	}
+
+	return &QueueManagement{
+		dispatch: dispatch,
+	}, nil
+}
+
+// Close releases the underlying management object. The QueueManagement cannot
+// be used after it is closed. Calling Close more than once has no effect.
+func (m *QueueManagement) Close() error {
+	if m.dispatch != nil {
+		release(m.dispatch)
+		m.dispatch = nil
+	}
+
+	return nil
+}
+
+// iidIMSMQQueueManagement is the interface identifier of IMSMQQueueManagement.
+var iidIMSMQQueueManagement = ole.NewGUID("{7FBE7759-5760-444D-B8A5-5E7AB9A84CCE}")
+
+// QueueManagementOption represents an option to configure QueueManagement.
+type QueueManagementOption struct {
+	set func(opts *queueManagementOptions)
+}
+
+// queueManagementOptions contains all the options to configure
+// QueueManagement.
+type queueManagementOptions struct {
+	machine    string
+	pathName   string
+	formatName string
+}
+
+// QueueManagementWithMachine returns a QueueManagementOption that configures
+// QueueManagement with the computer whose queue manager reports the
+// statistics. The default is the local computer. Outgoing queues are only
+// known to the computer that sends messages through them, so the statistics
+// of an outgoing queue must be requested from that computer.
+func QueueManagementWithMachine(name string) QueueManagementOption {
+	return QueueManagementOption{
+		set: func(opts *queueManagementOptions) {
+			opts.machine = name
+		},
+	}
+}
+
+// QueueManagementWithPathName returns a QueueManagementOption that configures
+// QueueManagement with the specified PathName of the queue.
+func QueueManagementWithPathName(name string) QueueManagementOption {
+	return QueueManagementOption{
+		set: func(opts *queueManagementOptions) {
+			opts.pathName = name
+		},
+	}
+}
+
+// QueueManagementWithFormatName returns a QueueManagementOption that
+// configures QueueManagement with the specified FormatName of the queue.
+func QueueManagementWithFormatName(name string) QueueManagementOption {
+	return QueueManagementOption{
+		set: func(opts *queueManagementOptions) {
+			opts.formatName = name
+		},
+	}
+}
+
+// optional returns nil if s is empty so that it is passed as an omitted
+// optional parameter.
+func optional(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+
+	return s
+}
+
+// mqErrorQueueNotActive is the MQ_ERROR_QUEUE_NOT_ACTIVE HRESULT which is
+// returned when the statistics of a queue that is not open by any application
+// and does not contain any messages are requested.
+const mqErrorQueueNotActive = 0xC00E0004
+
+// MessageCount returns the number of messages in the queue. A queue that is
+// not active, that is a queue that is not open by any application and that
+// contains no messages, reports 0.
+func (m *QueueManagement) MessageCount() (int32, error) {
+	res, err := getProperty(m.dispatch, "MessageCount")
+	if err != nil {
+		if hresult(err) == mqErrorQueueNotActive {
+			return 0, nil
+		}
+
+		return 0, fmt.Errorf("go-msmq: MessageCount() failed to get MessageCount: %w", err)
+	}
+	defer res.Clear()
+
+	return variantInt32(res, "MessageCount")
+}
+
+// BytesInJournal returns the number of bytes used by the messages in the
+// journal of the queue.
+func (m *QueueManagement) BytesInJournal() (uint64, error) {
+	res, err := getProperty(m.dispatch, "BytesInJournal")
+	if err != nil {
+		if hresult(err) == mqErrorQueueNotActive {
+			return 0, nil
+		}
+
+		return 0, fmt.Errorf("go-msmq: BytesInJournal() failed to get BytesInJournal: %w", err)
+	}
+	defer res.Clear()
+
+	return toUint64(res), nil
+}
+
+// BytesInQueue returns the number of bytes used by the messages in the queue.
+// Comparing it against QueueInfo.Quota allows applications to detect that a
+// queue is about to start rejecting messages.
+func (m *QueueManagement) BytesInQueue() (uint64, error) {
+	res, err := getProperty(m.dispatch, "BytesInQueue")
+	if err != nil {
+		if hresult(err) == mqErrorQueueNotActive {
+			return 0, nil
+		}
+
+		return 0, fmt.Errorf("go-msmq: BytesInQueue() failed to get BytesInQueue: %w", err)
+	}
+	defer res.Clear()
+
+	return toUint64(res), nil
+}
+
+// JournalMessageCount returns the number of messages in the journal of the
+// queue.
+func (m *QueueManagement) JournalMessageCount() (int32, error) {
+	res, err := getProperty(m.dispatch, "JournalMessageCount")
+	if err != nil {
+		if hresult(err) == mqErrorQueueNotActive {
+			return 0, nil
+		}
+
+		return 0, fmt.Errorf("go-msmq: JournalMessageCount() failed to get JournalMessageCount: %w", err)
+	}
+	defer res.Clear()
+
+	return variantInt32(res, "JournalMessageCount")
+}
+
+// QueueState defines the connection state of an outgoing queue.
+type QueueState int32
+
+const (
+	// QueueStateLocalConnection specifies that the queue is a local queue.
+	QueueStateLocalConnection QueueState = 0
+
+	// QueueStateDisconnected specifies that the queue manager is offline.
+	QueueStateDisconnected QueueState = 1
+
+	// QueueStateWaiting specifies that the queue manager is waiting to
+	// retry a failed connection.
+	QueueStateWaiting QueueState = 2
+
+	// QueueStateNeedValidate specifies that the identity of the destination
+	// queue manager must be validated.
+	QueueStateNeedValidate QueueState = 3
+
+	// QueueStateOnHold specifies that sending messages was paused.
+	QueueStateOnHold QueueState = 4
+
+	// QueueStateNonActive specifies that the queue is not active.
+	QueueStateNonActive QueueState = 5
+
+	// QueueStateConnected specifies that the queue manager is connected to
+	// the destination and is sending messages.
+	QueueStateConnected QueueState = 6
+
+	// QueueStateDisconnecting specifies that the queue manager is going
+	// offline.
+	QueueStateDisconnecting QueueState = 7
+
+	// QueueStateLocked specifies that the queue is locked.
+	QueueStateLocked QueueState = 8
+)
+
+// State returns the connection state of the queue. Monitoring State of the
+// outgoing queues of a computer reveals destinations that cannot be reached.
+func (m *QueueManagement) State() (QueueState, error) {
+	res, err := getProperty(m.dispatch, "State")
+	if err != nil {
+		return 0, fmt.Errorf("go-msmq: State() failed to get State: %w", err)
+	}
+	defer res.Clear()
+
+	return QueueState(toUint64(res)), nil
+}
+
+// IsLocal returns whether the queue is a local queue rather than an outgoing
+// queue.
+func (m *QueueManagement) IsLocal() (bool, error) {
+	res, err := getProperty(m.dispatch, "IsLocal")
+	if err != nil {
+		return false, fmt.Errorf("go-msmq: IsLocal() failed to get IsLocal: %w", err)
+	}
+	defer res.Clear()
+
+	return variantBool(res, "IsLocal")
+}
+
+// toUint64 converts the numeric value held by v to uint64. The byte counts
+// reported by MSMQ are returned as VT_UI8 but may be returned as smaller
+// integer types on older versions.
+func toUint64(v *ole.VARIANT) uint64 {
+	switch n := v.Value().(type) {
+	case uint64:
+		return n
+	case int64:
+		return uint64(n)
+	case uint32:
+		return uint64(n)
+	case int32:
+		return uint64(n)
+	default:
+		return 0
+	}
+}
+
+// Management returns a QueueManagement for the queue referenced by the
+// FormatName of QueueInfo.
+func (qi *QueueInfo) Management() (*QueueManagement, error) {
+	s, err := qi.FormatName()
+	if err != nil {
+		return nil, fmt.Errorf("go-msmq: Management() failed to get queue management: %w", err)
+	}
+
+	m, err := NewQueueManagement(QueueManagementWithFormatName(s))
+	if err != nil {
+		return nil, fmt.Errorf("go-msmq: Management() failed to get queue management: %w", err)
+	}
+
+	return m, nil
+}
+
+// MessageCount returns the number of messages in the queue referenced by
+// QueueInfo. See QueueManagement.MessageCount.
+func (qi *QueueInfo) MessageCount() (int32, error) {
+	m, err := qi.Management()
+	if err != nil {
+		if hresult(err) == mqErrorQueueNotActive {
+			return 0, nil
+		}
+
+		return 0, err
+	}
+	defer m.Close()
+
+	return m.MessageCount()
+}
+
+// MessageCount returns the number of messages in the queue. See
+// QueueManagement.MessageCount.
+func (q *Queue) MessageCount() (int32, error) {
+	if q.qi == nil {
+		return 0, fmt.Errorf("go-msmq: MessageCount() failed: %w", q.notOpen())
+	}
+
+	return q.qi.MessageCount()
+}
+
+// EodReceiveInfo describes the exactly-once-delivery (EOD) state of the
+// messages received by a transactional queue from a single sending queue.
+type EodReceiveInfo struct {
+	// QueueFormatName is the format name of the sending queue.
+	QueueFormatName string
+
+	// SenderID is the GUID of the sending queue manager.
+	SenderID string
+
+	// SequenceID identifies the current sequence of messages.
+	SequenceID uint64
+
+	// SequenceNumber is the number of the last message accepted in the
+	// sequence. A gap between the messages sent and SequenceNumber
+	// indicates messages that are still in transit or were rejected.
+	SequenceNumber uint32
+
+	// LastAccessTime is when the last message of the sequence was accepted.
+	LastAccessTime time.Time
+
+	// RejectCount is the number of messages of the sequence that were
+	// rejected.
+	RejectCount uint32
+}
+
+// EodGetReceiveInfo returns the exactly-once-delivery state of the queue for
+// each sending queue, which helps diagnose missing or duplicated transactional
+// messages.
+//
+// See: https://docs.microsoft.com/en-us/previous-versions/windows/desktop/legacy/ms706262(v=vs.85)
+func (m *QueueManagement) EodGetReceiveInfo() ([]EodReceiveInfo, error) {
+	res, err := callMethod(m.dispatch, "EodGetReceiveInfo")
+	if err != nil {
+		return nil, fmt.Errorf("go-msmq: EodGetReceiveInfo() failed to get receive information: %w", err)
+	}
+	defer res.Clear()
+
+	// The result is an array of VARIANT that hold one MSMQCollection per
+	// sending queue, or empty if no queue sent messages.
+	if res.VT&ole.VT_ARRAY == 0 {
+		return nil, nil
+	}
+
+	// Each collection is a reference of its own, so all of them are
+	// released even if reading one fails.
+	var collections []*ole.IDispatch
+	for _, value := range res.ToArray().ToValueArray() {
+		if collection, ok := value.(*ole.IDispatch); ok && collection != nil {
+			collections = append(collections, collection)
+		}
+	}
+	defer func() {
+		for _, collection := range collections {
+			release(collection)
+		}
+	}()
+
+	infos := make([]EodReceiveInfo, 0, len(collections))
+	for _, collection := range collections {
+		info, err := eodReceiveInfo(collection)
+		if err != nil {
+			return nil, fmt.Errorf("go-msmq: EodGetReceiveInfo() failed to get receive information: %w", err)
+		}
+
+		infos = append(infos, info)
+	}
+
+	return infos, nil
+}
+
+// eodReceiveInfo reads the named values of a collection returned by
+// EodGetReceiveInfo.
+func eodReceiveInfo(item *ole.IDispatch) (EodReceiveInfo, error) {
+	values := map[string]*ole.VARIANT{}
+	defer func() {
+		for _, v := range values {
+			v.Clear()
+		}
+	}()
+
+	for _, key := range []string{"QueueFormatName", "SenderID", "SequenceID", "SequenceNumber", "LastAccessTime", "RejectCount"} {
+		v, err := callMethod(item, "Item", key)
+		if err != nil {
+			return EodReceiveInfo{}, err
+		}
+
+		values[key] = v
+	}
+
+	info := EodReceiveInfo{
+		SequenceID:     toUint64(values["SequenceID"]),
+		SequenceNumber: uint32(toUint64(values["SequenceNumber"])),
+		RejectCount:    uint32(toUint64(values["RejectCount"])),
+	}
+	info.QueueFormatName, _ = values["QueueFormatName"].Value().(string)
+	info.SenderID, _ = values["SenderID"].Value().(string)
+	info.LastAccessTime, _ = values["LastAccessTime"].Value().(time.Time)
+
+	return info, nil
+}
@@ -2,22 +2,31 @@ package msmq
 
 import (
 	"fmt"
+	"io"
+	"time"
 
 	"github.com/go-ole/go-ole"
-	"github.com/go-ole/go-ole/oleutil"
 )
 
 type Message struct {
 	dispatch *ole.IDispatch
+
+	// native holds the properties of a message of a Queue returned by
+	// WrapMessageQueue, which has no message object. Only the properties of
+	// NativeMessage are supported.
+	native *NativeMessage
 }
 
-func NewMessage() (Message, error) {
-	unknown, err := oleutil.CreateObject("MSMQ.MSMQMessage")
-	if err != nil && err.Error() == "Invalid class string" {
-		return Message{}, ErrMSMQNotInstalled
-	}
+// IsZero reports whether m is the zero Message, such as the Message returned
+// by Queue.Peek and Queue.Receive when no message arrives before the timeout.
+// The properties of the zero Message return ErrNotInitialized, except Body,
+// BodyBytes and BodyType, which return an empty body.
+func (m *Message) IsZero() bool {
+	return m == nil || m.dispatch == nil && m.native == nil
+}
 
-	dispatch, err := unknown.QueryInterface(ole.IID_IDispatch)
+func NewMessage() (Message, error) {
+	dispatch, err := createObject("MSMQ.MSMQMessage")
 	if err != nil {
 		return Message{}, err
 	}
@@ -32,18 +41,92 @@ func NewMessage() (Message, error) {
 // Send sends a message to the queue. An option can be specified to indicate
 // whether the message is sent as a transaction.
 func (m *Message) Send(queue *Queue, opts ...SendOption) error {
+	if queue == nil {
+		return fmt.Errorf("go-msmq: Send() failed to send message: %w: nil Queue", ErrNotInitialized)
+	}
+
+	d := currentDefaults()
 	options := &sendOptions{
-		level: MTS,
+		level: d.TransactionLevel,
+		retry: d.Retry,
 	}
 	for _, o := range opts {
 		o.set(options)
 	}
 
-	_, err := m.dispatch.CallMethod("Send", queue.dispatch, int(options.level))
+	if queue.mq != nil {
+		start := time.Now()
+		err := options.retry.Do(func() error {
+			return queue.sendNative(m, options.level, options.tx)
+		})
+		queue.record("Send", start, err, err == nil)
+		if err != nil {
+			return fmt.Errorf("go-msmq: Send() failed to send message: %w", err)
+		}
+
+		return nil
+	}
+
+	tx, err := transactionArg(options.level, options.tx)
 	if err != nil {
 		return fmt.Errorf("go-msmq: Send() failed to send message: %w", err)
 	}
 
+	start := time.Now()
+	err = m.send(queue, tx, options.retry)
+	queue.record("Send", start, err, err == nil)
+	if err != nil {
+		return fmt.Errorf("go-msmq: Send() failed to send message: %w", err)
+	}
+
+	return nil
+}
+
+// send sends the message to queue.
+func (m *Message) send(queue *Queue, tx interface{}, retry *RetryPolicy) error {
+	return retry.Do(func() error {
+		_, err := queue.do(true, func(dispatch *ole.IDispatch) (*ole.VARIANT, error) {
+			return callMethod(m.dispatch, "Send", dispatch, tx)
+		})
+		return err
+	})
+}
+
+// SendTo sends a message to every queue referenced by the destination. An
+// option can be specified to indicate whether the message is sent as a
+// transaction.
+func (m *Message) SendTo(dest *Destination, opts ...SendOption) error {
+	if dest == nil {
+		return fmt.Errorf("go-msmq: SendTo() failed to send message: %w: nil Destination", ErrNotInitialized)
+	}
+
+	d := currentDefaults()
+	options := &sendOptions{
+		level: d.TransactionLevel,
+		retry: d.Retry,
+	}
+	for _, o := range opts {
+		o.set(options)
+	}
+
+	tx, err := transactionArg(options.level, options.tx)
+	if err != nil {
+		return fmt.Errorf("go-msmq: SendTo() failed to send message: %w", err)
+	}
+
+	start := time.Now()
+	err = options.retry.Do(func() error {
+		_, err := callMethod(m.dispatch, "Send", dest.dispatch, tx)
+		return err
+	})
+	logOperation(currentLogger(), "SendTo", start, err)
+	if o := currentObserver(); o != nil {
+		o.Observe(Operation{Name: "SendTo", Duration: time.Since(start), Message: err == nil, Err: err})
+	}
+	if err != nil {
+		return fmt.Errorf("go-msmq: SendTo() failed to send message: %w", err)
+	}
+
 	return nil
 }
 
@@ -55,12 +138,14 @@ type SendOption struct {
 // sendOptions contains all the options to send messages to a queue.
 type sendOptions struct {
 	level TransactionLevel
+	tx    *Transaction
+	retry *RetryPolicy
 }
 
 // SendWithTransaction returns a SendOption that configures sending messages
 // to a queue with the specified level value.
 //
-// The default is MTS.
+// The default is MTS, or the TransactionLevel set with SetDefaults.
 func SendWithTransaction(level TransactionLevel) SendOption {
 	return SendOption{
 		set: func(o *sendOptions) {
@@ -69,18 +154,45 @@ func SendWithTransaction(level TransactionLevel) SendOption {
 	}
 }
 
+// SendWithRetry returns a SendOption that retries sending messages according
+// to policy when the send fails with a retryable error.
+//
+// The default is not to retry, or the Retry policy set with SetDefaults.
+func SendWithRetry(policy *RetryPolicy) SendOption {
+	return SendOption{
+		set: func(o *sendOptions) {
+			o.retry = policy
+		},
+	}
+}
+
+// SendInTransaction returns a SendOption that configures sending messages to
+// a queue as part of tx. It takes precedence over SendWithTransaction.
+func SendInTransaction(tx *Transaction) SendOption {
+	return SendOption{
+		set: func(o *sendOptions) {
+			o.tx = tx
+		},
+	}
+}
+
 func (m *Message) Body() (string, error) {
 	// Assert that the message is not empty. This can happen in scenarios
 	// like a Queue.Peek() timing out which returns a "Nothing" object
 	// which is equivalent to an empty Message struct.
-	if (Message{}) == *m {
+	if m.IsZero() {
 		return "", nil
 	}
 
-	res, err := m.dispatch.GetProperty("Body")
+	if m.native != nil {
+		return m.native.text()
+	}
+
+	res, err := getProperty(m.dispatch, "Body")
 	if err != nil {
 		return "", err
 	}
+	defer res.Clear()
 
 	switch {
 	// Applications using win32 API to communicate with MSMQ set message
@@ -94,12 +206,49 @@ func (m *Message) Body() (string, error) {
 	case res.VT&ole.VT_ARRAY != 0:
 		return string(res.ToArray().ToByteArray()), nil
 	default:
-		return res.Value().(string), nil
+		return variantString(res, "Body")
+	}
+}
+
+// BodyBytes returns the body of the message as a byte slice. A string body is
+// returned as its UTF-8 encoding.
+func (m *Message) BodyBytes() ([]byte, error) {
+	// See Message.Body for why an empty message is valid.
+	if m.IsZero() {
+		return nil, nil
+	}
+
+	if m.native != nil {
+		s, err := m.native.text()
+		if err != nil {
+			return nil, fmt.Errorf("go-msmq: BodyBytes() failed to get Body: %w", err)
+		}
+		return []byte(s), nil
+	}
+
+	res, err := getProperty(m.dispatch, "Body")
+	if err != nil {
+		return nil, fmt.Errorf("go-msmq: BodyBytes() failed to get Body: %w", err)
+	}
+	defer res.Clear()
+
+	switch {
+	case res.VT&ole.VT_ARRAY != 0:
+		return res.ToArray().ToByteArray(), nil
+	default:
+		s, err := variantString(res, "Body")
+		return []byte(s), err
 	}
 }
 
 func (m *Message) SetBody(s string) error {
-	_, err := m.dispatch.PutProperty("Body", s)
+	if m.native != nil {
+		_, m.native.Body, _ = EncodeBody(s)
+		m.native.BodyType = BodyTypeString
+		return nil
+	}
+
+	_, err := putProperty(m.dispatch, "Body", s)
 	if err != nil {
 		return err
 	}
@@ -107,12 +256,745 @@ func (m *Message) SetBody(s string) error {
 	return nil
 }
 
+// SetBodyBytes sets the body of the message to an array of bytes.
+func (m *Message) SetBodyBytes(b []byte) error {
+	if m.native != nil {
+		m.native.Body, m.native.BodyType = b, BodyTypeBytes
+		return nil
+	}
+
+	_, err := putProperty(m.dispatch, "Body", b)
+	if err != nil {
+		return fmt.Errorf("go-msmq: SetBodyBytes() failed to set Body: %w", err)
+	}
+
+	return nil
+}
+
+// ArrivedTime returns when the message arrived at its destination queue. The
+// value is automatically converted to the local system time and system date.
+func (m *Message) ArrivedTime() (time.Time, error) {
+	if m.native != nil {
+		return m.native.ArrivedTime, nil
+	}
+
+	res, err := getProperty(m.dispatch, "ArrivedTime")
+	if err != nil {
+		return time.Time{}, fmt.Errorf("go-msmq: ArrivedTime() failed to get ArrivedTime: %w", err)
+	}
+	defer res.Clear()
+
+	return variantTime(res, "ArrivedTime")
+}
+
+// BodyLength returns the size (in bytes) of the body of the message.
+func (m *Message) BodyLength() (int32, error) {
+	if m.native != nil {
+		return int32(len(m.native.Body)), nil
+	}
+
+	res, err := getProperty(m.dispatch, "BodyLength")
+	if err != nil {
+		return 0, fmt.Errorf("go-msmq: BodyLength() failed to get BodyLength: %w", err)
+	}
+	defer res.Clear()
+
+	return variantInt32(res, "BodyLength")
+}
+
+// Label returns the label of the message.
+func (m *Message) Label() (string, error) {
+	if m.native != nil {
+		return m.native.Label, nil
+	}
+
+	res, err := getProperty(m.dispatch, "Label")
+	if err != nil {
+		return "", fmt.Errorf("go-msmq: Label() failed to get Label: %w", err)
+	}
+	defer res.Clear()
+
+	return variantString(res, "Label")
+}
+
+// SetLabel sets the label of the message. The label can be used to describe
+// the message and is limited to 250 characters.
+func (m *Message) SetLabel(label string) error {
+	if m.native != nil {
+		m.native.Label = label
+		return nil
+	}
+
+	_, err := putProperty(m.dispatch, "Label", label)
+	if err != nil {
+		return fmt.Errorf("go-msmq: SetLabel(%s) failed to set Label: %w", label, err)
+	}
+
+	return nil
+}
+
 // LookupID returns the lookup identifier of the message.
-func (m *Message) LookupID() (string, error) {
-	res, err := m.dispatch.GetProperty("LookupId")
+func (m *Message) LookupID() (LookupID, error) {
+	if m.native != nil {
+		return m.native.LookupID, nil
+	}
+
+	res, err := getProperty(m.dispatch, "LookupId")
+	if err != nil {
+		return 0, fmt.Errorf("go-msmq: LookupID() failed to get LookupId: %w", err)
+	}
+	defer res.Clear()
+
+	s, err := variantString(res, "LookupId")
+	if err != nil {
+		return 0, err
+	}
+
+	return ParseLookupID(s)
+}
+
+// AckLevel defines which acknowledgment messages MSMQ sends to the
+// administration queue of a message.
+type AckLevel int32
+
+const (
+	// AckNone specifies that no acknowledgment is sent. This is the
+	// default.
+	AckNone AckLevel = 0
+
+	// AckFullReachQueue specifies that a positive or negative
+	// acknowledgment is sent depending on whether the message reaches the
+	// queue.
+	AckFullReachQueue AckLevel = 5
+
+	// AckFullReceive specifies that a positive or negative acknowledgment
+	// is sent depending on whether the message is received before its
+	// time-to-be-received expires.
+	AckFullReceive AckLevel = 14
+
+	// AckNackReachQueue specifies that a negative acknowledgment is sent
+	// when the message cannot reach the queue.
+	AckNackReachQueue AckLevel = 4
+
+	// AckNackReceive specifies that a negative acknowledgment is sent when
+	// the message cannot reach the queue or is not received before its
+	// time-to-be-received expires.
+	AckNackReceive AckLevel = 12
+)
+
+// Ack returns which acknowledgment messages are sent for the message.
+func (m *Message) Ack() (AckLevel, error) {
+	res, err := getProperty(m.dispatch, "Ack")
+	if err != nil {
+		return 0, fmt.Errorf("go-msmq: Ack() failed to get Ack: %w", err)
+	}
+	defer res.Clear()
+
+	v, err := variantInt32(res, "Ack")
+	return AckLevel(v), err
+}
+
+// SetAck sets which acknowledgment messages MSMQ sends to the administration
+// queue of the message. An administration queue must be set with
+// SetAdminQueueInfo.
+//
+// See: https://docs.microsoft.com/en-us/previous-versions/windows/desktop/legacy/ms700225(v=vs.85)
+func (m *Message) SetAck(level AckLevel) error {
+	_, err := putProperty(m.dispatch, "Ack", int32(level))
+	if err != nil {
+		return fmt.Errorf("go-msmq: SetAck(%d) failed to set Ack: %w", level, err)
+	}
+
+	return nil
+}
+
+// AdminQueueInfo returns the QueueInfo of the administration queue that
+// receives the acknowledgment messages of the message.
+func (m *Message) AdminQueueInfo() (*QueueInfo, error) {
+	res, err := getProperty(m.dispatch, "AdminQueueInfo")
+	if err != nil {
+		return nil, fmt.Errorf("go-msmq: AdminQueueInfo() failed to get AdminQueueInfo: %w", err)
+	}
+
+	return &QueueInfo{
+		dispatch: track(res.ToIDispatch(), "MSMQ.MSMQQueueInfo"),
+	}, nil
+}
+
+// SetAdminQueueInfo sets the administration queue that receives the
+// acknowledgment messages of the message. The queue must not be
+// transactional.
+//
+// See: https://docs.microsoft.com/en-us/previous-versions/windows/desktop/legacy/ms705172(v=vs.85)
+func (m *Message) SetAdminQueueInfo(qi *QueueInfo) error {
+	_, err := putPropertyRef(m.dispatch, "AdminQueueInfo", qi.dispatch)
+	if err != nil {
+		return fmt.Errorf("go-msmq: SetAdminQueueInfo() failed to set AdminQueueInfo: %w", err)
+	}
+
+	return nil
+}
+
+// MessageClass defines whether a message is a normal message, a report
+// message or an acknowledgment, and for acknowledgments, what happened to
+// the original message.
+type MessageClass int32
+
+const (
+	// ClassNormal is a message sent by an application.
+	ClassNormal MessageClass = 0x0
+
+	// ClassReport is a report message.
+	ClassReport MessageClass = 0x1
+
+	// ClassAckReachQueue acknowledges that the original message reached
+	// its queue.
+	ClassAckReachQueue MessageClass = 0x2
+
+	// ClassAckReceive acknowledges that the original message was received.
+	ClassAckReceive MessageClass = 0x4000
+
+	// ClassNackBadDestinationQueue reports that the destination queue is
+	// not available.
+	ClassNackBadDestinationQueue MessageClass = 0x8000
+
+	// ClassNackDeleted reports that the original message was deleted from
+	// an outgoing queue before it reached its queue.
+	ClassNackDeleted MessageClass = 0x8001
+
+	// ClassNackReachQueueTimeout reports that the time-to-reach-queue of
+	// the original message expired.
+	ClassNackReachQueueTimeout MessageClass = 0x8002
+
+	// ClassNackQueueExceedQuota reports that the quota of the destination
+	// queue was exceeded.
+	ClassNackQueueExceedQuota MessageClass = 0x8003
+
+	// ClassNackAccessDenied reports that the sender cannot send to the
+	// destination queue.
+	ClassNackAccessDenied MessageClass = 0x8004
+
+	// ClassNackHopCountExceeded reports that the original message exceeded
+	// the maximum number of routing hops.
+	ClassNackHopCountExceeded MessageClass = 0x8005
+
+	// ClassNackBadSignature reports that the signature of the original
+	// message is not valid.
+	ClassNackBadSignature MessageClass = 0x8006
+
+	// ClassNackBadEncryption reports that the original message could not
+	// be decrypted.
+	ClassNackBadEncryption MessageClass = 0x8007
+
+	// ClassNackCouldNotEncrypt reports that the original message could not
+	// be encrypted.
+	ClassNackCouldNotEncrypt MessageClass = 0x8008
+
+	// ClassNackNotTransactionalQueue reports that a transactional message
+	// was sent to a nontransactional queue.
+	ClassNackNotTransactionalQueue MessageClass = 0x8009
+
+	// ClassNackNotTransactionalMessage reports that a nontransactional
+	// message was sent to a transactional queue.
+	ClassNackNotTransactionalMessage MessageClass = 0x800A
+
+	// ClassNackQueueDeleted reports that the queue was deleted before the
+	// original message was received.
+	ClassNackQueueDeleted MessageClass = 0xC000
+
+	// ClassNackQueuePurged reports that the queue was purged before the
+	// original message was received.
+	ClassNackQueuePurged MessageClass = 0xC001
+
+	// ClassNackReceiveTimeout reports that the time-to-be-received of the
+	// original message expired while it was in its queue.
+	ClassNackReceiveTimeout MessageClass = 0xC002
+
+	// ClassNackReceiveTimeoutAtSender reports that the time-to-be-received
+	// of the original message expired before it left the sending computer.
+	ClassNackReceiveTimeoutAtSender MessageClass = 0xC003
+)
+
+// IsNack reports whether c is a negative acknowledgment.
+func (c MessageClass) IsNack() bool {
+	return c&0x8000 != 0
+}
+
+// IsExpiration reports whether c reports that a timeout of the original
+// message expired.
+func (c MessageClass) IsExpiration() bool {
+	switch c {
+	case ClassNackReachQueueTimeout, ClassNackReceiveTimeout, ClassNackReceiveTimeoutAtSender:
+		return true
+	}
+
+	return false
+}
+
+// Class returns the class of the message.
+//
+// See: https://docs.microsoft.com/en-us/previous-versions/windows/desktop/legacy/ms700281(v=vs.85)
+func (m *Message) Class() (MessageClass, error) {
+	res, err := getProperty(m.dispatch, "Class")
+	if err != nil {
+		return 0, fmt.Errorf("go-msmq: Class() failed to get Class: %w", err)
+	}
+	defer res.Clear()
+
+	v, err := variantInt32(res, "Class")
+	return MessageClass(v), err
+}
+
+// MaxTimeToReachQueue returns how long the message has to reach its queue.
+// LongLived means the LONG_LIVED setting of the computer applies, and any
+// other negative duration means no limit.
+func (m *Message) MaxTimeToReachQueue() (time.Duration, error) {
+	res, err := getProperty(m.dispatch, "MaxTimeToReachQueue")
+	if err != nil {
+		return 0, fmt.Errorf("go-msmq: MaxTimeToReachQueue() failed to get MaxTimeToReachQueue: %w", err)
+	}
+	defer res.Clear()
+
+	v, err := variantInt32(res, "MaxTimeToReachQueue")
+	return seconds(v), err
+}
+
+// SetMaxTimeToReachQueue sets how long the message has to reach its queue,
+// rounded down to the second. LongLived, the default, applies the LONG_LIVED
+// setting of the computer, and any other negative duration means no limit.
+//
+// See: https://docs.microsoft.com/en-us/previous-versions/windows/desktop/legacy/ms706165(v=vs.85)
+func (m *Message) SetMaxTimeToReachQueue(d time.Duration) error {
+	_, err := putProperty(m.dispatch, "MaxTimeToReachQueue", durationSeconds(d))
+	if err != nil {
+		return fmt.Errorf("go-msmq: SetMaxTimeToReachQueue(%s) failed to set MaxTimeToReachQueue: %w", d, err)
+	}
+
+	return nil
+}
+
+// MaxTimeToReceive returns how long the message has to be received once it
+// is sent. A negative duration means no limit.
+func (m *Message) MaxTimeToReceive() (time.Duration, error) {
+	res, err := getProperty(m.dispatch, "MaxTimeToReceive")
+	if err != nil {
+		return 0, fmt.Errorf("go-msmq: MaxTimeToReceive() failed to get MaxTimeToReceive: %w", err)
+	}
+	defer res.Clear()
+
+	v, err := variantInt32(res, "MaxTimeToReceive")
+	return seconds(v), err
+}
+
+// SetMaxTimeToReceive sets how long the message has to be received once it is
+// sent, rounded down to the second. When it expires, the message is removed
+// from its queue. A negative duration means no limit, which is the default.
+//
+// See: https://docs.microsoft.com/en-us/previous-versions/windows/desktop/legacy/ms703988(v=vs.85)
+func (m *Message) SetMaxTimeToReceive(d time.Duration) error {
+	// LONG_LIVED only applies to MaxTimeToReachQueue.
+	if d < 0 {
+		d = -1
+	}
+
+	_, err := putProperty(m.dispatch, "MaxTimeToReceive", durationSeconds(d))
+	if err != nil {
+		return fmt.Errorf("go-msmq: SetMaxTimeToReceive(%s) failed to set MaxTimeToReceive: %w", d, err)
+	}
+
+	return nil
+}
+
+// LongLived is the MaxTimeToReachQueue of a message that applies the
+// LONG_LIVED setting of the computer, 90 days unless configured otherwise,
+// which is the default of new messages.
+const LongLived time.Duration = -2
+
+// The values of the timeout properties of a message that are not a number of
+// seconds.
+const (
+	infiniteSeconds  int32 = -1 // INFINITE
+	longLivedSeconds int32 = -2 // LONG_LIVED
+)
+
+// seconds returns the duration of a timeout property of a message. The
+// INFINITE and LONG_LIVED values are negative.
+func seconds(v int32) time.Duration {
+	switch {
+	case v == longLivedSeconds:
+		return LongLived
+	case v < 0:
+		return -1
+	default:
+		return time.Duration(v) * time.Second
+	}
+}
+
+// durationSeconds returns d as the value of a timeout property of a message.
+func durationSeconds(d time.Duration) int32 {
+	switch {
+	case d == LongLived:
+		return longLivedSeconds
+	case d < 0:
+		return infiniteSeconds
+	default:
+		return int32(d / time.Second)
+	}
+}
+
+// AppSpecific returns the application-specific information of the message.
+func (m *Message) AppSpecific() (int32, error) {
+	if m.native != nil {
+		return int32(m.native.AppSpecific), nil
+	}
+
+	res, err := getProperty(m.dispatch, "AppSpecific")
 	if err != nil {
-		return "", fmt.Errorf("go-msmq: LookupID() failed to get LookupId: %w", err)
+		return 0, fmt.Errorf("go-msmq: AppSpecific() failed to get AppSpecific: %w", err)
 	}
+	defer res.Clear()
+
+	return variantInt32(res, "AppSpecific")
+}
 
-	return res.Value().(string), nil
+// SetAppSpecific sets application-specific information, such as a message
+// type, that can be used to filter or route messages without reading their
+// bodies.
+//
+// See: https://docs.microsoft.com/en-us/previous-versions/windows/desktop/legacy/ms700277(v=vs.85)
+func (m *Message) SetAppSpecific(i int32) error {
+	if m.native != nil {
+		m.native.AppSpecific = uint32(i)
+		return nil
+	}
+
+	_, err := putProperty(m.dispatch, "AppSpecific", i)
+	if err != nil {
+		return fmt.Errorf("go-msmq: SetAppSpecific(%d) failed to set AppSpecific: %w", i, err)
+	}
+
+	return nil
+}
+
+// CorrelationID returns the 20-byte correlation identifier of the message.
+func (m *Message) CorrelationID() ([]byte, error) {
+	if m.native != nil {
+		return append([]byte(nil), m.native.CorrelationID[:]...), nil
+	}
+
+	res, err := getProperty(m.dispatch, "CorrelationId")
+	if err != nil {
+		return nil, fmt.Errorf("go-msmq: CorrelationID() failed to get CorrelationId: %w", err)
+	}
+	defer res.Clear()
+
+	return variantBytes(res, "CorrelationId")
+}
+
+// SetCorrelationID sets the 20-byte correlation identifier of the message. It
+// is typically set to the ID of another message, such as the request that a
+// response message answers.
+//
+// See: https://docs.microsoft.com/en-us/previous-versions/windows/desktop/legacy/ms705201(v=vs.85)
+func (m *Message) SetCorrelationID(id []byte) error {
+	if m.native != nil {
+		if len(id) != len(m.native.CorrelationID) {
+			return fmt.Errorf("go-msmq: SetCorrelationID() failed to set CorrelationId: got %d bytes, want %d", len(id), len(m.native.CorrelationID))
+		}
+		copy(m.native.CorrelationID[:], id)
+		return nil
+	}
+
+	_, err := putProperty(m.dispatch, "CorrelationId", id)
+	if err != nil {
+		return fmt.Errorf("go-msmq: SetCorrelationID() failed to set CorrelationId: %w", err)
+	}
+
+	return nil
+}
+
+// Delivery returns how the message is delivered.
+func (m *Message) Delivery() (DeliveryMode, error) {
+	if m.native != nil {
+		return m.native.Delivery, nil
+	}
+
+	res, err := getProperty(m.dispatch, "Delivery")
+	if err != nil {
+		return 0, fmt.Errorf("go-msmq: Delivery() failed to get Delivery: %w", err)
+	}
+	defer res.Clear()
+
+	v, err := variantInt32(res, "Delivery")
+	return DeliveryMode(v), err
+}
+
+// SetDelivery sets how the message is delivered. The default is Express.
+// Messages sent as part of a transaction are always Recoverable.
+//
+// See: https://docs.microsoft.com/en-us/previous-versions/windows/desktop/legacy/ms700721(v=vs.85)
+func (m *Message) SetDelivery(mode DeliveryMode) error {
+	if m.native != nil {
+		m.native.Delivery = mode
+		return nil
+	}
+
+	_, err := putProperty(m.dispatch, "Delivery", int32(mode))
+	if err != nil {
+		return fmt.Errorf("go-msmq: SetDelivery(%d) failed to set Delivery: %w", mode, err)
+	}
+
+	return nil
+}
+
+// DeliveryMode defines how MSMQ delivers a message.
+type DeliveryMode int32
+
+const (
+	// Express specifies that the message is stored in memory until it is
+	// delivered, so it is lost if the computer storing it fails.
+	Express DeliveryMode = 0
+
+	// Recoverable specifies that the message is stored on disk at every hop
+	// until it is delivered.
+	Recoverable DeliveryMode = 1
+)
+
+// DestinationQueueInfo returns the QueueInfo of the queue the message was sent
+// to. It is only available if the message was read with the want destination
+// queue option set to true.
+func (m *Message) DestinationQueueInfo() (*QueueInfo, error) {
+	res, err := getProperty(m.dispatch, "DestinationQueueInfo")
+	if err != nil {
+		return nil, fmt.Errorf("go-msmq: DestinationQueueInfo() failed to get DestinationQueueInfo: %w", err)
+	}
+
+	return &QueueInfo{
+		dispatch: track(res.ToIDispatch(), "MSMQ.MSMQQueueInfo"),
+	}, nil
+}
+
+// Extension returns the application-defined extension of the message.
+func (m *Message) Extension() ([]byte, error) {
+	res, err := getProperty(m.dispatch, "Extension")
+	if err != nil {
+		return nil, fmt.Errorf("go-msmq: Extension() failed to get Extension: %w", err)
+	}
+	defer res.Clear()
+
+	if res.VT&ole.VT_ARRAY == 0 {
+		return nil, nil
+	}
+
+	return res.ToArray().ToByteArray(), nil
+}
+
+// SetExtension sets additional application-defined information that is
+// associated with the message.
+//
+// See: https://docs.microsoft.com/en-us/previous-versions/windows/desktop/legacy/ms704968(v=vs.85)
+func (m *Message) SetExtension(b []byte) error {
+	_, err := putProperty(m.dispatch, "Extension", b)
+	if err != nil {
+		return fmt.Errorf("go-msmq: SetExtension() failed to set Extension: %w", err)
+	}
+
+	return nil
+}
+
+// ID returns the 20-byte identifier that MSMQ generates when the message is
+// sent.
+func (m *Message) ID() ([]byte, error) {
+	if m.native != nil {
+		return append([]byte(nil), m.native.ID[:]...), nil
+	}
+
+	res, err := getProperty(m.dispatch, "Id")
+	if err != nil {
+		return nil, fmt.Errorf("go-msmq: ID() failed to get Id: %w", err)
+	}
+	defer res.Clear()
+
+	return variantBytes(res, "Id")
+}
+
+// JournalLevel defines whether MSMQ keeps a copy of a message in the journal
+// of the sending computer, or moves it to the dead-letter queue if it cannot
+// be delivered. The values can be combined.
+type JournalLevel int32
+
+const (
+	// JournalNone specifies that no copy of the message is kept. This is
+	// the default.
+	JournalNone JournalLevel = 0
+
+	// JournalDeadLetter specifies that the message is moved to the
+	// dead-letter queue if it cannot be delivered or its time-to-be-received
+	// expires.
+	JournalDeadLetter JournalLevel = 1
+
+	// JournalSource specifies that a copy of the message is kept in the
+	// computer journal of the sending computer once the message is delivered.
+	JournalSource JournalLevel = 2
+)
+
+// Journal returns the journaling options of the message.
+func (m *Message) Journal() (JournalLevel, error) {
+	res, err := getProperty(m.dispatch, "Journal")
+	if err != nil {
+		return 0, fmt.Errorf("go-msmq: Journal() failed to get Journal: %w", err)
+	}
+	defer res.Clear()
+
+	v, err := variantInt32(res, "Journal")
+	return JournalLevel(v), err
+}
+
+// SetJournal sets the journaling options of the message.
+//
+// See: https://docs.microsoft.com/en-us/previous-versions/windows/desktop/legacy/ms699814(v=vs.85)
+func (m *Message) SetJournal(level JournalLevel) error {
+	_, err := putProperty(m.dispatch, "Journal", int32(level))
+	if err != nil {
+		return fmt.Errorf("go-msmq: SetJournal(%d) failed to set Journal: %w", level, err)
+	}
+
+	return nil
+}
+
+// SetUseDeadLetterQueue sets whether the message is moved to the dead-letter
+// queue if it cannot be delivered, leaving JournalSource unchanged. Messages
+// that are sent as part of a transaction are always moved to the
+// transactional dead-letter queue. See HTTPFormatName for how this applies to
+// messages sent over HTTP.
+func (m *Message) SetUseDeadLetterQueue(enabled bool) error {
+	err := m.setJournalFlag(JournalDeadLetter, enabled)
+	if err != nil {
+		return fmt.Errorf("go-msmq: SetUseDeadLetterQueue(%t) failed to set Journal: %w", enabled, err)
+	}
+
+	return nil
+}
+
+// SetUseJournalQueue sets whether a copy of the message is kept in the
+// journal of the sending computer once it is delivered, leaving
+// JournalDeadLetter unchanged. See HTTPFormatName for how this applies to
+// messages sent over HTTP.
+func (m *Message) SetUseJournalQueue(enabled bool) error {
+	err := m.setJournalFlag(JournalSource, enabled)
+	if err != nil {
+		return fmt.Errorf("go-msmq: SetUseJournalQueue(%t) failed to set Journal: %w", enabled, err)
+	}
+
+	return nil
+}
+
+// setJournalFlag sets or clears flag in the journaling options of the message.
+func (m *Message) setJournalFlag(flag JournalLevel, enabled bool) error {
+	level, err := m.Journal()
+	if err != nil {
+		return err
+	}
+
+	if enabled {
+		level |= flag
+	} else {
+		level &^= flag
+	}
+
+	return m.SetJournal(level)
+}
+
+// Priority returns the priority of the message.
+func (m *Message) Priority() (int32, error) {
+	if m.native != nil {
+		return int32(m.native.Priority), nil
+	}
+
+	res, err := getProperty(m.dispatch, "Priority")
+	if err != nil {
+		return 0, fmt.Errorf("go-msmq: Priority() failed to get Priority: %w", err)
+	}
+	defer res.Clear()
+
+	return variantInt32(res, "Priority")
+}
+
+// SetPriority sets the priority of the message. The value must be between 0
+// (lowest) and 7 (highest). The default is 3. Priority is ignored by
+// transactional queues.
+//
+// See: https://docs.microsoft.com/en-us/previous-versions/windows/desktop/legacy/ms705175(v=vs.85)
+func (m *Message) SetPriority(priority int32) error {
+	if m.native != nil {
+		if priority < 0 || priority > 7 {
+			return fmt.Errorf("go-msmq: SetPriority(%d) failed to set Priority: must be between 0 and 7", priority)
+		}
+		m.native.Priority = uint8(priority)
+		return nil
+	}
+
+	_, err := putProperty(m.dispatch, "Priority", priority)
+	if err != nil {
+		return fmt.Errorf("go-msmq: SetPriority(%d) failed to set Priority: %w", priority, err)
+	}
+
+	return nil
+}
+
+// SentTime returns when the message was sent. The value is automatically
+// converted to the local system time and system date.
+func (m *Message) SentTime() (time.Time, error) {
+	if m.native != nil {
+		return m.native.SentTime, nil
+	}
+
+	res, err := getProperty(m.dispatch, "SentTime")
+	if err != nil {
+		return time.Time{}, fmt.Errorf("go-msmq: SentTime() failed to get SentTime: %w", err)
+	}
+	defer res.Clear()
+
+	return variantTime(res, "SentTime")
+}
+
+// TransactionStatusQueueInfo returns the QueueInfo of the transaction status
+// queue on the source computer. It is set by connector applications for
+// messages sent to foreign queues within a transaction, so that the receiving
+// connector can report the outcome of the transaction.
+//
+// See: https://docs.microsoft.com/en-us/previous-versions/windows/desktop/legacy/ms706219(v=vs.85)
+func (m *Message) TransactionStatusQueueInfo() (*QueueInfo, error) {
+	res, err := getProperty(m.dispatch, "TransactionStatusQueueInfo")
+	if err != nil {
+		return nil, fmt.Errorf("go-msmq: TransactionStatusQueueInfo() failed to get TransactionStatusQueueInfo: %w", err)
+	}
+
+	return &QueueInfo{
+		dispatch: track(res.ToIDispatch(), "MSMQ.MSMQQueueInfo"),
+	}, nil
+}
+
+// Close releases the underlying message object. Messages returned by Peek and
+// Receive, and messages created with NewMessage, hold a reference to a COM
+// object and should be closed once they are no longer needed; long-running
+// consumers would otherwise accumulate references. The Message cannot be used
+// after it is closed. Calling Close more than once has no effect.
+func (m *Message) Close() error {
+	m.release()
+	return nil
+}
+
+// Message satisfies io.Closer so that it can be managed alongside other
+// resources.
+var _ io.Closer = (*Message)(nil)
+
+// release releases the underlying message object. The message must not be used
+// afterwards.
+func (m *Message) release() {
+	if m.dispatch != nil {
+		release(m.dispatch)
+		m.dispatch = nil
+	}
+	m.native = nil
 }
@@ -0,0 +1,136 @@
+package msmq
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrUnsupportedOperation is returned by the operations of a Queue returned
+// by WrapMessageQueue that MessageQueue does not provide.
+var ErrUnsupportedOperation = errors.New("go-msmq: operation is not supported by the wrapped MessageQueue")
+
+// WrapMessageQueue returns a Queue that sends, peeks and receives messages
+// through mq, so that the types built on Queue, such as Consumer and
+// Producer, can run against the in-memory queues of the msmqfake package:
+//   fq, err := server.Open(`DIRECT=OS:.\private$\orders`, msmq.Receive, msmq.DenyNone)
+//   ...
+//   consumer := msmq.NewConsumer(msmq.WrapMessageQueue(fq))
+//
+// Message.Send, Peek, Receive, TryPeek, TryReceive and Close are delegated to
+// mq, with the transaction level of their options; the other operations
+// return ErrUnsupportedOperation. Internal transactions cannot be used with
+// mq, so options that need them, such as ConsumerWithTransactional and
+// ProducerWithTransactional, fail, and neither does ConsumerWithPriority work,
+// since it receives messages by lookup identifier.
+//
+// The messages of the Queue carry the properties of NativeMessage and return
+// ErrNilObject for the others. Only such messages, which are received from a
+// wrapped MessageQueue or created by a Producer of one, can be sent to it.
+func WrapMessageQueue(mq MessageQueue) *Queue {
+	q := &Queue{mq: mq}
+	q.open.Store(true)
+	return q
+}
+
+// wrapped reports whether q was returned by WrapMessageQueue.
+func (q *Queue) wrapped() bool {
+	return q != nil && q.mq != nil
+}
+
+// sendNative sends msg through the MessageQueue of q.
+func (q *Queue) sendNative(msg *Message, level TransactionLevel, tx *Transaction) error {
+	if tx != nil {
+		return ErrTransactionUsage
+	}
+	if msg.native == nil {
+		return fmt.Errorf("%w: the message was not created for a wrapped MessageQueue", ErrNilObject)
+	}
+
+	return q.mq.Send(msg.native, level)
+}
+
+// receiveNative receives or, if peek is true, peeks the first message through
+// the MessageQueue of q. The zero Message is returned if timeout, in
+// milliseconds, expires first, as with the message object model.
+func (q *Queue) receiveNative(peek bool, level TransactionLevel, tx *Transaction, timeout int) (Message, error) {
+	op := "Receive"
+	if peek {
+		op = "Peek"
+	}
+
+	start := time.Now()
+	msg, err := q.doReceiveNative(peek, level, tx, timeout)
+	q.record(op, start, err, msg != nil)
+	if err != nil {
+		return Message{}, err
+	}
+
+	return nativeMessage(msg), nil
+}
+
+// doReceiveNative receives or peeks the first message through the
+// MessageQueue of q.
+func (q *Queue) doReceiveNative(peek bool, level TransactionLevel, tx *Transaction, timeout int) (*NativeMessage, error) {
+	if tx != nil {
+		return nil, ErrTransactionUsage
+	}
+
+	// The message object model waits indefinitely for the largest timeout,
+	// and MessageQueue for a negative one.
+	d := time.Duration(-1)
+	if timeout >= 0 && timeout < 1<<31-1 {
+		d = time.Duration(timeout) * time.Millisecond
+	}
+
+	var (
+		msg *NativeMessage
+		err error
+	)
+	if peek {
+		msg, err = q.mq.Peek(d)
+	} else {
+		msg, err = q.mq.Receive(d, level)
+	}
+	if errors.Is(err, ErrIOTimeout) {
+		return nil, nil
+	}
+
+	return msg, err
+}
+
+// nativeMessage returns a Message backed by msg, or the zero Message if msg is
+// nil.
+func nativeMessage(msg *NativeMessage) Message {
+	return Message{native: msg}
+}
+
+// text returns the body of m as a string: string bodies are decoded, and other
+// bodies are returned as is, like the byte arrays of the message object
+// model.
+func (m *NativeMessage) text() (string, error) {
+	switch m.BodyType {
+	case BodyTypeString, BodyTypeUnicodeString, BodyTypeANSIString:
+		v, err := m.Value()
+		if err != nil {
+			return "", err
+		}
+		return v.(string), nil
+	default:
+		return string(m.Body), nil
+	}
+}
+
+// closeNative closes the MessageQueue of q. Calling it more than once has no
+// effect.
+func (q *Queue) closeNative() error {
+	if !q.open.Swap(false) {
+		return nil
+	}
+
+	if err := q.mq.Close(); err != nil {
+		return fmt.Errorf("go-msmq: Close() failed to close queue: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,114 @@
+package msmq
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// Middleware wraps a Handler with behavior that runs around it, such as
+// logging, metrics or validation. Middleware is applied with Chain or
+// ConsumerWithMiddleware.
+type Middleware func(next Handler) Handler
+
+// SendFunc sends msg to queue. It is the signature of the send step of a
+// Producer that SendMiddleware wraps.
+type SendFunc func(ctx context.Context, msg *Message, queue *Queue, opts ...SendOption) error
+
+// SendMiddleware wraps a SendFunc with behavior that runs around it, such as
+// setting properties or encrypting the body before the message is sent.
+// SendMiddleware is applied with ChainSend or ProducerWithMiddleware.
+type SendMiddleware func(next SendFunc) SendFunc
+
+// Chain returns handler wrapped by middleware. The first middleware is the
+// outermost, so it runs first:
+//   h := msmq.Chain(handler, logging, metrics)
+// runs logging, then metrics, then handler.
+func Chain(handler Handler, middleware ...Middleware) Handler {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		handler = middleware[i](handler)
+	}
+
+	return handler
+}
+
+// ChainSend returns send wrapped by middleware. The first middleware is the
+// outermost, so it runs first.
+func ChainSend(send SendFunc, middleware ...SendMiddleware) SendFunc {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		send = middleware[i](send)
+	}
+
+	return send
+}
+
+// sendMessage is the SendFunc that sends msg with Message.Send.
+func sendMessage(ctx context.Context, msg *Message, queue *Queue, opts ...SendOption) error {
+	return msg.Send(queue, opts...)
+}
+
+// LoggingMiddleware returns a Middleware that logs the outcome of each
+// handled message to l, with the lookup identifier and label of the message
+// and the duration of the handler.
+func LoggingMiddleware(l Logger) Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, msg Message) error {
+			start := time.Now()
+			err := next(ctx, msg)
+
+			id, _ := msg.LookupID()
+			label, _ := msg.Label()
+			logOperation(l, "Handle", start, err, slog.String("lookup_id", id.String()), slog.String("label", label))
+			return err
+		}
+	}
+}
+
+// RecoverMiddleware returns a Middleware that turns a panic of the handler
+// into an error, so that a faulty message does not crash the Consumer.
+func RecoverMiddleware() Middleware {
+	return func(next Handler) Handler {
+		return func(ctx context.Context, msg Message) (err error) {
+			defer func() {
+				if r := recover(); r != nil {
+					err = &PanicError{Value: r}
+				}
+			}()
+
+			return next(ctx, msg)
+		}
+	}
+}
+
+// PanicError is returned by a handler wrapped with RecoverMiddleware that
+// panicked.
+type PanicError struct {
+	// Value is the value passed to panic.
+	Value interface{}
+}
+
+// Error implements the error interface.
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("go-msmq: handler panicked: %v", e.Value)
+}
+
+// ConsumerWithMiddleware returns a ConsumerOption that configures the
+// middleware wrapping the handler passed to Consumer.Start, outermost first.
+func ConsumerWithMiddleware(middleware ...Middleware) ConsumerOption {
+	return ConsumerOption{
+		set: func(opts *consumerOptions) {
+			opts.middleware = append(opts.middleware, middleware...)
+		},
+	}
+}
+
+// ProducerWithMiddleware returns a ProducerOption that configures the
+// middleware wrapping the sends of the Producer, outermost first.
+func ProducerWithMiddleware(middleware ...SendMiddleware) ProducerOption {
+	return ProducerOption{
+		set: func(opts *producerOptions) {
+			opts.middleware = append(opts.middleware, middleware...)
+		},
+	}
+}
@@ -0,0 +1,227 @@
+package msmq
+
+import "fmt"
+
+// Migrate copies every message of source to dest, or moves them with
+// MigrateWithMove, and returns the number of messages migrated. source must
+// be opened with Receive AccessMode, or Peek AccessMode to copy, and dest
+// with Send AccessMode. Either queue may be on another computer, opened with
+// a DIRECT format name:
+//   source, err := msmq.Open(`DIRECT=OS:old-server\private$\orders`, msmq.Options{AccessMode: msmq.Receive})
+//   ...
+//   dest, err := msmq.Open(`DIRECT=OS:new-server\private$\orders`, msmq.Options{AccessMode: msmq.Send})
+//   ...
+//   n, err := msmq.Migrate(source, dest, msmq.MigrateWithMove(true), msmq.MigrateWithTransactional(true, true))
+//
+// Like Import, the messages keep their body, label, application-specific
+// information, correlation identifier, extension, priority and delivery mode.
+//
+// Messages are migrated in batches. A batch is sent to, and received from,
+// the queues declared transactional with MigrateWithTransactional in a single
+// transaction, so that a failure leaves the batch entirely in source and
+// nowhere in dest. Otherwise, a moved message is removed from source only
+// once it is sent, so that a failure may duplicate messages but never loses
+// them.
+//
+// A move is resumed by calling Migrate again, since the migrated messages are
+// no longer in source. A copy is resumed with MigrateWithResume and the last
+// lookup identifier reported to MigrateWithOnBatch.
+func Migrate(source, dest *Queue, opts ...MigrateOption) (int, error) {
+	options := &migrateOptions{
+		batchSize: 100,
+	}
+	for _, o := range opts {
+		o.set(options)
+	}
+
+	n := 0
+	last := options.resume
+	for {
+		migrated, id, err := migrateBatch(source, dest, last, options)
+		if err != nil {
+			return n, fmt.Errorf("go-msmq: Migrate() failed to migrate messages: %w", err)
+		}
+		if migrated == 0 {
+			return n, nil
+		}
+
+		n += migrated
+		// Moved messages are removed, so a move always starts from the first
+		// message.
+		if !options.move {
+			last = id
+		}
+		if options.onBatch != nil {
+			options.onBatch(n, id)
+		}
+	}
+}
+
+// migrateBatch migrates up to a batch of messages following the lookup
+// identifier after, or starting from the first message if after is 0. It
+// returns the number of messages migrated and the lookup identifier of the
+// last one.
+func migrateBatch(source, dest *Queue, after LookupID, options *migrateOptions) (int, LookupID, error) {
+	var tx *Transaction
+	if options.destTransactional || options.move && options.sourceTransactional {
+		var err error
+		tx, err = BeginTransaction()
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+	abort := func(err error) (int, LookupID, error) {
+		if tx != nil {
+			tx.Abort()
+		}
+		return 0, 0, err
+	}
+
+	sendOpt := SendWithTransaction(NoTransaction)
+	if options.destTransactional {
+		sendOpt = SendInTransaction(tx)
+	}
+
+	var ids []LookupID
+	id := after
+	for len(ids) < options.batchSize {
+		var msg Message
+		var err error
+		if id == 0 {
+			msg, err = source.PeekFirstByLookupID()
+		} else {
+			msg, err = source.PeekNextByLookupID(id)
+		}
+		if err != nil {
+			return abort(err)
+		}
+		if msg.dispatch == nil {
+			break
+		}
+
+		b, err := backupMessage(&msg)
+		msg.release()
+		if err != nil {
+			return abort(err)
+		}
+		if err := b.send(dest, sendOpt); err != nil {
+			return abort(err)
+		}
+
+		id = b.LookupID
+		ids = append(ids, id)
+	}
+
+	remove := func(opt ReceiveByLookupIDOption) error {
+		for _, id := range ids {
+			msg, err := source.ReceiveByLookupID(id, opt, ReceiveByLookupIDWithWantBody(false))
+			if err != nil {
+				return err
+			}
+			msg.release()
+		}
+		return nil
+	}
+
+	if options.move && options.sourceTransactional {
+		if err := remove(ReceiveByLookupIDInTransaction(tx)); err != nil {
+			return abort(err)
+		}
+	}
+	if tx != nil {
+		if err := tx.Commit(); err != nil {
+			return 0, 0, err
+		}
+	}
+	// Messages of a non-transactional source are removed once they are
+	// sent for good.
+	if options.move && !options.sourceTransactional {
+		if err := remove(ReceiveByLookupIDWithTransaction(NoTransaction)); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	return len(ids), id, nil
+}
+
+// MigrateOption represents an option to migrate messages.
+type MigrateOption struct {
+	set func(opts *migrateOptions)
+}
+
+// migrateOptions contains all the options to migrate messages.
+type migrateOptions struct {
+	move                bool
+	batchSize           int
+	sourceTransactional bool
+	destTransactional   bool
+	resume              LookupID
+	onBatch             func(migrated int, lookupID LookupID)
+}
+
+// MigrateWithMove returns a MigrateOption that configures whether the
+// migrated messages are removed from the source queue.
+//
+// The default is false, which copies the messages.
+func MigrateWithMove(move bool) MigrateOption {
+	return MigrateOption{
+		set: func(opts *migrateOptions) {
+			opts.move = move
+		},
+	}
+}
+
+// MigrateWithBatchSize returns a MigrateOption that configures the number of
+// messages migrated in a batch, and in a transaction when a queue is
+// transactional.
+//
+// The default is 100.
+func MigrateWithBatchSize(n int) MigrateOption {
+	return MigrateOption{
+		set: func(opts *migrateOptions) {
+			if n > 0 {
+				opts.batchSize = n
+			}
+		},
+	}
+}
+
+// MigrateWithTransactional returns a MigrateOption that declares whether the
+// source and destination queues are transactional. Messages are sent to a
+// transactional destination, and moved from a transactional source, in a
+// transaction per batch.
+//
+// The default is false for both queues.
+func MigrateWithTransactional(source, dest bool) MigrateOption {
+	return MigrateOption{
+		set: func(opts *migrateOptions) {
+			opts.sourceTransactional = source
+			opts.destTransactional = dest
+		},
+	}
+}
+
+// MigrateWithResume returns a MigrateOption that configures copying the
+// messages following the message with the lookup identifier id, to resume an
+// interrupted copy. It has no effect on a move.
+//
+// The default is 0, which copies every message.
+func MigrateWithResume(id LookupID) MigrateOption {
+	return MigrateOption{
+		set: func(opts *migrateOptions) {
+			opts.resume = id
+		},
+	}
+}
+
+// MigrateWithOnBatch returns a MigrateOption that configures a function
+// called after every batch with the number of messages migrated so far and
+// the lookup identifier of the last one, for example to report progress or
+// to save a checkpoint for MigrateWithResume.
+func MigrateWithOnBatch(fn func(migrated int, lookupID LookupID)) MigrateOption {
+	return MigrateOption{
+		set: func(opts *migrateOptions) {
+			opts.onBatch = fn
+		},
+	}
+}
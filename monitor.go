@@ -0,0 +1,314 @@
+package msmq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Sample is the depth of a queue sampled by a Monitor.
+type Sample struct {
+	// Queue is the format name of the queue.
+	Queue string
+
+	// Messages is the number of messages in the queue.
+	Messages int32
+
+	// Bytes is the number of bytes used by the messages in the queue.
+	Bytes uint64
+
+	// Time is the time the queue was sampled.
+	Time time.Time
+}
+
+// Threshold identifies the threshold crossed in a ThresholdEvent.
+type Threshold int
+
+const (
+	// MessagesThreshold is the threshold on the number of messages set with
+	// MonitorWithMessagesThreshold.
+	MessagesThreshold Threshold = iota
+
+	// BytesThreshold is the threshold on the number of bytes set with
+	// MonitorWithBytesThreshold.
+	BytesThreshold
+)
+
+// ThresholdEvent reports that the depth of a queue crossed a threshold.
+type ThresholdEvent struct {
+	Sample
+
+	// Threshold is the threshold that was crossed.
+	Threshold Threshold
+
+	// Exceeded is true when the depth rose above the threshold and false
+	// when it fell back to or below it.
+	Exceeded bool
+}
+
+// Monitor periodically samples the number of messages and bytes in a set of
+// queues through QueueManagement, and invokes callbacks when the depth of a
+// queue crosses a threshold or stops decreasing. It provides simple alerting
+// without a metrics stack:
+//   m, err := msmq.NewMonitor([]string{name},
+//       msmq.MonitorWithMessagesThreshold(1000),
+//       msmq.MonitorWithOnThreshold(func(e msmq.ThresholdEvent) {
+//           log.Printf("%s: %d messages (exceeded: %t)", e.Queue, e.Messages, e.Exceeded)
+//       }),
+//   )
+//   ...
+//   err = m.Run(ctx)
+type Monitor struct {
+	formatNames []string
+	options     *monitorOptions
+
+	// state is the state of each queue, which is only accessed by Run.
+	state map[string]*monitorState
+}
+
+// monitorState is the state a Monitor keeps for a queue between samples.
+type monitorState struct {
+	last            *Sample
+	messagesOver    bool
+	bytesOver       bool
+	notDecreasing   int
+	stalledReported bool
+}
+
+// NewMonitor returns a pointer to a Monitor of the queues referenced by
+// formatNames configured by the options. The Monitor does not sample the
+// queues until Run is called.
+func NewMonitor(formatNames []string, opts ...MonitorOption) (*Monitor, error) {
+	options := &monitorOptions{
+		interval: 30 * time.Second,
+	}
+	for _, o := range opts {
+		o.set(options)
+	}
+	if options.interval <= 0 {
+		return nil, fmt.Errorf("go-msmq: NewMonitor() failed to create monitor: %w", invalidOption("MonitorWithInterval", options.interval, "must be positive"))
+	}
+
+	return &Monitor{
+		formatNames: formatNames,
+		options:     options,
+		state:       make(map[string]*monitorState),
+	}, nil
+}
+
+// Run samples the queues immediately and then every interval until ctx is
+// done, and returns the error of ctx. Errors sampling a queue are passed to
+// the callback set with MonitorWithOnError and do not stop the Monitor. Run
+// must not be called concurrently.
+func (m *Monitor) Run(ctx context.Context) error {
+	ticker := time.NewTicker(m.options.interval)
+	defer ticker.Stop()
+
+	for {
+		m.poll()
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("go-msmq: Run() failed to monitor queues: %w", ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// poll samples every queue once and invokes the callbacks.
+func (m *Monitor) poll() {
+	for _, name := range m.formatNames {
+		s, err := sampleQueue(name)
+		if err != nil {
+			if m.options.onError != nil {
+				m.options.onError(name, err)
+			}
+			continue
+		}
+
+		m.check(s)
+	}
+}
+
+// check compares s with the previous sample of its queue and invokes the
+// callbacks.
+func (m *Monitor) check(s Sample) {
+	st, ok := m.state[s.Queue]
+	if !ok {
+		st = &monitorState{}
+		m.state[s.Queue] = st
+	}
+
+	if m.options.onSample != nil {
+		m.options.onSample(s)
+	}
+
+	if m.options.maxMessages != nil {
+		over := s.Messages > *m.options.maxMessages
+		if over != st.messagesOver {
+			st.messagesOver = over
+			m.threshold(ThresholdEvent{Sample: s, Threshold: MessagesThreshold, Exceeded: over})
+		}
+	}
+	if m.options.maxBytes != nil {
+		over := s.Bytes > *m.options.maxBytes
+		if over != st.bytesOver {
+			st.bytesOver = over
+			m.threshold(ThresholdEvent{Sample: s, Threshold: BytesThreshold, Exceeded: over})
+		}
+	}
+
+	// A queue is stalled when it holds messages and its depth has not
+	// decreased for the configured number of samples, which usually means
+	// that its consumers stopped.
+	switch {
+	case s.Messages == 0 || (st.last != nil && s.Messages < st.last.Messages):
+		st.notDecreasing = 0
+		st.stalledReported = false
+	case st.last != nil:
+		st.notDecreasing++
+	}
+	if m.options.stallSamples > 0 && st.notDecreasing >= m.options.stallSamples && !st.stalledReported {
+		st.stalledReported = true
+		if m.options.onStall != nil {
+			m.options.onStall(s)
+		}
+	}
+
+	st.last = &s
+}
+
+// threshold invokes the threshold callback with e.
+func (m *Monitor) threshold(e ThresholdEvent) {
+	if m.options.onThreshold != nil {
+		m.options.onThreshold(e)
+	}
+}
+
+// sampleQueue returns the depth of the queue referenced by formatName. A
+// queue that is not active is empty.
+func sampleQueue(formatName string) (Sample, error) {
+	s := Sample{Queue: formatName, Time: time.Now()}
+
+	m, err := NewQueueManagement(QueueManagementWithFormatName(formatName))
+	if errors.Is(err, ErrQueueNotActive) {
+		return s, nil
+	}
+	if err != nil {
+		return s, err
+	}
+	defer m.Close()
+
+	s.Messages, err = m.MessageCount()
+	if err != nil {
+		return s, err
+	}
+
+	s.Bytes, err = m.BytesInQueue()
+	if err != nil {
+		return s, err
+	}
+
+	return s, nil
+}
+
+// MonitorOption represents an option to configure a Monitor.
+type MonitorOption struct {
+	set func(opts *monitorOptions)
+}
+
+// monitorOptions contains all the options to configure a Monitor.
+type monitorOptions struct {
+	interval     time.Duration
+	maxMessages  *int32
+	maxBytes     *uint64
+	stallSamples int
+	onSample     func(Sample)
+	onThreshold  func(ThresholdEvent)
+	onStall      func(Sample)
+	onError      func(queue string, err error)
+}
+
+// MonitorWithInterval returns a MonitorOption that configures the interval
+// between samples. The interval must be positive. The default is 30 seconds.
+func MonitorWithInterval(interval time.Duration) MonitorOption {
+	return MonitorOption{
+		set: func(opts *monitorOptions) {
+			opts.interval = interval
+		},
+	}
+}
+
+// MonitorWithMessagesThreshold returns a MonitorOption that configures the
+// number of messages above which a queue crosses MessagesThreshold.
+func MonitorWithMessagesThreshold(messages int32) MonitorOption {
+	return MonitorOption{
+		set: func(opts *monitorOptions) {
+			opts.maxMessages = &messages
+		},
+	}
+}
+
+// MonitorWithBytesThreshold returns a MonitorOption that configures the
+// number of bytes above which a queue crosses BytesThreshold.
+func MonitorWithBytesThreshold(bytes uint64) MonitorOption {
+	return MonitorOption{
+		set: func(opts *monitorOptions) {
+			opts.maxBytes = &bytes
+		},
+	}
+}
+
+// MonitorWithStallSamples returns a MonitorOption that configures the number
+// of consecutive samples in which the depth of a non-empty queue must not
+// decrease before the queue is reported as stalled. Stalls are not detected
+// by default.
+func MonitorWithStallSamples(samples int) MonitorOption {
+	return MonitorOption{
+		set: func(opts *monitorOptions) {
+			opts.stallSamples = samples
+		},
+	}
+}
+
+// MonitorWithOnSample returns a MonitorOption that configures a callback
+// invoked with every sample.
+func MonitorWithOnSample(fn func(Sample)) MonitorOption {
+	return MonitorOption{
+		set: func(opts *monitorOptions) {
+			opts.onSample = fn
+		},
+	}
+}
+
+// MonitorWithOnThreshold returns a MonitorOption that configures the
+// callback invoked when a queue crosses a threshold, in either direction.
+func MonitorWithOnThreshold(fn func(ThresholdEvent)) MonitorOption {
+	return MonitorOption{
+		set: func(opts *monitorOptions) {
+			opts.onThreshold = fn
+		},
+	}
+}
+
+// MonitorWithOnStall returns a MonitorOption that configures the callback
+// invoked once when a queue is stalled. It is invoked again only after the
+// depth of the queue decreased. See MonitorWithStallSamples.
+func MonitorWithOnStall(fn func(Sample)) MonitorOption {
+	return MonitorOption{
+		set: func(opts *monitorOptions) {
+			opts.onStall = fn
+		},
+	}
+}
+
+// MonitorWithOnError returns a MonitorOption that configures the callback
+// invoked when a queue cannot be sampled.
+func MonitorWithOnError(fn func(queue string, err error)) MonitorOption {
+	return MonitorOption{
+		set: func(opts *monitorOptions) {
+			opts.onError = fn
+		},
+	}
+}
@@ -0,0 +1,126 @@
+// +build windows
+
+package msmq
+
+import (
+	"unsafe"
+
+	"github.com/go-ole/go-ole"
+	"golang.org/x/sys/windows"
+)
+
+// mqrt exposes the functions of the native Message Queuing API that are not
+// available through the COM object model.
+var (
+	mqrt = windows.NewLazySystemDLL("mqrt.dll")
+
+	procMQOpenQueue           = mqrt.NewProc("MQOpenQueue")
+	procMQCloseQueue          = mqrt.NewProc("MQCloseQueue")
+	procMQSendMessage         = mqrt.NewProc("MQSendMessage")
+	procMQReceiveMessage      = mqrt.NewProc("MQReceiveMessage")
+	procMQGetOverlappedResult = mqrt.NewProc("MQGetOverlappedResult")
+	procMQMoveMessage         = mqrt.NewProc("MQMoveMessage")
+	procMQSetQueueSecurity    = mqrt.NewProc("MQSetQueueSecurity")
+	procMQGetQueueSecurity    = mqrt.NewProc("MQGetQueueSecurity")
+)
+
+// mqError returns the error reported by the HRESULT hr of a native call, or
+// nil if hr is a success or informational code.
+func mqError(hr uintptr) error {
+	if int32(hr) < 0 {
+		return comError(ole.NewError(hr))
+	}
+
+	return nil
+}
+
+// mqMoveMessage moves the message referenced by lookupID from the queue
+// referenced by src to the queue referenced by dst.
+//
+// The values of TransactionLevel match the MQ_*_TRANSACTION constants that
+// are accepted in place of an ITransaction pointer, so level is passed
+// through as is.
+//
+// See: https://docs.microsoft.com/en-us/windows/win32/api/mq/nf-mq-mqmovemessage
+func mqMoveMessage(src, dst uintptr, lookupID uint64, level TransactionLevel) error {
+	if err := procMQMoveMessage.Find(); err != nil {
+		return err
+	}
+
+	args := []uintptr{src, dst}
+	args = append(args, ulonglong(lookupID)...)
+	args = append(args, uintptr(level))
+
+	hr, _, _ := procMQMoveMessage.Call(args...)
+	return mqError(hr)
+}
+
+// mqSetQueueSecurity sets the parts of the security descriptor of the queue
+// referenced by formatName that are specified by info.
+//
+// See: https://docs.microsoft.com/en-us/windows/win32/api/mq/nf-mq-mqsetqueuesecurity
+func mqSetQueueSecurity(formatName string, info uint32, sd securityDescriptor) error {
+	if err := procMQSetQueueSecurity.Find(); err != nil {
+		return err
+	}
+
+	name, err := windows.UTF16PtrFromString(formatName)
+	if err != nil {
+		return err
+	}
+
+	hr, _, _ := procMQSetQueueSecurity.Call(uintptr(unsafe.Pointer(name)), uintptr(info), uintptr(sd))
+	return mqError(hr)
+}
+
+// mqErrorSecurityDescriptorTooSmall is the
+// MQ_ERROR_SECURITY_DESCRIPTOR_TOO_SMALL HRESULT which is returned when the
+// buffer passed to MQGetQueueSecurity is too small.
+const mqErrorSecurityDescriptorTooSmall = 0xC00E0023
+
+// mqGetQueueSecurity returns the parts of the security descriptor of the
+// queue referenced by formatName that are specified by info, in
+// self-relative form.
+//
+// See: https://docs.microsoft.com/en-us/windows/win32/api/mq/nf-mq-mqgetqueuesecurity
+func mqGetQueueSecurity(formatName string, info uint32) ([]byte, error) {
+	if err := procMQGetQueueSecurity.Find(); err != nil {
+		return nil, err
+	}
+
+	name, err := windows.UTF16PtrFromString(formatName)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, 512)
+	for {
+		var needed uint32
+		hr, _, _ := procMQGetQueueSecurity.Call(
+			uintptr(unsafe.Pointer(name)),
+			uintptr(info),
+			uintptr(unsafe.Pointer(&buf[0])),
+			uintptr(len(buf)),
+			uintptr(unsafe.Pointer(&needed)),
+		)
+		if uint32(hr) == mqErrorSecurityDescriptorTooSmall && int(needed) > len(buf) {
+			buf = make([]byte, needed)
+			continue
+		}
+		if err := mqError(hr); err != nil {
+			return nil, err
+		}
+
+		return buf, nil
+	}
+}
+
+// ulonglong returns the arguments needed to pass v as a ULONGLONG. On 32-bit
+// platforms the value is split across two arguments.
+func ulonglong(v uint64) []uintptr {
+	if unsafe.Sizeof(uintptr(0)) == 8 {
+		return []uintptr{uintptr(v)}
+	}
+
+	return []uintptr{uintptr(uint32(v)), uintptr(uint32(v >> 32))}
+}
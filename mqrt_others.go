@@ -0,0 +1,15 @@
+// +build !windows
+
+package msmq
+
+func mqMoveMessage(src, dst uintptr, lookupID uint64, level TransactionLevel) error {
+	return ErrUnsupportedPlatform
+}
+
+func mqSetQueueSecurity(formatName string, info uint32, sd securityDescriptor) error {
+	return ErrUnsupportedPlatform
+}
+
+func mqGetQueueSecurity(formatName string, info uint32) ([]byte, error) {
+	return nil, ErrUnsupportedPlatform
+}
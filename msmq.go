@@ -1,7 +1,11 @@
+// Package msmq provides access to Microsoft Message Queuing (MSMQ) through its
+// COM object model.
+//
+// The objects of the package live in the COM multithreaded apartment, which is
+// initialized on demand and kept alive by the package, so they can be used
+// from any goroutine. See Init and Shutdown.
+//
+// The package compiles on every platform so that code importing it can be
+// built and tested anywhere, but MSMQ is only available on Windows. On other
+// platforms, every operation fails with ErrUnsupportedPlatform.
 package msmq
-
-import "github.com/go-ole/go-ole"
-
-func init() {
-	_ = ole.CoInitializeEx(0, ole.COINIT_MULTITHREADED)
-}
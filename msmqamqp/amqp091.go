@@ -0,0 +1,142 @@
+package msmqamqp
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/jandauz/go-msmq"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// Sink091 is an msmq.Sink that publishes messages to an exchange of an AMQP
+// 0-9-1 broker. Forward waits for the broker to confirm each message, so
+// that the Bridge only removes messages the broker has accepted. Messages are
+// published as mandatory, so a message that no queue is bound to receive
+// fails Forward instead of being dropped by the broker.
+type Sink091 struct {
+	ch       *amqp.Channel
+	exchange string
+	options  *options
+
+	// returns receives the mandatory messages the broker could not route.
+	// mu serializes Forward so that a return is matched with its publish.
+	mu      sync.Mutex
+	returns chan amqp.Return
+}
+
+// NewSink091 returns a pointer to a Sink091 that publishes to exchange
+// through ch, configured by the options. It puts ch in confirm mode, so ch
+// must not be used to publish by anything else.
+func NewSink091(ch *amqp.Channel, exchange string, opts ...Option) (*Sink091, error) {
+	if err := ch.Confirm(false); err != nil {
+		return nil, fmt.Errorf("msmqamqp: NewSink091() failed to put channel in confirm mode: %w", err)
+	}
+
+	return &Sink091{
+		ch:       ch,
+		exchange: exchange,
+		options:  newOptions(opts),
+		returns:  ch.NotifyReturn(make(chan amqp.Return, 16)),
+	}, nil
+}
+
+// Forward publishes msg to the exchange and waits for the broker to confirm
+// it.
+func (s *Sink091) Forward(ctx context.Context, msg *msmq.Message) error {
+	r, err := newRecord(msg, s.options.headers)
+	if err != nil {
+		return fmt.Errorf("msmqamqp: Forward() failed to read message: %w", err)
+	}
+
+	mode := amqp.Transient
+	if r.persistent {
+		mode = amqp.Persistent
+	}
+	publishing := amqp.Publishing{
+		Headers:       table(r.headers),
+		DeliveryMode:  mode,
+		Priority:      r.priority,
+		CorrelationId: r.correlationID,
+		MessageId:     r.messageID,
+		Timestamp:     r.sentTime,
+		Body:          r.body,
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := s.options.routingKey(r.label)
+	confirm, err := s.ch.PublishWithDeferredConfirmWithContext(ctx, s.exchange, key, true, false, publishing)
+	if err != nil {
+		return fmt.Errorf("msmqamqp: Forward() failed to publish message: %w", err)
+	}
+
+	ok, err := confirm.WaitContext(ctx)
+	if err != nil {
+		return fmt.Errorf("msmqamqp: Forward() failed to wait for confirmation: %w", err)
+	}
+	if !ok {
+		return fmt.Errorf("msmqamqp: Forward() failed: broker rejected message %s", r.messageID)
+	}
+
+	// The broker returns an unroutable message before it confirms it, so its
+	// return is already delivered once the confirmation arrives.
+	if ret, returned := s.returned(r.messageID); returned {
+		return fmt.Errorf("msmqamqp: Forward() failed: broker returned message %s: %d %s", r.messageID, ret.ReplyCode, ret.ReplyText)
+	}
+
+	return nil
+}
+
+// returned drains the returned messages and reports the return of the
+// message with the specified ID, if any.
+func (s *Sink091) returned(messageID string) (amqp.Return, bool) {
+	var (
+		match amqp.Return
+		found bool
+	)
+	for {
+		select {
+		case ret, ok := <-s.returns:
+			if !ok {
+				return match, found
+			}
+			if ret.MessageId == messageID {
+				match, found = ret, true
+			}
+		default:
+			return match, found
+		}
+	}
+}
+
+// table converts headers to an amqp.Table, converting nested objects too,
+// since the broker only accepts nested tables.
+func table(headers map[string]interface{}) amqp.Table {
+	if headers == nil {
+		return nil
+	}
+
+	t := make(amqp.Table, len(headers))
+	for k, v := range headers {
+		t[k] = tableValue(v)
+	}
+	return t
+}
+
+// tableValue converts the objects nested in v to amqp.Tables.
+func tableValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		return table(v)
+	case []interface{}:
+		vs := make([]interface{}, len(v))
+		for i := range v {
+			vs[i] = tableValue(v[i])
+		}
+		return vs
+	default:
+		return v
+	}
+}
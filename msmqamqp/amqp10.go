@@ -0,0 +1,58 @@
+package msmqamqp
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/Azure/go-amqp"
+	"github.com/jandauz/go-msmq"
+)
+
+// Sink10 is an msmq.Sink that sends messages through a sender link to an
+// AMQP 1.0 broker. The link should use the default unsettled sender mode, so
+// that Forward waits for the broker to settle each message and the Bridge
+// only removes messages the broker has accepted.
+type Sink10 struct {
+	sender  *amqp.Sender
+	options *options
+}
+
+// NewSink10 returns a pointer to a Sink10 that sends through sender,
+// configured by the options.
+func NewSink10(sender *amqp.Sender, opts ...Option) *Sink10 {
+	return &Sink10{
+		sender:  sender,
+		options: newOptions(opts),
+	}
+}
+
+// Forward sends msg through the sender link and waits for the broker to
+// settle it.
+func (s *Sink10) Forward(ctx context.Context, msg *msmq.Message) error {
+	r, err := newRecord(msg, s.options.headers)
+	if err != nil {
+		return fmt.Errorf("msmqamqp: Forward() failed to read message: %w", err)
+	}
+
+	subject := s.options.routingKey(r.label)
+	m := amqp.NewMessage(r.body)
+	m.Header = &amqp.MessageHeader{
+		Durable:  r.persistent,
+		Priority: r.priority,
+	}
+	m.Properties = &amqp.MessageProperties{
+		MessageID:    r.messageID,
+		Subject:      &subject,
+		CreationTime: &r.sentTime,
+	}
+	if r.correlationID != "" {
+		m.Properties.CorrelationID = r.correlationID
+	}
+	m.ApplicationProperties = r.headers
+
+	if err := s.sender.Send(ctx, m, nil); err != nil {
+		return fmt.Errorf("msmqamqp: Forward() failed to send message: %w", err)
+	}
+
+	return nil
+}
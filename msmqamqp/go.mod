@@ -0,0 +1,16 @@
+module github.com/jandauz/go-msmq/msmqamqp
+
+go 1.23
+
+require (
+	github.com/Azure/go-amqp v1.0.5
+	github.com/jandauz/go-msmq v0.0.0
+	github.com/rabbitmq/amqp091-go v1.9.0
+)
+
+require (
+	github.com/go-ole/go-ole v1.2.5 // indirect
+	golang.org/x/sys v0.16.0 // indirect
+)
+
+replace github.com/jandauz/go-msmq => ../
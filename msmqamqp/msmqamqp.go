@@ -0,0 +1,161 @@
+// Package msmqamqp provides msmq.Sinks that forward messages from MSMQ to an
+// AMQP broker, to migrate services off MSMQ gradually: producers keep sending
+// to MSMQ while consumers move to the broker one at a time.
+//
+// A Sink091 publishes to an AMQP 0-9-1 broker, such as RabbitMQ, through a
+// channel in confirm mode:
+//   ch, err := conn.Channel()
+//   sink, err := msmqamqp.NewSink091(ch, "orders")
+//   bridge := msmq.NewSinkBridge(src, sink)
+//   err = bridge.Run(ctx)
+//
+// A Sink10 sends to an AMQP 1.0 broker, such as Azure Service Bus or
+// ActiveMQ Artemis, through a sender link:
+//   sender, err := session.NewSender(ctx, "orders", nil)
+//   bridge := msmq.NewSinkBridge(src, msmqamqp.NewSink10(sender))
+//
+// Both map the properties of the MSMQ message the same way: the body is sent
+// as is, the Label becomes the routing key or subject, the ID becomes the
+// message ID, the CorrelationID is kept, and the headers are decoded from
+// the Extension.
+package msmqamqp
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/jandauz/go-msmq"
+)
+
+// ExtensionHeader is the name of the header that carries the Extension of a
+// message when it is not a JSON object.
+const ExtensionHeader = "msmq-extension"
+
+// record contains the properties of an MSMQ message that are forwarded to
+// the broker.
+type record struct {
+	body          []byte
+	label         string
+	messageID     string
+	correlationID string
+	priority      uint8
+	persistent    bool
+	sentTime      time.Time
+	headers       map[string]interface{}
+}
+
+// newRecord reads the properties of msg, decoding its headers with fn.
+func newRecord(msg *msmq.Message, fn func([]byte) (map[string]interface{}, error)) (*record, error) {
+	var (
+		r   record
+		err error
+	)
+	if r.body, err = msg.BodyBytes(); err != nil {
+		return nil, err
+	}
+	if r.label, err = msg.Label(); err != nil {
+		return nil, err
+	}
+	if r.messageID, err = msmq.MessageIDKey(*msg); err != nil {
+		return nil, err
+	}
+
+	correlationID, err := msg.CorrelationID()
+	if err != nil {
+		return nil, err
+	}
+	// MSMQ reports an unset CorrelationID as zeros.
+	if len(bytes.Trim(correlationID, "\x00")) > 0 {
+		r.correlationID = hex.EncodeToString(correlationID)
+	}
+
+	priority, err := msg.Priority()
+	if err != nil {
+		return nil, err
+	}
+	r.priority = uint8(priority)
+
+	delivery, err := msg.Delivery()
+	if err != nil {
+		return nil, err
+	}
+	r.persistent = delivery == msmq.Recoverable
+
+	if r.sentTime, err = msg.SentTime(); err != nil {
+		return nil, err
+	}
+
+	ext, err := msg.Extension()
+	if err != nil {
+		return nil, err
+	}
+	if r.headers, err = fn(ext); err != nil {
+		return nil, fmt.Errorf("msmqamqp: failed to decode headers: %w", err)
+	}
+
+	return &r, nil
+}
+
+// ExtensionHeaders decodes the headers of a message from its Extension. An
+// Extension holding a JSON object is decoded into one header per field, and
+// any other non-empty Extension is carried as is in ExtensionHeader. It is
+// the default of WithHeaders.
+func ExtensionHeaders(ext []byte) (map[string]interface{}, error) {
+	if len(ext) == 0 {
+		return nil, nil
+	}
+
+	var headers map[string]interface{}
+	if bytes.HasPrefix(bytes.TrimSpace(ext), []byte("{")) && json.Unmarshal(ext, &headers) == nil {
+		return headers, nil
+	}
+
+	return map[string]interface{}{ExtensionHeader: ext}, nil
+}
+
+// Option represents an option to configure a Sink091 or a Sink10.
+type Option struct {
+	set func(opts *options)
+}
+
+// options contains all the options to configure a Sink091 or a Sink10.
+type options struct {
+	routingKey func(label string) string
+	headers    func(ext []byte) (map[string]interface{}, error)
+}
+
+// newOptions returns the options configured by opts.
+func newOptions(opts []Option) *options {
+	options := &options{
+		routingKey: func(label string) string { return label },
+		headers:    ExtensionHeaders,
+	}
+	for _, o := range opts {
+		o.set(options)
+	}
+	return options
+}
+
+// WithRoutingKey returns an Option that configures how the Label of a
+// message maps to its routing key on an AMQP 0-9-1 broker, or its subject on
+// an AMQP 1.0 broker. The default uses the Label as is.
+func WithRoutingKey(fn func(label string) string) Option {
+	return Option{
+		set: func(opts *options) {
+			opts.routingKey = fn
+		},
+	}
+}
+
+// WithHeaders returns an Option that configures how the headers of a message
+// are decoded from its Extension. The default is ExtensionHeaders.
+func WithHeaders(fn func(ext []byte) (map[string]interface{}, error)) Option {
+	return Option{
+		set: func(opts *options) {
+			opts.headers = fn
+		},
+	}
+}
@@ -0,0 +1,93 @@
+package msmqfake
+
+import (
+	"time"
+
+	"github.com/jandauz/go-msmq"
+)
+
+// Cursor iterates over the messages of a queue in receive order without
+// removing them, like the cursor of an MSMQ queue handle.
+type Cursor struct {
+	queue *Queue
+
+	// current is the position of the current message, or nil if the cursor
+	// is before the first message.
+	current *position
+}
+
+// PeekCurrent returns the current message without removing it. If the cursor
+// is not positioned on a message yet, it moves to the first message, waiting
+// up to timeout for one to arrive.
+func (c *Cursor) PeekCurrent(timeout time.Duration) (*msmq.NativeMessage, error) {
+	return c.queue.await(timeout, func() (*msmq.NativeMessage, error) {
+		e, err := c.entry(msmq.Peek | msmq.Receive)
+		if e == nil || err != nil {
+			return nil, err
+		}
+
+		return copyMessage(e.msg), nil
+	})
+}
+
+// PeekNext moves the cursor to the next message and returns it without
+// removing it, waiting up to timeout for one to arrive.
+func (c *Cursor) PeekNext(timeout time.Duration) (*msmq.NativeMessage, error) {
+	return c.queue.await(timeout, func() (*msmq.NativeMessage, error) {
+		if err := c.queue.check(msmq.Peek | msmq.Receive); err != nil {
+			return nil, err
+		}
+
+		e := c.queue.queue.first(c.current)
+		if e == nil {
+			return nil, nil
+		}
+
+		c.current = &position{priority: e.msg.Priority, lookupID: e.msg.LookupID}
+		return copyMessage(e.msg), nil
+	})
+}
+
+// ReceiveCurrent receives the current message, waiting up to timeout for one
+// to arrive if the cursor is not positioned on a message yet. The cursor
+// then refers to the position of the removed message, so PeekNext returns
+// the message that followed it.
+func (c *Cursor) ReceiveCurrent(timeout time.Duration, level msmq.TransactionLevel) (*msmq.NativeMessage, error) {
+	return c.queue.await(timeout, func() (*msmq.NativeMessage, error) {
+		if !c.queue.queue.transactional && level != msmq.NoTransaction {
+			return nil, msmq.ErrTransactionUsage
+		}
+
+		e, err := c.entry(msmq.Receive)
+		if e == nil || err != nil {
+			return nil, err
+		}
+
+		c.queue.queue.remove(e)
+		return copyMessage(e.msg), nil
+	})
+}
+
+// entry returns the current message, moving to the first message if the
+// cursor is not positioned yet. msmq.ErrMessageNotFound is returned if the
+// current message was removed. The server mutex must be held.
+func (c *Cursor) entry(access msmq.AccessMode) (*entry, error) {
+	if err := c.queue.check(access); err != nil {
+		return nil, err
+	}
+
+	if c.current == nil {
+		e := c.queue.queue.first(nil)
+		if e != nil {
+			c.current = &position{priority: e.msg.Priority, lookupID: e.msg.LookupID}
+		}
+		return e, nil
+	}
+
+	e := c.queue.queue.lookup(c.current.lookupID)
+	if e == nil {
+		return nil, msmq.ErrMessageNotFound
+	}
+
+	return e, nil
+}
@@ -0,0 +1,267 @@
+// Package msmqfake provides in-memory queues that implement
+// msmq.MessageQueue, so code that sends and receives messages can be tested
+// on any platform without MSMQ:
+//   s := msmqfake.NewServer()
+//   s.CreateQueue(`DIRECT=OS:.\private$\orders`, false)
+//   q, err := s.Open(`DIRECT=OS:.\private$\orders`, msmq.Receive|msmq.Send, msmq.DenyNone)
+//   ...
+//   err = q.Send(&msmq.NativeMessage{Label: "order", Body: body}, msmq.NoTransaction)
+//
+// Wrapped with msmq.WrapMessageQueue, the queues also back the Consumer and
+// Producer of the msmq package:
+//   consumer := msmq.NewConsumer(msmq.WrapMessageQueue(q))
+//   producer := msmq.NewProducer(msmq.WrapMessageQueue(q),
+//       msmq.ProducerWithSendOptions(msmq.SendWithTransaction(msmq.NoTransaction)),
+//   )
+//
+// The queues mimic the behavior of MSMQ: messages are ordered by priority and
+// then by arrival, transactional queues ignore priorities and require
+// transactions to send, every message gets a unique lookup identifier, and
+// the errors returned are the sentinel errors of the msmq package.
+package msmqfake
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jandauz/go-msmq"
+)
+
+// defaultPriority is the priority of messages sent with a zero priority.
+const defaultPriority = 3
+
+// Server is an in-memory queue manager. The zero value is not usable; use
+// NewServer.
+type Server struct {
+	mu       sync.Mutex
+	queues   map[string]*queue
+	lookupID msmq.LookupID
+
+	// source identifies the server in the IDs of its messages.
+	source [16]byte
+}
+
+// queue is the state of a queue shared by all its handles.
+type queue struct {
+	transactional bool
+	deleted       bool
+
+	// messages are ordered by priority, then by lookup identifier.
+	messages []*entry
+
+	// notify is closed and replaced whenever messages become visible or a
+	// handle is closed, to wake up waiting receivers.
+	notify chan struct{}
+
+	// readers is the number of open handles that can peek or receive, and
+	// exclusive whether one of them was opened with DenyReceive.
+	readers   int
+	exclusive bool
+}
+
+// entry is a message stored in a queue.
+type entry struct {
+	msg   *msmq.NativeMessage
+	queue *queue
+
+	// tx is the transaction that sent or received the message, if it is not
+	// committed yet. The message is invisible until then.
+	tx *Transaction
+
+	// received reports whether tx received the message, as opposed to
+	// sending it.
+	received bool
+}
+
+// NewServer returns an empty Server.
+func NewServer() *Server {
+	s := &Server{queues: make(map[string]*queue)}
+	rand.Read(s.source[:])
+	return s
+}
+
+// CreateQueue creates a queue identified by name, which is usually a format
+// name. Names are case-insensitive. msmq.ErrQueueExists is returned if the
+// queue exists.
+func (s *Server) CreateQueue(name string, transactional bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := strings.ToLower(name)
+	if _, ok := s.queues[key]; ok {
+		return msmq.ErrQueueExists
+	}
+
+	s.queues[key] = &queue{
+		transactional: transactional,
+		notify:        make(chan struct{}),
+	}
+	return nil
+}
+
+// DeleteQueue deletes the queue identified by name along with its messages.
+// Open handles of the queue fail with msmq.ErrQueueDeleted.
+func (s *Server) DeleteQueue(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := strings.ToLower(name)
+	q, ok := s.queues[key]
+	if !ok {
+		return msmq.ErrQueueNotFound
+	}
+
+	delete(s.queues, key)
+	q.deleted = true
+	q.messages = nil
+	q.signal()
+	return nil
+}
+
+// Open opens the queue identified by name. msmq.ErrQueueNotFound is returned
+// if the queue does not exist and msmq.ErrSharingViolation if the share mode
+// conflicts with the other handles of the queue.
+func (s *Server) Open(name string, accessMode msmq.AccessMode, shareMode msmq.ShareMode) (*Queue, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	q, ok := s.queues[strings.ToLower(name)]
+	if !ok {
+		return nil, msmq.ErrQueueNotFound
+	}
+
+	reader := accessMode&(msmq.Receive|msmq.Peek) != 0
+	if reader {
+		if q.exclusive || (shareMode == msmq.DenyReceive && q.readers > 0) {
+			return nil, msmq.ErrSharingViolation
+		}
+
+		q.readers++
+		q.exclusive = shareMode == msmq.DenyReceive
+	}
+
+	return &Queue{
+		server: s,
+		queue:  q,
+		access: accessMode,
+		reader: reader,
+	}, nil
+}
+
+// Messages returns a copy of the visible messages of the queue identified by
+// name, in the order they would be received. Messages sent or received in
+// uncommitted transactions are omitted.
+func (s *Server) Messages(name string) ([]*msmq.NativeMessage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	q, ok := s.queues[strings.ToLower(name)]
+	if !ok {
+		return nil, msmq.ErrQueueNotFound
+	}
+
+	var msgs []*msmq.NativeMessage
+	for _, e := range q.messages {
+		if e.tx == nil {
+			msgs = append(msgs, copyMessage(e.msg))
+		}
+	}
+
+	return msgs, nil
+}
+
+// store adds msg to q as sent by tx, which may be nil. s.mu must be held.
+func (s *Server) store(q *queue, msg *msmq.NativeMessage, tx *Transaction) *entry {
+	s.lookupID++
+
+	stored := copyMessage(msg)
+	stored.LookupID = s.lookupID
+	copy(stored.ID[:], s.source[:])
+	binary.LittleEndian.PutUint32(stored.ID[16:], uint32(s.lookupID))
+	stored.SentTime = time.Now().Truncate(time.Second)
+	stored.ArrivedTime = stored.SentTime
+	switch {
+	case q.transactional:
+		stored.Priority = 0
+	case stored.Priority == 0:
+		stored.Priority = defaultPriority
+	}
+
+	e := &entry{msg: stored, queue: q, tx: tx}
+	i := len(q.messages)
+	for i > 0 && q.messages[i-1].msg.Priority < stored.Priority {
+		i--
+	}
+	q.messages = append(q.messages, nil)
+	copy(q.messages[i+1:], q.messages[i:])
+	q.messages[i] = e
+
+	if tx == nil {
+		q.signal()
+	}
+	return e
+}
+
+// signal wakes up the receivers waiting on q.
+func (q *queue) signal() {
+	close(q.notify)
+	q.notify = make(chan struct{})
+}
+
+// remove removes e from q.
+func (q *queue) remove(e *entry) {
+	for i, m := range q.messages {
+		if m == e {
+			q.messages = append(q.messages[:i], q.messages[i+1:]...)
+			return
+		}
+	}
+}
+
+// first returns the first visible message of q after the position after, or
+// the first visible message if after is nil.
+func (q *queue) first(after *position) *entry {
+	for _, e := range q.messages {
+		if e.tx == nil && (after == nil || after.before(e.msg)) {
+			return e
+		}
+	}
+
+	return nil
+}
+
+// lookup returns the visible message of q with the lookup identifier id.
+func (q *queue) lookup(id msmq.LookupID) *entry {
+	for _, e := range q.messages {
+		if e.tx == nil && e.msg.LookupID == id {
+			return e
+		}
+	}
+
+	return nil
+}
+
+// position is the position of a message in the receive order of a queue.
+type position struct {
+	priority uint8
+	lookupID msmq.LookupID
+}
+
+// before reports whether p comes before msg in the receive order.
+func (p *position) before(msg *msmq.NativeMessage) bool {
+	if p.priority != msg.Priority {
+		return p.priority > msg.Priority
+	}
+
+	return p.lookupID < msg.LookupID
+}
+
+// copyMessage returns a copy of msg that does not share its body.
+func copyMessage(msg *msmq.NativeMessage) *msmq.NativeMessage {
+	c := *msg
+	c.Body = append([]byte(nil), msg.Body...)
+	return &c
+}
@@ -0,0 +1,192 @@
+package msmqfake
+
+import (
+	"time"
+
+	"github.com/jandauz/go-msmq"
+)
+
+// Queue is an open handle of an in-memory queue. It implements
+// msmq.MessageQueue.
+type Queue struct {
+	server *Server
+	queue  *queue
+	access msmq.AccessMode
+	reader bool
+	closed bool
+}
+
+var _ msmq.MessageQueue = (*Queue)(nil)
+
+// Close closes the handle. Receivers waiting on the handle fail with
+// msmq.ErrInvalidHandle.
+func (q *Queue) Close() error {
+	q.server.mu.Lock()
+	defer q.server.mu.Unlock()
+
+	if q.closed {
+		return msmq.ErrInvalidHandle
+	}
+
+	q.closed = true
+	if q.reader {
+		q.queue.readers--
+		if q.queue.readers == 0 {
+			q.queue.exclusive = false
+		}
+	}
+	q.queue.signal()
+	return nil
+}
+
+// Send sends msg to the queue. The handle must be opened with msmq.Send.
+// Transactional queues require msmq.SingleMessage, msmq.MTS or msmq.XA,
+// which all send the message in a single-message transaction, and
+// non-transactional queues require msmq.NoTransaction; otherwise
+// msmq.ErrTransactionUsage is returned.
+func (q *Queue) Send(msg *msmq.NativeMessage, level msmq.TransactionLevel) error {
+	q.server.mu.Lock()
+	defer q.server.mu.Unlock()
+
+	if err := q.check(msmq.Send); err != nil {
+		return err
+	}
+	if q.queue.transactional == (level == msmq.NoTransaction) {
+		return msmq.ErrTransactionUsage
+	}
+
+	q.server.store(q.queue, msg, nil)
+	return nil
+}
+
+// Receive receives the first message in the queue, waiting up to timeout for
+// a message to arrive. A negative timeout waits indefinitely.
+// msmq.ErrIOTimeout is returned if no message arrives in time. The handle
+// must be opened with msmq.Receive.
+func (q *Queue) Receive(timeout time.Duration, level msmq.TransactionLevel) (*msmq.NativeMessage, error) {
+	return q.await(timeout, func() (*msmq.NativeMessage, error) {
+		if err := q.check(msmq.Receive); err != nil {
+			return nil, err
+		}
+		if !q.queue.transactional && level != msmq.NoTransaction {
+			return nil, msmq.ErrTransactionUsage
+		}
+
+		e := q.queue.first(nil)
+		if e == nil {
+			return nil, nil
+		}
+
+		q.queue.remove(e)
+		return copyMessage(e.msg), nil
+	})
+}
+
+// Peek returns the first message in the queue without removing it, waiting
+// up to timeout for a message to arrive. The handle must be opened with
+// msmq.Peek or msmq.Receive.
+func (q *Queue) Peek(timeout time.Duration) (*msmq.NativeMessage, error) {
+	return q.await(timeout, func() (*msmq.NativeMessage, error) {
+		if err := q.check(msmq.Peek | msmq.Receive); err != nil {
+			return nil, err
+		}
+
+		e := q.queue.first(nil)
+		if e == nil {
+			return nil, nil
+		}
+
+		return copyMessage(e.msg), nil
+	})
+}
+
+// PeekByLookupID returns the message with the lookup identifier id without
+// removing it. msmq.ErrMessageNotFound is returned if there is no such
+// message.
+func (q *Queue) PeekByLookupID(id msmq.LookupID) (*msmq.NativeMessage, error) {
+	q.server.mu.Lock()
+	defer q.server.mu.Unlock()
+
+	if err := q.check(msmq.Peek | msmq.Receive); err != nil {
+		return nil, err
+	}
+
+	e := q.queue.lookup(id)
+	if e == nil {
+		return nil, msmq.ErrMessageNotFound
+	}
+
+	return copyMessage(e.msg), nil
+}
+
+// ReceiveByLookupID receives the message with the lookup identifier id.
+// msmq.ErrMessageNotFound is returned if there is no such message.
+func (q *Queue) ReceiveByLookupID(id msmq.LookupID, level msmq.TransactionLevel) (*msmq.NativeMessage, error) {
+	q.server.mu.Lock()
+	defer q.server.mu.Unlock()
+
+	if err := q.check(msmq.Receive); err != nil {
+		return nil, err
+	}
+	if !q.queue.transactional && level != msmq.NoTransaction {
+		return nil, msmq.ErrTransactionUsage
+	}
+
+	e := q.queue.lookup(id)
+	if e == nil {
+		return nil, msmq.ErrMessageNotFound
+	}
+
+	q.queue.remove(e)
+	return copyMessage(e.msg), nil
+}
+
+// Cursor returns a cursor positioned before the first message in the queue.
+func (q *Queue) Cursor() *Cursor {
+	return &Cursor{queue: q}
+}
+
+// check returns an error if the handle is closed, the queue was deleted or
+// the handle was opened with none of the access rights in access. The server
+// mutex must be held.
+func (q *Queue) check(access msmq.AccessMode) error {
+	switch {
+	case q.closed:
+		return msmq.ErrInvalidHandle
+	case q.queue.deleted:
+		return msmq.ErrQueueDeleted
+	case q.access&access == 0:
+		return msmq.ErrAccessDenied
+	}
+
+	return nil
+}
+
+// await calls take until it returns a message or an error, waiting for the
+// queue to change in between, or returns msmq.ErrIOTimeout once timeout
+// expires. take is called with the server mutex held and returns a nil
+// message and error if there is no message yet.
+func (q *Queue) await(timeout time.Duration, take func() (*msmq.NativeMessage, error)) (*msmq.NativeMessage, error) {
+	var expired <-chan time.Time
+	if timeout >= 0 {
+		t := time.NewTimer(timeout)
+		defer t.Stop()
+		expired = t.C
+	}
+
+	for {
+		q.server.mu.Lock()
+		msg, err := take()
+		notify := q.queue.notify
+		q.server.mu.Unlock()
+		if msg != nil || err != nil {
+			return msg, err
+		}
+
+		select {
+		case <-notify:
+		case <-expired:
+			return nil, msmq.ErrIOTimeout
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package msmqfake
+
+import (
+	"errors"
+	"time"
+
+	"github.com/jandauz/go-msmq"
+)
+
+// ErrTransactionDone is returned when a Transaction is used after it was
+// committed or aborted.
+var ErrTransactionDone = errors.New("msmqfake: transaction already committed or aborted")
+
+// Transaction groups sends and receives on transactional queues so that they
+// either all take effect or none do, like msmq.Transaction. Messages sent in
+// the transaction are invisible until it commits, and messages received in it
+// are returned to their queue if it aborts.
+type Transaction struct {
+	server  *Server
+	entries []*entry
+	done    bool
+}
+
+// BeginTransaction starts a Transaction.
+func (s *Server) BeginTransaction() *Transaction {
+	return &Transaction{server: s}
+}
+
+// Send sends msg to q within the transaction. q must be transactional.
+func (tx *Transaction) Send(q *Queue, msg *msmq.NativeMessage) error {
+	tx.server.mu.Lock()
+	defer tx.server.mu.Unlock()
+
+	if err := tx.check(q, msmq.Send); err != nil {
+		return err
+	}
+
+	tx.entries = append(tx.entries, tx.server.store(q.queue, msg, tx))
+	return nil
+}
+
+// Receive receives the first message in q within the transaction, waiting up
+// to timeout for a message to arrive. q must be transactional.
+func (tx *Transaction) Receive(q *Queue, timeout time.Duration) (*msmq.NativeMessage, error) {
+	return q.await(timeout, func() (*msmq.NativeMessage, error) {
+		if err := tx.check(q, msmq.Receive); err != nil {
+			return nil, err
+		}
+
+		e := q.queue.first(nil)
+		if e == nil {
+			return nil, nil
+		}
+
+		e.tx = tx
+		e.received = true
+		tx.entries = append(tx.entries, e)
+		return copyMessage(e.msg), nil
+	})
+}
+
+// Commit makes the messages sent in the transaction visible and removes the
+// messages received in it.
+func (tx *Transaction) Commit() error {
+	return tx.end(true)
+}
+
+// Abort discards the messages sent in the transaction and returns the
+// messages received in it to their queues.
+func (tx *Transaction) Abort() error {
+	return tx.end(false)
+}
+
+func (tx *Transaction) end(commit bool) error {
+	tx.server.mu.Lock()
+	defer tx.server.mu.Unlock()
+
+	if tx.done {
+		return ErrTransactionDone
+	}
+	tx.done = true
+
+	for _, e := range tx.entries {
+		if e.received == commit {
+			e.queue.remove(e)
+			continue
+		}
+
+		e.tx = nil
+		e.received = false
+		e.queue.signal()
+	}
+	tx.entries = nil
+
+	return nil
+}
+
+// check returns an error if the transaction is done, q cannot be used with
+// access or q is not transactional. The server mutex must be held.
+func (tx *Transaction) check(q *Queue, access msmq.AccessMode) error {
+	if tx.done {
+		return ErrTransactionDone
+	}
+	if err := q.check(access); err != nil {
+		return err
+	}
+	if !q.queue.transactional {
+		return msmq.ErrTransactionUsage
+	}
+
+	return nil
+}
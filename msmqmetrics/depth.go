@@ -0,0 +1,90 @@
+package msmqmetrics
+
+import (
+	"errors"
+
+	"github.com/jandauz/go-msmq"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// DepthCollector reports the number of messages and bytes in a set of
+// queues. The queues are sampled through msmq.QueueManagement on every
+// scrape, so they do not have to be opened.
+type DepthCollector struct {
+	formatNames []string
+	messages    *prometheus.Desc
+	bytes       *prometheus.Desc
+}
+
+var _ prometheus.Collector = (*DepthCollector)(nil)
+
+// NewDepthCollector returns a pointer to a DepthCollector for the queues
+// referenced by formatNames, configured by opts.
+func NewDepthCollector(formatNames []string, opts ...Option) *DepthCollector {
+	options := &options{
+		namespace: "msmq",
+	}
+	for _, o := range opts {
+		o.set(options)
+	}
+
+	return &DepthCollector{
+		formatNames: formatNames,
+		messages: prometheus.NewDesc(
+			prometheus.BuildFQName(options.namespace, "queue", "messages"),
+			"Number of messages in the queue.",
+			[]string{"queue"}, options.constLabels,
+		),
+		bytes: prometheus.NewDesc(
+			prometheus.BuildFQName(options.namespace, "queue", "bytes"),
+			"Number of bytes used by the messages in the queue.",
+			[]string{"queue"}, options.constLabels,
+		),
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (c *DepthCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.messages
+	ch <- c.bytes
+}
+
+// Collect implements prometheus.Collector. A queue that cannot be sampled is
+// reported as an invalid metric, which fails the scrape with the error.
+func (c *DepthCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, name := range c.formatNames {
+		count, bytes, err := depth(name)
+		if err != nil {
+			ch <- prometheus.NewInvalidMetric(c.messages, err)
+			continue
+		}
+
+		ch <- prometheus.MustNewConstMetric(c.messages, prometheus.GaugeValue, float64(count), name)
+		ch <- prometheus.MustNewConstMetric(c.bytes, prometheus.GaugeValue, float64(bytes), name)
+	}
+}
+
+// depth returns the number of messages and bytes in the queue referenced by
+// formatName. A queue that is not active is empty.
+func depth(formatName string) (int32, uint64, error) {
+	m, err := msmq.NewQueueManagement(msmq.QueueManagementWithFormatName(formatName))
+	if errors.Is(err, msmq.ErrQueueNotActive) {
+		return 0, 0, nil
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+	defer m.Close()
+
+	count, err := m.MessageCount()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	bytes, err := m.BytesInQueue()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return count, bytes, nil
+}
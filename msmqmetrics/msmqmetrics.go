@@ -0,0 +1,170 @@
+// Package msmqmetrics exports Prometheus metrics for go-msmq. A Collector
+// counts the messages sent and received and the errors of the operations of
+// the msmq package, and measures their latency:
+//   c := msmqmetrics.NewCollector()
+//   prometheus.MustRegister(c)
+//   msmq.SetObserver(c)
+//
+// A DepthCollector reports the number of messages and bytes in a set of
+// queues, sampled through the management API on every scrape:
+//   prometheus.MustRegister(msmqmetrics.NewDepthCollector(
+//       []string{`DIRECT=OS:.\private$\orders`},
+//   ))
+//
+// Both are prometheus.Collectors, so they can be registered with any
+// prometheus.Registerer.
+package msmqmetrics
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/jandauz/go-msmq"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Collector collects metrics about the operations of the msmq package and the
+// handlers of its consumers. It implements msmq.Observer and
+// msmq.HandlerObserver and must be installed with msmq.SetObserver.
+type Collector struct {
+	sent      *prometheus.CounterVec
+	received  *prometheus.CounterVec
+	peeked    *prometheus.CounterVec
+	errors    *prometheus.CounterVec
+	durations *prometheus.HistogramVec
+
+	handled          *prometheus.CounterVec
+	handlerRetries   *prometheus.CounterVec
+	handlerDurations *prometheus.HistogramVec
+}
+
+var (
+	_ msmq.Observer        = (*Collector)(nil)
+	_ msmq.HandlerObserver = (*Collector)(nil)
+	_ prometheus.Collector = (*Collector)(nil)
+)
+
+// NewCollector returns a pointer to a Collector configured by opts.
+func NewCollector(opts ...Option) *Collector {
+	options := &options{
+		namespace: "msmq",
+		buckets:   prometheus.DefBuckets,
+	}
+	for _, o := range opts {
+		o.set(options)
+	}
+
+	counter := func(name, help string, labels ...string) *prometheus.CounterVec {
+		return prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   options.namespace,
+			Name:        name,
+			Help:        help,
+			ConstLabels: options.constLabels,
+		}, labels)
+	}
+
+	histogram := func(name, help string, labels ...string) *prometheus.HistogramVec {
+		return prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace:   options.namespace,
+			Name:        name,
+			Help:        help,
+			ConstLabels: options.constLabels,
+			Buckets:     options.buckets,
+		}, labels)
+	}
+
+	return &Collector{
+		sent:      counter("messages_sent_total", "Number of messages sent.", "queue"),
+		received:  counter("messages_received_total", "Number of messages received.", "queue"),
+		peeked:    counter("messages_peeked_total", "Number of messages peeked.", "queue"),
+		errors:    counter("errors_total", "Number of failed operations by HRESULT.", "operation", "queue", "hresult"),
+		durations: histogram("operation_duration_seconds", "Duration of the operations, such as Send and Receive, in seconds.", "operation", "queue"),
+
+		handled:          counter("handler_messages_total", "Number of messages processed by consumer handlers by result.", "queue", "handler", "result"),
+		handlerRetries:   counter("handler_retries_total", "Number of retried attempts of consumer handlers.", "queue", "handler"),
+		handlerDurations: histogram("handler_duration_seconds", "Processing time of consumer handlers in seconds.", "queue", "handler"),
+	}
+}
+
+// Observe implements msmq.Observer.
+func (c *Collector) Observe(op msmq.Operation) {
+	c.durations.WithLabelValues(op.Name, op.Queue).Observe(op.Duration.Seconds())
+
+	if op.Err != nil {
+		c.errors.WithLabelValues(op.Name, op.Queue, hresult(op.Err)).Inc()
+		return
+	}
+	if !op.Message {
+		return
+	}
+
+	switch kind(op.Name) {
+	case "Send":
+		c.sent.WithLabelValues(op.Queue).Inc()
+	case "Receive":
+		c.received.WithLabelValues(op.Queue).Inc()
+	case "Peek":
+		c.peeked.WithLabelValues(op.Queue).Inc()
+	}
+}
+
+// ObserveHandling implements msmq.HandlerObserver.
+func (c *Collector) ObserveHandling(h msmq.Handling) {
+	c.handlerDurations.WithLabelValues(h.Queue, h.Handler).Observe(h.Duration.Seconds())
+	if h.Retries > 0 {
+		c.handlerRetries.WithLabelValues(h.Queue, h.Handler).Inc()
+	}
+
+	result := "success"
+	if h.Err != nil {
+		result = "failure"
+	}
+	c.handled.WithLabelValues(h.Queue, h.Handler, result).Inc()
+}
+
+// Describe implements prometheus.Collector.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	c.sent.Describe(ch)
+	c.received.Describe(ch)
+	c.peeked.Describe(ch)
+	c.errors.Describe(ch)
+	c.durations.Describe(ch)
+	c.handled.Describe(ch)
+	c.handlerRetries.Describe(ch)
+	c.handlerDurations.Describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	c.sent.Collect(ch)
+	c.received.Collect(ch)
+	c.peeked.Collect(ch)
+	c.errors.Collect(ch)
+	c.durations.Collect(ch)
+	c.handled.Collect(ch)
+	c.handlerRetries.Collect(ch)
+	c.handlerDurations.Collect(ch)
+}
+
+// kind returns "Send", "Receive" or "Peek" depending on the kind of the
+// operation named name, or name itself for other operations.
+func kind(name string) string {
+	for _, k := range []string{"Send", "Receive", "Peek"} {
+		if len(name) >= len(k) && name[:len(k)] == k {
+			return k
+		}
+	}
+
+	return name
+}
+
+// hresult returns the HRESULT of err formatted as a hexadecimal number, or
+// "unknown" if err was not reported by MSMQ.
+func hresult(err error) string {
+	var e *msmq.Error
+	if errors.As(err, &e) {
+		return fmt.Sprintf("0x%08X", e.Code)
+	}
+
+	return "unknown"
+}
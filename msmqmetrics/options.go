@@ -0,0 +1,47 @@
+package msmqmetrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// Option represents an option to configure a Collector or a DepthCollector.
+type Option struct {
+	set func(opts *options)
+}
+
+// options contains all the options to configure a Collector or a
+// DepthCollector.
+type options struct {
+	namespace   string
+	constLabels prometheus.Labels
+	buckets     []float64
+}
+
+// WithNamespace returns an Option that configures the namespace of the
+// metrics, which prefixes their names. The default is "msmq".
+func WithNamespace(namespace string) Option {
+	return Option{
+		set: func(opts *options) {
+			opts.namespace = namespace
+		},
+	}
+}
+
+// WithConstLabels returns an Option that configures labels added to every
+// metric, for example to identify the service.
+func WithConstLabels(labels prometheus.Labels) Option {
+	return Option{
+		set: func(opts *options) {
+			opts.constLabels = labels
+		},
+	}
+}
+
+// WithBuckets returns an Option that configures the buckets of the
+// operation duration histogram, in seconds. The default is
+// prometheus.DefBuckets. It has no effect on a DepthCollector.
+func WithBuckets(buckets []float64) Option {
+	return Option{
+		set: func(opts *options) {
+			opts.buckets = buckets
+		},
+	}
+}
@@ -0,0 +1,163 @@
+// Package msmqsvc hosts MSMQ consumers as a Windows service. Run starts the
+// workers when the service starts, and on a stop or shutdown request cancels
+// their context and waits for them to drain before reporting the service as
+// stopped:
+//   func main() {
+//       queue, err := msmq.Open(`.\private$\orders`, msmq.Options{})
+//       ...
+//       consumer := msmq.NewConsumer(queue, msmq.ConsumerWithWorkers(4))
+//       err = msmqsvc.Run("orders",
+//           msmqsvc.WithConsumer(consumer, handleOrder),
+//           msmqsvc.WithStopTimeout(30*time.Second),
+//       )
+//       ...
+//   }
+//
+// When the process is not started by the service control manager, for
+// example from a console or on other platforms, Run runs the workers in the
+// foreground until the process is interrupted, so the same binary can be
+// debugged interactively.
+package msmqsvc
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jandauz/go-msmq"
+)
+
+// Worker is a long-running task hosted by the service. It must return once
+// ctx is done, after completing its in-flight work.
+type Worker func(ctx context.Context) error
+
+// run runs the workers until ctx is done or a worker fails, which cancels the
+// others. It returns the first error of the workers. When ctx is done, it
+// waits up to the stop timeout for the workers to return.
+func run(ctx context.Context, options *options) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+	)
+	for _, w := range options.workers {
+		wg.Add(1)
+		go func(w Worker) {
+			defer wg.Done()
+
+			if err := w(ctx); err != nil {
+				if options.onError != nil {
+					options.onError(err)
+				}
+				errOnce.Do(func() {
+					firstErr = err
+					cancel()
+				})
+			}
+		}(w)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		if options.stopTimeout <= 0 {
+			<-done
+			break
+		}
+
+		select {
+		case <-done:
+		case <-time.After(options.stopTimeout):
+			return fmt.Errorf("msmqsvc: workers did not stop within %s", options.stopTimeout)
+		}
+	}
+
+	return firstErr
+}
+
+// Option represents an option to configure Run.
+type Option struct {
+	set func(opts *options)
+}
+
+// options contains all the options to configure Run.
+type options struct {
+	workers     []Worker
+	stopTimeout time.Duration
+	onError     func(err error)
+}
+
+// WithWorker returns an Option that adds w to the workers of the service.
+func WithWorker(w Worker) Option {
+	return Option{
+		set: func(opts *options) {
+			opts.workers = append(opts.workers, w)
+		},
+	}
+}
+
+// WithConsumer returns an Option that adds a worker running c with handler
+// to the service. See msmq.Consumer.Start.
+func WithConsumer(c *msmq.Consumer, handler msmq.Handler) Option {
+	return WithWorker(func(ctx context.Context) error {
+		return c.Start(ctx, handler)
+	})
+}
+
+// WithStopTimeout returns an Option that configures how long the workers
+// have to drain when the service stops. It is reported to the service
+// control manager as the wait hint of the stop. The default is 20 seconds;
+// zero waits indefinitely.
+func WithStopTimeout(timeout time.Duration) Option {
+	return Option{
+		set: func(opts *options) {
+			opts.stopTimeout = timeout
+		},
+	}
+}
+
+// WithOnError returns an Option that configures the callback invoked with the
+// error of a worker, for example to write it to the event log. The service
+// stops when a worker fails. The default is no callback.
+func WithOnError(fn func(err error)) Option {
+	return Option{
+		set: func(opts *options) {
+			opts.onError = fn
+		},
+	}
+}
+
+// newOptions returns the options configured by opts.
+func newOptions(opts []Option) *options {
+	options := &options{
+		stopTimeout: 20 * time.Second,
+	}
+	for _, o := range opts {
+		o.set(options)
+	}
+
+	return options
+}
+
+// runInteractive runs the workers in the foreground until the process is
+// interrupted.
+func runInteractive(name string, options *options) error {
+	ctx, stop := signalContext()
+	defer stop()
+
+	if err := run(ctx, options); err != nil {
+		return fmt.Errorf("msmqsvc: Run(%s) failed to run workers: %w", name, err)
+	}
+
+	return nil
+}
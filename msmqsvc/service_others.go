@@ -0,0 +1,23 @@
+// +build !windows
+
+package msmqsvc
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// Run runs the workers configured by opts in the foreground until the
+// process is interrupted or terminated, since services are specific to
+// Windows. It returns the error of the first worker that failed.
+func Run(name string, opts ...Option) error {
+	return runInteractive(name, newOptions(opts))
+}
+
+// signalContext returns a context that is done when the process is
+// interrupted or terminated.
+func signalContext() (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+}
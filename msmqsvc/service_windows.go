@@ -0,0 +1,99 @@
+// +build windows
+
+package msmqsvc
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+
+	"golang.org/x/sys/windows/svc"
+)
+
+// Run runs the workers configured by opts as the Windows service name until
+// the service is stopped, or in the foreground when the process is not
+// started by the service control manager. It returns the error of the first
+// worker that failed.
+func Run(name string, opts ...Option) error {
+	options := newOptions(opts)
+
+	interactive, err := svc.IsAnInteractiveSession()
+	if err != nil {
+		return fmt.Errorf("msmqsvc: Run(%s) failed to detect session: %w", name, err)
+	}
+	if interactive {
+		return runInteractive(name, options)
+	}
+
+	h := &handler{options: options}
+	if err := svc.Run(name, h); err != nil {
+		return fmt.Errorf("msmqsvc: Run(%s) failed to run service: %w", name, err)
+	}
+	if h.err != nil {
+		return fmt.Errorf("msmqsvc: Run(%s) failed to run workers: %w", name, h.err)
+	}
+
+	return nil
+}
+
+// handler is the svc.Handler of the service.
+type handler struct {
+	options *options
+
+	// err is the error of the workers, set before Execute returns.
+	err error
+}
+
+// Execute implements svc.Handler. It runs the workers until a stop or
+// shutdown request, or until a worker fails.
+func (h *handler) Execute(args []string, r <-chan svc.ChangeRequest, s chan<- svc.Status) (bool, uint32) {
+	const accepts = svc.AcceptStop | svc.AcceptShutdown
+
+	s <- svc.Status{State: svc.StartPending}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- run(ctx, h.options)
+	}()
+
+	s <- svc.Status{State: svc.Running, Accepts: accepts}
+
+	for {
+		select {
+		case c := <-r:
+			switch c.Cmd {
+			case svc.Interrogate:
+				s <- c.CurrentStatus
+			case svc.Stop, svc.Shutdown:
+				s <- svc.Status{State: svc.StopPending, WaitHint: uint32(h.options.stopTimeout / time.Millisecond)}
+				cancel()
+				h.err = <-done
+				return h.exit()
+			}
+		case h.err = <-done:
+			s <- svc.Status{State: svc.StopPending}
+			return h.exit()
+		}
+	}
+}
+
+// exit returns the exit code of the service: a service-specific exit code of
+// 1 if the workers failed.
+func (h *handler) exit() (bool, uint32) {
+	if h.err != nil {
+		return true, 1
+	}
+
+	return false, 0
+}
+
+// signalContext returns a context that is done when the process is
+// interrupted.
+func signalContext() (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(context.Background(), os.Interrupt)
+}
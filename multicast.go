@@ -0,0 +1,57 @@
+package msmq
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+)
+
+// MulticastFormatName returns the format name used to send messages to every
+// queue associated with the specified multicast IP address and port, for
+// example "MULTICAST=234.1.1.1:8001".
+func MulticastFormatName(address string, port int) string {
+	return "MULTICAST=" + net.JoinHostPort(address, strconv.Itoa(port))
+}
+
+// OpenMulticast opens the multicast group identified by address, in the form
+// <address>:<port>, for sending. A single Send delivers the message to every
+// queue whose MulticastAddress is address:
+//   // On each receiving computer.
+//   queueInfo, err := msmq.NewQueueInfo(
+//       msmq.WithPathName(`.\private$\prices`),
+//       msmq.WithMulticastAddress("234.1.1.1:8001"),
+//   )
+//   ...
+//   // On the sending computer.
+//   group, err := msmq.OpenMulticast("234.1.1.1:8001")
+//   ...
+//   defer group.Close()
+//   err = msg.Send(group, msmq.SendWithTransaction(msmq.NoTransaction))
+//
+// Multicast messages are sent with PGM and cannot be sent as part of a
+// transaction. Use Message.SetDelivery to choose between Express and
+// Recoverable delivery. The QueueInfo used to open the group is closed when
+// the returned Queue is closed.
+//
+// See: https://docs.microsoft.com/en-us/previous-versions/windows/desktop/legacy/ms704978(v=vs.85)
+func OpenMulticast(address string) (*Queue, error) {
+	name := "MULTICAST=" + address
+	err := ValidateFormatName(name)
+	if err != nil {
+		return nil, fmt.Errorf("go-msmq: OpenMulticast(%s) failed to open multicast group: %w", address, err)
+	}
+
+	qi, err := NewQueueInfo(WithFormatName(name))
+	if err != nil {
+		return nil, fmt.Errorf("go-msmq: OpenMulticast(%s) failed to open multicast group: %w", address, err)
+	}
+
+	q, err := qi.Open(Send, DenyNone)
+	if err != nil {
+		qi.Close()
+		return nil, fmt.Errorf("go-msmq: OpenMulticast(%s) failed to open multicast group: %w", address, err)
+	}
+
+	q.onClose = qi.Close
+	return q, nil
+}
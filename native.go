@@ -0,0 +1,110 @@
+package msmq
+
+import (
+	"errors"
+	"time"
+)
+
+// NativeQueue is a queue opened through the native Message Queuing API in
+// mqrt.dll instead of the COM object model. Sending and receiving through
+// NativeQueue avoids the automation overhead of every COM call, which makes
+// it suitable for high-throughput loops, and exposes features that the COM
+// object model does not, such as overlapped receives.
+//
+// Unlike Queue, NativeQueue does not require COM to be initialized.
+//
+// See: https://docs.microsoft.com/en-us/windows/win32/msmq/message-queuing-functions
+type NativeQueue struct {
+	handle     uintptr
+	formatName string
+
+	// bodySize is the size of the body buffer allocated for the next
+	// receive. It grows to fit the largest message received and is accessed
+	// atomically because overlapped receives complete on other goroutines.
+	bodySize uint32
+}
+
+// MessageQueue is the interface of a queue that exchanges messages as
+// NativeMessage values. It is implemented by NativeQueue and by the in-memory
+// queues of the msmqfake package, so code written against MessageQueue can
+// be tested on any platform without MSMQ.
+type MessageQueue interface {
+	// Send sends msg to the queue.
+	Send(msg *NativeMessage, level TransactionLevel) error
+
+	// Receive receives the first message in the queue, waiting up to
+	// timeout for a message to arrive. A negative timeout waits
+	// indefinitely.
+	Receive(timeout time.Duration, level TransactionLevel) (*NativeMessage, error)
+
+	// Peek returns the first message in the queue without removing it,
+	// waiting up to timeout for a message to arrive.
+	Peek(timeout time.Duration) (*NativeMessage, error)
+
+	// Close closes the queue.
+	Close() error
+}
+
+var _ MessageQueue = (*NativeQueue)(nil)
+
+// ErrNativeQueueClosed is returned when an operation is attempted on a
+// closed NativeQueue.
+var ErrNativeQueueClosed = errors.New("go-msmq: native queue is closed")
+
+// NativeMessage is a message sent or received through a NativeQueue.
+type NativeMessage struct {
+	// ID is the identifier of the message. It is set by MSMQ when the
+	// message is sent and is ignored by Send.
+	ID [20]byte
+
+	// CorrelationID is the correlation identifier of the message. It is not
+	// sent if all zero.
+	CorrelationID [20]byte
+
+	// Label is the label of the message.
+	Label string
+
+	// Body is the body of the message.
+	Body []byte
+
+	// BodyType is the type of the body. It is not sent if zero. See
+	// DecodeBody and EncodeBody.
+	BodyType BodyType
+
+	// Priority is the priority of the message, from 0 through 7. If zero,
+	// the message is sent with the default priority of 3.
+	Priority uint8
+
+	// Delivery is the delivery mode of the message.
+	Delivery DeliveryMode
+
+	// AppSpecific is application-specific information.
+	AppSpecific uint32
+
+	// LookupID is the lookup identifier of a received message. It is ignored
+	// by Send.
+	LookupID LookupID
+
+	// SentTime is the time the message was sent. It is ignored by Send.
+	SentTime time.Time
+
+	// ArrivedTime is the time the message arrived in the queue. It is
+	// ignored by Send.
+	ArrivedTime time.Time
+}
+
+// NativeResult is the result of an overlapped receive.
+type NativeResult struct {
+	Message *NativeMessage
+	Err     error
+}
+
+// Handle returns the handle of the queue.
+func (q *NativeQueue) Handle() uintptr {
+	return q.handle
+}
+
+// FormatName returns the format name the queue was opened with.
+func (q *NativeQueue) FormatName() string {
+	return q.formatName
+}
@@ -0,0 +1,49 @@
+// +build !windows
+
+package msmq
+
+import (
+	"time"
+)
+
+// OpenNativeQueue returns ErrUnsupportedPlatform.
+func OpenNativeQueue(formatName string, accessMode AccessMode, shareMode ShareMode) (*NativeQueue, error) {
+	return nil, ErrUnsupportedPlatform
+}
+
+// OpenNative returns ErrUnsupportedPlatform.
+func (qi *QueueInfo) OpenNative(accessMode AccessMode, shareMode ShareMode) (*NativeQueue, error) {
+	return nil, ErrUnsupportedPlatform
+}
+
+// Close returns ErrUnsupportedPlatform.
+func (q *NativeQueue) Close() error {
+	return ErrUnsupportedPlatform
+}
+
+// Send returns ErrUnsupportedPlatform.
+func (q *NativeQueue) Send(msg *NativeMessage, level TransactionLevel) error {
+	return ErrUnsupportedPlatform
+}
+
+// Receive returns ErrUnsupportedPlatform.
+func (q *NativeQueue) Receive(timeout time.Duration, level TransactionLevel) (*NativeMessage, error) {
+	return nil, ErrUnsupportedPlatform
+}
+
+// Peek returns ErrUnsupportedPlatform.
+func (q *NativeQueue) Peek(timeout time.Duration) (*NativeMessage, error) {
+	return nil, ErrUnsupportedPlatform
+}
+
+// ReceiveAsync delivers ErrUnsupportedPlatform.
+func (q *NativeQueue) ReceiveAsync(timeout time.Duration) <-chan NativeResult {
+	results := make(chan NativeResult, 1)
+	results <- NativeResult{Err: ErrUnsupportedPlatform}
+	return results
+}
+
+// MoveMessage returns ErrUnsupportedPlatform.
+func (q *NativeQueue) MoveMessage(lookupID LookupID, dest *NativeQueue, level TransactionLevel) error {
+	return ErrUnsupportedPlatform
+}
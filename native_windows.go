@@ -0,0 +1,514 @@
+// +build windows
+
+package msmq
+
+import (
+	"fmt"
+	"runtime"
+	"sync/atomic"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// Message property identifiers.
+//
+// See: https://docs.microsoft.com/en-us/windows/win32/msmq/message-properties
+const (
+	propidMMsgID         = 2
+	propidMCorrelationID = 3
+	propidMPriority      = 4
+	propidMDelivery      = 5
+	propidMAppSpecific   = 8
+	propidMBody          = 9
+	propidMBodySize      = 10
+	propidMLabel         = 11
+	propidMLabelLen      = 12
+	propidMSentTime      = 31
+	propidMArrivedTime   = 32
+	propidMBodyType      = 42
+	propidMLookupID      = 60
+)
+
+// Variant types of message properties.
+const (
+	vtUI1    = 17
+	vtUI4    = 19
+	vtUI8    = 21
+	vtLPWSTR = 31
+	vtVector = 0x1000
+)
+
+// Receive actions.
+const (
+	mqActionReceive     = 0x00000000
+	mqActionPeekCurrent = 0x80000000
+)
+
+const (
+	// mqMaxMsgLabelLen is the maximum length of a label in characters.
+	mqMaxMsgLabelLen = 250
+
+	// mqInformationOperationPending is returned when an overlapped receive
+	// is pending.
+	mqInformationOperationPending = 0x400E0006
+
+	// mqErrorBufferOverflow is returned when the body buffer is too small.
+	// The message is left in the queue.
+	mqErrorBufferOverflow = 0xC00E001A
+
+	// defaultBodySize is the initial size of the body buffer.
+	defaultBodySize = 4096
+)
+
+// mqPropVariant mirrors the layout of MQPROPVARIANT.
+type mqPropVariant struct {
+	vt       uint16
+	reserved [3]uint16
+	val      [2]uintptr
+}
+
+// mqMsgProps mirrors the layout of MQMSGPROPS.
+type mqMsgProps struct {
+	cProp    uint32
+	aPropID  *uint32
+	aPropVar *mqPropVariant
+	aStatus  *int32
+}
+
+// msgProps builds an MQMSGPROPS. The capacity is fixed when created so that
+// the pointers returned by add remain valid.
+type msgProps struct {
+	ids    []uint32
+	vars   []mqPropVariant
+	status []int32
+	props  mqMsgProps
+}
+
+func newMsgProps(n int) *msgProps {
+	return &msgProps{
+		ids:    make([]uint32, 0, n),
+		vars:   make([]mqPropVariant, 0, n),
+		status: make([]int32, 0, n),
+	}
+}
+
+// add adds the property id of type vt and returns its value.
+func (p *msgProps) add(id uint32, vt uint16) *mqPropVariant {
+	p.ids = append(p.ids, id)
+	p.vars = append(p.vars, mqPropVariant{vt: vt})
+	p.status = append(p.status, 0)
+	return &p.vars[len(p.vars)-1]
+}
+
+func (p *msgProps) addUI1(id uint32, v uint8) *mqPropVariant {
+	pv := p.add(id, vtUI1)
+	*(*uint8)(unsafe.Pointer(&pv.val)) = v
+	return pv
+}
+
+func (p *msgProps) addUI4(id uint32, v uint32) *mqPropVariant {
+	pv := p.add(id, vtUI4)
+	*(*uint32)(unsafe.Pointer(&pv.val)) = v
+	return pv
+}
+
+func (p *msgProps) addUI8(id uint32) *mqPropVariant {
+	return p.add(id, vtUI8)
+}
+
+// addBytes adds a VT_UI1|VT_VECTOR property that refers to b.
+func (p *msgProps) addBytes(id uint32, b []byte) *mqPropVariant {
+	pv := p.add(id, vtUI1|vtVector)
+	pv.val[0] = uintptr(len(b))
+	if len(b) > 0 {
+		pv.val[1] = uintptr(unsafe.Pointer(&b[0]))
+	}
+	return pv
+}
+
+// addString adds a VT_LPWSTR property that refers to s.
+func (p *msgProps) addString(id uint32, s []uint16) *mqPropVariant {
+	pv := p.add(id, vtLPWSTR)
+	pv.val[0] = uintptr(unsafe.Pointer(&s[0]))
+	return pv
+}
+
+// msgProps returns the MQMSGPROPS.
+func (p *msgProps) msgProps() *mqMsgProps {
+	p.props = mqMsgProps{
+		cProp:    uint32(len(p.ids)),
+		aPropID:  &p.ids[0],
+		aPropVar: &p.vars[0],
+		aStatus:  &p.status[0],
+	}
+	return &p.props
+}
+
+func (pv *mqPropVariant) uint8() uint8 {
+	return *(*uint8)(unsafe.Pointer(&pv.val))
+}
+
+func (pv *mqPropVariant) uint32() uint32 {
+	return *(*uint32)(unsafe.Pointer(&pv.val))
+}
+
+func (pv *mqPropVariant) uint64() uint64 {
+	return *(*uint64)(unsafe.Pointer(&pv.val))
+}
+
+// OpenNativeQueue opens the queue referenced by formatName through the
+// native Message Queuing API.
+//
+// See: https://docs.microsoft.com/en-us/windows/win32/api/mq/nf-mq-mqopenqueue
+func OpenNativeQueue(formatName string, accessMode AccessMode, shareMode ShareMode) (*NativeQueue, error) {
+	if err := procMQOpenQueue.Find(); err != nil {
+		return nil, fmt.Errorf("go-msmq: OpenNativeQueue(%s) failed to open queue: %w", formatName, err)
+	}
+
+	name, err := windows.UTF16PtrFromString(formatName)
+	if err != nil {
+		return nil, fmt.Errorf("go-msmq: OpenNativeQueue(%s) failed to open queue: %w", formatName, err)
+	}
+
+	var handle uintptr
+	hr, _, _ := procMQOpenQueue.Call(
+		uintptr(unsafe.Pointer(name)),
+		uintptr(accessMode),
+		uintptr(shareMode),
+		uintptr(unsafe.Pointer(&handle)))
+	if err := mqError(hr); err != nil {
+		return nil, fmt.Errorf("go-msmq: OpenNativeQueue(%s) failed to open queue: %w", formatName, err)
+	}
+
+	return &NativeQueue{
+		handle:     handle,
+		formatName: formatName,
+		bodySize:   defaultBodySize,
+	}, nil
+}
+
+// OpenNative opens the queue through the native Message Queuing API instead
+// of the COM object model.
+func (qi *QueueInfo) OpenNative(accessMode AccessMode, shareMode ShareMode) (*NativeQueue, error) {
+	formatName, err := qi.FormatName()
+	if err != nil {
+		return nil, fmt.Errorf("go-msmq: OpenNative(%v, %v) failed to open queue: %w", accessMode, shareMode, err)
+	}
+
+	return OpenNativeQueue(formatName, accessMode, shareMode)
+}
+
+// Close closes the queue. Pending overlapped receives complete with an
+// error.
+//
+// See: https://docs.microsoft.com/en-us/windows/win32/api/mq/nf-mq-mqclosequeue
+func (q *NativeQueue) Close() error {
+	if q.handle == 0 {
+		return nil
+	}
+
+	hr, _, _ := procMQCloseQueue.Call(q.handle)
+	if err := mqError(hr); err != nil {
+		return fmt.Errorf("go-msmq: Close() failed to close queue: %w", err)
+	}
+	q.handle = 0
+
+	return nil
+}
+
+// Send sends msg to the queue. The queue must be opened with Send
+// AccessMode. level must be NoTransaction, MTS, XA or SingleMessage.
+//
+// See: https://docs.microsoft.com/en-us/windows/win32/api/mq/nf-mq-mqsendmessage
+func (q *NativeQueue) Send(msg *NativeMessage, level TransactionLevel) error {
+	if q.handle == 0 {
+		return fmt.Errorf("go-msmq: Send() failed to send message: %w", ErrNativeQueueClosed)
+	}
+	if err := procMQSendMessage.Find(); err != nil {
+		return fmt.Errorf("go-msmq: Send() failed to send message: %w", err)
+	}
+
+	props := newMsgProps(7)
+	props.addBytes(propidMBody, msg.Body)
+	props.addUI1(propidMDelivery, uint8(msg.Delivery))
+	props.addUI4(propidMAppSpecific, msg.AppSpecific)
+
+	if msg.Label != "" {
+		label, err := windows.UTF16FromString(msg.Label)
+		if err != nil {
+			return fmt.Errorf("go-msmq: Send() failed to send message: %w", err)
+		}
+		props.addString(propidMLabel, label)
+	}
+	if msg.Priority != 0 {
+		props.addUI1(propidMPriority, msg.Priority)
+	}
+	if msg.CorrelationID != [20]byte{} {
+		props.addBytes(propidMCorrelationID, msg.CorrelationID[:])
+	}
+	if msg.BodyType != 0 {
+		props.addUI4(propidMBodyType, uint32(msg.BodyType))
+	}
+
+	hr, _, _ := procMQSendMessage.Call(q.handle, uintptr(unsafe.Pointer(props.msgProps())), uintptr(level))
+	runtime.KeepAlive(props)
+	runtime.KeepAlive(msg)
+	if err := mqError(hr); err != nil {
+		return fmt.Errorf("go-msmq: Send() failed to send message: %w", err)
+	}
+
+	return nil
+}
+
+// Receive receives the first message in the queue, waiting up to timeout for
+// a message to arrive. A negative timeout waits indefinitely. The queue must
+// be opened with Receive AccessMode. level must be NoTransaction, MTS, XA or
+// SingleMessage.
+//
+// See: https://docs.microsoft.com/en-us/windows/win32/api/mq/nf-mq-mqreceivemessage
+func (q *NativeQueue) Receive(timeout time.Duration, level TransactionLevel) (*NativeMessage, error) {
+	msg, err := q.receive(timeout, mqActionReceive, level)
+	if err != nil {
+		return nil, fmt.Errorf("go-msmq: Receive(%v) failed to receive message: %w", timeout, err)
+	}
+
+	return msg, nil
+}
+
+// Peek returns the first message in the queue without removing it, waiting
+// up to timeout for a message to arrive. A negative timeout waits
+// indefinitely. The queue must be opened with Peek or Receive AccessMode.
+func (q *NativeQueue) Peek(timeout time.Duration) (*NativeMessage, error) {
+	msg, err := q.receive(timeout, mqActionPeekCurrent, NoTransaction)
+	if err != nil {
+		return nil, fmt.Errorf("go-msmq: Peek(%v) failed to peek message: %w", timeout, err)
+	}
+
+	return msg, nil
+}
+
+// ReceiveAsync starts an overlapped receive of the first message in the
+// queue and returns a channel that delivers the result. The receive waits up
+// to timeout for a message to arrive. A negative timeout waits indefinitely.
+// Closing the queue completes a pending receive with an error.
+//
+// Overlapped receives cannot be part of a transaction.
+func (q *NativeQueue) ReceiveAsync(timeout time.Duration) <-chan NativeResult {
+	results := make(chan NativeResult, 1)
+	fail := func(err error) <-chan NativeResult {
+		results <- NativeResult{Err: fmt.Errorf("go-msmq: ReceiveAsync(%v) failed to receive message: %w", timeout, err)}
+		return results
+	}
+
+	if q.handle == 0 {
+		return fail(ErrNativeQueueClosed)
+	}
+	if err := procMQReceiveMessage.Find(); err != nil {
+		return fail(err)
+	}
+
+	event, err := windows.CreateEvent(nil, 1, 0, nil)
+	if err != nil {
+		return fail(err)
+	}
+
+	overlapped := &windows.Overlapped{HEvent: event}
+	rp := newReceiveProps(atomic.LoadUint32(&q.bodySize))
+	hr, _, _ := procMQReceiveMessage.Call(
+		q.handle,
+		milliseconds(timeout),
+		mqActionReceive,
+		uintptr(unsafe.Pointer(rp.props.msgProps())),
+		uintptr(unsafe.Pointer(overlapped)),
+		0,
+		0,
+		0)
+	if hr != mqInformationOperationPending {
+		windows.CloseHandle(event)
+		if err := mqError(hr); err != nil && hr != mqErrorBufferOverflow {
+			return fail(err)
+		}
+
+		// The receive completed synchronously. A message that did not fit
+		// the buffer is still in the queue, so it is received again.
+		if hr == mqErrorBufferOverflow {
+			q.grow(rp)
+			msg, err := q.receive(0, mqActionReceive, NoTransaction)
+			if err != nil {
+				return fail(err)
+			}
+			results <- NativeResult{Message: msg}
+			return results
+		}
+
+		results <- NativeResult{Message: rp.message()}
+		return results
+	}
+
+	go func() {
+		defer windows.CloseHandle(event)
+
+		if _, err := windows.WaitForSingleObject(event, windows.INFINITE); err != nil {
+			fail(err)
+			return
+		}
+
+		hr, _, _ := procMQGetOverlappedResult.Call(uintptr(unsafe.Pointer(overlapped)))
+		runtime.KeepAlive(rp)
+		if hr == mqErrorBufferOverflow {
+			q.grow(rp)
+			msg, err := q.receive(0, mqActionReceive, NoTransaction)
+			if err != nil {
+				fail(err)
+				return
+			}
+			results <- NativeResult{Message: msg}
+			return
+		}
+		if err := mqError(hr); err != nil {
+			fail(err)
+			return
+		}
+
+		results <- NativeResult{Message: rp.message()}
+	}()
+
+	return results
+}
+
+// MoveMessage moves the message referenced by lookupID from this queue to
+// dest, which must be a subqueue of the same queue opened with Move
+// AccessMode. This queue must be opened with Receive AccessMode.
+//
+// See: https://docs.microsoft.com/en-us/windows/win32/api/mq/nf-mq-mqmovemessage
+func (q *NativeQueue) MoveMessage(lookupID LookupID, dest *NativeQueue, level TransactionLevel) error {
+	if err := mqMoveMessage(q.handle, dest.handle, uint64(lookupID), level); err != nil {
+		return fmt.Errorf("go-msmq: MoveMessage(%v) failed to move message: %w", lookupID, err)
+	}
+
+	return nil
+}
+
+// receive receives or peeks the first message in the queue. A message that
+// does not fit the body buffer is left in the queue by MSMQ, so the receive
+// is retried with a buffer of the reported size.
+func (q *NativeQueue) receive(timeout time.Duration, action uint32, level TransactionLevel) (*NativeMessage, error) {
+	if q.handle == 0 {
+		return nil, ErrNativeQueueClosed
+	}
+	if err := procMQReceiveMessage.Find(); err != nil {
+		return nil, err
+	}
+
+	for {
+		rp := newReceiveProps(atomic.LoadUint32(&q.bodySize))
+		hr, _, _ := procMQReceiveMessage.Call(
+			q.handle,
+			milliseconds(timeout),
+			uintptr(action),
+			uintptr(unsafe.Pointer(rp.props.msgProps())),
+			0,
+			0,
+			0,
+			uintptr(level))
+		runtime.KeepAlive(rp)
+		if hr == mqErrorBufferOverflow {
+			q.grow(rp)
+			continue
+		}
+		if err := mqError(hr); err != nil {
+			return nil, err
+		}
+
+		return rp.message(), nil
+	}
+}
+
+// grow grows the body buffer to the size of the message that did not fit
+// rp.
+func (q *NativeQueue) grow(rp *receiveProps) {
+	size := rp.bodySize.uint32()
+	for {
+		current := atomic.LoadUint32(&q.bodySize)
+		if size <= current || atomic.CompareAndSwapUint32(&q.bodySize, current, size) {
+			return
+		}
+	}
+}
+
+// receiveProps are the properties retrieved by a receive.
+type receiveProps struct {
+	props *msgProps
+
+	id            [20]byte
+	correlationID [20]byte
+	body          []byte
+	label         []uint16
+
+	priority    *mqPropVariant
+	delivery    *mqPropVariant
+	appSpecific *mqPropVariant
+	bodySize    *mqPropVariant
+	labelLen    *mqPropVariant
+	bodyType    *mqPropVariant
+	lookupID    *mqPropVariant
+	sentTime    *mqPropVariant
+	arrivedTime *mqPropVariant
+}
+
+func newReceiveProps(bodySize uint32) *receiveProps {
+	rp := &receiveProps{
+		props: newMsgProps(13),
+		body:  make([]byte, bodySize),
+		label: make([]uint16, mqMaxMsgLabelLen+1),
+	}
+
+	rp.props.addBytes(propidMMsgID, rp.id[:])
+	rp.props.addBytes(propidMCorrelationID, rp.correlationID[:])
+	rp.props.addBytes(propidMBody, rp.body)
+	rp.bodySize = rp.props.addUI4(propidMBodySize, 0)
+	rp.props.addString(propidMLabel, rp.label)
+	rp.labelLen = rp.props.addUI4(propidMLabelLen, uint32(len(rp.label)))
+	rp.priority = rp.props.addUI1(propidMPriority, 0)
+	rp.delivery = rp.props.addUI1(propidMDelivery, 0)
+	rp.appSpecific = rp.props.addUI4(propidMAppSpecific, 0)
+	rp.bodyType = rp.props.addUI4(propidMBodyType, 0)
+	rp.lookupID = rp.props.addUI8(propidMLookupID)
+	rp.sentTime = rp.props.addUI4(propidMSentTime, 0)
+	rp.arrivedTime = rp.props.addUI4(propidMArrivedTime, 0)
+
+	return rp
+}
+
+// message returns the received message.
+func (rp *receiveProps) message() *NativeMessage {
+	label := rp.label
+	if n := rp.labelLen.uint32(); n > 0 && int(n) <= len(label) {
+		label = label[:n]
+	}
+
+	return &NativeMessage{
+		ID:            rp.id,
+		CorrelationID: rp.correlationID,
+		Label:         windows.UTF16ToString(label),
+		Body:          rp.body[:rp.bodySize.uint32()],
+		BodyType:      BodyType(rp.bodyType.uint32()),
+		Priority:      rp.priority.uint8(),
+		Delivery:      DeliveryMode(rp.delivery.uint8()),
+		AppSpecific:   rp.appSpecific.uint32(),
+		LookupID:      LookupID(rp.lookupID.uint64()),
+		SentTime:      time.Unix(int64(rp.sentTime.uint32()), 0),
+		ArrivedTime:   time.Unix(int64(rp.arrivedTime.uint32()), 0),
+	}
+}
+
+// milliseconds returns timeout as the timeout argument of MQReceiveMessage.
+func milliseconds(timeout time.Duration) uintptr {
+	if timeout < 0 {
+		return uintptr(windows.INFINITE)
+	}
+
+	return uintptr(uint32(timeout / time.Millisecond))
+}
@@ -0,0 +1,129 @@
+package msmq
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Operation describes an operation of the package reported to an Observer.
+type Operation struct {
+	// Name is the name of the operation, such as "Open", "Send", "Receive",
+	// "PeekCurrent" or "Purge".
+	Name string
+
+	// Queue is the format name of the queue the operation was performed on.
+	// It is empty for operations that are not bound to an open queue, such
+	// as sending to a Destination.
+	Queue string
+
+	// Duration is the time the operation took, including retries.
+	Duration time.Duration
+
+	// Message reports whether a message was sent, received or peeked. It is
+	// false when a receive or peek times out without a message.
+	Message bool
+
+	// Err is the error the operation failed with, or nil.
+	Err error
+}
+
+// Observer is notified of the operations of the package, for example to
+// collect metrics. Observe is called synchronously after each operation and
+// may be called concurrently, so it must be fast and safe for concurrent use.
+type Observer interface {
+	Observe(op Operation)
+}
+
+// ObserverFunc is an adapter to use an ordinary function as an Observer.
+type ObserverFunc func(op Operation)
+
+// Observe calls f(op).
+func (f ObserverFunc) Observe(op Operation) {
+	f(op)
+}
+
+// Handling describes the processing of a message by the handler of a
+// consumer.
+type Handling struct {
+	// Queue is the format name of the queue the message was received from.
+	Queue string
+
+	// Handler is the name of the handler, which identifies it among the
+	// handlers of the same queue. It is empty if the handler is not named.
+	Handler string
+
+	// Duration is the time the handler took to process the message.
+	Duration time.Duration
+
+	// Retries is the number of times the message was processed before,
+	// which is 0 for the first attempt.
+	Retries int
+
+	// Err is the error returned by the handler, or nil if it succeeded.
+	Err error
+}
+
+// HandlerObserver is implemented by Observers that are notified when a
+// consumer handler processes a message, to collect metrics about the
+// performance of the handlers. ObserveHandling is called after every
+// attempt to process a message.
+type HandlerObserver interface {
+	ObserveHandling(h Handling)
+}
+
+// MultiObserver returns an Observer that notifies each of observers in turn.
+// The Observer implements HandlerObserver and notifies the observers that
+// implement it.
+func MultiObserver(observers ...Observer) Observer {
+	return multiObserver(observers)
+}
+
+// multiObserver is the Observer returned by MultiObserver.
+type multiObserver []Observer
+
+// Observe implements Observer.
+func (m multiObserver) Observe(op Operation) {
+	for _, o := range m {
+		o.Observe(op)
+	}
+}
+
+// ObserveHandling implements HandlerObserver.
+func (m multiObserver) ObserveHandling(h Handling) {
+	for _, o := range m {
+		if ho, ok := o.(HandlerObserver); ok {
+			ho.ObserveHandling(h)
+		}
+	}
+}
+
+// packageObserver is the Observer installed by SetObserver.
+var packageObserver atomic.Pointer[Observer]
+
+// SetObserver installs o as the Observer of the package. A nil Observer
+// disables the notifications, which is the default.
+func SetObserver(o Observer) {
+	if o == nil {
+		packageObserver.Store(nil)
+		return
+	}
+
+	packageObserver.Store(&o)
+}
+
+// currentObserver returns the Observer of the package, or nil.
+func currentObserver() Observer {
+	if o := packageObserver.Load(); o != nil {
+		return *o
+	}
+
+	return nil
+}
+
+// observeHandling reports h to the Observer of the package if it implements
+// HandlerObserver.
+func observeHandling(h Handling) {
+	if o, ok := currentObserver().(HandlerObserver); ok {
+		o.ObserveHandling(h)
+	}
+}
@@ -0,0 +1,82 @@
+package msmq
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Options configures how Open opens a queue.
+type Options struct {
+	// AccessMode specifies how the queue is accessed. The default is
+	// Receive.
+	AccessMode AccessMode
+
+	// ShareMode specifies who else can access the queue. The default is
+	// DenyNone.
+	ShareMode ShareMode
+
+	// Retry retries opening the queue when it fails with a retryable error.
+	// The default is not to retry.
+	Retry *RetryPolicy
+
+	// PeekOptions are the default options of Peek, PeekCurrent and PeekNext
+	// on the queue. They override the defaults of the package, and the
+	// options passed to each call override them:
+	//   queue, err := msmq.Open(name, msmq.Options{
+	//       AccessMode:  msmq.Peek,
+	//       PeekOptions: msmq.FastPeek(msmq.PeekWithTimeout(0)),
+	//   })
+	PeekOptions []PeekOption
+
+	// ReceiveOptions are the default options of Receive and ReceiveCurrent
+	// on the queue, like PeekOptions.
+	ReceiveOptions []ReceiveOption
+
+	// AutoReopen reopens the queue when its handle is no longer valid; see
+	// Queue.SetAutoReopen. The default is false.
+	AutoReopen bool
+}
+
+// Open opens the queue referenced by name, which is either a format name or a
+// path name, in a single call:
+//   queue, err := msmq.Open(`DIRECT=OS:.\private$\orders`, msmq.Options{
+//       AccessMode: msmq.Send,
+//   })
+//   ...
+//   defer queue.Close()
+// It is equivalent to creating a QueueInfo with WithFormatName or
+// WithPathName and calling QueueInfo.Open. The QueueInfo is closed when the
+// returned Queue is closed.
+func Open(name string, opts Options) (*Queue, error) {
+	accessMode := opts.AccessMode
+	if accessMode == 0 {
+		accessMode = Receive
+	}
+
+	option := WithPathName(name)
+	if strings.Contains(name, "=") {
+		option = WithFormatName(name)
+	}
+
+	qi, err := NewQueueInfo(option)
+	if err != nil {
+		return nil, fmt.Errorf("go-msmq: Open(%s) failed to open queue: %w", name, err)
+	}
+
+	var q *Queue
+	err = opts.Retry.Do(func() error {
+		var err error
+		q, err = qi.Open(accessMode, opts.ShareMode)
+		return err
+	})
+	if err != nil {
+		qi.Close()
+		return nil, fmt.Errorf("go-msmq: Open(%s) failed to open queue: %w", name, err)
+	}
+
+	q.onClose = qi.Close
+	q.peekOptions = opts.PeekOptions
+	q.receiveOptions = opts.ReceiveOptions
+	q.SetAutoReopen(opts.AutoReopen)
+	return q, nil
+}
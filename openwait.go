@@ -0,0 +1,83 @@
+package msmq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// OpenWaitOption represents an option to wait for a queue in
+// QueueInfo.OpenWait.
+type OpenWaitOption struct {
+	set func(o *openWaitOptions)
+}
+
+// openWaitOptions contains all the options to wait for a queue.
+type openWaitOptions struct {
+	backoff *RetryPolicy
+}
+
+// OpenWaitWithBackoff returns an OpenWaitOption that configures the wait
+// between attempts to open the queue with the backoff fields of policy, and
+// which errors are waited out with its Retryable field. Unlike other uses of
+// RetryPolicy, attempts are only limited if MaxAttempts is set.
+//
+// The default waits from 100 milliseconds up to 10 seconds, doubling after
+// each attempt, while IsWaitable reports the error.
+func OpenWaitWithBackoff(policy *RetryPolicy) OpenWaitOption {
+	return OpenWaitOption{
+		set: func(o *openWaitOptions) {
+			o.backoff = policy
+		},
+	}
+}
+
+// IsWaitable reports whether err is an error that QueueInfo.OpenWait waits
+// out: a queue that does not exist yet, or a transient error such as the
+// queue manager still starting, see IsTransient.
+func IsWaitable(err error) bool {
+	return errors.Is(err, ErrQueueNotFound) || IsTransient(err)
+}
+
+// OpenWait is like Open but, when the queue does not exist yet or the
+// Message Queuing service is not available, waits and tries again until the
+// queue opens or ctx is done. It suits consumers that start before the queue
+// is provisioned or while the computer restarts:
+//   ctx, cancel := context.WithTimeout(ctx, 5*time.Minute)
+//   defer cancel()
+//   queue, err := qi.OpenWait(ctx, msmq.Receive, msmq.DenyNone)
+// When ctx is done, the error wraps both ctx.Err() and the last error of
+// Open.
+func (qi *QueueInfo) OpenWait(ctx context.Context, accessMode AccessMode, shareMode ShareMode, opts ...OpenWaitOption) (*Queue, error) {
+	options := &openWaitOptions{}
+	for _, o := range opts {
+		o.set(options)
+	}
+
+	retryable := IsWaitable
+	if options.backoff != nil && options.backoff.Retryable != nil {
+		retryable = options.backoff.Retryable
+	}
+
+	for attempt := 1; ; attempt++ {
+		queue, err := qi.Open(accessMode, shareMode)
+		if err == nil {
+			return queue, nil
+		}
+		if !retryable(err) {
+			return nil, fmt.Errorf("go-msmq: OpenWait(%v, %v) failed to open queue: %w", accessMode, shareMode, err)
+		}
+		if options.backoff != nil && options.backoff.MaxAttempts > 0 && attempt >= options.backoff.MaxAttempts {
+			return nil, fmt.Errorf("go-msmq: OpenWait(%v, %v) failed to open queue after %d attempts: %w", accessMode, shareMode, attempt, err)
+		}
+
+		timer := time.NewTimer(options.backoff.backoff(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, fmt.Errorf("go-msmq: OpenWait(%v, %v) failed to open queue: %w: %w", accessMode, shareMode, ctx.Err(), err)
+		case <-timer.C:
+		}
+	}
+}
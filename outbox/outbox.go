@@ -0,0 +1,159 @@
+// Package outbox implements the transactional outbox pattern for services
+// that update a database and send MSMQ messages. Instead of sending a message
+// directly, which cannot be part of the database transaction, the service
+// writes the message to an outbox table in the same transaction as its other
+// changes:
+//   tx, err := db.BeginTx(ctx, nil)
+//   ...
+//   _, err = tx.ExecContext(ctx, "UPDATE orders SET state = 'shipped' WHERE id = ?", id)
+//   ...
+//   err = o.Add(ctx, tx, outbox.Message{Queue: queueName, Label: "shipped", Body: body})
+//   ...
+//   err = tx.Commit()
+//
+// A Relay then reads the pending messages from the table, sends them to their
+// queues and marks them as sent:
+//   relay, err := outbox.NewRelay(o)
+//   ...
+//   err = relay.Run(ctx)
+//
+// The outbox table must have the following columns:
+//   CREATE TABLE msmq_outbox (
+//       id         BIGINT AUTO_INCREMENT PRIMARY KEY,
+//       queue      VARCHAR(255) NOT NULL,
+//       label      VARCHAR(250) NOT NULL,
+//       body       BLOB NOT NULL,
+//       created_at BIGINT NOT NULL,
+//       sent_at    BIGINT
+//   )
+package outbox
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// errAlreadySent is returned when a message was marked as sent by another
+// relay.
+var errAlreadySent = errors.New("outbox: message already sent")
+
+// Message is a message waiting in the outbox.
+type Message struct {
+	// ID identifies the message in the outbox. It is assigned by the
+	// database and ignored by Add.
+	ID int64
+
+	// Queue is the format name or path name of the destination queue.
+	Queue string
+
+	// Label is the label of the message.
+	Label string
+
+	// Body is the body of the message.
+	Body []byte
+}
+
+// Outbox is the outbox table of a database.
+type Outbox struct {
+	// DB is the database holding the table.
+	DB *sql.DB
+
+	// Table is the name of the table. The default is "msmq_outbox".
+	Table string
+
+	// Placeholder returns the placeholder of the nth argument of a
+	// statement, starting at 1. The default returns "?", as used by MySQL
+	// and SQLite; PostgreSQL requires "$1", "$2" and so on.
+	Placeholder func(n int) string
+}
+
+// Add writes msg to the outbox within tx, so that the message is only sent if
+// tx commits.
+func (o *Outbox) Add(ctx context.Context, tx *sql.Tx, msg Message) error {
+	query := fmt.Sprintf("INSERT INTO %s (queue, label, body, created_at) VALUES (%s, %s, %s, %s)",
+		o.table(), o.placeholder(1), o.placeholder(2), o.placeholder(3), o.placeholder(4))
+
+	_, err := tx.ExecContext(ctx, query, msg.Queue, msg.Label, msg.Body, time.Now().UnixNano())
+	if err != nil {
+		return fmt.Errorf("outbox: Add(%s) failed to add message: %w", msg.Queue, err)
+	}
+
+	return nil
+}
+
+// pending returns up to limit messages that were not sent yet, in the order
+// they were added.
+func (o *Outbox) pending(ctx context.Context, limit int) ([]Message, error) {
+	query := fmt.Sprintf("SELECT id, queue, label, body FROM %s WHERE sent_at IS NULL ORDER BY id LIMIT %d", o.table(), limit)
+
+	rows, err := o.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var msgs []Message
+	for rows.Next() {
+		var msg Message
+		if err := rows.Scan(&msg.ID, &msg.Queue, &msg.Label, &msg.Body); err != nil {
+			return nil, err
+		}
+		msgs = append(msgs, msg)
+	}
+
+	return msgs, rows.Err()
+}
+
+// markSent records within tx that the message with the identifier id was
+// sent.
+func (o *Outbox) markSent(ctx context.Context, tx *sql.Tx, id int64) error {
+	query := fmt.Sprintf("UPDATE %s SET sent_at = %s WHERE id = %s AND sent_at IS NULL", o.table(), o.placeholder(1), o.placeholder(2))
+
+	res, err := tx.ExecContext(ctx, query, time.Now().UnixNano(), id)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return errAlreadySent
+	}
+
+	return nil
+}
+
+// Purge deletes the messages that were sent before t.
+func (o *Outbox) Purge(ctx context.Context, t time.Time) error {
+	query := fmt.Sprintf("DELETE FROM %s WHERE sent_at IS NOT NULL AND sent_at < %s", o.table(), o.placeholder(1))
+
+	_, err := o.DB.ExecContext(ctx, query, t.UnixNano())
+	if err != nil {
+		return fmt.Errorf("outbox: Purge() failed to delete sent messages: %w", err)
+	}
+
+	return nil
+}
+
+// table returns the name of the table.
+func (o *Outbox) table() string {
+	if o.Table != "" {
+		return o.Table
+	}
+
+	return "msmq_outbox"
+}
+
+// placeholder returns the placeholder of the nth argument.
+func (o *Outbox) placeholder(n int) string {
+	if o.Placeholder != nil {
+		return o.Placeholder(n)
+	}
+
+	return "?"
+}
@@ -0,0 +1,246 @@
+package outbox
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jandauz/go-msmq"
+)
+
+// Relay sends the pending messages of an Outbox to their queues.
+//
+// Each message is sent in an internal MSMQ transaction, and marked as sent in
+// a database transaction that is committed after the MSMQ transaction. A
+// message is therefore never lost, and is only sent twice if the database
+// transaction fails to commit after the MSMQ transaction committed. To let
+// consumers detect such duplicates, the CorrelationID of each message is set
+// to its outbox ID, so msmq.DedupMiddleware with msmq.CorrelationIDKey makes
+// the delivery exactly-once end to end.
+//
+// The destination queues must be transactional. Messages are sent in the
+// order they were added.
+type Relay struct {
+	outbox  *Outbox
+	options *relayOptions
+
+	// mu guards queues, the destination queues opened by the Relay.
+	mu     sync.Mutex
+	queues map[string]*msmq.Queue
+}
+
+// NewRelay returns a pointer to a Relay for o configured by the options. The
+// returned error wraps msmq.ErrInvalidOption if the interval or the batch
+// size is not positive.
+func NewRelay(o *Outbox, opts ...RelayOption) (*Relay, error) {
+	options := &relayOptions{
+		interval:  time.Second,
+		batchSize: 100,
+	}
+	for _, opt := range opts {
+		opt.set(options)
+	}
+
+	var errs []error
+	if options.interval <= 0 {
+		errs = append(errs, fmt.Errorf("%w: RelayWithInterval(%v): must be positive", msmq.ErrInvalidOption, options.interval))
+	}
+	if options.batchSize <= 0 {
+		errs = append(errs, fmt.Errorf("%w: RelayWithBatchSize(%d): must be positive", msmq.ErrInvalidOption, options.batchSize))
+	}
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("outbox: NewRelay() failed to create relay: %w", errors.Join(errs...))
+	}
+
+	return &Relay{
+		outbox:  o,
+		options: options,
+		queues:  make(map[string]*msmq.Queue),
+	}, nil
+}
+
+// Run relays the pending messages every poll interval until ctx is done, and
+// returns the error of ctx. Errors relaying messages are passed to the
+// callback set with RelayWithOnError and do not stop Run.
+func (r *Relay) Run(ctx context.Context) error {
+	ticker := time.NewTicker(r.options.interval)
+	defer ticker.Stop()
+
+	for {
+		// Keep relaying while full batches are pending, so that a backlog
+		// drains without waiting for the next tick.
+		for {
+			n, err := r.Relay(ctx)
+			if err != nil && r.options.onError != nil {
+				r.options.onError(err)
+			}
+			if err != nil || n < r.options.batchSize || ctx.Err() != nil {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("outbox: Run() failed to relay messages: %w", ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// Relay sends up to a batch of pending messages and returns the number of
+// messages sent. It stops at the first message that cannot be sent, so that
+// the order of the messages is preserved.
+func (r *Relay) Relay(ctx context.Context) (int, error) {
+	msgs, err := r.outbox.pending(ctx, r.options.batchSize)
+	if err != nil {
+		return 0, fmt.Errorf("outbox: Relay() failed to read pending messages: %w", err)
+	}
+
+	for i, msg := range msgs {
+		err := r.send(ctx, msg)
+		if errors.Is(err, errAlreadySent) {
+			continue
+		}
+		if err != nil {
+			return i, fmt.Errorf("outbox: Relay() failed to send message %d: %w", msg.ID, err)
+		}
+	}
+
+	return len(msgs), nil
+}
+
+// Close closes the destination queues.
+func (r *Relay) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for name, q := range r.queues {
+		q.Close()
+		delete(r.queues, name)
+	}
+
+	return nil
+}
+
+// send sends msg and marks it as sent.
+func (r *Relay) send(ctx context.Context, msg Message) error {
+	queue, err := r.queue(msg.Queue)
+	if err != nil {
+		return err
+	}
+
+	dbTx, err := r.outbox.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer dbTx.Rollback()
+
+	// Marking the message first locks its row, so that concurrent relays do
+	// not both send it.
+	if err := r.outbox.markSent(ctx, dbTx, msg.ID); err != nil {
+		return err
+	}
+
+	m, err := msmq.NewMessage()
+	if err != nil {
+		return err
+	}
+	defer m.Close()
+
+	if err := m.SetLabel(msg.Label); err != nil {
+		return err
+	}
+	if err := m.SetBodyBytes(msg.Body); err != nil {
+		return err
+	}
+	if err := m.SetCorrelationID(CorrelationID(msg.ID)); err != nil {
+		return err
+	}
+
+	tx, err := msmq.BeginTransaction()
+	if err != nil {
+		return err
+	}
+	if err := m.Send(queue, msmq.SendInTransaction(tx)); err != nil {
+		tx.Abort()
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	return dbTx.Commit()
+}
+
+// queue returns the queue referenced by name opened for sending.
+func (r *Relay) queue(name string) (*msmq.Queue, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if q, ok := r.queues[name]; ok {
+		return q, nil
+	}
+
+	q, err := msmq.Open(name, msmq.Options{AccessMode: msmq.Send})
+	if err != nil {
+		return nil, err
+	}
+
+	r.queues[name] = q
+	return q, nil
+}
+
+// CorrelationID returns the CorrelationID of the message with the outbox
+// identifier id. MSMQ correlation identifiers are 20 bytes long; the
+// identifier is stored in the last 8 bytes.
+func CorrelationID(id int64) []byte {
+	b := make([]byte, 20)
+	binary.BigEndian.PutUint64(b[12:], uint64(id))
+	return b
+}
+
+// RelayOption represents an option to configure a Relay.
+type RelayOption struct {
+	set func(opts *relayOptions)
+}
+
+// relayOptions contains all the options to configure a Relay.
+type relayOptions struct {
+	interval  time.Duration
+	batchSize int
+	onError   func(err error)
+}
+
+// RelayWithInterval returns a RelayOption that configures how often Run
+// polls the outbox. The interval must be positive. The default is 1 second.
+func RelayWithInterval(interval time.Duration) RelayOption {
+	return RelayOption{
+		set: func(opts *relayOptions) {
+			opts.interval = interval
+		},
+	}
+}
+
+// RelayWithBatchSize returns a RelayOption that configures the maximum number
+// of messages read from the outbox at once. The size must be positive. The
+// default is 100.
+func RelayWithBatchSize(size int) RelayOption {
+	return RelayOption{
+		set: func(opts *relayOptions) {
+			opts.batchSize = size
+		},
+	}
+}
+
+// RelayWithOnError returns a RelayOption that configures the callback invoked
+// by Run when messages cannot be relayed. The default is to drop the error.
+func RelayWithOnError(fn func(err error)) RelayOption {
+	return RelayOption{
+		set: func(opts *relayOptions) {
+			opts.onError = fn
+		},
+	}
+}
@@ -0,0 +1,184 @@
+// +build windows
+
+package perfcounters
+
+import (
+	"fmt"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+var (
+	pdh = windows.NewLazySystemDLL("pdh.dll")
+
+	procPdhOpenQueryW                = pdh.NewProc("PdhOpenQueryW")
+	procPdhAddEnglishCounterW        = pdh.NewProc("PdhAddEnglishCounterW")
+	procPdhCollectQueryData          = pdh.NewProc("PdhCollectQueryData")
+	procPdhGetFormattedCounterValue  = pdh.NewProc("PdhGetFormattedCounterValue")
+	procPdhGetFormattedCounterArrayW = pdh.NewProc("PdhGetFormattedCounterArrayW")
+	procPdhCloseQuery                = pdh.NewProc("PdhCloseQuery")
+)
+
+const (
+	// pdhFmtDouble formats counter values as float64.
+	pdhFmtDouble = 0x00000200
+
+	// pdhFmtNoCap100 prevents percentages from being capped at 100.
+	pdhFmtNoCap100 = 0x00008000
+
+	// pdhMoreData is returned when the buffer passed to
+	// PdhGetFormattedCounterArrayW is too small.
+	pdhMoreData = 0x800007D2
+
+	// pdhNoData is returned when a wildcard counter has no instances, for
+	// example when no queue is active.
+	pdhNoData = 0x800007D5
+
+	// pdhCStatusNewData is the highest status of a valid counter value.
+	pdhCStatusNewData = 1
+)
+
+// pdhError is a PDH_STATUS error code.
+type pdhError uint32
+
+// Error implements the error interface.
+func (e pdhError) Error() string {
+	return fmt.Sprintf("PDH error 0x%08X", uint32(e))
+}
+
+// pdhCounterValue is PDH_FMT_COUNTERVALUE formatted as a double.
+type pdhCounterValue struct {
+	status uint32
+	_      uint32
+	value  float64
+}
+
+// pdhCounterValueItem is PDH_FMT_COUNTERVALUE_ITEM_W formatted as a double.
+// The padding keeps the layout identical to the C structure on 32-bit and
+// 64-bit platforms.
+type pdhCounterValueItem struct {
+	name  *uint16
+	_     [8 - unsafe.Sizeof(uintptr(0))]byte
+	value pdhCounterValue
+}
+
+func pdhOpenQuery() (uintptr, error) {
+	if err := procPdhOpenQueryW.Find(); err != nil {
+		return 0, err
+	}
+
+	var query uintptr
+	r, _, _ := procPdhOpenQueryW.Call(0, 0, uintptr(unsafe.Pointer(&query)))
+	if r != 0 {
+		return 0, pdhError(r)
+	}
+
+	return query, nil
+}
+
+func pdhAddEnglishCounter(query uintptr, path string) (uintptr, error) {
+	p, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return 0, err
+	}
+
+	var counter uintptr
+	r, _, _ := procPdhAddEnglishCounterW.Call(query, uintptr(unsafe.Pointer(p)), 0, uintptr(unsafe.Pointer(&counter)))
+	if r != 0 {
+		return 0, pdhError(r)
+	}
+
+	return counter, nil
+}
+
+func pdhCollectQueryData(query uintptr) error {
+	r, _, _ := procPdhCollectQueryData.Call(query)
+	if r != 0 {
+		return pdhError(r)
+	}
+
+	return nil
+}
+
+func pdhGetFormattedCounterValue(counter uintptr) (float64, error) {
+	var v pdhCounterValue
+	r, _, _ := procPdhGetFormattedCounterValue.Call(counter, pdhFmtDouble|pdhFmtNoCap100, 0, uintptr(unsafe.Pointer(&v)))
+	if r != 0 {
+		return 0, pdhError(r)
+	}
+
+	if v.status > pdhCStatusNewData {
+		return 0, pdhError(v.status)
+	}
+
+	return v.value, nil
+}
+
+// pdhGetFormattedCounterArray returns the value of every instance of a
+// wildcard counter by instance name.
+func pdhGetFormattedCounterArray(counter uintptr) (map[string]float64, error) {
+	var size, count uint32
+	var items []pdhCounterValueItem
+	for {
+		var buf uintptr
+		if len(items) > 0 {
+			buf = uintptr(unsafe.Pointer(&items[0]))
+		}
+
+		r, _, _ := procPdhGetFormattedCounterArrayW.Call(counter, pdhFmtDouble|pdhFmtNoCap100, uintptr(unsafe.Pointer(&size)), uintptr(unsafe.Pointer(&count)), buf)
+		if r == 0 && buf != 0 {
+			break
+		}
+
+		switch r {
+		case pdhNoData:
+			return map[string]float64{}, nil
+		case pdhMoreData:
+			// The instance names are stored in the buffer after the items,
+			// so the buffer is allocated as items to keep it aligned.
+			itemSize := uint32(unsafe.Sizeof(pdhCounterValueItem{}))
+			items = make([]pdhCounterValueItem, (size+itemSize-1)/itemSize)
+		default:
+			return nil, pdhError(r)
+		}
+	}
+
+	values := make(map[string]float64, count)
+	for _, item := range items[:count] {
+		if item.value.status > pdhCStatusNewData {
+			continue
+		}
+
+		values[utf16PtrToString(item.name)] = item.value.value
+	}
+
+	return values, nil
+}
+
+func pdhCloseQuery(query uintptr) error {
+	r, _, _ := procPdhCloseQuery.Call(query)
+	if r != 0 {
+		return pdhError(r)
+	}
+
+	return nil
+}
+
+// utf16PtrToString converts a NUL-terminated UTF-16 string to a string.
+func utf16PtrToString(p *uint16) string {
+	if p == nil {
+		return ""
+	}
+
+	var s []uint16
+	for ptr := unsafe.Pointer(p); ; ptr = unsafe.Pointer(uintptr(ptr) + 2) {
+		c := *(*uint16)(ptr)
+		if c == 0 {
+			break
+		}
+		s = append(s, c)
+	}
+
+	return windows.UTF16ToString(s)
+}
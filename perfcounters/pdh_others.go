@@ -0,0 +1,31 @@
+// +build !windows
+
+package perfcounters
+
+import (
+	"github.com/jandauz/go-msmq"
+)
+
+func pdhOpenQuery() (uintptr, error) {
+	return 0, msmq.ErrUnsupportedPlatform
+}
+
+func pdhAddEnglishCounter(query uintptr, path string) (uintptr, error) {
+	return 0, msmq.ErrUnsupportedPlatform
+}
+
+func pdhCollectQueryData(query uintptr) error {
+	return msmq.ErrUnsupportedPlatform
+}
+
+func pdhGetFormattedCounterValue(counter uintptr) (float64, error) {
+	return 0, msmq.ErrUnsupportedPlatform
+}
+
+func pdhGetFormattedCounterArray(counter uintptr) (map[string]float64, error) {
+	return nil, msmq.ErrUnsupportedPlatform
+}
+
+func pdhCloseQuery(query uintptr) error {
+	return msmq.ErrUnsupportedPlatform
+}
@@ -0,0 +1,71 @@
+package msmq
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrPingTimeout is returned by Ping when the queue manager does not respond
+// within the timeout.
+var ErrPingTimeout = errors.New("go-msmq: queue manager did not respond in time")
+
+// ErrQueueManagerOffline is returned by Ping when the queue manager responds
+// but is disconnected from the network.
+var ErrQueueManagerOffline = errors.New("go-msmq: queue manager is offline")
+
+// Ping verifies that the queue manager of the specified computer is reachable
+// and connected to the network within timeout. An empty machine name refers to
+// the local computer. It is intended for deployment scripts and health
+// checks:
+//   err := msmq.Ping("host1", 5*time.Second)
+//   if errors.Is(err, msmq.ErrPingTimeout) {
+//       ...
+//   }
+// The queue manager is contacted through the MSMQ management interface, which
+// requires the caller to have administrative privileges on remote computers.
+// If timeout expires, the request to the queue manager is abandoned but keeps
+// running in the background until it completes.
+func Ping(machine string, timeout time.Duration) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- ping(machine)
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			return fmt.Errorf("go-msmq: Ping(%s) failed to reach queue manager: %w", machine, err)
+		}
+
+		return nil
+	case <-time.After(timeout):
+		return fmt.Errorf("go-msmq: Ping(%s) failed to reach queue manager: %w", machine, ErrPingTimeout)
+	}
+}
+
+// ping contacts the queue manager of the specified computer.
+func ping(machine string) error {
+	var opts []ApplicationOption
+	if machine != "" {
+		opts = append(opts, ApplicationWithMachine(machine))
+	}
+
+	app, err := NewApplication(opts...)
+	if err != nil {
+		return err
+	}
+	defer app.Close()
+
+	res, err := getProperty(app.dispatch, "IsConnected")
+	if err != nil {
+		return err
+	}
+	defer res.Clear()
+
+	if connected, _ := res.Value().(bool); !connected {
+		return ErrQueueManagerOffline
+	}
+
+	return nil
+}
@@ -0,0 +1,256 @@
+package msmq
+
+import (
+	"context"
+	"fmt"
+)
+
+// Stage is a step of a Pipeline. It transforms the value produced by the
+// previous stage.
+type Stage struct {
+	// Name identifies the stage in the errors routed to the error queue.
+	Name string
+
+	// Func transforms v.
+	Func func(ctx context.Context, v interface{}) (interface{}, error)
+}
+
+// StageError is the error of a failed stage of a Pipeline.
+type StageError struct {
+	// Stage is the name of the stage: "decode", "encode", "send" or the
+	// name of a Stage.
+	Stage string
+
+	Err error
+}
+
+// Error implements the error interface.
+func (e *StageError) Error() string {
+	return fmt.Sprintf("go-msmq: pipeline stage %s failed: %v", e.Stage, e.Err)
+}
+
+// Unwrap returns the error of the stage.
+func (e *StageError) Unwrap() error {
+	return e.Err
+}
+
+// Pipeline consumes messages from an input queue, passes each one through a
+// chain of stages and sends the result to one or more output queues:
+//   p := msmq.NewPipeline(input,
+//       msmq.PipelineWithDecoder(decodeOrder),
+//       msmq.PipelineWithStage("enrich", enrich),
+//       msmq.PipelineWithStage("validate", validate),
+//       msmq.PipelineWithEncoder(encodeInvoice),
+//       msmq.PipelineWithOutputs(invoices),
+//       msmq.PipelineWithErrorQueue(errors),
+//   )
+//   err := p.Run(ctx)
+//
+// The decoder turns the received message into a value, each stage transforms
+// the value, and the encoder turns the final value into the body of the
+// output message, which keeps the label of the input message. When a step
+// fails, the input message is sent to the error queue with the *StageError
+// in its Extension, and the Pipeline moves on. Without an error queue, the
+// error is returned to the Consumer, which retries the message according to
+// its options.
+//
+// The Pipeline runs on a Consumer. With ConsumerWithTransactional, the sends
+// to the output and error queues are part of the transaction of the input
+// message, so that each message is processed exactly once.
+type Pipeline struct {
+	input   *Queue
+	options *pipelineOptions
+}
+
+// NewPipeline returns a pointer to a Pipeline that consumes input, configured
+// by the options.
+func NewPipeline(input *Queue, opts ...PipelineOption) *Pipeline {
+	options := &pipelineOptions{
+		decode: func(msg Message) (interface{}, error) {
+			return msg.BodyBytes()
+		},
+		encode: func(v interface{}) ([]byte, error) {
+			switch v := v.(type) {
+			case []byte:
+				return v, nil
+			case string:
+				return []byte(v), nil
+			}
+			return nil, fmt.Errorf("go-msmq: cannot encode %T without an encoder", v)
+		},
+	}
+	for _, o := range opts {
+		o.set(options)
+	}
+
+	return &Pipeline{
+		input:   input,
+		options: options,
+	}
+}
+
+// Run consumes the input queue until ctx is done. See Consumer.Start.
+func (p *Pipeline) Run(ctx context.Context) error {
+	consumer := NewConsumer(p.input, p.options.consumerOptions...)
+	return consumer.Start(ctx, p.handle)
+}
+
+// handle processes msg and routes a failure to the error queue.
+func (p *Pipeline) handle(ctx context.Context, msg Message) error {
+	err := p.process(ctx, msg)
+	if err == nil || p.options.errorQueue == nil {
+		return err
+	}
+
+	if err := msg.SetExtension([]byte(err.Error())); err != nil {
+		return err
+	}
+
+	return msg.Send(p.options.errorQueue, sendOptionsFromContext(ctx)...)
+}
+
+// process passes msg through the stages and sends the result to the output
+// queues.
+func (p *Pipeline) process(ctx context.Context, msg Message) error {
+	v, err := p.options.decode(msg)
+	if err != nil {
+		return &StageError{Stage: "decode", Err: err}
+	}
+
+	for _, s := range p.options.stages {
+		v, err = s.Func(ctx, v)
+		if err != nil {
+			return &StageError{Stage: s.Name, Err: err}
+		}
+	}
+
+	body, err := p.options.encode(v)
+	if err != nil {
+		return &StageError{Stage: "encode", Err: err}
+	}
+
+	if err := p.send(ctx, msg, body); err != nil {
+		return &StageError{Stage: "send", Err: err}
+	}
+
+	return nil
+}
+
+// send sends body to the output queues with the label of msg.
+func (p *Pipeline) send(ctx context.Context, msg Message, body []byte) error {
+	label, err := msg.Label()
+	if err != nil {
+		return err
+	}
+
+	out, err := NewMessage()
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	if err := out.SetLabel(label); err != nil {
+		return err
+	}
+	if err := out.SetBodyBytes(body); err != nil {
+		return err
+	}
+
+	opts := sendOptionsFromContext(ctx)
+	for _, q := range p.options.outputs {
+		if err := out.Send(q, opts...); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// sendOptionsFromContext returns the options to send messages in the
+// transaction of ctx, if any.
+func sendOptionsFromContext(ctx context.Context) []SendOption {
+	if tx, ok := TransactionFromContext(ctx); ok {
+		return []SendOption{SendInTransaction(tx)}
+	}
+
+	return nil
+}
+
+// PipelineOption represents an option to configure a Pipeline.
+type PipelineOption struct {
+	set func(opts *pipelineOptions)
+}
+
+// pipelineOptions contains all the options to configure a Pipeline.
+type pipelineOptions struct {
+	decode          func(msg Message) (interface{}, error)
+	stages          []Stage
+	encode          func(v interface{}) ([]byte, error)
+	outputs         []*Queue
+	errorQueue      *Queue
+	consumerOptions []ConsumerOption
+}
+
+// PipelineWithDecoder returns a PipelineOption that configures how a
+// received message is turned into the value passed to the first stage. The
+// default passes the body of the message as a []byte.
+func PipelineWithDecoder(fn func(msg Message) (interface{}, error)) PipelineOption {
+	return PipelineOption{
+		set: func(opts *pipelineOptions) {
+			opts.decode = fn
+		},
+	}
+}
+
+// PipelineWithStage returns a PipelineOption that appends a stage named name
+// to the Pipeline. Stages run in the order they are appended.
+func PipelineWithStage(name string, fn func(ctx context.Context, v interface{}) (interface{}, error)) PipelineOption {
+	return PipelineOption{
+		set: func(opts *pipelineOptions) {
+			opts.stages = append(opts.stages, Stage{Name: name, Func: fn})
+		},
+	}
+}
+
+// PipelineWithEncoder returns a PipelineOption that configures how the value
+// returned by the last stage is turned into the body of the output message.
+// The default accepts a []byte or a string.
+func PipelineWithEncoder(fn func(v interface{}) ([]byte, error)) PipelineOption {
+	return PipelineOption{
+		set: func(opts *pipelineOptions) {
+			opts.encode = fn
+		},
+	}
+}
+
+// PipelineWithOutputs returns a PipelineOption that configures the queues the
+// output messages are sent to. The queues must be opened with Send
+// AccessMode.
+func PipelineWithOutputs(queues ...*Queue) PipelineOption {
+	return PipelineOption{
+		set: func(opts *pipelineOptions) {
+			opts.outputs = append(opts.outputs, queues...)
+		},
+	}
+}
+
+// PipelineWithErrorQueue returns a PipelineOption that configures the queue
+// the input messages that fail are sent to. The queue must be opened with
+// Send AccessMode.
+func PipelineWithErrorQueue(queue *Queue) PipelineOption {
+	return PipelineOption{
+		set: func(opts *pipelineOptions) {
+			opts.errorQueue = queue
+		},
+	}
+}
+
+// PipelineWithConsumerOptions returns a PipelineOption that configures the
+// Consumer of the input queue, for example its workers and transactions.
+func PipelineWithConsumerOptions(opts ...ConsumerOption) PipelineOption {
+	return PipelineOption{
+		set: func(o *pipelineOptions) {
+			o.consumerOptions = append(o.consumerOptions, opts...)
+		},
+	}
+}
@@ -0,0 +1,168 @@
+package msmq
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrPoisonMessage is passed to the error callback of a Consumer, wrapped
+// with the last error of the handler, when a message is quarantined by its
+// PoisonPolicy.
+var ErrPoisonMessage = errors.New("go-msmq: poison message quarantined")
+
+// PoisonPolicy sets aside messages that keep failing, so that a transactional
+// Consumer does not redeliver them forever. Each failed delivery of a message
+// is counted by its lookup identifier, and once a message failed
+// MaxDeliveries times, it is moved to the quarantine queue instead of being
+// returned to the queue:
+//   poison, err := queueInfo.Subqueue("poison")
+//   ...
+//   quarantine, err := poison.Open(msmq.Move, msmq.DenyNone)
+//   ...
+//   consumer := msmq.NewConsumer(queue,
+//       msmq.ConsumerWithTransactional(true),
+//       msmq.ConsumerWithPoisonPolicy(msmq.PoisonPolicy{
+//           MaxDeliveries: 5,
+//           Quarantine:    quarantine,
+//           Subqueue:      true,
+//       }),
+//   )
+type PoisonPolicy struct {
+	// MaxDeliveries is the number of failed deliveries after which a
+	// message is quarantined. The default is 5.
+	MaxDeliveries int
+
+	// Attempts counts the failed deliveries of the messages. The default is
+	// an in-memory AttemptStore, which is lost when the process exits.
+	Attempts AttemptStore
+
+	// Quarantine is the queue poison messages are moved to. Unless Subqueue
+	// is set, it must be a transactional queue opened with Send AccessMode,
+	// and the message is received and sent to it in the same transaction.
+	Quarantine *Queue
+
+	// Subqueue reports whether Quarantine is a subqueue of the queue of the
+	// Consumer opened with Move AccessMode, in which case the message is
+	// moved into it with Queue.MoveMessage.
+	Subqueue bool
+}
+
+// AttemptStore counts the failed deliveries of messages by lookup
+// identifier. Implementations must be safe for concurrent use.
+type AttemptStore interface {
+	// Add records a failed delivery of the message with the lookup
+	// identifier and returns the number of failed deliveries so far.
+	Add(lookupID LookupID) int
+
+	// Delete forgets the failed deliveries of the message with the lookup
+	// identifier.
+	Delete(lookupID LookupID)
+}
+
+// NewAttemptStore returns an in-memory AttemptStore. Entries are deleted
+// when their message succeeds or is quarantined, so messages that are
+// received by another process after failing here remain in the store.
+func NewAttemptStore() AttemptStore {
+	return &memoryAttemptStore{
+		attempts: make(map[LookupID]int),
+	}
+}
+
+// memoryAttemptStore is the AttemptStore returned by NewAttemptStore.
+type memoryAttemptStore struct {
+	mu       sync.Mutex
+	attempts map[LookupID]int
+}
+
+// Add implements AttemptStore.
+func (s *memoryAttemptStore) Add(lookupID LookupID) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.attempts[lookupID]++
+	return s.attempts[lookupID]
+}
+
+// Delete implements AttemptStore.
+func (s *memoryAttemptStore) Delete(lookupID LookupID) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.attempts, lookupID)
+}
+
+// ConsumerWithPoisonPolicy returns a ConsumerOption that configures how
+// messages that keep failing are quarantined. It only applies to
+// transactional consumers. The default is to redeliver failed messages
+// indefinitely.
+func ConsumerWithPoisonPolicy(policy PoisonPolicy) ConsumerOption {
+	if policy.MaxDeliveries <= 0 {
+		policy.MaxDeliveries = 5
+	}
+	if policy.Attempts == nil {
+		policy.Attempts = NewAttemptStore()
+	}
+
+	return ConsumerOption{
+		set: func(opts *consumerOptions) {
+			opts.poison = &policy
+		},
+	}
+}
+
+// reject handles msg, received in tx, whose handler failed with err. tx is
+// aborted to redeliver the message, unless the message is quarantined by the
+// poison policy.
+func (c *Consumer) reject(tx *Transaction, msg Message, err error) {
+	policy := c.options.poison
+	id, idErr := msg.LookupID()
+	if policy == nil || idErr != nil || policy.Attempts.Add(id) < policy.MaxDeliveries {
+		c.fail(msg, err)
+		if err := tx.Abort(); err != nil {
+			c.fail(msg, err)
+		}
+		return
+	}
+
+	if qerr := c.quarantine(tx, msg, id); qerr != nil {
+		c.fail(msg, fmt.Errorf("go-msmq: failed to quarantine poison message: %w", qerr))
+		return
+	}
+
+	policy.Attempts.Delete(id)
+	c.fail(msg, fmt.Errorf("%w: %w", ErrPoisonMessage, err))
+}
+
+// quarantine moves msg, received in tx, to the quarantine queue of the
+// poison policy and ends tx.
+func (c *Consumer) quarantine(tx *Transaction, msg Message, id LookupID) error {
+	policy := c.options.poison
+	if policy.Subqueue {
+		// The message must be back in the queue to be moved into the
+		// subqueue, which happens in its own transaction.
+		if err := tx.Abort(); err != nil {
+			return err
+		}
+
+		return c.queue.MoveMessage(id, policy.Quarantine, true)
+	}
+
+	if err := msg.Send(policy.Quarantine, SendInTransaction(tx)); err != nil {
+		tx.Abort()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// succeeded forgets the failed deliveries of msg once it was handled.
+func (c *Consumer) succeeded(msg Message) {
+	if c.options.poison == nil {
+		return
+	}
+
+	if id, err := msg.LookupID(); err == nil {
+		c.options.poison.Attempts.Delete(id)
+	}
+}
@@ -0,0 +1,168 @@
+package msmq
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+)
+
+// errMessageNotReusable is returned by resetMessage for a message with
+// properties that cannot be reset to their defaults.
+var errMessageNotReusable = errors.New("go-msmq: the message cannot be reused")
+
+// messagePool keeps MSMQMessage objects for reuse, so that senders of many
+// messages do not create an object for each of them. It is bounded, and the
+// messages that do not fit are released, since the objects are COM objects
+// that must be released explicitly rather than garbage collected.
+type messagePool struct {
+	messages chan Message
+}
+
+// newMessagePool returns a pointer to a messagePool keeping up to size
+// messages.
+func newMessagePool(size int) *messagePool {
+	return &messagePool{
+		messages: make(chan Message, size),
+	}
+}
+
+// get returns a message from the pool, or a new message if the pool is empty.
+func (p *messagePool) get() (Message, error) {
+	select {
+	case msg := <-p.messages:
+		return msg, nil
+	default:
+		return NewMessage()
+	}
+}
+
+// put resets msg and returns it to the pool. msg is released instead if it
+// cannot be reset or the pool is full.
+func (p *messagePool) put(msg Message) {
+	if resetMessage(&msg) != nil {
+		msg.release()
+		return
+	}
+
+	select {
+	case p.messages <- msg:
+	default:
+		msg.release()
+	}
+}
+
+// close releases the messages in the pool. The pool must not be used
+// afterwards.
+func (p *messagePool) close() {
+	for {
+		select {
+		case msg := <-p.messages:
+			msg.release()
+		default:
+			return
+		}
+	}
+}
+
+// resetMessage sets the properties of msg that senders set back to their
+// defaults. The body is left as is since every sender sets it. A message with
+// an administration or response queue is not reset, since those properties
+// cannot be cleared.
+func resetMessage(msg *Message) error {
+	for _, name := range []string{"AdminQueueInfo", "ResponseQueueInfo"} {
+		res, err := getProperty(msg.dispatch, name)
+		if err != nil {
+			return err
+		}
+		queue := res.ToIDispatch()
+		res.Clear()
+		if queue != nil {
+			return errMessageNotReusable
+		}
+	}
+
+	for _, reset := range []func() error{
+		func() error { return msg.SetLabel("") },
+		func() error { return msg.SetPriority(3) },
+		func() error { return msg.SetAppSpecific(0) },
+		func() error { return msg.SetCorrelationID(make([]byte, 20)) },
+		func() error { return msg.SetDelivery(Express) },
+		func() error { return msg.SetAck(AckNone) },
+		func() error { return msg.SetJournal(JournalNone) },
+		func() error { return msg.SetMaxTimeToReachQueue(LongLived) },
+		func() error { return msg.SetMaxTimeToReceive(-1) },
+		func() error { return msg.SetSOAPHeader("") },
+		func() error { return msg.SetSOAPBody("") },
+	} {
+		if err := reset(); err != nil {
+			return err
+		}
+	}
+
+	ext, err := msg.Extension()
+	if err != nil || len(ext) == 0 {
+		return err
+	}
+
+	return msg.SetExtension([]byte{})
+}
+
+// setBody sets the body of msg to body, which must be a string or a []byte.
+func setBody(msg *Message, body interface{}) error {
+	switch body := body.(type) {
+	case string:
+		return msg.SetBody(body)
+	case []byte:
+		return msg.SetBodyBytes(body)
+	default:
+		return fmt.Errorf("body must be a string or a []byte, not %T", body)
+	}
+}
+
+// batchPool keeps the messages of SendBatch, so that successive batches
+// reuse them too.
+var batchPool = newMessagePool(runtime.GOMAXPROCS(0))
+
+// SendBatch sends a message with each of bodies, which must be strings or
+// []bytes, to queue, with the options, and returns the number of messages
+// sent. It stops at the first failure. The messages are taken from a pool of
+// message objects, so that sending many messages does not create an object
+// for each of them. To send the batch atomically, pass SendInTransaction:
+//   tx, err := msmq.BeginTransaction()
+//   ...
+//   n, err := msmq.SendBatch(queue, bodies, msmq.SendInTransaction(tx))
+//   if err != nil {
+//       tx.Abort()
+//       ...
+//   }
+//   err = tx.Commit()
+func SendBatch(queue *Queue, bodies []interface{}, opts ...SendOption) (int, error) {
+	for i, body := range bodies {
+		if err := sendPooled(queue, body, opts); err != nil {
+			return i, fmt.Errorf("go-msmq: SendBatch() failed to send message %d: %w", i, err)
+		}
+	}
+
+	return len(bodies), nil
+}
+
+// sendPooled sends a message from batchPool with body to queue. The message
+// is returned to the pool only if it was sent.
+func sendPooled(queue *Queue, body interface{}, opts []SendOption) error {
+	msg, err := batchPool.get()
+	if err != nil {
+		return err
+	}
+
+	err = setBody(&msg, body)
+	if err == nil {
+		err = msg.Send(queue, opts...)
+	}
+	if err != nil {
+		msg.release()
+		return err
+	}
+
+	batchPool.put(msg)
+	return nil
+}
@@ -0,0 +1,44 @@
+package msmq
+
+// FastPeek returns the PeekOptions that peek only the properties of messages,
+// without their body, destination queue or connector type, followed by opts:
+//   msg, err := queue.Peek(msmq.FastPeek(msmq.PeekWithTimeout(0))...)
+func FastPeek(opts ...PeekOption) []PeekOption {
+	return append([]PeekOption{
+		PeekWithWantBody(false),
+		PeekWithWantDestinationQueue(false),
+		PeekWithWantConnectorType(false),
+	}, opts...)
+}
+
+// FullPeek returns the PeekOptions that peek messages with their body,
+// destination queue and connector type, followed by opts.
+func FullPeek(opts ...PeekOption) []PeekOption {
+	return append([]PeekOption{
+		PeekWithWantBody(true),
+		PeekWithWantDestinationQueue(true),
+		PeekWithWantConnectorType(true),
+	}, opts...)
+}
+
+// FastReceive returns the ReceiveOptions that receive only the properties of
+// messages, without their body, destination queue or connector type,
+// followed by opts. It suits discarding messages, for example.
+func FastReceive(opts ...ReceiveOption) []ReceiveOption {
+	return append([]ReceiveOption{
+		ReceiveWithWantBody(false),
+		ReceiveWithWantDestinationQueue(false),
+		ReceiveWithWantConnectorType(false),
+	}, opts...)
+}
+
+// FullReceive returns the ReceiveOptions that receive messages with their
+// body, destination queue and connector type, followed by opts:
+//   msg, err := queue.Receive(msmq.FullReceive(msmq.ReceiveWithTimeout(1000))...)
+func FullReceive(opts ...ReceiveOption) []ReceiveOption {
+	return append([]ReceiveOption{
+		ReceiveWithWantBody(true),
+		ReceiveWithWantDestinationQueue(true),
+		ReceiveWithWantConnectorType(true),
+	}, opts...)
+}
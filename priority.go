@@ -0,0 +1,183 @@
+package msmq
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// PriorityBands splits the workers of a Consumer between high-priority and
+// low-priority messages, so that a flood of low-priority messages cannot keep
+// every worker busy while urgent messages wait, and urgent traffic cannot
+// starve the rest:
+//   consumer := msmq.NewConsumer(queue,
+//       msmq.ConsumerWithWorkers(8),
+//       msmq.ConsumerWithPriorityBands(msmq.PriorityBands{
+//           Threshold:   5,
+//           HighWorkers: 2,
+//       }),
+//   )
+//
+// The workers of the high band only receive messages whose priority is at
+// least Threshold, and the workers of the low band only receive the other
+// messages. MSMQ orders a queue by priority, so the high band looks at the
+// first message of the queue, while each worker of the low band walks the
+// queue by lookup identifier from where it last stopped.
+//
+// Messages sent to transactional queues all have priority 0, so priority
+// bands are only useful with nontransactional queues. The queue must be
+// opened with Receive AccessMode and DenyNone ShareMode, since messages are
+// peeked before they are received.
+type PriorityBands struct {
+	// Threshold is the lowest priority of the high band, between 1 and 7.
+	// The default is 4, so that messages above the default priority of 3
+	// are high-priority.
+	Threshold int32
+
+	// HighWorkers is the number of workers of the high band; the other
+	// workers of the Consumer form the low band. Each band has at least one
+	// worker. The default is half of the workers.
+	HighWorkers int
+
+	// PollInterval is how often an idle worker looks for a message of its
+	// band, since MSMQ cannot wait for a message of a given priority. The
+	// default is 100 milliseconds.
+	PollInterval time.Duration
+}
+
+// ConsumerWithPriorityBands returns a ConsumerOption that configures the
+// Consumer to split its workers between priority bands. The Consumer has at
+// least two workers with this option. The default is for every worker to
+// receive the first message of the queue.
+func ConsumerWithPriorityBands(bands PriorityBands) ConsumerOption {
+	if bands.Threshold <= 0 {
+		bands.Threshold = 4
+	}
+	if bands.PollInterval <= 0 {
+		bands.PollInterval = 100 * time.Millisecond
+	}
+
+	return ConsumerOption{
+		set: func(opts *consumerOptions) {
+			opts.priority = &bands
+		},
+	}
+}
+
+// bandReceiver receives the messages of a priority band for a worker.
+type bandReceiver struct {
+	c    *Consumer
+	high bool
+
+	// cursor is the lookup identifier of the last message examined by a
+	// worker of the low band, or 0 to start from the front of the queue.
+	cursor LookupID
+}
+
+// bandReceiver returns the bandReceiver of the ith worker.
+func (c *Consumer) bandReceiver(i int) *bandReceiver {
+	bands := c.options.priority
+	high := bands.HighWorkers
+	if high <= 0 {
+		high = c.options.workers / 2
+	}
+	if high < 1 {
+		high = 1
+	}
+	if high > c.options.workers-1 {
+		high = c.options.workers - 1
+	}
+
+	return &bandReceiver{
+		c:    c,
+		high: i < high,
+	}
+}
+
+// receive is the receiveFunc of the worker. It looks for a message of its
+// band every poll interval until the receive timeout expires.
+func (r *bandReceiver) receive(ctx context.Context, tx *Transaction) (Message, error) {
+	deadline := time.Now().Add(r.c.options.receiveTimeout)
+	for {
+		id, ok, err := r.find()
+		if err != nil {
+			return Message{}, err
+		}
+
+		if ok {
+			opt := ReceiveByLookupIDWithTransaction(NoTransaction)
+			if tx != nil {
+				opt = ReceiveByLookupIDInTransaction(tx)
+			}
+
+			msg, err := r.c.queue.ReceiveByLookupID(id, opt)
+			if errors.Is(err, ErrMessageNotFound) || err == nil && msg.dispatch == nil {
+				// Another worker or process received the message first.
+				continue
+			}
+			return msg, err
+		}
+
+		if !time.Now().Before(deadline) || !sleep(ctx, r.c.options.priority.PollInterval) {
+			return Message{}, nil
+		}
+	}
+}
+
+// find returns the lookup identifier of the next message of the band, and
+// reports whether there is one.
+func (r *bandReceiver) find() (LookupID, bool, error) {
+	threshold := r.c.options.priority.Threshold
+
+	if r.high {
+		msg, err := r.c.queue.Peek(PeekWithWantBody(false), PeekWithTimeout(0))
+		if err != nil || msg.dispatch == nil {
+			return 0, false, err
+		}
+		defer msg.release()
+
+		priority, err := msg.Priority()
+		if err != nil || priority < threshold {
+			return 0, false, err
+		}
+
+		id, err := msg.LookupID()
+		return id, err == nil, err
+	}
+
+	for {
+		var (
+			msg Message
+			err error
+		)
+		if r.cursor == 0 {
+			msg, err = r.c.queue.PeekFirstByLookupID(PeekByLookupIDWithWantBody(false))
+		} else {
+			msg, err = r.c.queue.PeekNextByLookupID(r.cursor, PeekByLookupIDWithWantBody(false))
+		}
+		if errors.Is(err, ErrMessageNotFound) || err == nil && msg.dispatch == nil {
+			// The end of the queue: start again from the front next time.
+			r.cursor = 0
+			return 0, false, nil
+		}
+		if err != nil {
+			return 0, false, err
+		}
+
+		id, err := msg.LookupID()
+		if err != nil {
+			msg.release()
+			return 0, false, err
+		}
+		priority, err := msg.Priority()
+		msg.release()
+		if err != nil {
+			return 0, false, err
+		}
+
+		r.cursor = id
+		if priority < threshold {
+			return id, true, nil
+		}
+	}
+}
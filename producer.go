@@ -0,0 +1,440 @@
+package msmq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrProducerClosed is returned by Producer.Enqueue after the Producer is
+// closed.
+var ErrProducerClosed = errors.New("go-msmq: producer closed")
+
+// Producer sends messages to a queue asynchronously. Enqueue adds a message
+// to a bounded buffer and returns, and background workers send the buffered
+// messages, optionally in batches. When the buffer is full, Enqueue blocks
+// until a worker makes room, which applies backpressure to the callers:
+//   producer := msmq.NewProducer(queue,
+//       msmq.ProducerWithBatchSize(100),
+//       msmq.ProducerWithTransactional(true),
+//       msmq.ProducerWithOnError(func(body interface{}, err error) {
+//           log.Printf("failed to send %v: %v", body, err)
+//       }),
+//   )
+//   defer producer.Close()
+//   err := producer.Enqueue("hello", msmq.EnqueueWithLabel("greeting"))
+//
+// Send failures are reported to the callback set with ProducerWithOnError.
+// A Producer is safe for concurrent use by multiple goroutines.
+//
+// The workers reuse the message objects they send, resetting their
+// properties in between, so SendMiddleware must not keep a message after the
+// send returns.
+type Producer struct {
+	queue   *Queue
+	options *producerOptions
+	send    SendFunc
+	items   chan *envelope
+	workers sync.WaitGroup
+	pool    *messagePool
+
+	// mu guards closed, pending and the generations, and idle is signaled
+	// when messages are sent or fail. Each message belongs to the generation
+	// current when it is enqueued, and Flush starts a new one so that it only
+	// waits for the messages of the previous generations.
+	mu          sync.Mutex
+	idle        *sync.Cond
+	closed      bool
+	pending     int
+	generation  uint64
+	generations map[uint64]int
+}
+
+// envelope is a message waiting to be sent by a Producer.
+type envelope struct {
+	body       interface{}
+	opts       []EnqueueOption
+	generation uint64
+}
+
+// NewProducer returns a pointer to a Producer that sends to queue, configured
+// by the options. The queue must be opened with Send AccessMode and must not
+// be closed before the Producer. It can be a MessageQueue wrapped with
+// WrapMessageQueue, such as an in-memory queue of the msmqfake package.
+func NewProducer(queue *Queue, opts ...ProducerOption) *Producer {
+	options := &producerOptions{
+		workers:    1,
+		bufferSize: 1024,
+		batchSize:  1,
+	}
+	for _, o := range opts {
+		o.set(options)
+	}
+	if options.workers < 1 {
+		options.workers = 1
+	}
+	if options.batchSize < 1 {
+		options.batchSize = 1
+	}
+	if options.bufferSize < 0 {
+		options.bufferSize = 0
+	}
+
+	p := &Producer{
+		queue:   queue,
+		options: options,
+		send:    ChainSend(sendMessage, options.middleware...),
+		items:   make(chan *envelope, options.bufferSize),
+		pool:    newMessagePool(options.workers),
+	}
+	p.idle = sync.NewCond(&p.mu)
+	p.generations = make(map[uint64]int)
+
+	for i := 0; i < options.workers; i++ {
+		p.workers.Add(1)
+		go p.work()
+	}
+
+	return p
+}
+
+// Enqueue buffers a message with the specified body for sending and returns
+// without waiting for it to be sent. body must be a string or a []byte. If
+// the buffer is full, Enqueue blocks until there is room.
+func (p *Producer) Enqueue(body interface{}, opts ...EnqueueOption) error {
+	switch body.(type) {
+	case string, []byte:
+	default:
+		return fmt.Errorf("go-msmq: Enqueue(%T) failed to enqueue message: body must be a string or a []byte", body)
+	}
+
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return fmt.Errorf("go-msmq: Enqueue() failed to enqueue message: %w", ErrProducerClosed)
+	}
+	p.pending++
+	generation := p.generation
+	p.generations[generation]++
+	p.mu.Unlock()
+
+	// The channel is only closed by Close, which waits for pending messages
+	// before closing it, so the send cannot panic.
+	p.items <- &envelope{body: body, opts: opts, generation: generation}
+	return nil
+}
+
+// Flush blocks until every message enqueued before the call is sent or has
+// failed. Messages enqueued during the call are not waited for.
+func (p *Producer) Flush() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	target := p.generation
+	p.generation++
+	for p.hasPending(target) {
+		p.idle.Wait()
+	}
+}
+
+// hasPending reports whether messages of the generations up to target are
+// neither sent nor failed. p.mu must be held.
+func (p *Producer) hasPending(target uint64) bool {
+	for generation := range p.generations {
+		if generation <= target {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Close stops accepting messages, waits for the buffered messages to be sent
+// and stops the workers. Calling Close more than once has no effect.
+func (p *Producer) Close() error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil
+	}
+	p.closed = true
+	for p.pending > 0 {
+		p.idle.Wait()
+	}
+	p.mu.Unlock()
+
+	close(p.items)
+	p.workers.Wait()
+	p.pool.close()
+	return nil
+}
+
+// work sends the buffered messages until the buffer is closed.
+func (p *Producer) work() {
+	defer p.workers.Done()
+
+	batch := make([]*envelope, 0, p.options.batchSize)
+	for e := range p.items {
+		batch = append(batch[:0], e)
+
+		// Take the messages that are already buffered, without waiting for
+		// more, so that batches only form under load.
+	fill:
+		for len(batch) < p.options.batchSize {
+			select {
+			case e, ok := <-p.items:
+				if !ok {
+					break fill
+				}
+				batch = append(batch, e)
+			default:
+				break fill
+			}
+		}
+
+		p.sendBatch(batch)
+		p.done(batch)
+	}
+}
+
+// sendBatch sends batch, in a single internal transaction if the Producer is
+// transactional, and reports the failures.
+func (p *Producer) sendBatch(batch []*envelope) {
+	if !p.options.transactional {
+		for _, e := range batch {
+			if err := p.sendOne(e, p.options.sendOptions...); err != nil {
+				p.fail(e, err)
+			}
+		}
+		return
+	}
+
+	tx, err := BeginTransaction()
+	if err != nil {
+		p.failAll(batch, err)
+		return
+	}
+
+	opts := append(append([]SendOption(nil), p.options.sendOptions...), SendInTransaction(tx))
+	for _, e := range batch {
+		if err := p.sendOne(e, opts...); err != nil {
+			tx.Abort()
+			p.failAll(batch, err)
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		p.failAll(batch, err)
+	}
+}
+
+// sendOne sets up a message from the pool with e and sends it. The message is
+// returned to the pool only if it was sent, since a failure may leave it in
+// any state. The queue of a wrapped MessageQueue gets a new NativeMessage
+// instead.
+func (p *Producer) sendOne(e *envelope, opts ...SendOption) error {
+	msg, err := p.message()
+	if err != nil {
+		return err
+	}
+	sent := false
+	defer func() {
+		if sent && !p.queue.wrapped() {
+			p.pool.put(msg)
+		} else {
+			msg.release()
+		}
+	}()
+
+	if err := setBody(&msg, e.body); err != nil {
+		return err
+	}
+
+	for _, o := range e.opts {
+		if err := o.set(&msg); err != nil {
+			return err
+		}
+	}
+
+	err = p.send(context.Background(), &msg, p.queue, opts...)
+	sent = err == nil
+	return err
+}
+
+// message returns a message to set up and send to the queue.
+func (p *Producer) message() (Message, error) {
+	if p.queue.wrapped() {
+		return nativeMessage(&NativeMessage{}), nil
+	}
+
+	return p.pool.get()
+}
+
+// fail reports that e could not be sent.
+func (p *Producer) fail(e *envelope, err error) {
+	if p.options.onError != nil {
+		p.options.onError(e.body, err)
+	}
+}
+
+// failAll reports that the messages of batch could not be sent.
+func (p *Producer) failAll(batch []*envelope, err error) {
+	for _, e := range batch {
+		p.fail(e, err)
+	}
+}
+
+// done records that the messages of batch were sent or failed.
+func (p *Producer) done(batch []*envelope) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.pending -= len(batch)
+	for _, e := range batch {
+		p.generations[e.generation]--
+		if p.generations[e.generation] == 0 {
+			delete(p.generations, e.generation)
+		}
+	}
+	p.idle.Broadcast()
+}
+
+// EnqueueOption represents an option to configure a message enqueued with
+// Producer.Enqueue.
+type EnqueueOption struct {
+	set func(msg *Message) error
+}
+
+// EnqueueWithLabel returns an EnqueueOption that configures the label of the
+// message.
+func EnqueueWithLabel(label string) EnqueueOption {
+	return EnqueueOption{
+		set: func(msg *Message) error {
+			return msg.SetLabel(label)
+		},
+	}
+}
+
+// EnqueueWithPriority returns an EnqueueOption that configures the priority
+// of the message.
+func EnqueueWithPriority(priority int32) EnqueueOption {
+	return EnqueueOption{
+		set: func(msg *Message) error {
+			return msg.SetPriority(priority)
+		},
+	}
+}
+
+// EnqueueWithAppSpecific returns an EnqueueOption that configures the
+// application-specific value of the message.
+func EnqueueWithAppSpecific(i int32) EnqueueOption {
+	return EnqueueOption{
+		set: func(msg *Message) error {
+			return msg.SetAppSpecific(i)
+		},
+	}
+}
+
+// EnqueueWithCorrelationID returns an EnqueueOption that configures the
+// correlation identifier of the message.
+func EnqueueWithCorrelationID(id []byte) EnqueueOption {
+	return EnqueueOption{
+		set: func(msg *Message) error {
+			return msg.SetCorrelationID(id)
+		},
+	}
+}
+
+// EnqueueWithDelivery returns an EnqueueOption that configures how the
+// message is delivered.
+func EnqueueWithDelivery(mode DeliveryMode) EnqueueOption {
+	return EnqueueOption{
+		set: func(msg *Message) error {
+			return msg.SetDelivery(mode)
+		},
+	}
+}
+
+// ProducerOption represents an option to configure a Producer.
+type ProducerOption struct {
+	set func(opts *producerOptions)
+}
+
+// producerOptions contains all the options to configure a Producer.
+type producerOptions struct {
+	workers       int
+	bufferSize    int
+	batchSize     int
+	transactional bool
+	sendOptions   []SendOption
+	middleware    []SendMiddleware
+	onError       func(body interface{}, err error)
+}
+
+// ProducerWithWorkers returns a ProducerOption that configures the number of
+// workers sending messages concurrently. The default is 1, which preserves
+// the order of the messages.
+func ProducerWithWorkers(workers int) ProducerOption {
+	return ProducerOption{
+		set: func(opts *producerOptions) {
+			opts.workers = workers
+		},
+	}
+}
+
+// ProducerWithBufferSize returns a ProducerOption that configures the number
+// of messages that can be buffered before Enqueue blocks. A negative size is
+// treated as 0, which makes Enqueue wait for a worker. The default is 1024.
+func ProducerWithBufferSize(size int) ProducerOption {
+	return ProducerOption{
+		set: func(opts *producerOptions) {
+			opts.bufferSize = size
+		},
+	}
+}
+
+// ProducerWithBatchSize returns a ProducerOption that configures the maximum
+// number of buffered messages a worker sends at once. With
+// ProducerWithTransactional, a batch is sent in a single transaction, so it
+// is delivered or fails as a whole. The default is 1.
+func ProducerWithBatchSize(size int) ProducerOption {
+	return ProducerOption{
+		set: func(opts *producerOptions) {
+			opts.batchSize = size
+		},
+	}
+}
+
+// ProducerWithTransactional returns a ProducerOption that configures whether
+// each batch is sent in its own internal transaction. The queue must be
+// transactional. The default is false.
+func ProducerWithTransactional(transactional bool) ProducerOption {
+	return ProducerOption{
+		set: func(opts *producerOptions) {
+			opts.transactional = transactional
+		},
+	}
+}
+
+// ProducerWithSendOptions returns a ProducerOption that configures the
+// options used to send every message, such as SendWithRetry.
+func ProducerWithSendOptions(opts ...SendOption) ProducerOption {
+	return ProducerOption{
+		set: func(o *producerOptions) {
+			o.sendOptions = opts
+		},
+	}
+}
+
+// ProducerWithOnError returns a ProducerOption that configures the callback
+// invoked with the body of each message that could not be sent and the
+// error. The default is to drop the error.
+func ProducerWithOnError(fn func(body interface{}, err error)) ProducerOption {
+	return ProducerOption{
+		set: func(opts *producerOptions) {
+			opts.onError = fn
+		},
+	}
+}
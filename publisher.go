@@ -0,0 +1,185 @@
+package msmq
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Publisher sends each message to a set of queues, for simple
+// publish-subscribe where multicast is not available:
+//   pub := msmq.NewPublisher(msmq.PublisherWithQueues(billing, shipping, audit))
+//   err := pub.Publish(&msg)
+//
+// By default each queue is sent to independently, so a failure on one queue
+// does not prevent delivery to the others, and the failures are reported in a
+// *PublishError. With PublisherWithTransactional, the message is sent to
+// every queue in a single internal transaction, so it is delivered to all of
+// them or to none.
+//
+// A Destination made with DestinationWithQueues can also be used, in which
+// case MSMQ sends the message to every queue of the Destination in a single
+// operation.
+type Publisher struct {
+	queues  []*Queue
+	dest    *Destination
+	options *publisherOptions
+}
+
+// PublishError reports the queues a message could not be published to.
+type PublishError struct {
+	// Failures are the failed sends, in the order of the queues.
+	Failures []PublishFailure
+}
+
+// PublishFailure is a failed send of a Publisher.
+type PublishFailure struct {
+	// Queue is the index of the queue in the queues of the Publisher.
+	Queue int
+
+	// Err is the error of the send.
+	Err error
+}
+
+// Error implements the error interface.
+func (e *PublishError) Error() string {
+	msgs := make([]string, len(e.Failures))
+	for i, f := range e.Failures {
+		msgs[i] = fmt.Sprintf("queue %d: %v", f.Queue, f.Err)
+	}
+
+	return "go-msmq: failed to publish message to " + strings.Join(msgs, "; ")
+}
+
+// Unwrap returns the errors of the failed sends.
+func (e *PublishError) Unwrap() []error {
+	errs := make([]error, len(e.Failures))
+	for i, f := range e.Failures {
+		errs[i] = f.Err
+	}
+
+	return errs
+}
+
+// NewPublisher returns a pointer to a Publisher configured by the options.
+// The queues must be opened with Send AccessMode.
+func NewPublisher(opts ...PublisherOption) *Publisher {
+	options := &publisherOptions{
+		level: MTS,
+	}
+	for _, o := range opts {
+		o.set(options)
+	}
+
+	return &Publisher{
+		queues:  options.queues,
+		dest:    options.dest,
+		options: options,
+	}
+}
+
+// Publish sends msg to every queue of the Publisher.
+func (p *Publisher) Publish(msg *Message) error {
+	if p.dest != nil {
+		level := p.options.level
+		if p.options.transactional {
+			level = SingleMessage
+		}
+		if err := msg.SendTo(p.dest, SendWithTransaction(level)); err != nil {
+			return fmt.Errorf("go-msmq: Publish() failed to publish message: %w", err)
+		}
+		return nil
+	}
+
+	if p.options.transactional {
+		return p.publishTransactional(msg)
+	}
+
+	var failures []PublishFailure
+	for i, q := range p.queues {
+		if err := msg.Send(q, SendWithTransaction(p.options.level)); err != nil {
+			failures = append(failures, PublishFailure{Queue: i, Err: err})
+		}
+	}
+	if failures != nil {
+		return &PublishError{Failures: failures}
+	}
+
+	return nil
+}
+
+// publishTransactional sends msg to every queue in a single transaction.
+func (p *Publisher) publishTransactional(msg *Message) error {
+	tx, err := BeginTransaction()
+	if err != nil {
+		return fmt.Errorf("go-msmq: Publish() failed to publish message: %w", err)
+	}
+
+	for i, q := range p.queues {
+		if err := msg.Send(q, SendInTransaction(tx)); err != nil {
+			tx.Abort()
+			return &PublishError{Failures: []PublishFailure{{Queue: i, Err: err}}}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("go-msmq: Publish() failed to publish message: %w", err)
+	}
+
+	return nil
+}
+
+// PublisherOption represents an option to configure a Publisher.
+type PublisherOption struct {
+	set func(opts *publisherOptions)
+}
+
+// publisherOptions contains all the options to configure a Publisher.
+type publisherOptions struct {
+	queues        []*Queue
+	dest          *Destination
+	transactional bool
+	level         TransactionLevel
+}
+
+// PublisherWithQueues returns a PublisherOption that configures the queues
+// messages are published to.
+func PublisherWithQueues(queues ...*Queue) PublisherOption {
+	return PublisherOption{
+		set: func(opts *publisherOptions) {
+			opts.queues = append(opts.queues, queues...)
+		},
+	}
+}
+
+// PublisherWithDestination returns a PublisherOption that configures the
+// Destination messages are published to. It takes precedence over
+// PublisherWithQueues.
+func PublisherWithDestination(dest *Destination) PublisherOption {
+	return PublisherOption{
+		set: func(opts *publisherOptions) {
+			opts.dest = dest
+		},
+	}
+}
+
+// PublisherWithTransactional returns a PublisherOption that configures
+// whether messages are published to all the queues in a single internal
+// transaction. The queues must be transactional. The default is false.
+func PublisherWithTransactional(transactional bool) PublisherOption {
+	return PublisherOption{
+		set: func(opts *publisherOptions) {
+			opts.transactional = transactional
+		},
+	}
+}
+
+// PublisherWithTransaction returns a PublisherOption that configures the
+// TransactionLevel of the sends when PublisherWithTransactional is not set.
+// The default is MTS.
+func PublisherWithTransaction(level TransactionLevel) PublisherOption {
+	return PublisherOption{
+		set: func(opts *publisherOptions) {
+			opts.level = level
+		},
+	}
+}
@@ -0,0 +1,273 @@
+package msmq
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/go-ole/go-ole"
+)
+
+// Query locates public queues registered in the directory service.
+type Query struct {
+	dispatch *ole.IDispatch
+}
+
+// NewQuery returns a pointer to a Query.
+func NewQuery() (*Query, error) {
+	dispatch, err := createObject("MSMQ.MSMQQuery")
+	if err != nil {
+		return nil, err
+	}
+
+	return &Query{
+		dispatch: dispatch,
+	}, nil
+}
+
+// Close releases the underlying query object. The Query cannot be used after
+// it is closed. Calling Close more than once has no effect.
+func (q *Query) Close() error {
+	if q.dispatch != nil {
+		release(q.dispatch)
+		q.dispatch = nil
+	}
+
+	return nil
+}
+
+// Query satisfies io.Closer so that it can be managed alongside other
+// resources.
+var _ io.Closer = (*Query)(nil)
+
+// LookupQueue returns the public queues that match all of the criteria
+// specified by opts. If no criteria are specified, all public queues are
+// returned:
+//   query, err := msmq.NewQuery()
+//   ...
+//   defer query.Close()
+//   queues, err := query.LookupQueue(
+//       msmq.ByLabel("orders"),
+//       msmq.ByCreateTime(msmq.RelGreater, time.Now().Add(-24*time.Hour)),
+//   )
+//   ...
+//   for queueInfo := range queues.All() {
+//       ...
+//   }
+//
+// Private queues are not registered in the directory service and cannot be
+// located with LookupQueue.
+//
+// See: https://docs.microsoft.com/en-us/previous-versions/windows/desktop/legacy/ms706035(v=vs.85)
+func (q *Query) LookupQueue(opts ...QueryOption) (*QueueInfos, error) {
+	options := &queryOptions{}
+	for _, o := range opts {
+		o.set(options)
+	}
+
+	res, err := callMethodWithOptionalArgs(q.dispatch, "LookupQueue",
+		optional(options.queueGUID),
+		optional(options.serviceTypeGUID),
+		options.label,
+		options.createTime,
+		options.modifyTime,
+		options.relServiceType,
+		options.relLabel,
+		options.relCreateTime,
+		options.relModifyTime,
+		options.multicastAddress,
+		options.relMulticastAddress,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("go-msmq: LookupQueue() failed to look up queues: %w", err)
+	}
+
+	return &QueueInfos{
+		dispatch: track(res.ToIDispatch(), "MSMQ.MSMQQueueInfos"),
+	}, nil
+}
+
+// QueryOption represents a criterion to locate public queues.
+type QueryOption struct {
+	set func(opts *queryOptions)
+}
+
+// queryOptions contains all the criteria to locate public queues. The criteria
+// that are not specified are nil so that they are omitted from the lookup.
+type queryOptions struct {
+	queueGUID           string
+	serviceTypeGUID     string
+	label               interface{}
+	createTime          interface{}
+	modifyTime          interface{}
+	relServiceType      interface{}
+	relLabel            interface{}
+	relCreateTime       interface{}
+	relModifyTime       interface{}
+	multicastAddress    interface{}
+	relMulticastAddress interface{}
+}
+
+// Relation defines how a queue property is compared with the value specified
+// in a QueryOption.
+type Relation int32
+
+const (
+	// RelEqual matches queues whose property equals the value.
+	RelEqual Relation = 1
+
+	// RelNotEqual matches queues whose property does not equal the value.
+	RelNotEqual Relation = 2
+
+	// RelLess matches queues whose property is less than the value.
+	RelLess Relation = 3
+
+	// RelGreater matches queues whose property is greater than the value.
+	RelGreater Relation = 4
+
+	// RelLessOrEqual matches queues whose property is less than or equal to
+	// the value.
+	RelLessOrEqual Relation = 5
+
+	// RelGreaterOrEqual matches queues whose property is greater than or equal
+	// to the value.
+	RelGreaterOrEqual Relation = 6
+)
+
+// ByQueueGUID returns a QueryOption that matches the queue with the specified
+// QueueGUID.
+func ByQueueGUID(guid string) QueryOption {
+	return QueryOption{
+		set: func(opts *queryOptions) {
+			opts.queueGUID = guid
+		},
+	}
+}
+
+// ByServiceTypeGUID returns a QueryOption that matches queues with the
+// specified ServiceTypeGUID.
+func ByServiceTypeGUID(guid string) QueryOption {
+	return QueryOption{
+		set: func(opts *queryOptions) {
+			opts.serviceTypeGUID = guid
+			opts.relServiceType = int32(RelEqual)
+		},
+	}
+}
+
+// ByLabel returns a QueryOption that matches queues with the specified Label.
+func ByLabel(label string) QueryOption {
+	return QueryOption{
+		set: func(opts *queryOptions) {
+			opts.label = label
+			opts.relLabel = int32(RelEqual)
+		},
+	}
+}
+
+// ByCreateTime returns a QueryOption that matches queues whose CreateTime has
+// the specified relation to t.
+func ByCreateTime(rel Relation, t time.Time) QueryOption {
+	return QueryOption{
+		set: func(opts *queryOptions) {
+			opts.createTime = t
+			opts.relCreateTime = int32(rel)
+		},
+	}
+}
+
+// ByModifyTime returns a QueryOption that matches queues whose ModifyTime has
+// the specified relation to t.
+func ByModifyTime(rel Relation, t time.Time) QueryOption {
+	return QueryOption{
+		set: func(opts *queryOptions) {
+			opts.modifyTime = t
+			opts.relModifyTime = int32(rel)
+		},
+	}
+}
+
+// ByMulticastAddress returns a QueryOption that matches queues associated with
+// the specified MulticastAddress.
+func ByMulticastAddress(address string) QueryOption {
+	return QueryOption{
+		set: func(opts *queryOptions) {
+			opts.multicastAddress = address
+			opts.relMulticastAddress = int32(RelEqual)
+		},
+	}
+}
+
+// ErrNoQueueFound is returned by OpenByLabel when no public queue has the
+// specified label.
+var ErrNoQueueFound = errors.New("go-msmq: no queue matches the label")
+
+// ErrAmbiguousLabel is returned by OpenByLabel when more than one public queue
+// has the specified label.
+var ErrAmbiguousLabel = errors.New("go-msmq: more than one queue matches the label")
+
+// OpenByLabel looks up the public queue with the specified label in the
+// directory service and opens it with the specified accessMode and shareMode.
+// ErrNoQueueFound is returned if no queue has the label and ErrAmbiguousLabel
+// is returned if more than one queue has it, since labels are not required to
+// be unique:
+//   queue, err := msmq.OpenByLabel("orders", msmq.Receive, msmq.DenyNone)
+//   if errors.Is(err, msmq.ErrAmbiguousLabel) {
+//       ...
+//   }
+// The QueueInfo of the queue is closed when the returned Queue is closed.
+func OpenByLabel(label string, accessMode AccessMode, shareMode ShareMode) (*Queue, error) {
+	qi, err := lookupByLabel(label)
+	if err != nil {
+		return nil, fmt.Errorf("go-msmq: OpenByLabel(%s, %v, %v) failed to open queue: %w", label, accessMode, shareMode, err)
+	}
+
+	q, err := qi.Open(accessMode, shareMode)
+	if err != nil {
+		qi.Close()
+		return nil, fmt.Errorf("go-msmq: OpenByLabel(%s, %v, %v) failed to open queue: %w", label, accessMode, shareMode, err)
+	}
+
+	q.onClose = qi.Close
+	return q, nil
+}
+
+// lookupByLabel returns the QueueInfo of the only public queue with the
+// specified label.
+func lookupByLabel(label string) (*QueueInfo, error) {
+	query, err := NewQuery()
+	if err != nil {
+		return nil, err
+	}
+	defer query.Close()
+
+	queues, err := query.LookupQueue(ByLabel(label))
+	if err != nil {
+		return nil, err
+	}
+	defer queues.Close()
+
+	qi, err := queues.Next()
+	if err != nil {
+		return nil, err
+	}
+
+	if qi == nil {
+		return nil, ErrNoQueueFound
+	}
+
+	other, err := queues.Next()
+	if err != nil {
+		qi.Close()
+		return nil, err
+	}
+
+	if other != nil {
+		other.Close()
+		qi.Close()
+		return nil, ErrAmbiguousLabel
+	}
+
+	return qi, nil
+}
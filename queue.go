@@ -1,10 +1,14 @@
-// +build windows
-
 package msmq
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/go-ole/go-ole"
 )
@@ -13,18 +17,445 @@ import (
 // QueueInfo. It provides the methods needed read and delete the
 // messages in the queue and the properties needed to manage the open
 // queue.
+//
+// A Queue is safe for concurrent use by multiple goroutines. Operations run
+// concurrently, so several goroutines can block in Receive on the same
+// Queue, and Close cancels the operations that are pending. The cursor of the
+// queue is shared, so goroutines navigating it with PeekCurrent, PeekNext,
+// ReceiveCurrent and Reset affect each other.
+//
+// The Messages and QueueInfos returned by a Queue are not safe for concurrent
+// use.
 type Queue struct {
-	qi       *QueueInfo
+	// mu guards dispatch: operations hold a read lock while they use it, and
+	// Close holds the write lock while it releases it.
+	mu       sync.RWMutex
 	dispatch *ole.IDispatch
+
+	// mq is the MessageQueue of a Queue returned by WrapMessageQueue, whose
+	// dispatch is always nil.
+	mq MessageQueue
+
+	// qi is the QueueInfo the queue was opened from. It is set when the
+	// queue is opened and never changes, so it is read without locking.
+	qi *QueueInfo
+
+	// open tracks whether the queue is open so that the IsOpen2 property does
+	// not have to be queried before every operation. It is set when the queue
+	// is opened or closed, and cleared when an operation reports that the
+	// handle of the queue is no longer valid.
+	open atomic.Bool
+
+	// accessMode and shareMode are the modes the queue was opened with, with
+	// which Reopen opens it again.
+	accessMode AccessMode
+	shareMode  ShareMode
+
+	// autoReopen is whether operations reopen the queue when its handle is
+	// no longer valid; see SetAutoReopen.
+	autoReopen atomic.Bool
+
+	// onClose is called after the queue is closed, for example to delete a
+	// temporary queue.
+	onClose func() error
+
+	// peekOptions and receiveOptions are the default options of the queue,
+	// set by Open, which are applied before the options of each call.
+	peekOptions    []PeekOption
+	receiveOptions []ReceiveOption
+
+	// logger overrides the Logger of the package if set.
+	logger atomic.Pointer[Logger]
+
+	// name is the format name of the queue used in log records and
+	// observations. It is only retrieved when needed; see formatName.
+	nameOnce sync.Once
+	name     string
+}
+
+// SetLogger installs l as the Logger of the queue, overriding the Logger of
+// the package. A nil Logger reverts to the Logger of the package.
+func (q *Queue) SetLogger(l Logger) {
+	if l == nil {
+		q.logger.Store(nil)
+		return
+	}
+
+	q.logger.Store(&l)
+}
+
+// log returns the Logger of the queue, or nil if logging is disabled.
+func (q *Queue) log() Logger {
+	if l := q.logger.Load(); l != nil {
+		return *l
+	}
+
+	return currentLogger()
+}
+
+// record logs the outcome of the operation op on the queue and reports it to
+// the Observer of the package. message reports whether a message was sent,
+// received or peeked.
+func (q *Queue) record(op string, start time.Time, err error, message bool, args ...any) {
+	l, o := q.log(), currentObserver()
+	if l == nil && o == nil {
+		return
+	}
+
+	name := q.formatName()
+	if l != nil {
+		logOperation(l, op, start, err, append([]any{slog.String("queue", name)}, args...)...)
+	}
+	if o != nil {
+		o.Observe(Operation{
+			Name:     op,
+			Queue:    name,
+			Duration: time.Since(start),
+			Message:  message,
+			Err:      err,
+		})
+	}
+}
+
+// formatName returns the format name of the queue used in log records and
+// observations, which is retrieved once.
+func (q *Queue) formatName() string {
+	q.nameOnce.Do(func() {
+		if q.qi != nil {
+			q.name, _ = q.qi.FormatName()
+		}
+	})
+
+	return q.name
+}
+
+// recordMessage records the outcome of the receive or peek operation op,
+// which returned res.
+func (q *Queue) recordMessage(op string, start time.Time, res *ole.VARIANT, err error) {
+	var msg *ole.IDispatch
+	if res != nil {
+		msg = res.ToIDispatch()
+	}
+
+	var args []any
+	if msg != nil && q.log() != nil {
+		id, _ := getProperty(msg, "LookupId")
+		lookupID, _ := variantString(id, "LookupId")
+		id.Clear()
+		args = append(args, slog.String("lookup_id", lookupID))
+	}
+
+	q.record(op, start, err, msg != nil, args...)
+}
+
+// errQueueNotOpen is returned when an operation is attempted on a queue that
+// is not open.
+var errQueueNotOpen = errors.New("Exception occurred. (The queue is not open or might not exist. )")
+
+// notOpen returns the error of an operation on the queue when it is not
+// open: ErrNotInitialized for a zero-value Queue, which was never opened, or
+// errQueueNotOpen.
+func (q *Queue) notOpen() error {
+	if q.mq != nil {
+		return ErrUnsupportedOperation
+	}
+
+	// qi is set when the queue is opened and never cleared.
+	if q.qi == nil {
+		return fmt.Errorf("%w: Queue must be opened with Open or QueueInfo.Open", ErrNotInitialized)
+	}
+
+	return errQueueNotOpen
 }
 
-// Close closes this queue.
+// HRESULTs that indicate that the handle of an open queue is no longer valid.
+const (
+	mqErrorInvalidHandle = 0xC00E0007
+	mqErrorStaleHandle   = 0xC00E0056
+	mqErrorQueueDeleted  = 0xC00E005A
+)
+
+// Drain passes each message remaining in the queue to handler until the queue
+// is empty or ctx is done. It is intended for shutdown hooks that must not
+// strand in-flight work. The queue must be opened with Receive AccessMode.
+//
+// A message is only removed from the queue after handler returns nil. If
+// handler returns an error, Drain stops and returns the error, leaving the
+// message in the queue. Because the message is peeked before it is handled
+// and removed afterwards, a message may be handled more than once when other
+// processes receive from the same queue.
+func (q *Queue) Drain(ctx context.Context, handler func(Message) error) error {
+	for {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("go-msmq: Drain() failed to drain queue: %w", err)
+		}
+
+		msg, ok, err := q.TryPeek()
+		if err != nil {
+			return fmt.Errorf("go-msmq: Drain() failed to drain queue: %w", err)
+		}
+
+		if !ok {
+			return nil
+		}
+
+		err = q.drainMessage(msg, handler)
+		if err != nil {
+			return fmt.Errorf("go-msmq: Drain() failed to drain queue: %w", err)
+		}
+	}
+}
+
+// drainMessage passes msg to handler and removes it from the queue if handler
+// succeeds.
+func (q *Queue) drainMessage(msg Message, handler func(Message) error) error {
+	defer msg.release()
+
+	id, err := msg.LookupID()
+	if err != nil {
+		return err
+	}
+
+	err = handler(msg)
+	if err != nil {
+		return err
+	}
+
+	received, err := q.ReceiveByLookupID(id, ReceiveByLookupIDWithWantBody(false))
+	if err != nil {
+		return err
+	}
+	received.release()
+
+	return nil
+}
+
+// MessageSummary describes a message in a queue without its body.
+type MessageSummary struct {
+	// LookupID is the lookup identifier of the message.
+	LookupID LookupID
+
+	// Label is the label of the message.
+	Label string
+
+	// Size is the size (in bytes) of the body of the message.
+	Size int32
+
+	// ArrivedTime is when the message arrived in the queue.
+	ArrivedTime time.Time
+}
+
+// Browse returns up to pageSize summaries of the messages that follow the
+// message referenced by startLookupID, without removing the messages from the
+// queue. If startLookupID is FirstLookupID, browsing starts at the first
+// message in the queue. The bodies of the messages are not retrieved, which
+// allows very large queues to be paged through efficiently:
+//   page, err := queue.Browse(msmq.FirstLookupID, 100)
+//   ...
+//   page, err = queue.Browse(page[len(page)-1].LookupID, 100)
+// A page with fewer than pageSize summaries indicates that the end of the
+// queue was reached. pageSize must be positive.
+func (q *Queue) Browse(startLookupID LookupID, pageSize int) ([]MessageSummary, error) {
+	if pageSize <= 0 {
+		return nil, fmt.Errorf("go-msmq: Browse(%v, %d) failed to browse messages: %w", startLookupID, pageSize, invalidOption("pageSize", pageSize, "must be positive"))
+	}
+
+	opts := []PeekByLookupIDOption{
+		PeekByLookupIDWithWantBody(false),
+	}
+
+	page := make([]MessageSummary, 0, pageSize)
+	id := startLookupID
+	for len(page) < pageSize {
+		msg, err := q.PeekNextByLookupID(id, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("go-msmq: Browse(%v, %d) failed to browse messages: %w", startLookupID, pageSize, err)
+		}
+
+		if msg.dispatch == nil {
+			break
+		}
+
+		summary, err := summarize(&msg)
+		msg.release()
+		if err != nil {
+			return nil, fmt.Errorf("go-msmq: Browse(%v, %d) failed to browse messages: %w", startLookupID, pageSize, err)
+		}
+
+		page = append(page, summary)
+		id = summary.LookupID
+	}
+
+	return page, nil
+}
+
+// summarize returns the MessageSummary of msg.
+func summarize(msg *Message) (MessageSummary, error) {
+	id, err := msg.LookupID()
+	if err != nil {
+		return MessageSummary{}, err
+	}
+
+	label, err := msg.Label()
+	if err != nil {
+		return MessageSummary{}, err
+	}
+
+	size, err := msg.BodyLength()
+	if err != nil {
+		return MessageSummary{}, err
+	}
+
+	arrived, err := msg.ArrivedTime()
+	if err != nil {
+		return MessageSummary{}, err
+	}
+
+	return MessageSummary{
+		LookupID:    id,
+		Label:       label,
+		Size:        size,
+		ArrivedTime: arrived,
+	}, nil
+}
+
+// Close closes this queue and releases the underlying queue object. The queue
+// cannot be used after it is closed, even if closing it failed, as it does
+// for a queue whose handle is no longer valid. Calling Close more than once
+// has no effect.
 //
 // See: https://docs.microsoft.com/en-us/previous-versions/windows/desktop/legacy/ms705220(v=vs.85)
 func (q *Queue) Close() error {
-	_, err := q.dispatch.CallMethod("Close")
+	if q.mq != nil {
+		return q.closeNative()
+	}
+
+	// Closing the queue cancels the operations that are pending, so it is
+	// done with a read lock; waiting for the write lock first would wait for
+	// pending receives to time out.
+	q.mu.RLock()
+	if q.dispatch == nil {
+		q.mu.RUnlock()
+		return nil
+	}
+	_, closeErr := callMethod(q.dispatch, "Close")
+	q.mu.RUnlock()
+
+	// The queue object is released and onClose is run even if Close failed,
+	// so that a queue with a stale handle does not leak them.
+	q.mu.Lock()
+	if q.dispatch == nil {
+		// Another goroutine closed the queue concurrently.
+		q.mu.Unlock()
+		return nil
+	}
+	q.open.Store(false)
+	release(q.dispatch)
+	q.dispatch = nil
+	onClose := q.onClose
+	q.onClose = nil
+	q.mu.Unlock()
+
+	var onCloseErr error
+	if onClose != nil {
+		onCloseErr = onClose()
+	}
+
+	if err := errors.Join(closeErr, onCloseErr); err != nil {
+		return fmt.Errorf("go-msmq: Close() failed to close queue: %w", err)
+	}
+
+	return nil
+}
+
+// Queue satisfies io.Closer so that it can be managed alongside other
+// resources.
+var _ io.Closer = (*Queue)(nil)
+
+// call calls the method name on the queue. If the call reports that the handle
+// of the queue is no longer valid, the queue is marked as not open, or
+// reopened if SetAutoReopen is enabled; see do.
+func (q *Queue) call(name string, params ...interface{}) (*ole.VARIANT, error) {
+	return q.do(!cursorMethods[name], func(dispatch *ole.IDispatch) (*ole.VARIANT, error) {
+		return callMethod(dispatch, name, params...)
+	})
+}
+
+// get gets the property name of the queue.
+func (q *Queue) get(name string) (*ole.VARIANT, error) {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	if q.dispatch == nil {
+		return nil, q.notOpen()
+	}
+
+	return getProperty(q.dispatch, name)
+}
+
+// MoveMessage moves the message referenced by lookupID from this queue to
+// dest. It is used to move messages between a queue and its subqueues, for
+// example to set aside poison messages.
+//
+// This queue must be opened with Receive AccessMode and dest must be a
+// subqueue of the same queue opened with Move AccessMode. If transactional is
+// true, the message is moved in a single-message transaction, which is
+// required when the queue is transactional.
+//
+// See: https://docs.microsoft.com/en-us/windows/win32/api/mq/nf-mq-mqmovemessage
+func (q *Queue) MoveMessage(lookupID LookupID, dest *Queue, transactional bool) error {
+	src, err := q.Handle()
+	if err != nil {
+		return fmt.Errorf("go-msmq: MoveMessage(%v) failed to move message: %w", lookupID, err)
+	}
+
+	dst, err := dest.Handle()
+	if err != nil {
+		return fmt.Errorf("go-msmq: MoveMessage(%v) failed to move message: %w", lookupID, err)
+	}
+
+	level := NoTransaction
+	if transactional {
+		level = SingleMessage
+	}
+
+	err = mqMoveMessage(uintptr(src), uintptr(dst), uint64(lookupID), level)
 	if err != nil {
-		return fmt.Errorf("msmq: Close() failed to close queue: %w", err)
+		return fmt.Errorf("go-msmq: MoveMessage(%v) failed to move message: %w", lookupID, err)
+	}
+
+	return nil
+}
+
+// MoveTransactional receives the message referenced by lookupID from src and
+// sends it to dst within a single internal transaction, so that the message
+// is never in both queues or in neither. Unlike Queue.MoveMessage, dst can be
+// any local transactional queue.
+//
+// src must be opened with Receive AccessMode and dst with Send AccessMode.
+// Both queues must be transactional.
+func MoveTransactional(src, dst *Queue, lookupID LookupID) error {
+	tx, err := BeginTransaction()
+	if err != nil {
+		return fmt.Errorf("go-msmq: MoveTransactional(%v) failed to move message: %w", lookupID, err)
+	}
+
+	msg, err := src.ReceiveByLookupID(lookupID, ReceiveByLookupIDInTransaction(tx))
+	if err != nil {
+		tx.Abort()
+		return fmt.Errorf("go-msmq: MoveTransactional(%v) failed to move message: %w", lookupID, err)
+	}
+	defer msg.release()
+
+	err = msg.Send(dst, SendInTransaction(tx))
+	if err != nil {
+		tx.Abort()
+		return fmt.Errorf("go-msmq: MoveTransactional(%v) failed to move message: %w", lookupID, err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("go-msmq: MoveTransactional(%v) failed to move message: %w", lookupID, err)
 	}
 
 	return nil
@@ -35,16 +466,47 @@ func (q *Queue) Close() error {
 //
 // See: https://docs.microsoft.com/en-us/previous-versions/windows/desktop/legacy/ms704311(v=vs.85)
 func (q *Queue) Peek(opts ...PeekOption) (Message, error) {
+	if q.mq != nil {
+		options := q.newPeekOptions(opts)
+		return q.receiveNative(true, NoTransaction, nil, options.timeout)
+	}
+
 	msg, err := q.peek("Peek", opts)
 	if err != nil {
 		return Message{}, err
 	}
 
 	return Message{
-		dispatch: msg.ToIDispatch(),
+		dispatch: track(msg.ToIDispatch(), "MSMQ.MSMQMessage"),
 	}, nil
 }
 
+// PeekBody returns the body of the first message in the queue, or waits for a
+// message to arrive if the queue is empty. It does not remove the message from
+// the queue.
+//
+// An empty string is returned if the timeout expires before a message arrives.
+func (q *Queue) PeekBody(opts ...PeekOption) (string, error) {
+	msg, err := q.Peek(opts...)
+	if err != nil {
+		return "", err
+	}
+	defer msg.release()
+
+	return msg.Body()
+}
+
+// PeekBodyBytes is like PeekBody but returns the body as a byte slice.
+func (q *Queue) PeekBodyBytes(opts ...PeekOption) ([]byte, error) {
+	msg, err := q.Peek(opts...)
+	if err != nil {
+		return nil, err
+	}
+	defer msg.release()
+
+	return msg.BodyBytes()
+}
+
 // PeekOption represents an option to peek messages in a queue.
 type PeekOption struct {
 	set func(opts *peekOptions)
@@ -61,9 +523,10 @@ type peekOptions struct {
 // PeekWithWantDestinationQueue returns a PeekOption that configures peeking
 // message with the specified want value.
 //
-// The default is false. If set to true, the Message.DestinationQueueInfo
-// property is updated when the message is read from the queue. Setting this
-// option to true may slow down the operation.
+// The default is false, or WantDestinationQueue set with SetDefaults. If set to
+// true, the Message.DestinationQueueInfo property is updated when the message
+// is read from the queue. Setting this option to true may slow down the
+// operation.
 func PeekWithWantDestinationQueue(want bool) PeekOption {
 	return PeekOption{
 		set: func(opts *peekOptions) {
@@ -75,9 +538,9 @@ func PeekWithWantDestinationQueue(want bool) PeekOption {
 // PeekWithWantBody returns a PeekOption that configures peeking messages with
 // the specified want value.
 //
-// The default is true. It specifies that the body of the message should be
-// retrieved. If the message body is not needed, set this option to false to
-// optimize the speed of the application.
+// The default is true, or WantBody set with SetDefaults. It specifies that the
+// body of the message should be retrieved. If the message body is not needed,
+// set this option to false to optimize the speed of the application.
 func PeekWithWantBody(want bool) PeekOption {
 	return PeekOption{
 		set: func(opts *peekOptions) {
@@ -89,8 +552,9 @@ func PeekWithWantBody(want bool) PeekOption {
 // PeekWithTimeout returns a PeekOption that configures peeking messages with
 // the specified timeout value.
 //
-// The default is infinite (max value of int). It specifies the time in
-// milliseconds that MSMQ will wait for a message to arrive.
+// The default is infinite (max value of int), or the ReceiveTimeout set with
+// SetDefaults. It specifies the time in milliseconds that MSMQ will wait for a
+// message to arrive.
 func PeekWithTimeout(timeout int) PeekOption {
 	return PeekOption{
 		set: func(opts *peekOptions) {
@@ -102,9 +566,9 @@ func PeekWithTimeout(timeout int) PeekOption {
 // PeekWithWantConnectorType returns a PeekOption that configures peeking
 // messages with the specified want value.
 //
-// The default is false. It specifies that MSMQ does not retrieve the
-// Message.ConnectorTypeGuid property when it peeks at a message in the
-// queue
+// The default is false, or WantConnectorType set with SetDefaults. It specifies
+// that MSMQ does not retrieve the Message.ConnectorTypeGuid property when it
+// peeks at a message in the queue
 func PeekWithWantConnectorType(want bool) PeekOption {
 	return PeekOption{
 		set: func(opts *peekOptions) {
@@ -114,17 +578,18 @@ func PeekWithWantConnectorType(want bool) PeekOption {
 }
 
 // PeekByLookupID returns the message referenced by id but does not remove the
-// message from the queue.
+// message from the queue. FirstLookupID and LastLookupID reference the first
+// and last messages.
 //
 // See: https://docs.microsoft.com/en-us/previous-versions/windows/desktop/legacy/ms699797(v=vs.85)
-func (q *Queue) PeekByLookupID(id uint64, opts ...PeekByLookupIDOption) (Message, error) {
+func (q *Queue) PeekByLookupID(id LookupID, opts ...PeekByLookupIDOption) (Message, error) {
 	msg, err := q.peek("PeekByLookupID", id, opts)
 	if err != nil {
-		return Message{}, fmt.Errorf("go-msmq: PeekByLookupID(%d) failed to peek message by lookup id: %w", id, err)
+		return Message{}, fmt.Errorf("go-msmq: PeekByLookupID(%v) failed to peek message by lookup id: %w", id, err)
 	}
 
 	return Message{
-		dispatch: msg.ToIDispatch(),
+		dispatch: track(msg.ToIDispatch(), "MSMQ.MSMQMessage"),
 	}, nil
 }
 
@@ -143,9 +608,10 @@ type peekByLookupIDOptions struct {
 // PeekByLookupIDWithWantDestinationQueue returns a PeekOption that configures peeking
 // message with the specified want value.
 //
-// The default is false. If set to true, the Message.DestinationQueueInfo
-// property is updated when the message is read from the queue. Setting this
-// option to true may slow down the operation.
+// The default is false, or WantDestinationQueue set with SetDefaults. If set to
+// true, the Message.DestinationQueueInfo property is updated when the message
+// is read from the queue. Setting this option to true may slow down the
+// operation.
 func PeekByLookupIDWithWantDestinationQueue(want bool) PeekByLookupIDOption {
 	return PeekByLookupIDOption{
 		set: func(opts *peekByLookupIDOptions) {
@@ -157,9 +623,9 @@ func PeekByLookupIDWithWantDestinationQueue(want bool) PeekByLookupIDOption {
 // PeekByLookupIDWithWantBody returns a PeekOption that configures peeking messages with
 // the specified want value.
 //
-// The default is true. It specifies that the body of the message should be
-// retrieved. If the message body is not needed, set this option to false to
-// optimize the speed of the application.
+// The default is true, or WantBody set with SetDefaults. It specifies that the
+// body of the message should be retrieved. If the message body is not needed,
+// set this option to false to optimize the speed of the application.
 func PeekByLookupIDWithWantBody(want bool) PeekByLookupIDOption {
 	return PeekByLookupIDOption{
 		set: func(opts *peekByLookupIDOptions) {
@@ -171,9 +637,9 @@ func PeekByLookupIDWithWantBody(want bool) PeekByLookupIDOption {
 // PeekByLookupIDWithWantConnectorType returns a PeekOption that configures peeking
 // messages with the specified want value.
 //
-// The default is false. It specifies that MSMQ does not retrieve the
-// Message.ConnectorTypeGuid property when it peeks at a message in the
-// queue
+// The default is false, or WantConnectorType set with SetDefaults. It specifies
+// that MSMQ does not retrieve the Message.ConnectorTypeGuid property when it
+// peeks at a message in the queue
 func PeekByLookupIDWithWantConnectorType(want bool) PeekByLookupIDOption {
 	return PeekByLookupIDOption{
 		set: func(opts *peekByLookupIDOptions) {
@@ -195,7 +661,7 @@ func (q *Queue) PeekCurrent(opts ...PeekOption) (Message, error) {
 	}
 
 	return Message{
-		dispatch: msg.ToIDispatch(),
+		dispatch: track(msg.ToIDispatch(), "MSMQ.MSMQMessage"),
 	}, nil
 }
 
@@ -210,7 +676,7 @@ func (q *Queue) PeekFirstByLookupID(opts ...PeekByLookupIDOption) (Message, erro
 	}
 
 	return Message{
-		dispatch: msg.ToIDispatch(),
+		dispatch: track(msg.ToIDispatch(), "MSMQ.MSMQMessage"),
 	}, nil
 }
 
@@ -225,7 +691,7 @@ func (q *Queue) PeekLastByLookupID(opts ...PeekByLookupIDOption) (Message, error
 	}
 
 	return Message{
-		dispatch: msg.ToIDispatch(),
+		dispatch: track(msg.ToIDispatch(), "MSMQ.MSMQMessage"),
 	}, nil
 }
 
@@ -243,112 +709,130 @@ func (q *Queue) PeekNext(opts ...PeekOption) (Message, error) {
 	}
 
 	return Message{
-		dispatch: msg.ToIDispatch(),
+		dispatch: track(msg.ToIDispatch(), "MSMQ.MSMQMessage"),
 	}, nil
 }
 
 // PeekNextByLookupID returns the message that follows the message referenced
-// by id but does not remove the message from the queue.
+// by id but does not remove the message from the queue. The first message
+// follows FirstLookupID.
 //
 // See: https://docs.microsoft.com/en-us/previous-versions/windows/desktop/legacy/ms706024(v=vs.85)
-func (q *Queue) PeekNextByLookupID(id uint64, opts ...PeekByLookupIDOption) (Message, error) {
+func (q *Queue) PeekNextByLookupID(id LookupID, opts ...PeekByLookupIDOption) (Message, error) {
 	msg, err := q.peek("PeekNextByLookupID", id, opts)
 	if err != nil {
-		return Message{}, fmt.Errorf("go-msmq: PeekNextByLookupID(%d) failed to peek next message by lookup id: %w", id, err)
+		return Message{}, fmt.Errorf("go-msmq: PeekNextByLookupID(%v) failed to peek next message by lookup id: %w", id, err)
 	}
 
 	return Message{
-		dispatch: msg.ToIDispatch(),
+		dispatch: track(msg.ToIDispatch(), "MSMQ.MSMQMessage"),
 	}, nil
 }
 
 // PeekPreviousByLookupID returns the message that precedes the message referenced
-// by id but does not remove the message from the queue.
+// by id but does not remove the message from the queue. The last message
+// precedes LastLookupID.
 //
 // See: https://docs.microsoft.com/en-us/previous-versions/windows/desktop/legacy/ms706024(v=vs.85)
-func (q *Queue) PeekPreviousByLookupID(id uint64, opts ...PeekByLookupIDOption) (Message, error) {
+func (q *Queue) PeekPreviousByLookupID(id LookupID, opts ...PeekByLookupIDOption) (Message, error) {
 	msg, err := q.peek("PeekPreviousByLookupID", id, opts)
 	if err != nil {
-		return Message{}, fmt.Errorf("go-msmq: PeekPreviousByLookupID(%d) failed to peek previous message by lookup id: %w", id, err)
+		return Message{}, fmt.Errorf("go-msmq: PeekPreviousByLookupID(%v) failed to peek previous message by lookup id: %w", id, err)
 	}
 
 	return Message{
-		dispatch: msg.ToIDispatch(),
+		dispatch: track(msg.ToIDispatch(), "MSMQ.MSMQMessage"),
 	}, nil
 }
 
 func (q *Queue) peek(action string, params ...interface{}) (*ole.VARIANT, error) {
-	open, err := q.IsOpen()
-	if err != nil {
-		return nil, err
-	}
+	start := time.Now()
+	res, err := q.doPeek(action, params...)
+	q.recordMessage(action, start, res, err)
+	return res, err
+}
 
-	if !open {
-		return nil, errors.New("Exception occurred. (The queue is not open or might not exist. )")
+// doPeek calls the peek method action of the queue.
+func (q *Queue) doPeek(action string, params ...interface{}) (*ole.VARIANT, error) {
+	if err := q.checkOpen(); err != nil {
+		return nil, err
 	}
 
 	switch action {
 	case "Peek", "PeekCurrent", "PeekNext":
-		options := &peekOptions{
-			wantDestinationQueue: false,
-			wantBody:             true,
-			timeout:              1<<31 - 1,
-			wantConnectorType:    false,
-		}
-
-		for _, o := range params[0].([]PeekOption) {
-			o.set(options)
-		}
-
-		return q.dispatch.CallMethod(action, options.wantDestinationQueue, options.wantBody, options.timeout, options.wantConnectorType)
+		options := q.newPeekOptions(params[0].([]PeekOption))
+		return q.call(action, options.wantDestinationQueue, options.wantBody, options.timeout, options.wantConnectorType)
 
 	case "PeekByLookupID", "PeekNextByLookupID", "PeekPreviousByLookupID":
-		id := params[0].(uint64)
+		id := params[0].(LookupID)
+		d := currentDefaults()
 		options := &peekByLookupIDOptions{
-			wantDestinationQueue: false,
-			wantBody:             true,
-			wantConnectorType:    false,
+			wantDestinationQueue: d.WantDestinationQueue,
+			wantBody:             d.WantBody,
+			wantConnectorType:    d.WantConnectorType,
 		}
 
 		for _, o := range params[1].([]PeekByLookupIDOption) {
 			o.set(options)
 		}
 
-		return q.dispatch.CallMethod(action, id, options.wantDestinationQueue, options.wantBody, options.wantConnectorType)
+		if method, byID := resolveLookupID(action, id); !byID {
+			return q.call(method, options.wantDestinationQueue, options.wantBody, options.wantConnectorType)
+		}
+		return q.call(action, uint64(id), options.wantDestinationQueue, options.wantBody, options.wantConnectorType)
 
 	case "PeekFirstByLookupID", "PeekLastByLookupID":
+		d := currentDefaults()
 		options := &peekByLookupIDOptions{
-			wantDestinationQueue: false,
-			wantBody:             true,
-			wantConnectorType:    false,
+			wantDestinationQueue: d.WantDestinationQueue,
+			wantBody:             d.WantBody,
+			wantConnectorType:    d.WantConnectorType,
 		}
 
 		for _, o := range params[0].([]PeekByLookupIDOption) {
 			o.set(options)
 		}
 
-		return q.dispatch.CallMethod(action, options.wantDestinationQueue, options.wantBody, options.wantConnectorType)
+		return q.call(action, options.wantDestinationQueue, options.wantBody, options.wantConnectorType)
 
 	default:
 		return nil, nil
 	}
 }
 
+// newPeekOptions returns the options of a peek with opts, which apply after
+// the defaults of the package and of the queue.
+func (q *Queue) newPeekOptions(opts []PeekOption) *peekOptions {
+	d := currentDefaults()
+	options := &peekOptions{
+		wantDestinationQueue: d.WantDestinationQueue,
+		wantBody:             d.WantBody,
+		timeout:              d.timeoutMillis(),
+		wantConnectorType:    d.WantConnectorType,
+	}
+
+	for _, o := range q.peekOptions {
+		o.set(options)
+	}
+	for _, o := range opts {
+		o.set(options)
+	}
+
+	return options
+}
+
 // Purge deletes all the messages in the queue. The queue must be opened with
 // Receive AccessMode in order to purge messages.
 //
 // See: https://docs.microsoft.com/en-us/previous-versions/windows/desktop/legacy/ms703966(v=vs.85)
 func (q *Queue) Purge() error {
-	open, err := q.IsOpen()
-	if err != nil {
+	if err := q.checkOpen(); err != nil {
 		return fmt.Errorf("go-msmq: failed to purge messages: %w", err)
 	}
 
-	if !open {
-		return fmt.Errorf("go-msmq: failed to purge messages: %w", errors.New("Exception occurred. (The queue is not open or might not exist. )"))
-	}
-
-	_, err = q.dispatch.CallMethod("Purge")
+	start := time.Now()
+	_, err := q.call("Purge")
+	q.record("Purge", start, err, false)
 	if err != nil {
 		return fmt.Errorf("go-msmq: Purge() failed to delete all messages: %w", err)
 	}
@@ -366,16 +850,52 @@ func (q *Queue) Purge() error {
 //
 // See: https://docs.microsoft.com/en-us/previous-versions/windows/desktop/legacy/ms706017(v=vs.85)
 func (q *Queue) Receive(opts ...ReceiveOption) (Message, error) {
+	if q.mq != nil {
+		options := q.newReceiveOptions(opts)
+		var msg Message
+		err := options.retry.Do(func() error {
+			var err error
+			msg, err = q.receiveNative(false, options.level, options.tx, options.timeout)
+			return err
+		})
+		return msg, err
+	}
+
 	msg, err := q.receive("Receive", opts)
 	if err != nil {
 		return Message{}, err
 	}
 
 	return Message{
-		dispatch: msg.ToIDispatch(),
+		dispatch: track(msg.ToIDispatch(), "MSMQ.MSMQMessage"),
 	}, nil
 }
 
+// ReceiveBody retrieves the body of the first message in the queue, removing
+// the message from the queue when the message is read.
+//
+// An empty string is returned if the timeout expires before a message arrives.
+func (q *Queue) ReceiveBody(opts ...ReceiveOption) (string, error) {
+	msg, err := q.Receive(opts...)
+	if err != nil {
+		return "", err
+	}
+	defer msg.release()
+
+	return msg.Body()
+}
+
+// ReceiveBodyBytes is like ReceiveBody but returns the body as a byte slice.
+func (q *Queue) ReceiveBodyBytes(opts ...ReceiveOption) ([]byte, error) {
+	msg, err := q.Receive(opts...)
+	if err != nil {
+		return nil, err
+	}
+	defer msg.release()
+
+	return msg.BodyBytes()
+}
+
 // ReceiveOption represents an option to receive messages from a queue.
 type ReceiveOption struct {
 	set (func(o *receiveOptions))
@@ -384,6 +904,8 @@ type ReceiveOption struct {
 // receiveOptions contains all the options to receive messages from a queue.
 type receiveOptions struct {
 	level                TransactionLevel
+	tx                   *Transaction
+	retry                *RetryPolicy
 	wantDestinationQueue bool
 	wantBody             bool
 	timeout              int
@@ -393,7 +915,7 @@ type receiveOptions struct {
 // ReceiveWithTransaction returns a ReceiveOption that configures receiving
 // messages from a queue with the specified level value.
 //
-// The default is MTS.
+// The default is MTS, or the TransactionLevel set with SetDefaults.
 func ReceiveWithTransaction(level TransactionLevel) ReceiveOption {
 	return ReceiveOption{
 		set: func(o *receiveOptions) {
@@ -402,12 +924,36 @@ func ReceiveWithTransaction(level TransactionLevel) ReceiveOption {
 	}
 }
 
+// ReceiveInTransaction returns a ReceiveOption that configures receiving
+// messages from a queue as part of tx. It takes precedence over
+// ReceiveWithTransaction.
+func ReceiveInTransaction(tx *Transaction) ReceiveOption {
+	return ReceiveOption{
+		set: func(o *receiveOptions) {
+			o.tx = tx
+		},
+	}
+}
+
+// ReceiveWithRetry returns a ReceiveOption that retries receiving messages
+// according to policy when the receive fails with a retryable error.
+//
+// The default is not to retry, or the Retry policy set with SetDefaults.
+func ReceiveWithRetry(policy *RetryPolicy) ReceiveOption {
+	return ReceiveOption{
+		set: func(o *receiveOptions) {
+			o.retry = policy
+		},
+	}
+}
+
 // ReceiveWithWantDestinationQueue returns a ReceiveOption that configures receiving
 // messages from a queue with the specified want value.
 //
-// The default is false. If set to true, the Message.DestinationQueueInfo
-// property is updated when the message is read from the queue. Setting this
-// option to true may slow down the operation.
+// The default is false, or WantDestinationQueue set with SetDefaults. If set to
+// true, the Message.DestinationQueueInfo property is updated when the message
+// is read from the queue. Setting this option to true may slow down the
+// operation.
 func ReceiveWithWantDestinationQueue(want bool) ReceiveOption {
 	return ReceiveOption{
 		set: func(opts *receiveOptions) {
@@ -419,9 +965,9 @@ func ReceiveWithWantDestinationQueue(want bool) ReceiveOption {
 // ReceiveWithWantBody returns a ReceiveOption that configures receiving
 // messages from a queue with the specified want value.
 //
-// The default is true. It specifies that the body of the message should be
-// retrieved. If the message body is not needed, set this option to false to
-// optimize the speed of the application.
+// The default is true, or WantBody set with SetDefaults. It specifies that the
+// body of the message should be retrieved. If the message body is not needed,
+// set this option to false to optimize the speed of the application.
 func ReceiveWithWantBody(want bool) ReceiveOption {
 	return ReceiveOption{
 		set: func(opts *receiveOptions) {
@@ -433,8 +979,9 @@ func ReceiveWithWantBody(want bool) ReceiveOption {
 // ReceiveWithTimeout returns a ReceiveOption that configures receiving messages
 // with the specified timeout value.
 //
-// The default is infinite (max value of int). It specifies the time in
-// milliseconds that MSMQ will wait for a message to arrive.
+// The default is infinite (max value of int), or the ReceiveTimeout set with
+// SetDefaults. It specifies the time in milliseconds that MSMQ will wait for a
+// message to arrive.
 func ReceiveWithTimeout(timeout int) ReceiveOption {
 	return ReceiveOption{
 		set: func(opts *receiveOptions) {
@@ -446,9 +993,9 @@ func ReceiveWithTimeout(timeout int) ReceiveOption {
 // ReceiveWithWantConnectorType returns a ReceiveOption that configures receiving
 // messages with the specified want value.
 //
-// The default is false. It specifies that MSMQ does not retrieve the
-// Message.ConnectorTypeGuid property when it receives a message in the
-// queue.
+// The default is false, or WantConnectorType set with SetDefaults. It specifies
+// that MSMQ does not retrieve the Message.ConnectorTypeGuid property when it
+// receives a message in the queue.
 func ReceiveWithWantConnectorType(want bool) ReceiveOption {
 	return ReceiveOption{
 		set: func(opts *receiveOptions) {
@@ -458,17 +1005,18 @@ func ReceiveWithWantConnectorType(want bool) ReceiveOption {
 }
 
 // ReceiveByLookupID returns the message referenced by id and removes the message
-// from the queue.
+// from the queue. FirstLookupID and LastLookupID reference the first and last
+// messages.
 //
 // See: https://docs.microsoft.com/en-us/previous-versions/windows/desktop/legacy/ms701233(v=vs.85)
-func (q *Queue) ReceiveByLookupID(id uint64, opts ...ReceiveByLookupIDOption) (Message, error) {
+func (q *Queue) ReceiveByLookupID(id LookupID, opts ...ReceiveByLookupIDOption) (Message, error) {
 	msg, err := q.receive("ReceiveByLookupID", id, opts)
 	if err != nil {
-		return Message{}, fmt.Errorf("go-msmq: ReceiveByLookupID(%d) failed to receive messages by lookup id: %w", id, err)
+		return Message{}, fmt.Errorf("go-msmq: ReceiveByLookupID(%v) failed to receive messages by lookup id: %w", id, err)
 	}
 
 	return Message{
-		dispatch: msg.ToIDispatch(),
+		dispatch: track(msg.ToIDispatch(), "MSMQ.MSMQMessage"),
 	}, nil
 }
 
@@ -482,6 +1030,7 @@ type ReceiveByLookupIDOption struct {
 // lookup ID in a queue.
 type receiveByLookupIDOptions struct {
 	level                TransactionLevel
+	tx                   *Transaction
 	wantDestinationQueue bool
 	wantBody             bool
 	wantConnectorType    bool
@@ -490,7 +1039,7 @@ type receiveByLookupIDOptions struct {
 // ReceiveByLookupIDWithTransaction returns a ReceiveOption that configures
 // receiving messages by lookup ID from a queue with the specified level value.
 //
-// The default is MTS.
+// The default is MTS, or the TransactionLevel set with SetDefaults.
 func ReceiveByLookupIDWithTransaction(level TransactionLevel) ReceiveByLookupIDOption {
 	return ReceiveByLookupIDOption{
 		set: func(o *receiveByLookupIDOptions) {
@@ -499,13 +1048,25 @@ func ReceiveByLookupIDWithTransaction(level TransactionLevel) ReceiveByLookupIDO
 	}
 }
 
+// ReceiveByLookupIDInTransaction returns a ReceiveByLookupIDOption that
+// configures receiving messages by lookup ID from a queue as part of tx. It
+// takes precedence over ReceiveByLookupIDWithTransaction.
+func ReceiveByLookupIDInTransaction(tx *Transaction) ReceiveByLookupIDOption {
+	return ReceiveByLookupIDOption{
+		set: func(o *receiveByLookupIDOptions) {
+			o.tx = tx
+		},
+	}
+}
+
 // ReceiveByLookupIDWithWantDestinationQueue returns a ReceiveByLookupIDOption
 // that configures receiving a message by lookup ID with the specified want
 // value.
 //
-// The default is false. If set to true, the Message.DestinationQueueInfo
-// property is updated when the message is read from the queue. Setting this
-// option to true may slow down the operation.
+// The default is false, or WantDestinationQueue set with SetDefaults. If set to
+// true, the Message.DestinationQueueInfo property is updated when the message
+// is read from the queue. Setting this option to true may slow down the
+// operation.
 func ReceiveByLookupIDWithWantDestinationQueue(want bool) ReceiveByLookupIDOption {
 	return ReceiveByLookupIDOption{
 		set: func(o *receiveByLookupIDOptions) {
@@ -517,9 +1078,9 @@ func ReceiveByLookupIDWithWantDestinationQueue(want bool) ReceiveByLookupIDOptio
 // ReceiveByLookupIDWithWantBody returns a ReceiveByLookupIDOption that configures
 // receiving messages by lookup ID with the specified want value.
 //
-// The default is true. It specifies that the body of the message should be
-// retrieved. If the message body is not needed, set this option to false to
-// optimize the speed of the application.
+// The default is true, or WantBody set with SetDefaults. It specifies that the
+// body of the message should be retrieved. If the message body is not needed,
+// set this option to false to optimize the speed of the application.
 func ReceiveByLookupIDWithWantBody(want bool) ReceiveByLookupIDOption {
 	return ReceiveByLookupIDOption{
 		set: func(opts *receiveByLookupIDOptions) {
@@ -531,9 +1092,9 @@ func ReceiveByLookupIDWithWantBody(want bool) ReceiveByLookupIDOption {
 // ReceiveByLookupIDWithWantConnectorType returns a ReceiveByLookupIDOption that
 // configures receiving messages by lookup ID with the specified want value.
 //
-// The default is false. It specifies that MSMQ does not retrieve the
-// Message.ConnectorTypeGuid property when it receives a message in the
-// queue.
+// The default is false, or WantConnectorType set with SetDefaults. It specifies
+// that MSMQ does not retrieve the Message.ConnectorTypeGuid property when it
+// receives a message in the queue.
 func ReceiveByLookupIDWithWantConnectorType(want bool) ReceiveByLookupIDOption {
 	return ReceiveByLookupIDOption{
 		set: func(opts *receiveByLookupIDOptions) {
@@ -555,7 +1116,7 @@ func (q *Queue) ReceiveCurrent(opts ...ReceiveOption) (Message, error) {
 	}
 
 	return Message{
-		dispatch: msg.ToIDispatch(),
+		dispatch: track(msg.ToIDispatch(), "MSMQ.MSMQMessage"),
 	}, nil
 }
 
@@ -570,7 +1131,7 @@ func (q *Queue) ReceiveFirstByLookupID(opts ...ReceiveByLookupIDOption) (Message
 	}
 
 	return Message{
-		dispatch: msg.ToIDispatch(),
+		dispatch: track(msg.ToIDispatch(), "MSMQ.MSMQMessage"),
 	}, nil
 }
 
@@ -585,105 +1146,144 @@ func (q *Queue) ReceiveLastByLookupID(opts ...ReceiveByLookupIDOption) (Message,
 	}
 
 	return Message{
-		dispatch: msg.ToIDispatch(),
+		dispatch: track(msg.ToIDispatch(), "MSMQ.MSMQMessage"),
 	}, nil
 }
 
 // ReceiveNextByLookupID returns the message that follows the message referenced
-// by id and removes the message from the queue.
+// by id and removes the message from the queue. The first message follows
+// FirstLookupID.
 //
 // See: https://docs.microsoft.com/en-us/previous-versions/windows/desktop/legacy/ms704392(v=vs.85)
-func (q *Queue) ReceiveNextByLookupID(id uint64, opts ...ReceiveByLookupIDOption) (Message, error) {
+func (q *Queue) ReceiveNextByLookupID(id LookupID, opts ...ReceiveByLookupIDOption) (Message, error) {
 	msg, err := q.receive("ReceiveNextByLookupID", id, opts)
 	if err != nil {
-		return Message{}, fmt.Errorf("go-msmq: ReceiveNextByLookupID(%d) failed to receive next message by lookup id: %w", id, err)
+		return Message{}, fmt.Errorf("go-msmq: ReceiveNextByLookupID(%v) failed to receive next message by lookup id: %w", id, err)
 	}
 
 	return Message{
-		dispatch: msg.ToIDispatch(),
+		dispatch: track(msg.ToIDispatch(), "MSMQ.MSMQMessage"),
 	}, nil
 }
 
 // ReceivePreviousByLookupID returns the message that precedes the message referenced
-// by id and removes the message from the queue.
+// by id and removes the message from the queue. The last message precedes
+// LastLookupID.
 //
 // See: https://docs.microsoft.com/en-us/previous-versions/windows/desktop/legacy/ms707123(v=vs.85)
-func (q *Queue) ReceivePreviousByLookupID(id uint64, opts ...ReceiveByLookupIDOption) (Message, error) {
+func (q *Queue) ReceivePreviousByLookupID(id LookupID, opts ...ReceiveByLookupIDOption) (Message, error) {
 	msg, err := q.receive("ReceivePreviousByLookupID", id, opts)
 	if err != nil {
-		return Message{}, fmt.Errorf("go-msmq: ReceivePreviousByLookupID(%d) failed to receive previous message by lookup id: %w", id, err)
+		return Message{}, fmt.Errorf("go-msmq: ReceivePreviousByLookupID(%v) failed to receive previous message by lookup id: %w", id, err)
 	}
 
 	return Message{
-		dispatch: msg.ToIDispatch(),
+		dispatch: track(msg.ToIDispatch(), "MSMQ.MSMQMessage"),
 	}, nil
 }
 
 func (q *Queue) receive(action string, params ...interface{}) (*ole.VARIANT, error) {
-	open, err := q.IsOpen()
-	if err != nil {
-		return nil, err
-	}
+	start := time.Now()
+	res, err := q.doReceive(action, params...)
+	q.recordMessage(action, start, res, err)
+	return res, err
+}
 
-	if !open {
-		return nil, errors.New("Exception occurred. (The queue is not open or might not exist. )")
+// doReceive calls the receive method action of the queue.
+func (q *Queue) doReceive(action string, params ...interface{}) (*ole.VARIANT, error) {
+	if err := q.checkOpen(); err != nil {
+		return nil, err
 	}
 
 	switch action {
 	case "Receive", "ReceiveCurrent":
-		options := &receiveOptions{
-			level:                MTS,
-			wantDestinationQueue: false,
-			wantBody:             true,
-			timeout:              1<<31 - 1,
-			wantConnectorType:    false,
+		options := q.newReceiveOptions(params[0].([]ReceiveOption))
+		tx, err := transactionArg(options.level, options.tx)
+		if err != nil {
+			return nil, err
 		}
 
-		for _, o := range params[0].([]ReceiveOption) {
-			o.set(options)
-		}
-
-		return q.dispatch.CallMethod(action, int(options.level), options.wantDestinationQueue, options.wantBody, options.timeout, options.wantConnectorType)
+		return options.retry.call(func() (*ole.VARIANT, error) {
+			return q.call(action, tx, options.wantDestinationQueue, options.wantBody, options.timeout, options.wantConnectorType)
+		})
 
 	case "ReceiveByLookupID", "ReceiveNextByLookupID", "ReceivePreviousByLookupID":
-		id := params[0].(uint64)
+		id := params[0].(LookupID)
+		d := currentDefaults()
 		options := &receiveByLookupIDOptions{
-			level:                MTS,
-			wantDestinationQueue: false,
-			wantBody:             true,
-			wantConnectorType:    false,
+			level:                d.TransactionLevel,
+			wantDestinationQueue: d.WantDestinationQueue,
+			wantBody:             d.WantBody,
+			wantConnectorType:    d.WantConnectorType,
 		}
 
 		for _, o := range params[1].([]ReceiveByLookupIDOption) {
 			o.set(options)
 		}
 
-		return q.dispatch.CallMethod(action, id, int(options.level), options.wantDestinationQueue, options.wantBody, options.wantConnectorType)
+		tx, err := transactionArg(options.level, options.tx)
+		if err != nil {
+			return nil, err
+		}
+
+		if method, byID := resolveLookupID(action, id); !byID {
+			return q.call(method, tx, options.wantDestinationQueue, options.wantBody, options.wantConnectorType)
+		}
+		return q.call(action, uint64(id), tx, options.wantDestinationQueue, options.wantBody, options.wantConnectorType)
 
 	case "ReceiveFirstByLookupID", "ReceiveLastByLookupID":
+		d := currentDefaults()
 		options := &receiveByLookupIDOptions{
-			level:                MTS,
-			wantDestinationQueue: false,
-			wantBody:             true,
-			wantConnectorType:    false,
+			level:                d.TransactionLevel,
+			wantDestinationQueue: d.WantDestinationQueue,
+			wantBody:             d.WantBody,
+			wantConnectorType:    d.WantConnectorType,
 		}
 
 		for _, o := range params[0].([]ReceiveByLookupIDOption) {
 			o.set(options)
 		}
 
-		return q.dispatch.CallMethod(action, int(options.level), options.wantDestinationQueue, options.wantBody, options.wantConnectorType)
+		tx, err := transactionArg(options.level, options.tx)
+		if err != nil {
+			return nil, err
+		}
+
+		return q.call(action, tx, options.wantDestinationQueue, options.wantBody, options.wantConnectorType)
 
 	default:
 		return nil, nil
 	}
 }
 
+// newReceiveOptions returns the options of a receive with opts, which apply
+// after the defaults of the package and of the queue.
+func (q *Queue) newReceiveOptions(opts []ReceiveOption) *receiveOptions {
+	d := currentDefaults()
+	options := &receiveOptions{
+		level:                d.TransactionLevel,
+		retry:                d.Retry,
+		wantDestinationQueue: d.WantDestinationQueue,
+		wantBody:             d.WantBody,
+		timeout:              d.timeoutMillis(),
+		wantConnectorType:    d.WantConnectorType,
+	}
+
+	for _, o := range q.receiveOptions {
+		o.set(options)
+	}
+	for _, o := range opts {
+		o.set(options)
+	}
+
+	return options
+}
+
 // Reset resets the postion of the cursor to the start of the queue.
 //
 // See: https://docs.microsoft.com/en-us/previous-versions/windows/desktop/legacy/ms706246(v=vs.85)
 func (q *Queue) Reset() error {
-	_, err := q.dispatch.CallMethod("Reset")
+	_, err := q.call("Reset")
 	if err != nil {
 		return fmt.Errorf("go-msmq: Reset() failed to reset the position of the cursor: %w", err)
 	}
@@ -691,53 +1291,101 @@ func (q *Queue) Reset() error {
 	return nil
 }
 
+// TryPeek returns the first message in the queue without waiting for a
+// message to arrive. It does not remove the message from the queue.
+//
+// ok is false if the queue is empty. Any timeout specified in opts is
+// ignored.
+func (q *Queue) TryPeek(opts ...PeekOption) (msg Message, ok bool, err error) {
+	// The capacity of opts is capped so that the timeout is appended to a
+	// copy rather than to the backing array of the caller.
+	msg, err = q.Peek(append(opts[:len(opts):len(opts)], PeekWithTimeout(0))...)
+	if err != nil {
+		return Message{}, false, fmt.Errorf("go-msmq: TryPeek() failed to peek message: %w", err)
+	}
+
+	return msg, !msg.IsZero(), nil
+}
+
+// TryReceive retrieves the first message in the queue without waiting for a
+// message to arrive, removing the message from the queue when the message is
+// read.
+//
+// ok is false if the queue is empty. Any timeout specified in opts is
+// ignored.
+func (q *Queue) TryReceive(opts ...ReceiveOption) (msg Message, ok bool, err error) {
+	msg, err = q.Receive(append(opts[:len(opts):len(opts)], ReceiveWithTimeout(0))...)
+	if err != nil {
+		return Message{}, false, fmt.Errorf("go-msmq: TryReceive() failed to receive message: %w", err)
+	}
+
+	return msg, !msg.IsZero(), nil
+}
+
 // Access returns the access mode in which the queue was opened.
 func (q *Queue) Access() (AccessMode, error) {
-	res, err := q.dispatch.GetProperty("Access")
+	res, err := q.get("Access")
 	if err != nil {
 		return AccessMode(0), fmt.Errorf("go-msmq: Access() failed to get Access: %w", err)
 	}
+	defer res.Clear()
 
-	return AccessMode(res.Value().(int32)), nil
+	v, err := variantInt32(res, "Access")
+	return AccessMode(v), err
 }
 
 // Handle returns the handle of the opened queue.
 func (q *Queue) Handle() (int32, error) {
-	res, err := q.dispatch.GetProperty("Handle")
+	res, err := q.get("Handle")
 	if err != nil {
 		return 0, fmt.Errorf("go-msmq: Handle() failed to get Handle: %w", err)
 	}
+	defer res.Clear()
 
-	return res.Value().(int32), err
+	return variantInt32(res, "Handle")
 }
 
 // IsOpen returns whether the queue is open.
 func (q *Queue) IsOpen() (bool, error) {
-	res, err := q.dispatch.GetProperty("IsOpen2")
+	res, err := q.get("IsOpen2")
+	if err == errQueueNotOpen || errors.Is(err, ErrNotInitialized) {
+		return false, nil
+	}
 	if err != nil {
 		return false, fmt.Errorf("go-msmq: IsOpen() failed to get IsOpen2: %w", err)
 	}
+	defer res.Clear()
 
-	return res.Value().(bool), err
+	open, err := variantBool(res, "IsOpen2")
+	if err != nil {
+		return false, err
+	}
+	q.open.Store(open)
+	return open, err
 }
 
-// QueueInfo returns the QueueInfo that was used to open the queue.
+// QueueInfo returns a new QueueInfo of the queue, as it was opened. The
+// QueueInfo the queue was opened from is left untouched since it may be shared
+// with other queues. The caller must close the returned QueueInfo.
 func (q *Queue) QueueInfo() (*QueueInfo, error) {
-	res, err := q.dispatch.GetProperty("QueueInfo")
+	res, err := q.get("QueueInfo")
 	if err != nil {
 		return nil, fmt.Errorf("go-msmq: QueueInfo() failed to get QueueInfo: %w", err)
 	}
 
-	q.qi.dispatch = res.ToIDispatch()
-	return q.qi, err
+	return &QueueInfo{
+		dispatch: track(res.ToIDispatch(), "MSMQ.MSMQQueueInfo"),
+	}, nil
 }
 
 // ShareMode returns the share mode in which the queue was opened.
 func (q *Queue) ShareMode() (ShareMode, error) {
-	res, err := q.dispatch.GetProperty("ShareMode")
+	res, err := q.get("ShareMode")
 	if err != nil {
 		return ShareMode(0), fmt.Errorf("go-msmq: ShareMode() failed to get ShareMode: %w", err)
 	}
+	defer res.Clear()
 
-	return ShareMode(res.Value().(int32)), nil
+	v, err := variantInt32(res, "ShareMode")
+	return ShareMode(v), err
 }
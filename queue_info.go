@@ -1,22 +1,36 @@
-// +build windows
-
 package msmq
 
 import (
 	"errors"
 	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"net"
+	"strconv"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/go-ole/go-ole"
-	"github.com/go-ole/go-ole/oleutil"
 )
 
 // QueueInfo provides queue management for a single queue. It provides methods
 // for creating a queue (either a transactional or non-transactional queue),
 // opening a queue, changing or retrieving properties of a queue, and deleting
 // a queue.
+//
+// A QueueInfo holds a reference to a COM object. Call Close to release the
+// reference when the QueueInfo is no longer needed; long-running services
+// that create many QueueInfo would otherwise accumulate references until the
+// process exits. Queues opened from a QueueInfo remain open after the
+// QueueInfo is closed.
 type QueueInfo struct {
 	dispatch *ole.IDispatch
+
+	// staged are the property options passed to NewQueueInfo that are not
+	// applied yet.
+	staged []func(qi *QueueInfo) error
 }
 
 // NewQueueInfo returns a pointer to a QueueInfo. The FormatName or PathName
@@ -25,13 +39,30 @@ type QueueInfo struct {
 //   queueInfo, err := msmq.NewQueueInfo(msmq.WithFormatName(name))
 // Alternatively, it can be done through the QueueInfo.SetFormatName() function:
 //   err := queueInfo.SetFormatName(name)
+//
+// Every option is validated, and the failures of all of them are joined into
+// the returned error, which wraps ErrInvalidOption, ErrInvalidPathName or
+// ErrInvalidFormatName. The options that set properties of the queue, such
+// as WithLabel or WithQuota, are staged rather than set immediately: Create
+// creates the queue with them, and Open first updates the properties of the
+// existing queue with them, as QueueInfo.Update does. Until then, the
+// getters of the QueueInfo do not reflect staged properties. The properties
+// stay staged until a Create or Open succeeds, so that retrying a failed
+// call applies them again.
 func NewQueueInfo(opts ...QueueInfoOption) (*QueueInfo, error) {
-	unknown, err := oleutil.CreateObject("MSMQ.MSMQQueueInfo")
-	if err != nil && err.Error() == "Invalid class string" {
-		return nil, ErrMSMQNotInstalled
+	var errs []error
+	for _, o := range opts {
+		if o.validate != nil {
+			if err := o.validate(); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("go-msmq: failed to create new QueueInfo: %w", errors.Join(errs...))
 	}
 
-	dispatch, err := unknown.QueryInterface(ole.IID_IDispatch)
+	dispatch, err := createObject("MSMQ.MSMQQueueInfo")
 	if err != nil {
 		return nil, err
 	}
@@ -41,40 +72,100 @@ func NewQueueInfo(opts ...QueueInfoOption) (*QueueInfo, error) {
 	}
 
 	for _, o := range opts {
-		err = o.set(queueInfo)
-		if err != nil {
-			return nil, fmt.Errorf("go-msmq: failed to create new QueueInfo: %w", err)
+		if o.staged {
+			queueInfo.staged = append(queueInfo.staged, o.set)
+			continue
+		}
+
+		if err := o.set(queueInfo); err != nil {
+			errs = append(errs, err)
 		}
 	}
+	if len(errs) > 0 {
+		queueInfo.Close()
+		return nil, fmt.Errorf("go-msmq: failed to create new QueueInfo: %w", errors.Join(errs...))
+	}
 
 	return queueInfo, nil
 }
 
+// ErrInvalidOption is wrapped by the errors returned by NewQueueInfo for
+// options with invalid values.
+var ErrInvalidOption = errors.New("go-msmq: invalid option")
+
 // QueueInfoOption represents an option to configure QueueInfo.
 type QueueInfoOption struct {
 	set func(qi *QueueInfo) error
+
+	// validate checks the value of the option without contacting MSMQ.
+	validate func() error
+
+	// staged reports whether the option sets a property of the queue,
+	// which is applied by Create or Open.
+	staged bool
 }
 
-// WithAuthenticate returns a QueueInfoOption that configures QueueInfo with the
-// specified Authenticate value.
-func WithAuthenticate(authenticate bool) QueueInfoOption {
+// stagedOption returns a QueueInfoOption that stages the property set by
+// set, after checking its value with validate.
+func stagedOption(set func(qi *QueueInfo) error, validate func() error) QueueInfoOption {
 	return QueueInfoOption{
-		set: func(qi *QueueInfo) error {
-			return qi.SetAuthenticate(authenticate)
-		},
+		set:      set,
+		validate: validate,
+		staged:   true,
 	}
 }
 
-// WithBasePriority returns a QueueInfoOption that configures QueueInfo with the
-// specified BasePriority value.
-func WithBasePriority(priority int32) QueueInfoOption {
+// invalidOption returns an error wrapping ErrInvalidOption for the option
+// named name.
+func invalidOption(name string, value interface{}, reason string) error {
+	return fmt.Errorf("%w: %s(%v): %s", ErrInvalidOption, name, value, reason)
+}
+
+// applyStaged sets the staged properties of qi. They stay staged until the
+// caller clears them once the queue was created or updated, so that a failed
+// Create or Open applies them again when it is retried.
+func (qi *QueueInfo) applyStaged() error {
+	var errs []error
+	for _, set := range qi.staged {
+		if err := set(qi); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// WithADsPath returns a QueueInfoOption that configures QueueInfo with the
+// queue referenced by the specified Active Directory path.
+func WithADsPath(path string) QueueInfoOption {
 	return QueueInfoOption{
 		set: func(qi *QueueInfo) error {
-			return qi.SetBasePriority(priority)
+			return qi.SetADsPath(path)
 		},
 	}
 }
 
+// WithAuthenticate returns a QueueInfoOption that configures QueueInfo with the
+// specified Authenticate value.
+func WithAuthenticate(authenticate bool) QueueInfoOption {
+	return stagedOption(func(qi *QueueInfo) error {
+		return qi.SetAuthenticate(authenticate)
+	}, nil)
+}
+
+// WithBasePriority returns a QueueInfoOption that configures QueueInfo with the
+// specified BasePriority value, from -32768 through 32767.
+func WithBasePriority(priority int32) QueueInfoOption {
+	return stagedOption(func(qi *QueueInfo) error {
+		return qi.SetBasePriority(priority)
+	}, func() error {
+		if priority < math.MinInt16 || priority > math.MaxInt16 {
+			return invalidOption("WithBasePriority", priority, "must be from -32768 through 32767")
+		}
+		return nil
+	})
+}
+
 // WithFormatName returns a QueueInfoOption that configures QueueInfo with the
 // specified FormatName value.
 func WithFormatName(name string) QueueInfoOption {
@@ -82,47 +173,67 @@ func WithFormatName(name string) QueueInfoOption {
 		set: func(qi *QueueInfo) error {
 			return qi.SetFormatName(name)
 		},
+		validate: func() error {
+			return ValidateFormatName(name)
+		},
 	}
 }
 
 // WithJournal returns a QueueInfoOption that configures QueueInfo with the
 // specified Journal value.
 func WithJournal(enabled bool) QueueInfoOption {
-	return QueueInfoOption{
-		set: func(qi *QueueInfo) error {
-			return qi.SetJournal(enabled)
-		},
-	}
+	return stagedOption(func(qi *QueueInfo) error {
+		return qi.SetJournal(enabled)
+	}, nil)
 }
 
 // WithJournalQuota returns a QueueInfoOption that configures QueueInfo with
 // the specified JournalQuota value.
 func WithJournalQuota(size int32) QueueInfoOption {
-	return QueueInfoOption{
-		set: func(qi *QueueInfo) error {
-			return qi.SetJournalQuota(size)
-		},
-	}
+	return stagedOption(func(qi *QueueInfo) error {
+		return qi.SetJournalQuota(size)
+	}, nil)
 }
 
+// maxQueueLabelLength is the maximum number of characters in the label of a
+// queue.
+const maxQueueLabelLength = 124
+
 // WithLabel returns a QueueInfoOption that configures QueueInfo with the
-// specified Label value.
+// specified Label value, of at most 124 characters.
 func WithLabel(label string) QueueInfoOption {
-	return QueueInfoOption{
-		set: func(qi *QueueInfo) error {
-			return qi.SetLabel(label)
-		},
-	}
+	return stagedOption(func(qi *QueueInfo) error {
+		return qi.SetLabel(label)
+	}, func() error {
+		if n := utf8.RuneCountInString(label); n > maxQueueLabelLength {
+			return invalidOption("WithLabel", label, fmt.Sprintf("is %d characters long, the maximum is %d", n, maxQueueLabelLength))
+		}
+		return nil
+	})
 }
 
 // WithMulticastAddress returns a QueueInfoOption that configures QueueInfo with the
-// specified MulticastAddress value.
+// specified MulticastAddress value, in the form <address>:<port>, or no
+// multicast address if empty.
 func WithMulticastAddress(address string) QueueInfoOption {
-	return QueueInfoOption{
-		set: func(qi *QueueInfo) error {
-			return qi.SetMulticastAddress(address)
-		},
-	}
+	return stagedOption(func(qi *QueueInfo) error {
+		return qi.SetMulticastAddress(address)
+	}, func() error {
+		if address == "" {
+			return nil
+		}
+		host, port, err := net.SplitHostPort(address)
+		if err != nil {
+			return invalidOption("WithMulticastAddress", address, err.Error())
+		}
+		if ip := net.ParseIP(host); ip == nil || ip.To4() == nil || !ip.IsMulticast() {
+			return invalidOption("WithMulticastAddress", address, "address must be an IPv4 multicast address")
+		}
+		if _, err := strconv.ParseUint(port, 10, 16); err != nil {
+			return invalidOption("WithMulticastAddress", address, "port must be a number from 0 through 65535")
+		}
+		return nil
+	})
 }
 
 // WithPathName returns a QueueInfoOption that configures QueueInfo with the
@@ -132,44 +243,69 @@ func WithPathName(name string) QueueInfoOption {
 		set: func(qi *QueueInfo) error {
 			return qi.SetPathName(name)
 		},
+		validate: func() error {
+			return ValidatePathName(name)
+		},
 	}
 }
 
 // WithPrivacyLevel returns a QueueInfoOption that configures QueueInfo with the
 // specified PrivacyLevel value.
 func WithPrivacyLevel(level PrivLevel) QueueInfoOption {
-	return QueueInfoOption{
-		set: func(qi *QueueInfo) error {
-			return qi.SetPrivacyLevel(level)
-		},
-	}
+	return stagedOption(func(qi *QueueInfo) error {
+		return qi.SetPrivacyLevel(level)
+	}, func() error {
+		if level < NonPrivate || level > OnlyPrivate {
+			return invalidOption("WithPrivacyLevel", level, "unknown privacy level")
+		}
+		return nil
+	})
 }
 
 // WithQuota returns a QueueInfoOption that configures QueueInfo with
 // the specified Quota value.
 func WithQuota(size int32) QueueInfoOption {
-	return QueueInfoOption{
-		set: func(qi *QueueInfo) error {
-			return qi.SetQuota(size)
-		},
-	}
+	return stagedOption(func(qi *QueueInfo) error {
+		return qi.SetQuota(size)
+	}, nil)
 }
 
 // WithServiceTypeGUID returns a QueueInfoOption that configures QueueInfo with
-// the specified ServiceTypeGUID value.
+// the specified ServiceTypeGUID value, in the form
+// {12345678-1234-1234-1234-123456789ABC}.
 func WithServiceTypeGUID(guid string) QueueInfoOption {
-	return QueueInfoOption{
-		set: func(qi *QueueInfo) error {
-			return qi.SetServiceTypeGUID(guid)
-		},
-	}
+	return stagedOption(func(qi *QueueInfo) error {
+		return qi.SetServiceTypeGUID(guid)
+	}, func() error {
+		if !isGUID(guid) {
+			return invalidOption("WithServiceTypeGUID", guid, "not a GUID")
+		}
+		return nil
+	})
 }
 
 // ErrMSMQNotInstalled is returned when trying to interact with MSMQ but it is
 // not installed.
 var ErrMSMQNotInstalled = errors.New("go-msmq: message queuing has not been installed on this computer")
 
-// Create creates a public or private queue based on the options set on QueueInfo.
+// Close releases the underlying queue information object. The QueueInfo
+// cannot be used after it is closed. Calling Close more than once has no
+// effect.
+func (qi *QueueInfo) Close() error {
+	if qi.dispatch != nil {
+		release(qi.dispatch)
+		qi.dispatch = nil
+	}
+
+	return nil
+}
+
+// QueueInfo satisfies io.Closer so that it can be managed alongside other
+// resources.
+var _ io.Closer = (*QueueInfo)(nil)
+
+// Create creates a public or private queue based on the options set on QueueInfo,
+// including the properties staged by the options of NewQueueInfo.
 //
 // The PathName option must be set on QueueInfo before calling Create.
 //   queueInfo, err := msmq.NewQueueInfo()
@@ -204,13 +340,190 @@ func (qi *QueueInfo) Create(opts ...CreateQueueOption) error {
 		o.set(options)
 	}
 
-	_, err = qi.dispatch.CallMethod("Create", options.transactional, options.worldReadable)
+	err = qi.applyStaged()
+	if err != nil {
+		return fmt.Errorf("go-msmq: Create(%v, %v) failed to set queue properties: %w", options.transactional, options.worldReadable, err)
+	}
+
+	var sd securityDescriptor
+	if options.sddl != "" {
+		sd, err = parseSDDL(options.sddl)
+		if err != nil {
+			return fmt.Errorf("go-msmq: Create(%v, %v) failed to parse security descriptor %q: %w", options.transactional, options.worldReadable, options.sddl, err)
+		}
+		defer sd.free()
+	}
+
+	_, err = callMethod(qi.dispatch, "Create", options.transactional, options.worldReadable)
 	if err != nil {
 		return fmt.Errorf("go-msmq: Create(%v, %v) failed to create queue: %w", options.transactional, options.worldReadable, err)
 	}
+
+	if sd != 0 {
+		err = qi.secure(sd)
+		if err != nil {
+			qi.Delete()
+			return fmt.Errorf("go-msmq: Create(%v, %v) failed to set queue security: %w", options.transactional, options.worldReadable, err)
+		}
+	}
+
+	qi.staged = nil
 	return nil
 }
 
+// secure replaces the DACL of the queue with the DACL of sd.
+func (qi *QueueInfo) secure(sd securityDescriptor) error {
+	name, err := qi.FormatName()
+	if err != nil {
+		return err
+	}
+
+	return mqSetQueueSecurity(name, daclSecurityInformation, sd)
+}
+
+// Security returns the DACL of the queue in the Security Descriptor
+// Definition Language, for example "D:P(A;;GA;;;BA)(A;;0x4;;;WD)".
+//
+// See: https://docs.microsoft.com/en-us/windows/win32/api/mq/nf-mq-mqgetqueuesecurity
+func (qi *QueueInfo) Security() (string, error) {
+	name, err := qi.FormatName()
+	if err != nil {
+		return "", fmt.Errorf("go-msmq: Security() failed to get queue security: %w", err)
+	}
+
+	sd, err := mqGetQueueSecurity(name, daclSecurityInformation)
+	if err != nil {
+		return "", fmt.Errorf("go-msmq: Security() failed to get queue security: %w", err)
+	}
+
+	sddl, err := formatSDDL(sd, daclSecurityInformation)
+	if err != nil {
+		return "", fmt.Errorf("go-msmq: Security() failed to format security descriptor: %w", err)
+	}
+
+	return sddl, nil
+}
+
+// SetSecurity replaces the DACL of the queue with the DACL of the specified
+// security descriptor, in the Security Descriptor Definition Language. See
+// CreateQueueWithSecurity.
+func (qi *QueueInfo) SetSecurity(sddl string) error {
+	sd, err := parseSDDL(sddl)
+	if err != nil {
+		return fmt.Errorf("go-msmq: SetSecurity(%q) failed to parse security descriptor: %w", sddl, err)
+	}
+	defer sd.free()
+
+	if err := qi.secure(sd); err != nil {
+		return fmt.Errorf("go-msmq: SetSecurity(%q) failed to set queue security: %w", sddl, err)
+	}
+
+	return nil
+}
+
+// mqErrorQueueExists is the MQ_ERROR_QUEUE_EXISTS HRESULT which is returned
+// when creating a queue that already exists.
+const mqErrorQueueExists = 0xC00E0005
+
+// EnsureCreated creates the queue based on the options set on QueueInfo if it
+// does not exist. If the queue already exists, EnsureCreated verifies that it
+// is transactional and world readable as specified by opts and returns a
+// *QueueMismatchError if it is not. It is the standard way for services that
+// own their queues to provision them at startup:
+//   queueInfo, err := msmq.NewQueueInfo(msmq.WithPathName(`.\private$\orders`))
+//   ...
+//   err = queueInfo.EnsureCreated(msmq.CreateQueueWithTransactional(true))
+func (qi *QueueInfo) EnsureCreated(opts ...CreateQueueOption) error {
+	exists, err := qi.Exists()
+	if err != nil {
+		return fmt.Errorf("go-msmq: EnsureCreated() failed to ensure queue is created: %w", err)
+	}
+
+	if !exists {
+		err = qi.Create(opts...)
+		if err == nil {
+			err = qi.Refresh()
+			if err != nil {
+				return fmt.Errorf("go-msmq: EnsureCreated() failed to ensure queue is created: %w", err)
+			}
+
+			return nil
+		}
+
+		// The queue may have been created by another process since it was
+		// looked up, in which case it is verified like any existing queue.
+		if hresult(err) != mqErrorQueueExists {
+			return fmt.Errorf("go-msmq: EnsureCreated() failed to ensure queue is created: %w", err)
+		}
+
+		err = qi.Refresh()
+		if err != nil {
+			return fmt.Errorf("go-msmq: EnsureCreated() failed to ensure queue is created: %w", err)
+		}
+	}
+
+	options := &createQueueOptions{
+		transactional: false,
+		worldReadable: false,
+	}
+	for _, o := range opts {
+		o.set(options)
+	}
+
+	transactional, err := qi.IsTransactional()
+	if err != nil {
+		return fmt.Errorf("go-msmq: EnsureCreated() failed to ensure queue is created: %w", err)
+	}
+
+	if transactional != options.transactional {
+		return qi.mismatch("Transactional", options.transactional, transactional)
+	}
+
+	worldReadable, err := qi.IsWorldReadable()
+	if err != nil {
+		return fmt.Errorf("go-msmq: EnsureCreated() failed to ensure queue is created: %w", err)
+	}
+
+	if worldReadable != options.worldReadable {
+		return qi.mismatch("WorldReadable", options.worldReadable, worldReadable)
+	}
+
+	return nil
+}
+
+// QueueMismatchError is returned by QueueInfo.EnsureCreated when an existing
+// queue was created with different options than the ones requested.
+type QueueMismatchError struct {
+	// PathName is the path name of the queue.
+	PathName string
+
+	// Property is the name of the property that does not match, such as
+	// Transactional or WorldReadable.
+	Property string
+
+	// Want is the requested value of the property.
+	Want bool
+
+	// Got is the value of the property of the existing queue.
+	Got bool
+}
+
+// Error implements the error interface.
+func (e *QueueMismatchError) Error() string {
+	return fmt.Sprintf("go-msmq: queue %s exists with %s %v, want %v", e.PathName, e.Property, e.Got, e.Want)
+}
+
+// mismatch returns a *QueueMismatchError for the specified property.
+func (qi *QueueInfo) mismatch(property string, want, got bool) error {
+	name, _ := qi.PathName()
+	return &QueueMismatchError{
+		PathName: name,
+		Property: property,
+		Want:     want,
+		Got:      got,
+	}
+}
+
 // CreateQueueOption represents an option to configure the creation of a queue.
 type CreateQueueOption struct {
 	set func(opts *createQueueOptions)
@@ -220,6 +533,26 @@ type CreateQueueOption struct {
 type createQueueOptions struct {
 	transactional bool
 	worldReadable bool
+	sddl          string
+}
+
+// CreateQueueWithSecurity returns a CreateQueueOption that configures the
+// queue with the DACL of the specified security descriptor, in the Security
+// Descriptor Definition Language, instead of the default DACL. For example,
+// the following grants full control to administrators and only allows
+// everyone else to send messages:
+//   msmq.CreateQueueWithSecurity("D:P(A;;GA;;;BA)(A;;0x4;;;WD)")
+// The DACL is applied immediately after the queue is created. If it cannot be
+// applied, the queue is deleted and Create returns an error. The
+// worldReadable value has no effect when a security descriptor is specified.
+//
+// See: https://docs.microsoft.com/en-us/windows/win32/api/mq/nf-mq-mqsetqueuesecurity
+func CreateQueueWithSecurity(sddl string) CreateQueueOption {
+	return CreateQueueOption{
+		set: func(opts *createQueueOptions) {
+			opts.sddl = sddl
+		},
+	}
 }
 
 // CreateQueueWithTransactional returns a CreateQueueOption that configures
@@ -246,7 +579,7 @@ func CreateQueueWithWorldReadable(worldReadable bool) CreateQueueOption {
 //
 // See: https://docs.microsoft.com/en-us/previous-versions/windows/desktop/legacy/ms706050(v=vs.85)
 func (qi *QueueInfo) Delete() error {
-	_, err := qi.dispatch.CallMethod("Delete")
+	_, err := callMethod(qi.dispatch, "Delete")
 	if err != nil {
 		return fmt.Errorf("go-msmq: Delete() failed to delete queue: %w", err)
 	}
@@ -254,21 +587,145 @@ func (qi *QueueInfo) Delete() error {
 	return nil
 }
 
+// HRESULTs that are reported when a queue cannot be located.
+const (
+	mqErrorQueueNotFound                  = 0xC00E0003
+	mqErrorUnsupportedFormatNameOperation = 0xC00E0020
+)
+
+// Exists returns whether the queue referenced by QueueInfo exists. Unlike
+// Create, Exists distinguishes a queue that does not exist from other
+// failures such as insufficient permissions, which are returned as errors.
+//
+// Public queues are looked up in the directory service and local private
+// queues are looked up on the local computer, which also updates the
+// properties of QueueInfo as if Refresh were called. Queues referenced by a
+// format name that does not support lookups, such as a direct format name,
+// are opened with Peek AccessMode to determine whether they exist.
+func (qi *QueueInfo) Exists() (bool, error) {
+	err := qi.Refresh()
+	if err == nil {
+		return true, nil
+	}
+
+	switch hresult(err) {
+	case mqErrorQueueNotFound:
+		return false, nil
+	case mqErrorUnsupportedFormatNameOperation:
+	default:
+		return false, fmt.Errorf("go-msmq: Exists() failed to look up queue: %w", err)
+	}
+
+	queue, err := qi.Open(Peek, DenyNone)
+	if err != nil {
+		if hresult(err) == mqErrorQueueNotFound {
+			return false, nil
+		}
+
+		return false, fmt.Errorf("go-msmq: Exists() failed to look up queue: %w", err)
+	}
+
+	queue.Close()
+	return true, nil
+}
+
 // Open opens a queue for sending, peeking at, retrieving, or purging messages
 // and creates a cursor for navigating the queue if the queue is being opened
-// for retrieving messages.
+// for retrieving messages. The properties staged by the options of
+// NewQueueInfo are first applied to the queue with Update.
 //
 // See: https://docs.microsoft.com/en-us/previous-versions/windows/desktop/legacy/ms707027(v=vs.85)
 func (qi *QueueInfo) Open(accessMode AccessMode, shareMode ShareMode) (*Queue, error) {
-	queue, err := qi.dispatch.CallMethod("Open", int(accessMode), int(shareMode))
+	if len(qi.staged) > 0 {
+		err := qi.applyStaged()
+		if err == nil {
+			err = qi.Update()
+		}
+		if err != nil {
+			return nil, fmt.Errorf("go-msmq: Open(%v, %v) failed to update queue properties: %w", accessMode, shareMode, err)
+		}
+		qi.staged = nil
+	}
+
+	start := time.Now()
+	queue, err := callMethod(qi.dispatch, "Open", int(accessMode), int(shareMode))
+	if l, o := currentLogger(), currentObserver(); l != nil || o != nil {
+		name, _ := qi.FormatName()
+		if l != nil {
+			logOperation(l, "Open", start, err, slog.String("queue", name), slog.Any("access", accessMode), slog.Any("share", shareMode))
+		}
+		if o != nil {
+			o.Observe(Operation{Name: "Open", Queue: name, Duration: time.Since(start), Err: err})
+		}
+	}
 	if err != nil {
+		// Remote reads are not supported over HTTP, so explain the otherwise
+		// opaque failure.
+		if name, ferr := qi.FormatName(); accessMode != Send && ferr == nil && IsHTTPFormatName(name) {
+			return nil, fmt.Errorf("go-msmq: Open(%v, %v) failed to open queue: HTTP format names only support Send AccessMode: %w", accessMode, shareMode, err)
+		}
 		return nil, fmt.Errorf("go-msmq: Open(%v, %v) failed to open queue: %w", accessMode, shareMode, err)
 	}
 
-	return &Queue{
-		dispatch: queue.ToIDispatch(),
-		qi:       qi,
-	}, nil
+	q := &Queue{
+		dispatch:   track(queue.ToIDispatch(), "MSMQ.MSMQQueue"),
+		qi:         qi,
+		accessMode: accessMode,
+		shareMode:  shareMode,
+	}
+	q.open.Store(true)
+	return q, nil
+}
+
+// OpenJournal opens the journal of the queue. The journal contains copies of
+// the messages that were retrieved from the queue when QueueInfo.Journal is
+// enabled. The journal is referenced using the format name of the queue
+// followed by ";JOURNAL", so the FormatName must be set before calling
+// OpenJournal.
+//
+// Messages cannot be sent to a journal, so accessMode should be either Peek or
+// Receive.
+//
+// See: https://docs.microsoft.com/en-us/previous-versions/windows/desktop/msmq/ms706062(v=vs.85)
+func (qi *QueueInfo) OpenJournal(accessMode AccessMode, shareMode ShareMode) (*Queue, error) {
+	journal, err := qi.suffixed("JOURNAL")
+	if err != nil {
+		return nil, fmt.Errorf("go-msmq: OpenJournal(%v, %v) failed to open journal: %w", accessMode, shareMode, err)
+	}
+
+	queue, err := journal.Open(accessMode, shareMode)
+	if err != nil {
+		journal.Close()
+		return nil, fmt.Errorf("go-msmq: OpenJournal(%v, %v) failed to open journal: %w", accessMode, shareMode, err)
+	}
+
+	queue.onClose = journal.Close
+	return queue, nil
+}
+
+// PurgeJournal deletes all the messages in the journal of the queue. The
+// journal is opened with Receive AccessMode for the duration of the purge, so
+// the caller must have permission to receive messages from the journal.
+//
+// See: https://docs.microsoft.com/en-us/previous-versions/windows/desktop/legacy/ms703966(v=vs.85)
+func (qi *QueueInfo) PurgeJournal() error {
+	journal, err := qi.OpenJournal(Receive, DenyNone)
+	if err != nil {
+		return fmt.Errorf("go-msmq: PurgeJournal() failed to purge journal: %w", err)
+	}
+
+	err = journal.Purge()
+	if err != nil {
+		journal.Close()
+		return fmt.Errorf("go-msmq: PurgeJournal() failed to purge journal: %w", err)
+	}
+
+	err = journal.Close()
+	if err != nil {
+		return fmt.Errorf("go-msmq: PurgeJournal() failed to purge journal: %w", err)
+	}
+
+	return nil
 }
 
 // AccessMode defines access modes for accessing messages within a queue. The
@@ -282,19 +739,37 @@ const (
 	// Send grants permissions to insert new messages into a queue.
 	Send AccessMode = 2
 
+	// Move grants permissions to move messages into a subqueue.
+	Move AccessMode = 4
+
 	// Peek grants permissions to peek but not delete messages from a local queue.
 	Peek AccessMode = 32
 
-	// admin specifies that a remote queue is to be opened.
-	admin AccessMode = 128
+	// Admin specifies that a remote queue, such as the outgoing queue of a
+	// remote computer, is to be opened. It is combined with Peek or Receive,
+	// see AccessMode.WithAdmin.
+	Admin AccessMode = 128
 
 	// PeekAndAdmin grants Peek permissions to a remote queue.
-	PeekAndAdmin AccessMode = Peek | admin
+	PeekAndAdmin AccessMode = Peek | Admin
 
 	// ReceiveAndAdmin grants Receive permissions to a remote queue.
-	ReceiveAndAdmin AccessMode = Receive | admin
+	ReceiveAndAdmin AccessMode = Receive | Admin
 )
 
+// Has reports whether all the bits of flag are set in the mode, for example
+// ReceiveAndAdmin.Has(Receive) is true. Has(0) is false.
+func (m AccessMode) Has(flag AccessMode) bool {
+	return flag != 0 && m&flag == flag
+}
+
+// WithAdmin returns the mode with the Admin bit set, for opening a remote or
+// outgoing queue:
+//   queue, err := qi.Open(msmq.Peek.WithAdmin(), msmq.DenyNone)
+func (m AccessMode) WithAdmin() AccessMode {
+	return m | Admin
+}
+
 // ShareMode defines the exclusivity level when accessing a queue. Default
 // value is DenyNone.
 type ShareMode int
@@ -317,7 +792,7 @@ const (
 //
 // See: https://docs.microsoft.com/en-us/previous-versions/windows/desktop/legacy/ms703265(v=vs.85)
 func (qi *QueueInfo) Refresh() error {
-	_, err := qi.dispatch.CallMethod("Refresh")
+	_, err := callMethod(qi.dispatch, "Refresh")
 	if err != nil {
 		return fmt.Errorf("go-msmq: Refresh() failed to retrieve updated properties: %w", err)
 	}
@@ -325,6 +800,132 @@ func (qi *QueueInfo) Refresh() error {
 	return nil
 }
 
+// PropertyChange describes a queue property whose value changed.
+type PropertyChange struct {
+	// Property is the name of the property, such as "Quota".
+	Property string
+
+	// Old is the value of the property before the change.
+	Old interface{}
+
+	// New is the value of the property after the change.
+	New interface{}
+}
+
+// RefreshDiff calls Refresh and returns the properties whose values changed,
+// which is useful to detect configuration drift:
+//   err := queueInfo.Refresh()
+//   ...
+//   for range time.Tick(time.Minute) {
+//       changes, err := queueInfo.RefreshDiff()
+//       ...
+//       for _, c := range changes {
+//           log.Printf("%s changed from %v to %v", c.Property, c.Old, c.New)
+//       }
+//   }
+// The Authenticate, BasePriority, Journal, JournalQuota, Label,
+// MulticastAddress, PrivacyLevel, Quota and ServiceTypeGUID properties are
+// compared. Refresh should be called once beforehand so that the first call
+// to RefreshDiff compares against the stored properties of the queue rather
+// than the defaults of a new QueueInfo.
+func (qi *QueueInfo) RefreshDiff() ([]PropertyChange, error) {
+	before, err := qi.snapshot()
+	if err != nil {
+		return nil, fmt.Errorf("go-msmq: RefreshDiff() failed to retrieve properties: %w", err)
+	}
+
+	err = qi.Refresh()
+	if err != nil {
+		return nil, fmt.Errorf("go-msmq: RefreshDiff() failed to retrieve properties: %w", err)
+	}
+
+	after, err := qi.snapshot()
+	if err != nil {
+		return nil, fmt.Errorf("go-msmq: RefreshDiff() failed to retrieve properties: %w", err)
+	}
+
+	var changes []PropertyChange
+	for i, p := range snapshotProperties {
+		if before[i] != after[i] {
+			changes = append(changes, PropertyChange{
+				Property: p.name,
+				Old:      before[i],
+				New:      after[i],
+			})
+		}
+	}
+
+	return changes, nil
+}
+
+// snapshotProperties are the properties compared by RefreshDiff.
+var snapshotProperties = []struct {
+	name string
+	get  func(qi *QueueInfo) (interface{}, error)
+}{
+	{"Authenticate", func(qi *QueueInfo) (interface{}, error) { return qi.Authenticate() }},
+	{"BasePriority", func(qi *QueueInfo) (interface{}, error) { return qi.BasePriority() }},
+	{"Journal", func(qi *QueueInfo) (interface{}, error) { return qi.Journal() }},
+	{"JournalQuota", func(qi *QueueInfo) (interface{}, error) { return qi.JournalQuota() }},
+	{"Label", func(qi *QueueInfo) (interface{}, error) { return qi.Label() }},
+	{"MulticastAddress", func(qi *QueueInfo) (interface{}, error) { return qi.MulticastAddress() }},
+	{"PrivacyLevel", func(qi *QueueInfo) (interface{}, error) { return qi.PrivacyLevel() }},
+	{"Quota", func(qi *QueueInfo) (interface{}, error) { return qi.Quota() }},
+	{"ServiceTypeGUID", func(qi *QueueInfo) (interface{}, error) { return qi.ServiceTypeGUID() }},
+}
+
+// snapshot returns the values of snapshotProperties.
+func (qi *QueueInfo) snapshot() ([]interface{}, error) {
+	values := make([]interface{}, len(snapshotProperties))
+	for i, p := range snapshotProperties {
+		v, err := p.get(qi)
+		if err != nil {
+			return nil, err
+		}
+
+		values[i] = v
+	}
+
+	return values, nil
+}
+
+// Subqueue returns a QueueInfo that references the subqueue with the specified
+// name. The subqueue is referenced using the format name of the queue followed
+// by the name of the subqueue:
+//   DIRECT=OS:.\private$\orders;poison
+// Subqueues are created implicitly when a message is moved into them and
+// cannot be created or deleted explicitly.
+//
+// See: https://docs.microsoft.com/en-us/previous-versions/windows/desktop/msmq/ms711414(v=vs.85)
+func (qi *QueueInfo) Subqueue(name string) (*QueueInfo, error) {
+	sub, err := qi.suffixed(name)
+	if err != nil {
+		return nil, fmt.Errorf("go-msmq: Subqueue(%s) failed to get subqueue: %w", name, err)
+	}
+
+	return sub, nil
+}
+
+// suffixed returns a QueueInfo that references the queue identified by the
+// format name of qi followed by ";" and suffix. It is used to reference
+// subqueues as well as the journal of a queue.
+func (qi *QueueInfo) suffixed(suffix string) (*QueueInfo, error) {
+	s, err := qi.FormatName()
+	if err != nil {
+		return nil, err
+	}
+
+	if s == "" {
+		return nil, errors.New("Exception occurred. (The queue format name is not set. )")
+	}
+
+	if strings.Contains(s, ";") {
+		return nil, errors.New("Exception occurred. (The queue is already a subqueue or journal. )")
+	}
+
+	return NewQueueInfo(WithFormatName(s + ";" + suffix))
+}
+
 // Update updates the properties of the queue represented by QueueInfo with
 // its current property values. It can only be called after a queue has been
 // created or before the queue is deleted.
@@ -334,7 +935,7 @@ func (qi *QueueInfo) Refresh() error {
 //
 // See: https://docs.microsoft.com/en-us/previous-versions/windows/desktop/legacy/ms705153(v=vs.85)
 func (qi *QueueInfo) Update() error {
-	_, err := qi.dispatch.CallMethod("Update")
+	_, err := callMethod(qi.dispatch, "Update")
 	if err != nil {
 		return fmt.Errorf("go-msmq: Update() failed to update queue: %w", err)
 	}
@@ -344,22 +945,57 @@ func (qi *QueueInfo) Update() error {
 // ADsPath returns the Active Directory Domain Services (AD DS) path to the
 // public queue.
 func (qi *QueueInfo) ADsPath() (string, error) {
-	res, err := qi.dispatch.GetProperty("ADsPath")
+	res, err := getProperty(qi.dispatch, "ADsPath")
 	if err != nil {
 		return "", fmt.Errorf("go-msmq: failed to get AD path: %w", err)
 	}
+	defer res.Clear()
 
-	return res.Value().(string), nil
+	return variantString(res, "ADsPath")
+}
+
+// SetADsPath references the public queue or queue alias at the specified
+// Active Directory path, for example:
+//   LDAP://CN=orders,CN=msmq,CN=host,CN=Computers,DC=example,DC=com
+// The ADsPath property of MSMQ queue objects is read-only, so the path is
+// resolved to a format name through the directory service and FormatName is
+// set to the result. The queue can then be opened as usual.
+func (qi *QueueInfo) SetADsPath(path string) error {
+	dest, err := NewDestination(DestinationWithADsPath(path))
+	if err != nil {
+		return fmt.Errorf("go-msmq: SetADsPath(%s) failed to resolve ADsPath: %w", path, err)
+	}
+	defer dest.Close()
+
+	err = dest.Open()
+	if err != nil {
+		return fmt.Errorf("go-msmq: SetADsPath(%s) failed to resolve ADsPath: %w", path, err)
+	}
+
+	name, err := dest.FormatName()
+	if err != nil {
+		return fmt.Errorf("go-msmq: SetADsPath(%s) failed to resolve ADsPath: %w", path, err)
+	}
+
+	if name == "" || strings.Contains(name, ",") {
+		return fmt.Errorf("go-msmq: SetADsPath(%s) failed to resolve ADsPath: path does not reference a single queue", path)
+	}
+
+	return qi.SetFormatName(name)
 }
 
 // Authenticate returns authenticate.
 func (qi *QueueInfo) Authenticate() (bool, error) {
-	res, err := qi.dispatch.GetProperty("Authenticate")
+	res, err := getProperty(qi.dispatch, "Authenticate")
 	if err != nil {
 		return false, fmt.Errorf("go-msmq: failed to get Authenticate: %w", err)
 	}
+	defer res.Clear()
 
-	i := res.Value().(int32)
+	i, err := variantInt32(res, "Authenticate")
+	if err != nil {
+		return false, err
+	}
 	return i != 0, nil
 }
 
@@ -376,7 +1012,7 @@ func (qi *QueueInfo) SetAuthenticate(authenticate bool) error {
 		i = 1
 	}
 
-	_, err := qi.dispatch.PutProperty("Authenticate", i)
+	_, err := putProperty(qi.dispatch, "Authenticate", i)
 	if err != nil {
 		return fmt.Errorf("go-msmq: SetAuthenticate(%v) failed to set Authenticate: %w", i, err)
 	}
@@ -386,12 +1022,13 @@ func (qi *QueueInfo) SetAuthenticate(authenticate bool) error {
 
 // BasePriority returns the base priority.
 func (qi *QueueInfo) BasePriority() (int32, error) {
-	res, err := qi.dispatch.GetProperty("BasePriority")
+	res, err := getProperty(qi.dispatch, "BasePriority")
 	if err != nil {
 		return 0, fmt.Errorf("go-msmq: failed to get BasePriority: %w", err)
 	}
+	defer res.Clear()
 
-	return res.Value().(int32), nil
+	return variantInt32(res, "BasePriority")
 }
 
 // SetBasePriority sets base prioirty. Base priority specifies the base priority
@@ -401,7 +1038,7 @@ func (qi *QueueInfo) BasePriority() (int32, error) {
 //
 // See: https://docs.microsoft.com/en-us/previous-versions/windows/desktop/legacy/ms701847(v=vs.85)
 func (qi *QueueInfo) SetBasePriority(priority int32) error {
-	_, err := qi.dispatch.PutProperty("BasePriority", priority)
+	_, err := putProperty(qi.dispatch, "BasePriority", priority)
 	if err != nil {
 		return fmt.Errorf("go-msmq: SetBasePriority(%d) failed to set BasePriority: %w", priority, err)
 	}
@@ -412,22 +1049,24 @@ func (qi *QueueInfo) SetBasePriority(priority int32) error {
 // CreateTime returns when the public queue or private queue was created. The
 // the value is automatically converted to the local system time and system date.
 func (qi *QueueInfo) CreateTime() (time.Time, error) {
-	res, err := qi.dispatch.GetProperty("CreateTime")
+	res, err := getProperty(qi.dispatch, "CreateTime")
 	if err != nil {
 		return time.Time{}, fmt.Errorf("go-msmq: failed to get CreateTime: %w", err)
 	}
+	defer res.Clear()
 
-	return res.Value().(time.Time), nil
+	return variantTime(res, "CreateTime")
 }
 
 // FormatName returns the format name.
 func (qi *QueueInfo) FormatName() (string, error) {
-	res, err := qi.dispatch.GetProperty("FormatName")
+	res, err := getProperty(qi.dispatch, "FormatName")
 	if err != nil {
 		return "", fmt.Errorf("go-msmq: failed to get FormatName: %w", err)
 	}
+	defer res.Clear()
 
-	return res.Value().(string), nil
+	return variantString(res, "FormatName")
 }
 
 // SetFormatName sets the format name. Format names are used to reference public
@@ -435,7 +1074,7 @@ func (qi *QueueInfo) FormatName() (string, error) {
 //
 // See: https://docs.microsoft.com/en-us/previous-versions/windows/desktop/legacy/ms705703(v=vs.85)
 func (qi *QueueInfo) SetFormatName(name string) error {
-	_, err := qi.dispatch.PutProperty("FormatName", name)
+	_, err := putProperty(qi.dispatch, "FormatName", name)
 	if err != nil {
 		return fmt.Errorf("go-msmq: SetFormatName(%s) failed to set FormatName: %w", name, err)
 	}
@@ -445,34 +1084,40 @@ func (qi *QueueInfo) SetFormatName(name string) error {
 
 // IsTransactional indicates whether the queue supports transactions.
 func (qi *QueueInfo) IsTransactional() (bool, error) {
-	res, err := qi.dispatch.GetProperty("IsTransactional2")
+	res, err := getProperty(qi.dispatch, "IsTransactional2")
 	if err != nil {
 		return false, fmt.Errorf("go-msmq: failed to get IsTransactional2: %w", err)
 	}
+	defer res.Clear()
 
-	return res.Value().(bool), nil
+	return variantBool(res, "IsTransactional2")
 }
 
 // IsWorldReadable indicates whether all members of the Everyone group can
 // read the messages in the queue.
 func (qi *QueueInfo) IsWorldReadable() (bool, error) {
-	res, err := qi.dispatch.GetProperty("IsWorldReadable2")
+	res, err := getProperty(qi.dispatch, "IsWorldReadable2")
 	if err != nil {
 		return false, fmt.Errorf("go-msmq: failed to get IsWorldReadable: %w", err)
 	}
+	defer res.Clear()
 
-	return res.Value().(bool), nil
+	return variantBool(res, "IsWorldReadable2")
 }
 
 // Journal returns whether messages retrieved from the queue are stored in the
 // journal of the queue.
 func (qi *QueueInfo) Journal() (bool, error) {
-	res, err := qi.dispatch.GetProperty("Journal")
+	res, err := getProperty(qi.dispatch, "Journal")
 	if err != nil {
 		return false, fmt.Errorf("go-msmq: failed to get Journal: %w", err)
 	}
+	defer res.Clear()
 
-	i := res.Value().(int32)
+	i, err := variantInt32(res, "Journal")
+	if err != nil {
+		return false, err
+	}
 	return i != 0, nil
 }
 
@@ -486,7 +1131,7 @@ func (qi *QueueInfo) SetJournal(enabled bool) error {
 		i = 1
 	}
 
-	_, err := qi.dispatch.PutProperty("Journal", i)
+	_, err := putProperty(qi.dispatch, "Journal", i)
 	if err != nil {
 		return fmt.Errorf("go-msmq: SetJournal(%v) failed to set Journal: %w", enabled, err)
 	}
@@ -496,19 +1141,20 @@ func (qi *QueueInfo) SetJournal(enabled bool) error {
 
 // JournalQuota returns the maximum size (in kilobytes) of the queue journal.
 func (qi *QueueInfo) JournalQuota() (int32, error) {
-	res, err := qi.dispatch.GetProperty("JournalQuota")
+	res, err := getProperty(qi.dispatch, "JournalQuota")
 	if err != nil {
 		return 0, fmt.Errorf("go-msmq: failed to get JournalQuota: %w", err)
 	}
+	defer res.Clear()
 
-	return res.Value().(int32), nil
+	return variantInt32(res, "JournalQuota")
 }
 
 // SetJournalQuota specifies the maximum size (in kilobytes) of the queue journal.
 //
 // See: https://docs.microsoft.com/en-us/previous-versions/windows/desktop/legacy/ms700230(v=vs.85)
 func (qi *QueueInfo) SetJournalQuota(size int32) error {
-	_, err := qi.dispatch.PutProperty("JournalQuota", size)
+	_, err := putProperty(qi.dispatch, "JournalQuota", size)
 	if err != nil {
 		return fmt.Errorf("go-msmq: SetJournalQuota(%d) failed to set JournalQuota: %w", size, err)
 	}
@@ -518,19 +1164,20 @@ func (qi *QueueInfo) SetJournalQuota(size int32) error {
 
 // Label returns the description of the queue.
 func (qi *QueueInfo) Label() (string, error) {
-	res, err := qi.dispatch.GetProperty("Label")
+	res, err := getProperty(qi.dispatch, "Label")
 	if err != nil {
 		return "", fmt.Errorf("go-msmq: failed to get Label: %w", err)
 	}
+	defer res.Clear()
 
-	return res.Value().(string), nil
+	return variantString(res, "Label")
 }
 
 // SetLabel sets the description of the queue.
 //
 // See: https://docs.microsoft.com/en-us/previous-versions/windows/desktop/legacy/ms701520(v=vs.85)
 func (qi *QueueInfo) SetLabel(label string) error {
-	_, err := qi.dispatch.PutProperty("Label", label)
+	_, err := putProperty(qi.dispatch, "Label", label)
 	if err != nil {
 		return fmt.Errorf("go-msmq: SetLabel(%s) failed to set Label: %w", label, err)
 	}
@@ -541,22 +1188,24 @@ func (qi *QueueInfo) SetLabel(label string) error {
 // ModifyTime returns when the public queue or private queue was last updated. The
 // the value is automatically converted to the local system time and system date.
 func (qi *QueueInfo) ModifyTime() (time.Time, error) {
-	res, err := qi.dispatch.GetProperty("ModifyTime")
+	res, err := getProperty(qi.dispatch, "ModifyTime")
 	if err != nil {
 		return time.Time{}, fmt.Errorf("go-msmq: failed to get ModifyTime: %w", err)
 	}
+	defer res.Clear()
 
-	return res.Value().(time.Time), nil
+	return variantTime(res, "ModifyTime")
 }
 
 // MulticastAddress returns the multicast address associated with the queue.
 func (qi *QueueInfo) MulticastAddress() (string, error) {
-	res, err := qi.dispatch.GetProperty("MulticastAddress")
+	res, err := getProperty(qi.dispatch, "MulticastAddress")
 	if err != nil {
 		return "", fmt.Errorf("go-msmq: failed to get MulticastAddress: %w", err)
 	}
+	defer res.Clear()
 
-	return res.Value().(string), nil
+	return variantString(res, "MulticastAddress")
 }
 
 // SetMulticastAddress sets the multicast address of the queue. The value of
@@ -567,7 +1216,7 @@ func (qi *QueueInfo) MulticastAddress() (string, error) {
 //
 // See: https://docs.microsoft.com/en-us/previous-versions/windows/desktop/legacy/ms704978(v=vs.85)
 func (qi *QueueInfo) SetMulticastAddress(address string) error {
-	_, err := qi.dispatch.PutProperty("MulticastAddress", address)
+	_, err := putProperty(qi.dispatch, "MulticastAddress", address)
 	if err != nil {
 		return fmt.Errorf("go-msmq: SetMulticastAddress(%s) failed to set MulticastAddress: %w", address, err)
 	}
@@ -577,12 +1226,13 @@ func (qi *QueueInfo) SetMulticastAddress(address string) error {
 
 // PathName returns the path name.
 func (qi *QueueInfo) PathName() (string, error) {
-	res, err := qi.dispatch.GetProperty("PathName")
+	res, err := getProperty(qi.dispatch, "PathName")
 	if err != nil {
 		return "", fmt.Errorf("go-msmq: failed to get PathName: %w", err)
 	}
+	defer res.Clear()
 
-	return res.Value().(string), nil
+	return variantString(res, "PathName")
 }
 
 // SetPathName sets the path name which specifies the name of the computer where
@@ -596,7 +1246,7 @@ func (qi *QueueInfo) PathName() (string, error) {
 //
 // See: https://docs.microsoft.com/en-us/previous-versions/windows/desktop/legacy/ms706083(v=vs.85)
 func (qi *QueueInfo) SetPathName(name string) error {
-	_, err := qi.dispatch.PutProperty("PathName", name)
+	_, err := putProperty(qi.dispatch, "PathName", name)
 	if err != nil {
 		return fmt.Errorf("go-msmq: SetPathName(%s) failed to set PathName: %w", name, err)
 	}
@@ -606,22 +1256,25 @@ func (qi *QueueInfo) SetPathName(name string) error {
 
 // PathNameDNS returns the DNS path name of the queue.
 func (qi *QueueInfo) PathNameDNS() (string, error) {
-	res, err := qi.dispatch.GetProperty("PathNameDNS")
+	res, err := getProperty(qi.dispatch, "PathNameDNS")
 	if err != nil {
 		return "", fmt.Errorf("go-msmq: failed to get PathNameDNS: %w", err)
 	}
+	defer res.Clear()
 
-	return res.Value().(string), nil
+	return variantString(res, "PathNameDNS")
 }
 
 // PrivLevel returns the privacy level.
 func (qi *QueueInfo) PrivacyLevel() (PrivLevel, error) {
-	res, err := qi.dispatch.GetProperty("PrivLevel")
+	res, err := getProperty(qi.dispatch, "PrivLevel")
 	if err != nil {
 		return 0, fmt.Errorf("go-msmq: failed to get PrivLevel: %w", err)
 	}
+	defer res.Clear()
 
-	return PrivLevel(res.Value().(int32)), nil
+	v, err := variantInt32(res, "PrivLevel")
+	return PrivLevel(v), err
 }
 
 // SetPrivacyLevel sets the privacy level of the queue. The default value is
@@ -632,7 +1285,7 @@ func (qi *QueueInfo) PrivacyLevel() (PrivLevel, error) {
 //
 // See: https://docs.microsoft.com/en-us/previous-versions/windows/desktop/legacy/ms701989(v=vs.85)
 func (qi *QueueInfo) SetPrivacyLevel(level PrivLevel) error {
-	_, err := qi.dispatch.PutProperty("PrivLevel", int(level))
+	_, err := putProperty(qi.dispatch, "PrivLevel", int(level))
 	if err != nil {
 		return fmt.Errorf("go-msmq: SetPrivacyLevel(%v) failed to set PrivLevel: %w", level, err)
 	}
@@ -658,22 +1311,24 @@ const (
 // QueueGUID returns GUID of the public queue in the form:
 //   {12345678-1234-1234-1234-123456789ABC}
 func (qi *QueueInfo) QueueGUID() (string, error) {
-	res, err := qi.dispatch.GetProperty("QueueGuid")
+	res, err := getProperty(qi.dispatch, "QueueGuid")
 	if err != nil {
 		return "", fmt.Errorf("go-msmq: failed to get QueueGuid : %w", err)
 	}
+	defer res.Clear()
 
-	return res.Value().(string), nil
+	return variantString(res, "QueueGuid")
 }
 
 // Quota returns the maximum size (in kilobytes) of the queue.
 func (qi *QueueInfo) Quota() (int32, error) {
-	res, err := qi.dispatch.GetProperty("Quota")
+	res, err := getProperty(qi.dispatch, "Quota")
 	if err != nil {
 		return 0, fmt.Errorf("go-msmq: failed to get Quota: %w", err)
 	}
+	defer res.Clear()
 
-	return res.Value().(int32), nil
+	return variantInt32(res, "Quota")
 }
 
 // SetQuota specifies the maximum size (in kilobytes) of the queue. The default
@@ -685,7 +1340,7 @@ func (qi *QueueInfo) Quota() (int32, error) {
 //
 // See: https://docs.microsoft.com/en-us/previous-versions/windows/desktop/legacy/ms707016(v=vs.85)
 func (qi *QueueInfo) SetQuota(size int32) error {
-	_, err := qi.dispatch.PutProperty("Quota", size)
+	_, err := putProperty(qi.dispatch, "Quota", size)
 	if err != nil {
 		return fmt.Errorf("go-msmq: SetQuota(%d) failed to set Quota: %w", size, err)
 	}
@@ -697,12 +1352,13 @@ func (qi *QueueInfo) SetQuota(size int32) error {
 // by the queue in the form:
 //   {12345678-1234-1234-1234-123456789ABC}
 func (qi *QueueInfo) ServiceTypeGUID() (string, error) {
-	res, err := qi.dispatch.GetProperty("ServiceTypeGuid")
+	res, err := getProperty(qi.dispatch, "ServiceTypeGuid")
 	if err != nil {
 		return "", fmt.Errorf("go-msmq: failed to get ServiceTypeGUID: %w", err)
 	}
+	defer res.Clear()
 
-	return res.Value().(string), nil
+	return variantString(res, "ServiceTypeGuid")
 }
 
 // SetServiceTypeGUID specifies the type of service provided by the queue. It is
@@ -711,7 +1367,7 @@ func (qi *QueueInfo) ServiceTypeGUID() (string, error) {
 //
 // See: https://docs.microsoft.com/en-us/previous-versions/windows/desktop/legacy/ms703206(v=vs.85)
 func (qi *QueueInfo) SetServiceTypeGUID(guid string) error {
-	_, err := qi.dispatch.PutProperty("ServiceTypeGuid", guid)
+	_, err := putProperty(qi.dispatch, "ServiceTypeGuid", guid)
 	if err != nil {
 		return fmt.Errorf("go-msmq: SetServiceTypeGUID(%s) failed to set ServiceTypeGuid: %w", guid, err)
 	}
@@ -0,0 +1,96 @@
+package msmq
+
+import (
+	"fmt"
+	"iter"
+
+	"github.com/go-ole/go-ole"
+)
+
+// QueueInfos is a collection of QueueInfo returned by a directory lookup such
+// as Query.LookupQueue. The collection is traversed with a cursor that starts
+// before the first QueueInfo.
+//
+// See: https://docs.microsoft.com/en-us/previous-versions/windows/desktop/legacy/ms705189(v=vs.85)
+type QueueInfos struct {
+	dispatch *ole.IDispatch
+	err      error
+}
+
+// Next returns the next QueueInfo in the collection and moves the cursor to
+// it. A nil QueueInfo is returned when the end of the collection is reached.
+//
+// See: https://docs.microsoft.com/en-us/previous-versions/windows/desktop/legacy/ms706252(v=vs.85)
+func (qis *QueueInfos) Next() (*QueueInfo, error) {
+	res, err := callMethod(qis.dispatch, "Next")
+	if err != nil {
+		return nil, fmt.Errorf("go-msmq: Next() failed to get next QueueInfo: %w", err)
+	}
+
+	dispatch := res.ToIDispatch()
+	if dispatch == nil {
+		return nil, nil
+	}
+
+	return &QueueInfo{
+		dispatch: track(dispatch, "MSMQ.MSMQQueueInfo"),
+	}, nil
+}
+
+// Reset moves the cursor back to the start of the collection.
+//
+// See: https://docs.microsoft.com/en-us/previous-versions/windows/desktop/legacy/ms700210(v=vs.85)
+func (qis *QueueInfos) Reset() error {
+	_, err := callMethod(qis.dispatch, "Reset")
+	if err != nil {
+		return fmt.Errorf("go-msmq: Reset() failed to reset the position of the cursor: %w", err)
+	}
+
+	return nil
+}
+
+// All returns an iterator over the QueueInfo in the collection, starting from
+// the beginning of the collection:
+//   for queueInfo := range queueInfos.All() {
+//       ...
+//   }
+//   if err := queueInfos.Err(); err != nil {
+//       ...
+//   }
+// Iteration stops at the first error, which is reported by Err.
+func (qis *QueueInfos) All() iter.Seq[*QueueInfo] {
+	return func(yield func(*QueueInfo) bool) {
+		qis.err = qis.Reset()
+		if qis.err != nil {
+			return
+		}
+
+		for {
+			var qi *QueueInfo
+			qi, qis.err = qis.Next()
+			if qis.err != nil || qi == nil {
+				return
+			}
+
+			if !yield(qi) {
+				return
+			}
+		}
+	}
+}
+
+// Err returns the error, if any, that stopped the last iteration by All.
+func (qis *QueueInfos) Err() error {
+	return qis.err
+}
+
+// Close releases the underlying collection. The QueueInfo returned from the
+// collection remain usable.
+func (qis *QueueInfos) Close() error {
+	if qis.dispatch != nil {
+		release(qis.dispatch)
+		qis.dispatch = nil
+	}
+
+	return nil
+}
@@ -0,0 +1,133 @@
+package msmq
+
+import (
+	"fmt"
+
+	"github.com/go-ole/go-ole"
+)
+
+// cursorMethods are the methods that use the cursor of the queue. They are
+// not called again after the queue is reopened, since reopening the queue
+// resets its cursor.
+var cursorMethods = map[string]bool{
+	"PeekCurrent":    true,
+	"PeekNext":       true,
+	"ReceiveCurrent": true,
+	"Reset":          true,
+}
+
+// SetAutoReopen configures whether operations on the queue reopen it when
+// MSMQ reports that its handle is no longer valid, as happens after the queue
+// is deleted and recreated or the Message Queuing service restarts, so that
+// long-lived consumers and producers survive them:
+//   queue, err := msmq.Open(name, msmq.Options{AutoReopen: true})
+// The queue is reopened with the QueueInfo and modes it was opened with, and
+// the failed operation is tried again once. Operations that use the cursor of
+// the queue, such as PeekNext, are not tried again since reopening the queue
+// resets its cursor. The default is false, which leaves the queue not open.
+func (q *Queue) SetAutoReopen(enabled bool) {
+	q.autoReopen.Store(enabled)
+}
+
+// Reopen closes the handle of the queue and opens the queue again with the
+// QueueInfo and modes it was opened with. A queue that was closed with Close
+// cannot be reopened.
+func (q *Queue) Reopen() error {
+	q.mu.RLock()
+	stale := q.dispatch
+	q.mu.RUnlock()
+
+	if stale == nil {
+		return fmt.Errorf("go-msmq: Reopen() failed to reopen queue: %w", q.notOpen())
+	}
+
+	return q.reopen(stale)
+}
+
+// reopen replaces stale, the dispatch of the queue, with the dispatch of the
+// queue opened again. It does nothing if the dispatch was already replaced or
+// released, for example by another goroutine.
+func (q *Queue) reopen(stale *ole.IDispatch) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.dispatch != stale {
+		return nil
+	}
+
+	reopened, err := q.qi.Open(q.accessMode, q.shareMode)
+	if err != nil {
+		return fmt.Errorf("go-msmq: Reopen() failed to reopen queue: %w", err)
+	}
+
+	// Closing a stale handle fails, which is of no consequence.
+	callMethod(stale, "Close")
+	release(stale)
+	q.dispatch = reopened.dispatch
+	q.open.Store(true)
+	return nil
+}
+
+// checkOpen returns nil if the queue is open. A queue whose handle is no
+// longer valid is first reopened if SetAutoReopen is enabled.
+func (q *Queue) checkOpen() error {
+	if q.open.Load() {
+		return nil
+	}
+
+	if q.autoReopen.Load() {
+		q.mu.RLock()
+		stale := q.dispatch
+		q.mu.RUnlock()
+
+		if stale != nil {
+			return q.reopen(stale)
+		}
+	}
+
+	return q.notOpen()
+}
+
+// do calls fn with the dispatch of the queue. If fn reports that the handle
+// of the queue is no longer valid, the queue is marked as not open, or, if
+// SetAutoReopen is enabled, reopened and fn is called again if retry is true.
+func (q *Queue) do(retry bool, fn func(dispatch *ole.IDispatch) (*ole.VARIANT, error)) (*ole.VARIANT, error) {
+	res, stale, err := q.doOnce(fn)
+	if stale == nil || !q.autoReopen.Load() {
+		return res, err
+	}
+
+	if rerr := q.reopen(stale); rerr != nil {
+		return nil, fmt.Errorf("%w; %w", err, rerr)
+	}
+	if !retry {
+		return nil, err
+	}
+
+	res, _, err = q.doOnce(fn)
+	return res, err
+}
+
+// doOnce calls fn with the dispatch of the queue, and returns the dispatch if
+// fn reports that its handle is no longer valid.
+func (q *Queue) doOnce(fn func(dispatch *ole.IDispatch) (*ole.VARIANT, error)) (*ole.VARIANT, *ole.IDispatch, error) {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	if q.dispatch == nil {
+		return nil, nil, q.notOpen()
+	}
+
+	res, err := fn(q.dispatch)
+	if err != nil {
+		switch hresult(err) {
+		case mqErrorInvalidHandle, mqErrorStaleHandle, mqErrorQueueDeleted:
+			q.open.Store(false)
+			return nil, q.dispatch, err
+		}
+
+		return nil, nil, err
+	}
+
+	return res, nil, nil
+}
@@ -0,0 +1,284 @@
+package msmq
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-ole/go-ole"
+)
+
+// Replay re-sends the messages in source to their original destination queue
+// or to an alternate destination. source is typically the journal of a queue
+// opened with QueueInfo.OpenJournal, or a dead-letter queue, and must be
+// opened with Peek or Receive AccessMode. Messages are not removed from
+// source.
+//
+// Replay is intended for recovering from failures such as a consumer bug that
+// discarded messages:
+//   journal, err := queueInfo.OpenJournal(msmq.Peek, msmq.DenyNone)
+//   ...
+//   n, err := msmq.Replay(journal, msmq.ReplayWithTimeWindow(from, to))
+//
+// The body, label, application-specific information, correlation identifier,
+// extension and priority of each message are copied to the new message. The
+// number of replayed messages is returned.
+func Replay(source *Queue, opts ...ReplayOption) (int, error) {
+	options := &replayOptions{
+		level: MTS,
+	}
+	for _, o := range opts {
+		o.set(options)
+	}
+
+	r := &replayer{
+		options: options,
+		queues:  map[string]*Queue{},
+	}
+	defer r.close()
+
+	peekOpts := []PeekByLookupIDOption{
+		PeekByLookupIDWithWantDestinationQueue(options.destination == nil),
+	}
+
+	n := 0
+	id := FirstLookupID
+	for {
+		msg, err := source.PeekNextByLookupID(id, peekOpts...)
+		if err != nil {
+			return n, fmt.Errorf("go-msmq: Replay() failed to replay messages: %w", err)
+		}
+
+		if msg.dispatch == nil {
+			return n, nil
+		}
+
+		id, err = r.replay(&msg)
+		msg.release()
+		if err == errSkipped {
+			continue
+		}
+
+		if err != nil {
+			return n, fmt.Errorf("go-msmq: Replay() failed to replay messages: %w", err)
+		}
+
+		n++
+	}
+}
+
+// ReplayOption represents an option to replay messages.
+type ReplayOption struct {
+	set func(opts *replayOptions)
+}
+
+// replayOptions contains all the options to replay messages.
+type replayOptions struct {
+	from        time.Time
+	to          time.Time
+	destination *Queue
+	level       TransactionLevel
+}
+
+// ReplayWithTimeWindow returns a ReplayOption that only replays messages that
+// arrived at their destination queue at or after from and before to. A zero
+// value leaves the respective side of the window open.
+//
+// The default is to replay all messages.
+func ReplayWithTimeWindow(from, to time.Time) ReplayOption {
+	return ReplayOption{
+		set: func(opts *replayOptions) {
+			opts.from = from
+			opts.to = to
+		},
+	}
+}
+
+// ReplayWithDestination returns a ReplayOption that configures replaying
+// messages to the specified queue instead of their original destination. The
+// queue must be opened with Send AccessMode.
+func ReplayWithDestination(queue *Queue) ReplayOption {
+	return ReplayOption{
+		set: func(opts *replayOptions) {
+			opts.destination = queue
+		},
+	}
+}
+
+// ReplayWithTransaction returns a ReplayOption that configures sending the
+// replayed messages with the specified level value.
+//
+// The default is MTS.
+func ReplayWithTransaction(level TransactionLevel) ReplayOption {
+	return ReplayOption{
+		set: func(opts *replayOptions) {
+			opts.level = level
+		},
+	}
+}
+
+// errSkipped is returned by replayer.replay when a message falls outside of
+// the time window.
+var errSkipped = errors.New("go-msmq: message skipped")
+
+// replayer re-sends messages and keeps track of the original destination
+// queues it opened.
+type replayer struct {
+	options *replayOptions
+	queues  map[string]*Queue
+}
+
+// replay re-sends msg and returns its lookup identifier.
+func (r *replayer) replay(msg *Message) (LookupID, error) {
+	id, err := msg.LookupID()
+	if err != nil {
+		return 0, err
+	}
+
+	arrived, err := msg.ArrivedTime()
+	if err != nil {
+		return id, err
+	}
+
+	if (!r.options.from.IsZero() && arrived.Before(r.options.from)) ||
+		(!r.options.to.IsZero() && !arrived.Before(r.options.to)) {
+		return id, errSkipped
+	}
+
+	dest := r.options.destination
+	if dest == nil {
+		dest, err = r.destination(msg)
+		if err != nil {
+			return id, err
+		}
+	}
+
+	cp, err := copyMessage(msg)
+	if err != nil {
+		return id, err
+	}
+	defer cp.release()
+
+	return id, cp.Send(dest, SendWithTransaction(r.options.level))
+}
+
+// destination returns the original destination queue of msg opened for
+// sending.
+func (r *replayer) destination(msg *Message) (*Queue, error) {
+	qi, err := msg.DestinationQueueInfo()
+	if err != nil {
+		return nil, err
+	}
+
+	name, err := qi.FormatName()
+	if err != nil {
+		qi.Close()
+		return nil, err
+	}
+
+	if q, ok := r.queues[name]; ok {
+		qi.Close()
+		return q, nil
+	}
+
+	q, err := qi.Open(Send, DenyNone)
+	if err != nil {
+		qi.Close()
+		return nil, err
+	}
+
+	q.onClose = qi.Close
+	r.queues[name] = q
+	return q, nil
+}
+
+// close closes the destination queues opened by replayer.
+func (r *replayer) close() {
+	for _, q := range r.queues {
+		q.Close()
+	}
+}
+
+// copyMessage returns a new message with the body, label, application-specific
+// information, correlation identifier, extension and priority of msg.
+func copyMessage(msg *Message) (Message, error) {
+	cp, err := NewMessage()
+	if err != nil {
+		return Message{}, err
+	}
+
+	err = copyMessageProperties(msg, &cp)
+	if err != nil {
+		cp.release()
+		return Message{}, err
+	}
+
+	return cp, nil
+}
+
+// copyMessageProperties copies the properties of src to dst.
+func copyMessageProperties(src, dst *Message) error {
+	body, err := getProperty(src.dispatch, "Body")
+	if err != nil {
+		return err
+	}
+	defer body.Clear()
+
+	if body.VT&ole.VT_ARRAY != 0 {
+		err = dst.SetBodyBytes(body.ToArray().ToByteArray())
+	} else if s, ok := body.Value().(string); ok {
+		err = dst.SetBody(s)
+	}
+	if err != nil {
+		return err
+	}
+
+	label, err := src.Label()
+	if err != nil {
+		return err
+	}
+
+	err = dst.SetLabel(label)
+	if err != nil {
+		return err
+	}
+
+	appSpecific, err := src.AppSpecific()
+	if err != nil {
+		return err
+	}
+
+	err = dst.SetAppSpecific(appSpecific)
+	if err != nil {
+		return err
+	}
+
+	correlationID, err := src.CorrelationID()
+	if err != nil {
+		return err
+	}
+
+	err = dst.SetCorrelationID(correlationID)
+	if err != nil {
+		return err
+	}
+
+	extension, err := src.Extension()
+	if err != nil {
+		return err
+	}
+
+	if len(extension) > 0 {
+		err = dst.SetExtension(extension)
+		if err != nil {
+			return err
+		}
+	}
+
+	priority, err := src.Priority()
+	if err != nil {
+		return err
+	}
+
+	return dst.SetPriority(priority)
+}
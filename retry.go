@@ -0,0 +1,136 @@
+package msmq
+
+import (
+	"errors"
+	"math/rand"
+	"time"
+
+	"github.com/go-ole/go-ole"
+)
+
+// RetryPolicy retries operations that fail with transient errors, waiting
+// with exponential backoff between attempts. It can be attached to Send,
+// Receive and Open:
+//   policy := &msmq.RetryPolicy{MaxAttempts: 5, Jitter: 0.2}
+//   err := msg.Send(queue, msmq.SendWithRetry(policy))
+// The zero value of each field selects its default.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts, including the first.
+	// The default is 3.
+	MaxAttempts int
+
+	// InitialBackoff is the wait before the second attempt. The default is
+	// 100 milliseconds.
+	InitialBackoff time.Duration
+
+	// MaxBackoff caps the wait between attempts. The default is 10 seconds.
+	MaxBackoff time.Duration
+
+	// Multiplier is the factor by which the wait grows after each attempt.
+	// The default is 2.
+	Multiplier float64
+
+	// Jitter randomizes each wait by up to the given fraction of it, from 0
+	// through 1, so that clients failing together do not retry in lockstep.
+	// The default is no jitter.
+	Jitter float64
+
+	// Retryable reports whether an error is worth retrying. The default is
+	// IsTransient.
+	Retryable func(error) bool
+}
+
+// IsTransient reports whether err is an MSMQ error that is likely to go away
+// on its own, such as the queue manager or a remote computer being
+// unavailable, or a queue handle that became stale when the queue was
+// recreated.
+func IsTransient(err error) bool {
+	for _, target := range []error{
+		ErrServiceNotAvailable,
+		ErrRemoteMachineNotAvailable,
+		ErrNoDS,
+		ErrQueueDeleted,
+		ErrStaleHandle,
+		ErrInsufficientResources,
+	} {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Do calls fn until it succeeds, returns an error that is not retryable, or
+// the attempts are exhausted, and returns the last error. A nil policy calls
+// fn once.
+func (p *RetryPolicy) Do(fn func() error) error {
+	if p == nil {
+		return fn()
+	}
+
+	attempts := p.MaxAttempts
+	if attempts <= 0 {
+		attempts = 3
+	}
+	retryable := p.Retryable
+	if retryable == nil {
+		retryable = IsTransient
+	}
+
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = fn()
+		if err == nil || attempt >= attempts || !retryable(err) {
+			return err
+		}
+
+		time.Sleep(p.backoff(attempt))
+	}
+}
+
+// backoff returns the wait after the specified failed attempt. A nil policy
+// waits with the default backoff.
+func (p *RetryPolicy) backoff(attempt int) time.Duration {
+	if p == nil {
+		p = &RetryPolicy{}
+	}
+
+	d := p.InitialBackoff
+	if d <= 0 {
+		d = 100 * time.Millisecond
+	}
+	max := p.MaxBackoff
+	if max <= 0 {
+		max = 10 * time.Second
+	}
+	multiplier := p.Multiplier
+	if multiplier <= 0 {
+		multiplier = 2
+	}
+
+	wait := float64(d)
+	for i := 1; i < attempt && wait < float64(max); i++ {
+		wait *= multiplier
+	}
+	if wait > float64(max) {
+		wait = float64(max)
+	}
+	if p.Jitter > 0 {
+		wait += wait * p.Jitter * (2*rand.Float64() - 1)
+	}
+
+	return time.Duration(wait)
+}
+
+// call calls fn under the policy and returns its result.
+func (p *RetryPolicy) call(fn func() (*ole.VARIANT, error)) (*ole.VARIANT, error) {
+	var res *ole.VARIANT
+	err := p.Do(func() error {
+		var err error
+		res, err = fn()
+		return err
+	})
+
+	return res, err
+}
@@ -0,0 +1,112 @@
+package msmq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+)
+
+// ErrNoRoute is returned by Router.Handle when no handler matches a message
+// and the Router has no default handler.
+var ErrNoRoute = errors.New("go-msmq: no handler matches message")
+
+// Router dispatches each message to the handler registered for its Label or
+// AppSpecific value, so that a single queue can carry several message types:
+//   router := msmq.NewRouter()
+//   router.HandleLabel("order.*", handleOrder)
+//   router.HandleAppSpecific(42, handleInvoice)
+//   router.HandleDefault(handleUnknown)
+//   err := consumer.Start(ctx, router.Handle)
+//
+// Routes are matched in the order they were registered and the first match
+// wins. Handlers must be registered before the Router handles messages.
+type Router struct {
+	routes   []route
+	fallback Handler
+}
+
+// route is a handler registered with a Router.
+type route struct {
+	// label is the Label pattern of the route, if byLabel is true, and
+	// appSpecific its AppSpecific value otherwise.
+	byLabel     bool
+	label       string
+	appSpecific int32
+
+	handler Handler
+}
+
+// NewRouter returns a pointer to a Router without routes.
+func NewRouter() *Router {
+	return &Router{}
+}
+
+// HandleLabel registers handler for the messages whose Label matches
+// pattern. The pattern syntax is that of path.Match, so "order.*" matches
+// "order.created" and "order.cancelled". An error is returned if pattern is
+// malformed.
+func (r *Router) HandleLabel(pattern string, handler Handler) error {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return fmt.Errorf("go-msmq: HandleLabel(%s) failed to register handler: %w", pattern, err)
+	}
+
+	r.routes = append(r.routes, route{byLabel: true, label: pattern, handler: handler})
+	return nil
+}
+
+// HandleAppSpecific registers handler for the messages whose AppSpecific
+// value is v.
+func (r *Router) HandleAppSpecific(v int32, handler Handler) {
+	r.routes = append(r.routes, route{appSpecific: v, handler: handler})
+}
+
+// HandleDefault registers handler for the messages that match no route.
+func (r *Router) HandleDefault(handler Handler) {
+	r.fallback = handler
+}
+
+// Handle dispatches msg to the handler of the first route that matches it,
+// or to the default handler. ErrNoRoute is returned if no handler matches.
+// Handle is a Handler, so a Router can be passed to Consumer.Start.
+func (r *Router) Handle(ctx context.Context, msg Message) error {
+	var (
+		label, appSpecific       bool
+		labelValue               string
+		appSpecificValue         int32
+		labelErr, appSpecificErr error
+	)
+
+	for _, rt := range r.routes {
+		if rt.byLabel {
+			if !label {
+				label = true
+				labelValue, labelErr = msg.Label()
+			}
+			if labelErr != nil {
+				return fmt.Errorf("go-msmq: Handle() failed to route message: %w", labelErr)
+			}
+			if ok, _ := path.Match(rt.label, labelValue); ok {
+				return rt.handler(ctx, msg)
+			}
+			continue
+		}
+
+		if !appSpecific {
+			appSpecific = true
+			appSpecificValue, appSpecificErr = msg.AppSpecific()
+		}
+		if appSpecificErr != nil {
+			return fmt.Errorf("go-msmq: Handle() failed to route message: %w", appSpecificErr)
+		}
+		if rt.appSpecific == appSpecificValue {
+			return rt.handler(ctx, msg)
+		}
+	}
+
+	if r.fallback != nil {
+		return r.fallback(ctx, msg)
+	}
+
+	return ErrNoRoute
+}
@@ -0,0 +1,382 @@
+package msmq
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"hash/fnv"
+	"sync"
+	"time"
+)
+
+// SagaState is the state of a saga: a long-running workflow made of several
+// related messages.
+type SagaState struct {
+	// Key identifies the saga. Every message of the saga has the same key.
+	Key string
+
+	// Data is the application state of the saga, which the handlers update.
+	Data []byte
+
+	// Steps is the number of messages handled by the saga so far.
+	Steps int
+
+	// Started is when the first message of the saga was handled.
+	Started time.Time
+
+	// Updated is when the last message of the saga was handled.
+	Updated time.Time
+
+	// Deadline is when the saga times out, or the zero time if it never
+	// does.
+	Deadline time.Time
+}
+
+// SagaStore keeps the state of the active sagas. Implementations must be safe
+// for concurrent use.
+type SagaStore interface {
+	// Load returns the state of the saga key, or nil if there is none.
+	Load(ctx context.Context, key string) (*SagaState, error)
+
+	// Save creates or replaces the state of a saga.
+	Save(ctx context.Context, state *SagaState) error
+
+	// Delete deletes the state of the saga key.
+	Delete(ctx context.Context, key string) error
+
+	// Expired returns the states whose deadline is before t.
+	Expired(ctx context.Context, t time.Time) ([]*SagaState, error)
+}
+
+// SagaStepFunc handles a message of a saga and updates its state. It reports
+// whether the saga is complete.
+type SagaStepFunc func(ctx context.Context, state *SagaState, msg Message) (done bool, err error)
+
+// SagaHandlers are the lifecycle handlers of a Saga.
+type SagaHandlers struct {
+	// Started handles the first message of a saga. Messages whose key has
+	// no saga are dropped if it is nil.
+	Started SagaStepFunc
+
+	// Step handles the other messages of a saga. It is required.
+	Step SagaStepFunc
+
+	// Completed is called once a handler reports that the saga is
+	// complete, before its state is deleted. It is optional.
+	Completed func(ctx context.Context, state *SagaState) error
+
+	// TimedOut is called when the deadline of a saga passes before it
+	// completes, before its state is deleted. It is optional.
+	TimedOut func(ctx context.Context, state *SagaState) error
+}
+
+// Saga groups related messages by key and drives long-running workflows
+// made of several messages, keeping the state of each workflow in a
+// SagaStore:
+//   saga, err := msmq.NewSaga(store, msmq.SagaHandlers{
+//       Started:   orderPlaced,
+//       Step:      paymentOrShipment,
+//       Completed: orderFulfilled,
+//       TimedOut:  orderStuck,
+//   }, msmq.SagaWithTimeout(24*time.Hour))
+//   ...
+//   go saga.Run(ctx)
+//   err = consumer.Start(ctx, saga.Handle)
+//
+// By default the key of a message is its CorrelationID, or its ID if it has
+// none, so that a saga started by a message continues with the replies
+// correlated to it. The messages of a saga are handled one at a time within
+// the process; a failed handler leaves the state unchanged and returns the
+// error to the Consumer, which retries the message.
+type Saga struct {
+	store    SagaStore
+	handlers SagaHandlers
+	options  *sagaOptions
+
+	// locks serialize the messages of a saga, by hash of its key.
+	locks [64]sync.Mutex
+}
+
+// NewSaga returns a pointer to a Saga keeping its state in store, configured
+// by the options. handlers must have a Step handler.
+func NewSaga(store SagaStore, handlers SagaHandlers, opts ...SagaOption) (*Saga, error) {
+	if handlers.Step == nil {
+		return nil, fmt.Errorf("go-msmq: NewSaga() failed to create saga: %w: nil Step handler", ErrNotInitialized)
+	}
+
+	options := &sagaOptions{
+		key:      SagaKey,
+		interval: 10 * time.Second,
+	}
+	for _, o := range opts {
+		o.set(options)
+	}
+	if options.interval <= 0 {
+		return nil, fmt.Errorf("go-msmq: NewSaga() failed to create saga: %w", invalidOption("SagaWithInterval", options.interval, "must be positive"))
+	}
+
+	return &Saga{
+		store:    store,
+		handlers: handlers,
+		options:  options,
+	}, nil
+}
+
+// SagaKey returns the correlation identifier of msg in hexadecimal, or its
+// ID if its correlation identifier is not set. It is the default key of a
+// Saga.
+func SagaKey(msg Message) (string, error) {
+	id, err := msg.CorrelationID()
+	if err != nil {
+		return "", err
+	}
+	if len(bytes.Trim(id, "\x00")) == 0 {
+		return MessageIDKey(msg)
+	}
+
+	return hex.EncodeToString(id), nil
+}
+
+// Handle is the Handler of the Saga. It passes msg to the Started or Step
+// handler of its saga and saves the updated state.
+func (s *Saga) Handle(ctx context.Context, msg Message) error {
+	key, err := s.options.key(msg)
+	if err != nil {
+		return fmt.Errorf("go-msmq: Handle() failed to get saga key: %w", err)
+	}
+
+	mu := s.lock(key)
+	mu.Lock()
+	defer mu.Unlock()
+
+	state, err := s.store.Load(ctx, key)
+	if err != nil {
+		return fmt.Errorf("go-msmq: Handle() failed to load saga %s: %w", key, err)
+	}
+
+	now := time.Now()
+	step := s.handlers.Step
+	if state == nil {
+		if s.handlers.Started == nil {
+			return nil
+		}
+
+		step = s.handlers.Started
+		state = &SagaState{Key: key, Started: now}
+		if s.options.timeout > 0 {
+			state.Deadline = now.Add(s.options.timeout)
+		}
+	}
+
+	done, err := step(ctx, state, msg)
+	if err != nil {
+		return err
+	}
+	state.Steps++
+	state.Updated = now
+
+	if !done {
+		if err := s.store.Save(ctx, state); err != nil {
+			return fmt.Errorf("go-msmq: Handle() failed to save saga %s: %w", key, err)
+		}
+		return nil
+	}
+
+	if s.handlers.Completed != nil {
+		if err := s.handlers.Completed(ctx, state); err != nil {
+			return err
+		}
+	}
+	if err := s.store.Delete(ctx, key); err != nil {
+		return fmt.Errorf("go-msmq: Handle() failed to delete saga %s: %w", key, err)
+	}
+
+	return nil
+}
+
+// Run times out the sagas whose deadline passed every interval until ctx is
+// done, and returns the error of ctx. Errors are passed to the callback set
+// with SagaWithOnError and do not stop Run.
+func (s *Saga) Run(ctx context.Context) error {
+	ticker := time.NewTicker(s.options.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.Expire(ctx); err != nil && s.options.onError != nil {
+			s.options.onError(err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("go-msmq: Run() failed to time out sagas: %w", ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// Expire times out the sagas whose deadline passed: it calls the TimedOut
+// handler with their state and deletes it.
+func (s *Saga) Expire(ctx context.Context) error {
+	states, err := s.store.Expired(ctx, time.Now())
+	if err != nil {
+		return fmt.Errorf("go-msmq: Expire() failed to list expired sagas: %w", err)
+	}
+
+	for _, state := range states {
+		if err := s.expire(ctx, state.Key); err != nil {
+			return fmt.Errorf("go-msmq: Expire() failed to time out saga %s: %w", state.Key, err)
+		}
+	}
+
+	return nil
+}
+
+// expire times out the saga key, unless it completed or was extended
+// concurrently.
+func (s *Saga) expire(ctx context.Context, key string) error {
+	mu := s.lock(key)
+	mu.Lock()
+	defer mu.Unlock()
+
+	state, err := s.store.Load(ctx, key)
+	if err != nil || state == nil || state.Deadline.IsZero() || time.Now().Before(state.Deadline) {
+		return err
+	}
+
+	if s.handlers.TimedOut != nil {
+		if err := s.handlers.TimedOut(ctx, state); err != nil {
+			return err
+		}
+	}
+
+	return s.store.Delete(ctx, key)
+}
+
+// lock returns the mutex serializing the saga key.
+func (s *Saga) lock(key string) *sync.Mutex {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return &s.locks[h.Sum32()%uint32(len(s.locks))]
+}
+
+// SagaOption represents an option to configure a Saga.
+type SagaOption struct {
+	set func(opts *sagaOptions)
+}
+
+// sagaOptions contains all the options to configure a Saga.
+type sagaOptions struct {
+	key      func(Message) (string, error)
+	timeout  time.Duration
+	interval time.Duration
+	onError  func(err error)
+}
+
+// SagaWithKey returns a SagaOption that configures how the key grouping the
+// messages of a saga is extracted, for example from the body. The default is
+// SagaKey.
+func SagaWithKey(fn func(Message) (string, error)) SagaOption {
+	return SagaOption{
+		set: func(opts *sagaOptions) {
+			opts.key = fn
+		},
+	}
+}
+
+// SagaWithTimeout returns a SagaOption that configures how long a saga has
+// to complete after its first message. Handlers may move the Deadline of a
+// state. The default is no timeout.
+func SagaWithTimeout(timeout time.Duration) SagaOption {
+	return SagaOption{
+		set: func(opts *sagaOptions) {
+			opts.timeout = timeout
+		},
+	}
+}
+
+// SagaWithInterval returns a SagaOption that configures how often Run looks
+// for sagas that timed out. The interval must be positive. The default is 10
+// seconds.
+func SagaWithInterval(interval time.Duration) SagaOption {
+	return SagaOption{
+		set: func(opts *sagaOptions) {
+			opts.interval = interval
+		},
+	}
+}
+
+// SagaWithOnError returns a SagaOption that configures the callback invoked
+// by Run when sagas cannot be timed out. The default is to drop the error.
+func SagaWithOnError(fn func(err error)) SagaOption {
+	return SagaOption{
+		set: func(opts *sagaOptions) {
+			opts.onError = fn
+		},
+	}
+}
+
+// MemorySagaStore is a SagaStore that keeps the states in memory, so they
+// are lost when the process exits. It suits tests and sagas that can be
+// restarted.
+type MemorySagaStore struct {
+	mu     sync.Mutex
+	states map[string]SagaState
+}
+
+// NewMemorySagaStore returns a pointer to an empty MemorySagaStore.
+func NewMemorySagaStore() *MemorySagaStore {
+	return &MemorySagaStore{
+		states: make(map[string]SagaState),
+	}
+}
+
+// Load implements SagaStore.
+func (s *MemorySagaStore) Load(ctx context.Context, key string) (*SagaState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state, ok := s.states[key]
+	if !ok {
+		return nil, nil
+	}
+
+	state.Data = append([]byte(nil), state.Data...)
+	return &state, nil
+}
+
+// Save implements SagaStore.
+func (s *MemorySagaStore) Save(ctx context.Context, state *SagaState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	saved := *state
+	saved.Data = append([]byte(nil), state.Data...)
+	s.states[state.Key] = saved
+	return nil
+}
+
+// Delete implements SagaStore.
+func (s *MemorySagaStore) Delete(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.states, key)
+	return nil
+}
+
+// Expired implements SagaStore.
+func (s *MemorySagaStore) Expired(ctx context.Context, t time.Time) ([]*SagaState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var expired []*SagaState
+	for _, state := range s.states {
+		if !state.Deadline.IsZero() && state.Deadline.Before(t) {
+			state := state
+			expired = append(expired, &state)
+		}
+	}
+
+	return expired, nil
+}
@@ -0,0 +1,323 @@
+package msmq
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// scheduleMagic prefixes the Extension of the messages parked by a
+// Scheduler.
+const scheduleMagic = "GMSQ"
+
+// errNotScheduled is returned when a message in the staging queue was not
+// parked by a Scheduler.
+var errNotScheduled = errors.New("go-msmq: message was not scheduled by a Scheduler")
+
+// Scheduler delays the delivery of messages, which MSMQ does not support
+// natively. Messages sent with SendAt or SendAfter are parked in a staging
+// queue with their due time and target queue encoded in their Extension, and
+// Run releases them to their target queue when they are due:
+//   s, err := msmq.NewScheduler(`DIRECT=OS:.\private$\scheduled`)
+//   ...
+//   defer s.Close()
+//   go s.Run(ctx)
+//   err = s.SendAfter(&msg, `DIRECT=OS:.\private$\reminders`, time.Hour)
+//
+// The original Extension of a message is restored when it is released. The
+// staging queue and the target queues must be transactional: a message is
+// received from the staging queue and sent to its target in the same
+// transaction, so it is released exactly once. Messages are released at the
+// first poll after they are due, so delivery is delayed by up to the poll
+// interval.
+type Scheduler struct {
+	staging *Queue
+	parked  *Queue
+	options *schedulerOptions
+
+	// mu guards targets, the target queues opened by Release.
+	mu      sync.Mutex
+	targets map[string]*Queue
+}
+
+// NewScheduler returns a pointer to a Scheduler that parks messages in the
+// staging queue referenced by name, configured by the options. The staging
+// queue is opened for sending and receiving until the Scheduler is closed.
+func NewScheduler(name string, opts ...SchedulerOption) (*Scheduler, error) {
+	options := &schedulerOptions{
+		interval: time.Second,
+	}
+	for _, o := range opts {
+		o.set(options)
+	}
+	if options.interval <= 0 {
+		return nil, fmt.Errorf("go-msmq: NewScheduler(%s) failed to create scheduler: %w", name, invalidOption("SchedulerWithInterval", options.interval, "must be positive"))
+	}
+
+	staging, err := Open(name, Options{AccessMode: Send})
+	if err != nil {
+		return nil, fmt.Errorf("go-msmq: NewScheduler(%s) failed to open staging queue: %w", name, err)
+	}
+
+	parked, err := Open(name, Options{AccessMode: Receive})
+	if err != nil {
+		staging.Close()
+		return nil, fmt.Errorf("go-msmq: NewScheduler(%s) failed to open staging queue: %w", name, err)
+	}
+
+	return &Scheduler{
+		staging: staging,
+		parked:  parked,
+		options: options,
+		targets: make(map[string]*Queue),
+	}, nil
+}
+
+// SendAt parks msg in the staging queue until t, when it is released to the
+// queue referenced by target, which is either a format name or a path name.
+// The Extension of msg is left encoded with the schedule.
+func (s *Scheduler) SendAt(msg *Message, target string, t time.Time) error {
+	ext, err := msg.Extension()
+	if err != nil {
+		return fmt.Errorf("go-msmq: SendAt(%s) failed to schedule message: %w", target, err)
+	}
+
+	err = msg.SetExtension(encodeSchedule(t, target, ext))
+	if err != nil {
+		return fmt.Errorf("go-msmq: SendAt(%s) failed to schedule message: %w", target, err)
+	}
+
+	err = msg.Send(s.staging, SendWithTransaction(SingleMessage))
+	if err != nil {
+		return fmt.Errorf("go-msmq: SendAt(%s) failed to schedule message: %w", target, err)
+	}
+
+	return nil
+}
+
+// SendAfter parks msg in the staging queue for d. See SendAt.
+func (s *Scheduler) SendAfter(msg *Message, target string, d time.Duration) error {
+	return s.SendAt(msg, target, time.Now().Add(d))
+}
+
+// Run releases the due messages every poll interval until ctx is done, and
+// returns the error of ctx. Errors releasing messages are passed to the
+// callback set with SchedulerWithOnError and do not stop Run.
+func (s *Scheduler) Run(ctx context.Context) error {
+	ticker := time.NewTicker(s.options.interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := s.Release(); err != nil && s.options.onError != nil {
+			s.options.onError(err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("go-msmq: Run() failed to release scheduled messages: %w", ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// Release releases the messages of the staging queue that are due and
+// returns the number of messages released. Messages that cannot be released
+// stay in the staging queue and are retried by the next call; the first
+// error is returned.
+func (s *Scheduler) Release() (int, error) {
+	due, err := s.due(time.Now())
+	if err != nil {
+		return 0, fmt.Errorf("go-msmq: Release() failed to release scheduled messages: %w", err)
+	}
+
+	var firstErr error
+	released := 0
+	for _, d := range due {
+		if err := s.release(d); err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("go-msmq: Release() failed to release scheduled message %d: %w", d.lookupID, err)
+			}
+			continue
+		}
+		released++
+	}
+
+	return released, firstErr
+}
+
+// Close closes the staging queue and the target queues.
+func (s *Scheduler) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for name, q := range s.targets {
+		q.Close()
+		delete(s.targets, name)
+	}
+	s.parked.Close()
+	return s.staging.Close()
+}
+
+// dueMessage is a parked message that is due.
+type dueMessage struct {
+	lookupID  LookupID
+	target    string
+	extension []byte
+}
+
+// due returns the parked messages that are due at now. Messages that were
+// not parked by a Scheduler are skipped.
+func (s *Scheduler) due(now time.Time) ([]dueMessage, error) {
+	opts := []PeekByLookupIDOption{
+		PeekByLookupIDWithWantBody(false),
+	}
+
+	var due []dueMessage
+	id := FirstLookupID
+	for {
+		msg, err := s.parked.PeekNextByLookupID(id, opts...)
+		if err != nil {
+			return nil, err
+		}
+
+		if msg.dispatch == nil {
+			return due, nil
+		}
+
+		id, err = msg.LookupID()
+		if err != nil {
+			msg.release()
+			return nil, err
+		}
+
+		ext, err := msg.Extension()
+		msg.release()
+		if err != nil {
+			return nil, err
+		}
+
+		t, target, original, err := decodeSchedule(ext)
+		if err != nil || t.After(now) {
+			continue
+		}
+
+		due = append(due, dueMessage{lookupID: id, target: target, extension: original})
+	}
+}
+
+// release moves the due message d from the staging queue to its target.
+func (s *Scheduler) release(d dueMessage) error {
+	target, err := s.target(d.target)
+	if err != nil {
+		return err
+	}
+
+	tx, err := BeginTransaction()
+	if err != nil {
+		return err
+	}
+
+	msg, err := s.parked.ReceiveByLookupID(d.lookupID, ReceiveByLookupIDInTransaction(tx))
+	if err != nil {
+		tx.Abort()
+		return err
+	}
+	defer msg.release()
+
+	if d.extension == nil {
+		d.extension = []byte{}
+	}
+	if err := msg.SetExtension(d.extension); err != nil {
+		tx.Abort()
+		return err
+	}
+
+	if err := msg.Send(target, SendInTransaction(tx)); err != nil {
+		tx.Abort()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// target returns the queue referenced by name opened for sending.
+func (s *Scheduler) target(name string) (*Queue, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if q, ok := s.targets[name]; ok {
+		return q, nil
+	}
+
+	q, err := Open(name, Options{AccessMode: Send})
+	if err != nil {
+		return nil, err
+	}
+
+	s.targets[name] = q
+	return q, nil
+}
+
+// encodeSchedule returns the Extension of a message parked until t for
+// target, which preserves the original extension of the message:
+//   "GMSQ" | due time (int64 Unix nanoseconds) | len(target) (uint16) | target | extension
+func encodeSchedule(t time.Time, target string, extension []byte) []byte {
+	b := make([]byte, 0, len(scheduleMagic)+8+2+len(target)+len(extension))
+	b = append(b, scheduleMagic...)
+	b = binary.BigEndian.AppendUint64(b, uint64(t.UnixNano()))
+	b = binary.BigEndian.AppendUint16(b, uint16(len(target)))
+	b = append(b, target...)
+	return append(b, extension...)
+}
+
+// decodeSchedule decodes an Extension encoded by encodeSchedule.
+func decodeSchedule(b []byte) (t time.Time, target string, extension []byte, err error) {
+	const header = len(scheduleMagic) + 8 + 2
+	if len(b) < header || string(b[:len(scheduleMagic)]) != scheduleMagic {
+		return time.Time{}, "", nil, errNotScheduled
+	}
+
+	t = time.Unix(0, int64(binary.BigEndian.Uint64(b[len(scheduleMagic):])))
+	n := int(binary.BigEndian.Uint16(b[len(scheduleMagic)+8:]))
+	if len(b) < header+n {
+		return time.Time{}, "", nil, errNotScheduled
+	}
+
+	return t, string(b[header : header+n]), b[header+n:], nil
+}
+
+// SchedulerOption represents an option to configure a Scheduler.
+type SchedulerOption struct {
+	set func(opts *schedulerOptions)
+}
+
+// schedulerOptions contains all the options to configure a Scheduler.
+type schedulerOptions struct {
+	interval time.Duration
+	onError  func(err error)
+}
+
+// SchedulerWithInterval returns a SchedulerOption that configures how often
+// Run releases the due messages. The interval must be positive. The default
+// is 1 second.
+func SchedulerWithInterval(interval time.Duration) SchedulerOption {
+	return SchedulerOption{
+		set: func(opts *schedulerOptions) {
+			opts.interval = interval
+		},
+	}
+}
+
+// SchedulerWithOnError returns a SchedulerOption that configures the callback
+// invoked by Run when messages cannot be released. The default is to drop
+// the error.
+func SchedulerWithOnError(fn func(err error)) SchedulerOption {
+	return SchedulerOption{
+		set: func(opts *schedulerOptions) {
+			opts.onError = fn
+		},
+	}
+}
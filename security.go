@@ -0,0 +1,103 @@
+// +build windows
+
+package msmq
+
+import (
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+var (
+	advapi32 = windows.NewLazySystemDLL("advapi32.dll")
+
+	procConvertStringSecurityDescriptorToSecurityDescriptorW = advapi32.NewProc("ConvertStringSecurityDescriptorToSecurityDescriptorW")
+	procConvertSecurityDescriptorToStringSecurityDescriptorW = advapi32.NewProc("ConvertSecurityDescriptorToStringSecurityDescriptorW")
+)
+
+// sddlRevision1 is the only revision of the SDDL format.
+const sddlRevision1 = 1
+
+// daclSecurityInformation is the DACL_SECURITY_INFORMATION flag which
+// indicates that the discretionary access control list of a security
+// descriptor is being set.
+const daclSecurityInformation = 0x00000004
+
+// securityDescriptor is a self-relative security descriptor allocated by the
+// system. It must be freed once it is no longer needed.
+type securityDescriptor uintptr
+
+// parseSDDL converts a security descriptor in the Security Descriptor
+// Definition Language, such as "D:P(A;;GA;;;BA)(A;;0x2;;;WD)", to a security
+// descriptor.
+//
+// See: https://docs.microsoft.com/en-us/windows/win32/secauthz/security-descriptor-string-format
+func parseSDDL(sddl string) (securityDescriptor, error) {
+	if err := procConvertStringSecurityDescriptorToSecurityDescriptorW.Find(); err != nil {
+		return 0, err
+	}
+
+	s, err := windows.UTF16PtrFromString(sddl)
+	if err != nil {
+		return 0, err
+	}
+
+	var sd securityDescriptor
+	r, _, err := procConvertStringSecurityDescriptorToSecurityDescriptorW.Call(
+		uintptr(unsafe.Pointer(s)),
+		sddlRevision1,
+		uintptr(unsafe.Pointer(&sd)),
+		0,
+	)
+	if r == 0 {
+		return 0, err
+	}
+
+	return sd, nil
+}
+
+// free releases the memory of the security descriptor.
+func (sd securityDescriptor) free() {
+	windows.LocalFree(windows.Handle(sd))
+}
+
+// formatSDDL converts the parts of the self-relative security descriptor sd
+// that are specified by info to the Security Descriptor Definition Language.
+func formatSDDL(sd []byte, info uint32) (string, error) {
+	if err := procConvertSecurityDescriptorToStringSecurityDescriptorW.Find(); err != nil {
+		return "", err
+	}
+
+	var s *uint16
+	r, _, err := procConvertSecurityDescriptorToStringSecurityDescriptorW.Call(
+		uintptr(unsafe.Pointer(&sd[0])),
+		sddlRevision1,
+		uintptr(info),
+		uintptr(unsafe.Pointer(&s)),
+		0,
+	)
+	if r == 0 {
+		return "", err
+	}
+	defer windows.LocalFree(windows.Handle(unsafe.Pointer(s)))
+
+	return utf16PtrToString(s), nil
+}
+
+// utf16PtrToString converts a NUL-terminated UTF-16 string to a string.
+func utf16PtrToString(p *uint16) string {
+	if p == nil {
+		return ""
+	}
+
+	var s []uint16
+	for ptr := unsafe.Pointer(p); ; ptr = unsafe.Pointer(uintptr(ptr) + 2) {
+		c := *(*uint16)(ptr)
+		if c == 0 {
+			break
+		}
+		s = append(s, c)
+	}
+
+	return windows.UTF16ToString(s)
+}
@@ -0,0 +1,17 @@
+// +build !windows
+
+package msmq
+
+const daclSecurityInformation = 0x00000004
+
+type securityDescriptor uintptr
+
+func parseSDDL(sddl string) (securityDescriptor, error) {
+	return 0, ErrUnsupportedPlatform
+}
+
+func (sd securityDescriptor) free() {}
+
+func formatSDDL(sd []byte, info uint32) (string, error) {
+	return "", ErrUnsupportedPlatform
+}
@@ -0,0 +1,146 @@
+package msmq
+
+import (
+	"encoding/xml"
+	"fmt"
+
+	"github.com/go-ole/go-ole"
+)
+
+// SetSOAPHeader sets XML elements that MSMQ adds to the header of the SRMP
+// envelope of the message when it is sent to an HTTP format name, such as
+// routing or security information expected by the receiving site:
+//   err := msg.SetSOAPHeader(`<tenant xmlns="urn:example">contoso</tenant>`)
+//
+// The header is ignored by messages sent to other format names.
+func (m *Message) SetSOAPHeader(header string) error {
+	_, err := putProperty(m.dispatch, "SoapHeader", header)
+	if err != nil {
+		return fmt.Errorf("go-msmq: SetSOAPHeader(%s) failed to set SoapHeader: %w", header, err)
+	}
+
+	return nil
+}
+
+// SetSOAPBody sets XML elements that MSMQ adds to the body of the SRMP
+// envelope of the message when it is sent to an HTTP format name. The body of
+// the message itself is sent as an attachment of the envelope.
+//
+// The body is ignored by messages sent to other format names.
+func (m *Message) SetSOAPBody(body string) error {
+	_, err := putProperty(m.dispatch, "SoapBody", body)
+	if err != nil {
+		return fmt.Errorf("go-msmq: SetSOAPBody(%s) failed to set SoapBody: %w", body, err)
+	}
+
+	return nil
+}
+
+// SOAPEnvelope returns the SRMP envelope of a message received over HTTP, or
+// an empty string if the message was not sent with SRMP.
+func (m *Message) SOAPEnvelope() (string, error) {
+	res, err := getProperty(m.dispatch, "SoapEnvelope")
+	if err != nil {
+		return "", fmt.Errorf("go-msmq: SOAPEnvelope() failed to get SoapEnvelope: %w", err)
+	}
+	defer res.Clear()
+
+	if res.VT == ole.VT_EMPTY || res.VT == ole.VT_NULL {
+		return "", nil
+	}
+
+	return variantString(res, "SoapEnvelope")
+}
+
+// CompoundMessage returns the whole SRMP message of a message received over
+// HTTP: the envelope and the attachments holding the body and other
+// properties, in MIME format. It returns nil if the message was not sent with
+// SRMP.
+func (m *Message) CompoundMessage() ([]byte, error) {
+	res, err := getProperty(m.dispatch, "CompoundMessage")
+	if err != nil {
+		return nil, fmt.Errorf("go-msmq: CompoundMessage() failed to get CompoundMessage: %w", err)
+	}
+	defer res.Clear()
+
+	if res.VT&ole.VT_ARRAY == 0 {
+		return nil, nil
+	}
+
+	return res.ToArray().ToByteArray(), nil
+}
+
+// SRMPEnvelope holds the fields of the SRMP envelope of a message received
+// over HTTP.
+type SRMPEnvelope struct {
+	// Action is the action of the WS-Routing path of the message, which MSMQ
+	// derives from the label of the message.
+	Action string
+
+	// To is the URL of the destination queue.
+	To string
+
+	// ID is the identifier of the message, in the form uuid:index@guid.
+	ID string
+
+	// SentAt and ExpiresAt are when the message was sent and when it
+	// expires, as written by the sending queue manager.
+	SentAt    string
+	ExpiresAt string
+
+	// Header and Body are the XML content of the header and body of the
+	// envelope, including the elements set with SetSOAPHeader and
+	// SetSOAPBody.
+	Header []byte
+	Body   []byte
+}
+
+// srmpEnvelope is the XML form of an SRMP envelope. Elements are matched by
+// their local name, since SRMP mixes the SOAP, WS-Routing and SRMP
+// namespaces.
+type srmpEnvelope struct {
+	Header struct {
+		Path struct {
+			Action string `xml:"action"`
+			To     string `xml:"to"`
+			ID     string `xml:"id"`
+		} `xml:"path"`
+		Properties struct {
+			SentAt    string `xml:"sentAt"`
+			ExpiresAt string `xml:"expiresAt"`
+		} `xml:"properties"`
+		Content []byte `xml:",innerxml"`
+	} `xml:"Header"`
+	Body struct {
+		Content []byte `xml:",innerxml"`
+	} `xml:"Body"`
+}
+
+// SRMP returns the fields of the SRMP envelope of a message received over
+// HTTP, or nil if the message was not sent with SRMP.
+//
+// See: https://docs.microsoft.com/en-us/openspecs/windows_protocols/mc-mqsrm
+func (m *Message) SRMP() (*SRMPEnvelope, error) {
+	s, err := m.SOAPEnvelope()
+	if err != nil {
+		return nil, fmt.Errorf("go-msmq: SRMP() failed to get envelope: %w", err)
+	}
+	if s == "" {
+		return nil, nil
+	}
+
+	var e srmpEnvelope
+	if err := xml.Unmarshal([]byte(s), &e); err != nil {
+		return nil, fmt.Errorf("go-msmq: SRMP() failed to parse envelope: %w", err)
+	}
+
+	return &SRMPEnvelope{
+		Action:    e.Header.Path.Action,
+		To:        e.Header.Path.To,
+		ID:        e.Header.Path.ID,
+		SentAt:    e.Header.Properties.SentAt,
+		ExpiresAt: e.Header.Properties.ExpiresAt,
+		Header:    e.Header.Content,
+		Body:      e.Body.Content,
+	}, nil
+}
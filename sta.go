@@ -0,0 +1,176 @@
+// +build windows
+
+package msmq
+
+import (
+	"runtime"
+	"sync/atomic"
+	"unsafe"
+
+	"github.com/go-ole/go-ole"
+	"golang.org/x/sys/windows"
+)
+
+var (
+	user32 = windows.NewLazySystemDLL("user32.dll")
+
+	procMsgWaitForMultipleObjectsEx = user32.NewProc("MsgWaitForMultipleObjectsEx")
+	procPeekMessageW                = user32.NewProc("PeekMessageW")
+	procTranslateMessage            = user32.NewProc("TranslateMessage")
+	procDispatchMessageW            = user32.NewProc("DispatchMessageW")
+)
+
+const (
+	// qsAllInput wakes MsgWaitForMultipleObjectsEx for any window message.
+	qsAllInput = 0x04FF
+
+	// mwmoInputAvailable wakes MsgWaitForMultipleObjectsEx for messages
+	// that are already in the queue.
+	mwmoInputAvailable = 0x0004
+
+	// pmRemove removes the message retrieved by PeekMessageW.
+	pmRemove = 0x0001
+)
+
+// msg mirrors the layout of MSG.
+type msg struct {
+	hwnd     uintptr
+	message  uint32
+	wParam   uintptr
+	lParam   uintptr
+	time     uint32
+	pt       [2]int32
+	lPrivate uint32
+}
+
+// staThread is the OS thread that owns the single-threaded apartment of the
+// package in the STA model. It runs the calls submitted to work and pumps
+// the window messages COM uses to deliver calls and events to the apartment.
+type staThread struct {
+	work     chan func()
+	event    windows.Handle
+	threadID uint32
+}
+
+// sta is the running staThread, or nil in the MTA model.
+var sta atomic.Pointer[staThread]
+
+// startSTA starts the thread of the single-threaded apartment, which runs
+// until stop is closed. done is closed when the thread exits.
+func startSTA(stop, done chan struct{}) error {
+	if err := procMsgWaitForMultipleObjectsEx.Find(); err != nil {
+		return err
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		defer close(done)
+
+		runtime.LockOSThread()
+		defer runtime.UnlockOSThread()
+
+		err := ole.CoInitializeEx(0, ole.COINIT_APARTMENTTHREADED)
+		if err != nil && !isSFalse(err) {
+			errs <- err
+			return
+		}
+		defer ole.CoUninitialize()
+
+		event, err := windows.CreateEvent(nil, 0, 0, nil)
+		if err != nil {
+			errs <- err
+			return
+		}
+		defer windows.CloseHandle(event)
+
+		t := &staThread{
+			work:     make(chan func(), 64),
+			event:    event,
+			threadID: windows.GetCurrentThreadId(),
+		}
+		sta.Store(t)
+		defer sta.Store(nil)
+
+		go func() {
+			<-stop
+			windows.SetEvent(event)
+		}()
+
+		errs <- nil
+		t.pump(stop)
+	}()
+
+	return <-errs
+}
+
+// pump runs submitted calls and dispatches window messages until stop is
+// closed.
+func (t *staThread) pump(stop chan struct{}) {
+	for {
+		r, _, _ := procMsgWaitForMultipleObjectsEx.Call(
+			1,
+			uintptr(unsafe.Pointer(&t.event)),
+			uintptr(windows.INFINITE),
+			qsAllInput,
+			mwmoInputAvailable)
+		switch r {
+		case windows.WAIT_OBJECT_0:
+			t.run()
+		case windows.WAIT_OBJECT_0 + 1:
+			dispatchMessages()
+		}
+
+		select {
+		case <-stop:
+			t.run()
+			return
+		default:
+		}
+	}
+}
+
+// run runs the calls that are waiting.
+func (t *staThread) run() {
+	for {
+		select {
+		case fn := <-t.work:
+			fn()
+		default:
+			return
+		}
+	}
+}
+
+// dispatchMessages dispatches the window messages in the queue of the
+// calling thread.
+func dispatchMessages() {
+	var m msg
+	for {
+		r, _, _ := procPeekMessageW.Call(uintptr(unsafe.Pointer(&m)), 0, 0, 0, pmRemove)
+		if r == 0 {
+			return
+		}
+
+		procTranslateMessage.Call(uintptr(unsafe.Pointer(&m)))
+		procDispatchMessageW.Call(uintptr(unsafe.Pointer(&m)))
+	}
+}
+
+// inApartment calls fn in the apartment of the objects of the package. In the
+// STA model, fn runs on the thread of the apartment unless it is called from
+// that thread already; otherwise it runs on the calling goroutine.
+func inApartment(fn func()) {
+	t := sta.Load()
+	if t == nil || windows.GetCurrentThreadId() == t.threadID {
+		fn()
+		return
+	}
+
+	done := make(chan struct{})
+	t.work <- func() {
+		defer close(done)
+		fn()
+	}
+	windows.SetEvent(t.event)
+	<-done
+}
@@ -0,0 +1,11 @@
+// +build !windows
+
+package msmq
+
+func startSTA(stop, done chan struct{}) error {
+	return ErrUnsupportedPlatform
+}
+
+func inApartment(fn func()) {
+	fn()
+}
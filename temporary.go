@@ -0,0 +1,61 @@
+package msmq
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+)
+
+// NewTemporaryQueue creates a uniquely named local private queue whose name
+// starts with prefix, and opens it with Receive AccessMode and DenyReceive
+// ShareMode. The queue is deleted when the returned Queue is closed. It is the
+// building block for per-client response queues in request/response patterns:
+//   replies, replyInfo, err := msmq.NewTemporaryQueue("orders-client")
+//   ...
+//   defer replies.Close()
+//   // Send requests that reference replyInfo as the response queue and
+//   // receive the responses from replies.
+// The queue is created with the specified opts. The returned QueueInfo must
+// not be closed before the Queue. If the process exits without closing the
+// Queue, the queue is not deleted.
+func NewTemporaryQueue(prefix string, opts ...CreateQueueOption) (*Queue, *QueueInfo, error) {
+	b := make([]byte, 8)
+	_, err := rand.Read(b)
+	if err != nil {
+		return nil, nil, fmt.Errorf("go-msmq: NewTemporaryQueue(%s) failed to create temporary queue: %w", prefix, err)
+	}
+
+	name := fmt.Sprintf(`.\private$\%s-%s`, prefix, hex.EncodeToString(b))
+	err = ValidatePathName(name)
+	if err != nil {
+		return nil, nil, fmt.Errorf("go-msmq: NewTemporaryQueue(%s) failed to create temporary queue: %w", prefix, err)
+	}
+
+	qi, err := NewQueueInfo(WithPathName(name))
+	if err != nil {
+		return nil, nil, fmt.Errorf("go-msmq: NewTemporaryQueue(%s) failed to create temporary queue: %w", prefix, err)
+	}
+
+	err = qi.Create(opts...)
+	if err != nil {
+		qi.Close()
+		return nil, nil, fmt.Errorf("go-msmq: NewTemporaryQueue(%s) failed to create temporary queue: %w", prefix, err)
+	}
+
+	err = qi.Refresh()
+	if err != nil {
+		qi.Delete()
+		qi.Close()
+		return nil, nil, fmt.Errorf("go-msmq: NewTemporaryQueue(%s) failed to create temporary queue: %w", prefix, err)
+	}
+
+	q, err := qi.Open(Receive, DenyReceive)
+	if err != nil {
+		qi.Delete()
+		qi.Close()
+		return nil, nil, fmt.Errorf("go-msmq: NewTemporaryQueue(%s) failed to create temporary queue: %w", prefix, err)
+	}
+
+	q.onClose = qi.Delete
+	return q, qi, nil
+}
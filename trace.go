@@ -0,0 +1,107 @@
+package msmq
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-ole/go-ole"
+)
+
+// LevelTrace is the level of the records logged by the COM tracer, which is
+// below slog.LevelDebug so that tracing can be filtered independently of the
+// operation logs.
+const LevelTrace = slog.LevelDebug - 4
+
+// traceLogger is the Logger installed by SetTraceLogger.
+var traceLogger atomic.Pointer[Logger]
+
+// SetTraceLogger installs l as the tracer of the COM calls of the package.
+// Every method call and property get or put made on an MSMQ object is logged
+// at LevelTrace with the member name, the arguments and their types, the
+// VARIANT type of the result, the HRESULT and the duration of the call:
+//   msmq.SetTraceLogger(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
+//       Level: msmq.LevelTrace,
+//   })))
+//
+// Tracing is meant for diagnosing interop issues and slows every call down.
+// A nil Logger disables tracing, which is the default.
+func SetTraceLogger(l Logger) {
+	if l == nil {
+		traceLogger.Store(nil)
+		return
+	}
+
+	traceLogger.Store(&l)
+}
+
+// currentTracer returns the trace Logger of the package, or nil.
+func currentTracer() Logger {
+	if l := traceLogger.Load(); l != nil {
+		return *l
+	}
+
+	return nil
+}
+
+// trace logs the COM call of the member name of dispatch that started at
+// start and returned res and err.
+func trace(l Logger, dispatch *ole.IDispatch, name string, kind int16, params []interface{}, start time.Time, res *ole.VARIANT, err error) {
+	args := []any{
+		slog.String("call", traceKind(kind)),
+		slog.String("member", name),
+		slog.String("object", fmt.Sprintf("%p", dispatch)),
+		slog.String("args", traceArgs(params)),
+		slog.Duration("duration", time.Since(start)),
+	}
+	if res != nil {
+		args = append(args, slog.String("result", ole.VT(res.VT).String()))
+	}
+	args = append(args, slog.String("hresult", fmt.Sprintf("0x%08X", hresult(err))))
+	if err != nil {
+		args = append(args, slog.Any("error", err))
+	}
+
+	l.Log(context.Background(), LevelTrace, "go-msmq: COM "+name, args...)
+}
+
+// traceKind returns the name of the invocation kind of a COM call.
+func traceKind(kind int16) string {
+	switch kind {
+	case ole.DISPATCH_METHOD:
+		return "CallMethod"
+	case ole.DISPATCH_PROPERTYGET:
+		return "GetProperty"
+	case ole.DISPATCH_PROPERTYPUT:
+		return "PutProperty"
+	case ole.DISPATCH_PROPERTYPUTREF:
+		return "PutPropertyRef"
+	}
+
+	return fmt.Sprintf("Invoke(%d)", kind)
+}
+
+// traceArgs formats the arguments of a COM call with their types. Objects are
+// formatted as their address and omitted optional arguments as "missing".
+func traceArgs(params []interface{}) string {
+	args := make([]string, len(params))
+	for i, p := range params {
+		switch v := p.(type) {
+		case nil:
+			args[i] = "missing"
+		case *ole.IDispatch:
+			args[i] = fmt.Sprintf("IDispatch(%p)", v)
+		case *ole.VARIANT:
+			args[i] = fmt.Sprintf("VARIANT(%s)", ole.VT(v.VT))
+		case string:
+			args[i] = fmt.Sprintf("string(%q)", v)
+		default:
+			args[i] = fmt.Sprintf("%T(%v)", v, v)
+		}
+	}
+
+	return "[" + strings.Join(args, ", ") + "]"
+}
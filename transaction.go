@@ -1,7 +1,12 @@
-// +build windows
-
 package msmq
 
+import (
+	"errors"
+	"fmt"
+
+	"github.com/go-ole/go-ole"
+)
+
 // TransactionLevel defines transaction levels for message transactions with a queue.
 type TransactionLevel int
 
@@ -27,3 +32,268 @@ const (
 	// must be sent or received from a transactional queue.
 	SingleMessage
 )
+
+// MTSRequired specifies that the message is sent or received within the
+// current COM+ transaction, like MTS. Unlike MTS, which silently sends or
+// receives the message outside of a transaction when there is no COM+
+// transaction, MTSRequired fails with ErrNoAmbientTransaction.
+const MTSRequired TransactionLevel = 0x100
+
+// ErrNoAmbientTransaction is returned when MTSRequired is used outside of a
+// COM+ transaction.
+var ErrNoAmbientTransaction = errors.New("go-msmq: MTSRequired used outside of a COM+ transaction")
+
+// InAmbientTransaction returns whether the calling goroutine runs in a COM+
+// context that has a transaction, which is when the MTS TransactionLevel
+// sends and receives messages within a transaction. Outside of such a
+// context, MTS behaves like NoTransaction.
+//
+// COM+ contexts are bound to operating system threads, so callers should use
+// runtime.LockOSThread to keep the goroutine on the same thread between
+// InAmbientTransaction and the operations it guards.
+//
+// See: https://docs.microsoft.com/en-us/windows/win32/api/comsvcs/nf-comsvcs-iobjectcontextinfo-isintransaction
+func InAmbientTransaction() (bool, error) {
+	ok, err := inAmbientTransaction()
+	if err != nil {
+		return false, fmt.Errorf("go-msmq: InAmbientTransaction() failed to get object context: %w", err)
+	}
+
+	return ok, nil
+}
+
+// ResolveTransactionLevel returns the TransactionLevel that MSMQ effectively
+// uses for level in the current context: MTS and MTSRequired resolve to MTS
+// within a COM+ transaction. Outside of one, MTS resolves to NoTransaction
+// and MTSRequired returns ErrNoAmbientTransaction. Other levels are returned
+// as is.
+func ResolveTransactionLevel(level TransactionLevel) (TransactionLevel, error) {
+	if level != MTS && level != MTSRequired {
+		return level, nil
+	}
+
+	ok, err := InAmbientTransaction()
+	if err != nil {
+		return level, err
+	}
+
+	switch {
+	case ok:
+		return MTS, nil
+	case level == MTSRequired:
+		return level, ErrNoAmbientTransaction
+	default:
+		return NoTransaction, nil
+	}
+}
+
+// Transaction is an MSMQ transaction that groups sending and receiving
+// messages so that they either all take effect or none do. Unlike the
+// TransactionLevel values, a Transaction can span several operations on
+// several queues:
+//   tx, err := msmq.BeginTransaction()
+//   ...
+//   msg, err := in.Receive(msmq.ReceiveInTransaction(tx))
+//   ...
+//   err = msg.Send(out, msmq.SendInTransaction(tx))
+//   ...
+//   err = tx.Commit()
+// A Transaction must be committed or aborted exactly once.
+//
+// See: https://docs.microsoft.com/en-us/previous-versions/windows/desktop/legacy/ms707072(v=vs.85)
+type Transaction struct {
+	dispatch *ole.IDispatch
+}
+
+// BeginTransaction starts an internal MSMQ transaction. Internal transactions
+// are faster than coordinated transactions but can only include MSMQ
+// operations of the local queue manager.
+//
+// See: https://docs.microsoft.com/en-us/previous-versions/windows/desktop/legacy/ms705226(v=vs.85)
+func BeginTransaction() (*Transaction, error) {
+	tx, err := beginTransaction("MSMQ.MSMQTransactionDispenser")
+	if err != nil {
+		return nil, fmt.Errorf("go-msmq: BeginTransaction() failed to begin transaction: %w", err)
+	}
+
+	return tx, nil
+}
+
+// BeginCoordinatedTransaction starts a transaction coordinated by the
+// Microsoft Distributed Transaction Coordinator (DTC), which allows MSMQ
+// operations to be combined with other resource managers.
+//
+// See: https://docs.microsoft.com/en-us/previous-versions/windows/desktop/legacy/ms701237(v=vs.85)
+func BeginCoordinatedTransaction() (*Transaction, error) {
+	tx, err := beginTransaction("MSMQ.MSMQCoordinatedTransactionDispenser")
+	if err != nil {
+		return nil, fmt.Errorf("go-msmq: BeginCoordinatedTransaction() failed to begin transaction: %w", err)
+	}
+
+	return tx, nil
+}
+
+// beginTransaction starts a transaction with the specified transaction
+// dispenser.
+func beginTransaction(dispenser string) (*Transaction, error) {
+	dispatch, err := createObject(dispenser)
+	if err != nil {
+		return nil, err
+	}
+	defer release(dispatch)
+
+	res, err := callMethod(dispatch, "BeginTransaction")
+	if err != nil {
+		return nil, err
+	}
+
+	return &Transaction{
+		dispatch: track(res.ToIDispatch(), "MSMQ.MSMQTransaction"),
+	}, nil
+}
+
+// Commit commits the transaction. Options can be specified to control the
+// retaining semantics and the two-phase commit behavior; by default, Commit
+// waits for the second phase of the commit to complete:
+//   err := tx.Commit(msmq.CommitWithFlags(msmq.CommitAsync))
+// The transaction cannot be used after it is committed unless
+// CommitWithRetaining(true) is specified.
+//
+// See: https://docs.microsoft.com/en-us/previous-versions/windows/desktop/legacy/ms703208(v=vs.85)
+func (t *Transaction) Commit(opts ...CommitOption) error {
+	options := &commitOptions{
+		retaining: false,
+		flags:     CommitSync,
+		rmFlags:   0,
+	}
+	for _, o := range opts {
+		o.set(options)
+	}
+
+	_, err := callMethod(t.dispatch, "Commit", options.retaining, int32(options.flags), int32(options.rmFlags))
+	if err != nil {
+		return fmt.Errorf("go-msmq: Commit(%v, %d, %d) failed to commit transaction: %w", options.retaining, options.flags, options.rmFlags, err)
+	}
+
+	if !options.retaining {
+		t.release()
+	}
+
+	return nil
+}
+
+// CommitOption represents an option to commit a transaction.
+type CommitOption struct {
+	set func(opts *commitOptions)
+}
+
+// commitOptions contains all the options to commit a transaction.
+type commitOptions struct {
+	retaining bool
+	flags     CommitFlags
+	rmFlags   uint32
+}
+
+// CommitFlags defines when Commit returns relative to the phases of the
+// two-phase commit protocol. The values correspond to the XACTTC flags.
+type CommitFlags uint32
+
+const (
+	// CommitSyncPhaseOne specifies that Commit returns once the first phase
+	// of the commit completes.
+	CommitSyncPhaseOne CommitFlags = 1
+
+	// CommitSync specifies that Commit returns once the second phase of the
+	// commit completes. This is the default.
+	CommitSync CommitFlags = 2
+
+	// CommitAsync specifies that Commit returns immediately and the commit
+	// completes asynchronously. The outcome of the transaction is not
+	// reported to the caller, so it should only be used when the outcome is
+	// verified by other means.
+	CommitAsync CommitFlags = 4
+)
+
+// CommitWithRetaining returns a CommitOption that configures whether the
+// transaction is retained after it is committed, so that a new unit of work
+// can be started in the same transaction. Internal MSMQ transactions do not
+// support retaining commits.
+//
+// The default is false.
+func CommitWithRetaining(retaining bool) CommitOption {
+	return CommitOption{
+		set: func(opts *commitOptions) {
+			opts.retaining = retaining
+		},
+	}
+}
+
+// CommitWithFlags returns a CommitOption that configures when Commit returns
+// relative to the phases of the commit.
+//
+// The default is CommitSync.
+func CommitWithFlags(flags CommitFlags) CommitOption {
+	return CommitOption{
+		set: func(opts *commitOptions) {
+			opts.flags = flags
+		},
+	}
+}
+
+// CommitWithRMFlags returns a CommitOption that configures the
+// resource-manager specific flags of the commit. MSMQ requires the flags to
+// be 0.
+//
+// The default is 0.
+func CommitWithRMFlags(flags uint32) CommitOption {
+	return CommitOption{
+		set: func(opts *commitOptions) {
+			opts.rmFlags = flags
+		},
+	}
+}
+
+// Abort aborts the transaction.
+//
+// See: https://docs.microsoft.com/en-us/previous-versions/windows/desktop/legacy/ms706051(v=vs.85)
+func (t *Transaction) Abort() error {
+	_, err := callMethod(t.dispatch, "Abort")
+	if err != nil {
+		return fmt.Errorf("go-msmq: Abort() failed to abort transaction: %w", err)
+	}
+
+	t.release()
+	return nil
+}
+
+// release releases the underlying transaction object.
+func (t *Transaction) release() {
+	if t.dispatch != nil {
+		release(t.dispatch)
+		t.dispatch = nil
+	}
+}
+
+// transactionArg returns the value of the Transaction parameter of the MSMQ
+// methods: tx if it is not nil, or level otherwise. MTSRequired is resolved
+// to MTS if the caller is in a COM+ transaction.
+func transactionArg(level TransactionLevel, tx *Transaction) (interface{}, error) {
+	if tx != nil {
+		return tx.dispatch, nil
+	}
+
+	if level == MTSRequired {
+		ok, err := InAmbientTransaction()
+		if err != nil {
+			return nil, err
+		}
+
+		if !ok {
+			return nil, ErrNoAmbientTransaction
+		}
+
+		level = MTS
+	}
+
+	return int(level), nil
+}
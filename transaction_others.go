@@ -0,0 +1,7 @@
+// +build !windows
+
+package msmq
+
+func inAmbientTransaction() (bool, error) {
+	return false, ErrUnsupportedPlatform
+}
@@ -0,0 +1,61 @@
+// +build windows
+
+package msmq
+
+import (
+	"syscall"
+	"unsafe"
+
+	"github.com/go-ole/go-ole"
+	"golang.org/x/sys/windows"
+)
+
+var (
+	ole32 = windows.NewLazySystemDLL("ole32.dll")
+
+	procCoGetObjectContext = ole32.NewProc("CoGetObjectContext")
+)
+
+// iidIObjectContextInfo is the interface identifier of IObjectContextInfo,
+// which reports whether the current COM+ context has a transaction.
+var iidIObjectContextInfo = ole.NewGUID("{75B52DDB-E8ED-11D1-93AD-00AA00BA3258}")
+
+// iObjectContextInfoVtbl is the virtual table of IObjectContextInfo.
+type iObjectContextInfoVtbl struct {
+	ole.IUnknownVtbl
+	IsInTransaction  uintptr
+	GetTransaction   uintptr
+	GetTransactionId uintptr
+	GetActivityId    uintptr
+	GetContextId     uintptr
+}
+
+// inAmbientTransaction returns whether the calling thread runs in a COM+
+// context that has a transaction.
+func inAmbientTransaction() (bool, error) {
+	if err := procCoGetObjectContext.Find(); err != nil {
+		return false, err
+	}
+
+	var info *ole.IUnknown
+	hr, _, _ := procCoGetObjectContext.Call(uintptr(unsafe.Pointer(iidIObjectContextInfo)), uintptr(unsafe.Pointer(&info)))
+	if int32(hr) < 0 {
+		// There is no object context when COM+ is not in use.
+		if uint32(hr) == rpcENotRegistered || uint32(hr) == coENotInitialized {
+			return false, nil
+		}
+
+		return false, ole.NewError(hr)
+	}
+	defer info.Release()
+
+	vtbl := (*iObjectContextInfoVtbl)(unsafe.Pointer(info.RawVTable))
+	ok, _, _ := syscall.Syscall(vtbl.IsInTransaction, 1, uintptr(unsafe.Pointer(info)), 0, 0)
+	return ok != 0, nil
+}
+
+// HRESULT values returned by CoGetObjectContext when there is no context.
+const (
+	rpcENotRegistered = 0x80040154
+	coENotInitialized = 0x800401F0
+)
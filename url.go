@@ -0,0 +1,131 @@
+package msmq
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// ErrInvalidURL is wrapped by the errors returned by OpenURL when the URL is
+// malformed.
+var ErrInvalidURL = errors.New("go-msmq: invalid queue URL")
+
+// OpenURL opens the queue described by a URL so that queue endpoints can be
+// configured with a single string. The URL is in the form:
+//   msmq://Host/[private$/]QueueName?access=AccessMode[&share=ShareMode][&protocol=Protocol]
+// where:
+//   - Host is the computer name, IP address or host name of the queue. An
+//     empty host or localhost refers to the local computer.
+//   - AccessMode is one of send, peek, receive, move, peekadmin or
+//     receiveadmin, and is required.
+//   - ShareMode is either denynone or denyreceive. The default is denynone.
+//   - Protocol is one of os, tcp, http or https. The default is os, or tcp
+//     when Host is an IP address.
+// For example:
+//   queue, err := msmq.OpenURL("msmq://host/private$/orders?access=receive")
+// The queue is referenced by a direct format name. The QueueInfo of the queue
+// is closed when the returned Queue is closed.
+func OpenURL(rawURL string) (*Queue, error) {
+	name, accessMode, shareMode, err := parseQueueURL(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("go-msmq: OpenURL(%s) failed to open queue: %w", rawURL, err)
+	}
+
+	qi, err := NewQueueInfo(WithFormatName(name))
+	if err != nil {
+		return nil, fmt.Errorf("go-msmq: OpenURL(%s) failed to open queue: %w", rawURL, err)
+	}
+
+	q, err := qi.Open(accessMode, shareMode)
+	if err != nil {
+		qi.Close()
+		return nil, fmt.Errorf("go-msmq: OpenURL(%s) failed to open queue: %w", rawURL, err)
+	}
+
+	q.onClose = qi.Close
+	return q, nil
+}
+
+// parseQueueURL returns the format name, AccessMode and ShareMode described by
+// a queue URL.
+func parseQueueURL(rawURL string) (string, AccessMode, ShareMode, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", 0, 0, fmt.Errorf("%w: %v", ErrInvalidURL, err)
+	}
+
+	if !strings.EqualFold(u.Scheme, "msmq") {
+		return "", 0, 0, fmt.Errorf("%w: scheme must be msmq", ErrInvalidURL)
+	}
+
+	queue := strings.Trim(u.Path, "/")
+	if queue == "" {
+		return "", 0, 0, fmt.Errorf("%w: queue name is missing", ErrInvalidURL)
+	}
+
+	query := u.Query()
+
+	var accessMode AccessMode
+	switch strings.ToLower(query.Get("access")) {
+	case "send":
+		accessMode = Send
+	case "peek":
+		accessMode = Peek
+	case "receive":
+		accessMode = Receive
+	case "move":
+		accessMode = Move
+	case "peekadmin":
+		accessMode = PeekAndAdmin
+	case "receiveadmin":
+		accessMode = ReceiveAndAdmin
+	case "":
+		return "", 0, 0, fmt.Errorf("%w: access is missing", ErrInvalidURL)
+	default:
+		return "", 0, 0, fmt.Errorf("%w: unknown access %q", ErrInvalidURL, query.Get("access"))
+	}
+
+	var shareMode ShareMode
+	switch strings.ToLower(query.Get("share")) {
+	case "", "denynone":
+		shareMode = DenyNone
+	case "denyreceive":
+		shareMode = DenyReceive
+	default:
+		return "", 0, 0, fmt.Errorf("%w: unknown share %q", ErrInvalidURL, query.Get("share"))
+	}
+
+	host := u.Hostname()
+	if host == "" || strings.EqualFold(host, "localhost") {
+		host = "."
+	}
+
+	protocol := strings.ToLower(query.Get("protocol"))
+	if protocol == "" {
+		protocol = "os"
+		if net.ParseIP(host) != nil {
+			protocol = "tcp"
+		}
+	}
+
+	var name string
+	switch protocol {
+	case "os":
+		name = `DIRECT=OS:` + host + `\` + strings.ReplaceAll(queue, "/", `\`)
+	case "tcp":
+		name = `DIRECT=TCP:` + host + `\` + strings.ReplaceAll(queue, "/", `\`)
+	case "http", "https":
+		name = HTTPFormatName(u.Host, queue, protocol == "https")
+	default:
+		return "", 0, 0, fmt.Errorf("%w: unknown protocol %q", ErrInvalidURL, query.Get("protocol"))
+	}
+
+	err = ValidateFormatName(name)
+	if err != nil {
+		return "", 0, 0, err
+	}
+
+	return name, accessMode, shareMode, nil
+}
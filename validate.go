@@ -0,0 +1,333 @@
+package msmq
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidPathName is wrapped by the errors returned by ValidatePathName.
+var ErrInvalidPathName = errors.New("go-msmq: invalid path name")
+
+// ErrInvalidFormatName is wrapped by the errors returned by
+// ValidateFormatName.
+var ErrInvalidFormatName = errors.New("go-msmq: invalid format name")
+
+// NameError describes why a path name or format name is invalid. It wraps
+// either ErrInvalidPathName or ErrInvalidFormatName.
+type NameError struct {
+	// Name is the path name or format name that is invalid.
+	Name string
+
+	// Reason describes why Name is invalid.
+	Reason string
+
+	kind error
+}
+
+// Error implements the error interface.
+func (e *NameError) Error() string {
+	return fmt.Sprintf("%v %q: %s", e.kind, e.Name, e.Reason)
+}
+
+// Unwrap returns ErrInvalidPathName or ErrInvalidFormatName.
+func (e *NameError) Unwrap() error {
+	return e.kind
+}
+
+// maxQueueNameLength is the maximum number of characters in the name of a
+// queue, excluding the machine name and the PRIVATE$ keyword.
+const maxQueueNameLength = 124
+
+// ValidatePathName checks the syntax of a path name without contacting MSMQ,
+// so that misconfiguration is reported with a clear error instead of an opaque
+// failure when the queue is created or opened. The path name must be in one
+// of the forms:
+//   ComputerName\QueueName
+//   ComputerName\PRIVATE$\QueueName
+//   .\QueueName
+//   .\PRIVATE$\QueueName
+// ValidatePathName does not check whether the queue exists.
+func ValidatePathName(name string) error {
+	invalid := func(reason string) error {
+		return &NameError{Name: name, Reason: reason, kind: ErrInvalidPathName}
+	}
+
+	if name == "" {
+		return invalid("path name is empty")
+	}
+
+	parts := strings.Split(name, `\`)
+	if len(parts) < 2 {
+		return invalid(`machine name and queue name must be separated by '\'`)
+	}
+
+	machine := parts[0]
+	if strings.EqualFold(machine, "PRIVATE$") {
+		return invalid(`machine name is missing, use ".\PRIVATE$\" for the local computer`)
+	}
+
+	if reason := checkMachineName(machine); reason != "" {
+		return invalid(reason)
+	}
+
+	queue := parts[1:]
+	if strings.EqualFold(queue[0], "PRIVATE$") {
+		queue = queue[1:]
+	}
+
+	switch {
+	case len(queue) == 0 || queue[0] == "":
+		return invalid("queue name is missing")
+	case len(queue) > 1:
+		for _, p := range queue {
+			if strings.EqualFold(p, "PRIVATE$") {
+				return invalid("PRIVATE$ must immediately follow the machine name")
+			}
+		}
+		return invalid(`queue name must not contain '\'`)
+	}
+
+	return checkQueueName(queue[0], invalid)
+}
+
+// ValidateFormatName checks the syntax of a format name without contacting
+// MSMQ, so that misconfiguration is reported with a clear error instead of an
+// opaque failure when the queue is opened. The following format names are
+// supported:
+//   PUBLIC=QueueGUID
+//   PRIVATE=ComputerGUID\QueueNumber
+//   DIRECT=OS:ComputerName\[PRIVATE$\]QueueName
+//   DIRECT=TCP:IPAddress\[PRIVATE$\]QueueName
+//   DIRECT=HTTP://Host/msmq/[PRIVATE$/]QueueName
+//   DIRECT=HTTPS://Host/msmq/[PRIVATE$/]QueueName
+//   MACHINE=ComputerGUID;{JOURNAL|DEADLETTER|DEADXACT}
+//   MULTICAST=Address:Port
+//   DL=DistributionListGUID[@Domain]
+// Format names may be followed by ";JOURNAL" or by the name of a subqueue,
+// and several format names may be combined into a multiple-element format
+// name separated by commas. ValidateFormatName does not check whether the
+// queue exists.
+func ValidateFormatName(name string) error {
+	if name == "" {
+		return &NameError{Name: name, Reason: "format name is empty", kind: ErrInvalidFormatName}
+	}
+
+	for _, element := range strings.Split(name, ",") {
+		err := validateFormatNameElement(element)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// validateFormatNameElement checks the syntax of a single element of a format
+// name.
+func validateFormatNameElement(name string) error {
+	invalid := func(reason string) error {
+		return &NameError{Name: name, Reason: reason, kind: ErrInvalidFormatName}
+	}
+
+	i := strings.Index(name, "=")
+	if i < 0 {
+		return invalid("format name must start with PUBLIC=, PRIVATE=, DIRECT=, MACHINE=, MULTICAST= or DL=")
+	}
+
+	prefix, value := strings.ToUpper(name[:i]), name[i+1:]
+	value, suffix := splitSuffix(value)
+
+	switch prefix {
+	case "PUBLIC":
+		if !isGUID(value) {
+			return invalid("PUBLIC= must be followed by the GUID of the queue")
+		}
+
+	case "PRIVATE":
+		parts := strings.Split(value, `\`)
+		if len(parts) != 2 || !isGUID(parts[0]) {
+			return invalid(`PRIVATE= must be followed by the GUID of the computer, '\' and the queue number`)
+		}
+
+		if _, err := strconv.ParseUint(parts[1], 16, 32); err != nil {
+			return invalid("queue number must be a hexadecimal number")
+		}
+
+	case "DIRECT":
+		reason := checkDirect(value)
+		if reason != "" {
+			return invalid(reason)
+		}
+
+	case "MACHINE":
+		if !isGUID(value) {
+			return invalid("MACHINE= must be followed by the GUID of the computer")
+		}
+
+		switch strings.ToUpper(suffix) {
+		case "JOURNAL", "DEADLETTER", "DEADXACT":
+			return nil
+		default:
+			return invalid("MACHINE= must be followed by ;JOURNAL, ;DEADLETTER or ;DEADXACT")
+		}
+
+	case "MULTICAST":
+		host, port, err := net.SplitHostPort(value)
+		if err != nil || net.ParseIP(host) == nil {
+			return invalid("MULTICAST= must be followed by an IP address and port in the form Address:Port")
+		}
+
+		if _, err := strconv.ParseUint(port, 10, 16); err != nil {
+			return invalid("multicast port must be a number between 0 and 65535")
+		}
+
+	case "DL":
+		guid := value
+		if i := strings.Index(value, "@"); i >= 0 {
+			guid = value[:i]
+		}
+
+		if !isGUID(guid) {
+			return invalid("DL= must be followed by the GUID of the distribution list")
+		}
+
+	default:
+		return invalid(fmt.Sprintf("unknown format name type %q", name[:i]))
+	}
+
+	if suffix != "" && strings.ContainsAny(suffix, `;\/`) {
+		return invalid("subqueue name must not contain ';', '\\' or '/'")
+	}
+
+	return nil
+}
+
+// checkDirect checks the syntax of the value of a direct format name and
+// returns the reason it is invalid, if any.
+func checkDirect(value string) string {
+	i := strings.Index(value, ":")
+	if i < 0 {
+		return "DIRECT= must be followed by a protocol: OS:, TCP:, HTTP:// or HTTPS://"
+	}
+
+	protocol, address := strings.ToUpper(value[:i]), value[i+1:]
+	switch protocol {
+	case "OS", "TCP":
+		parts := strings.Split(address, `\`)
+		if len(parts) < 2 {
+			return `machine name and queue name must be separated by '\'`
+		}
+
+		if protocol == "TCP" && net.ParseIP(parts[0]) == nil {
+			return "DIRECT=TCP: must be followed by an IP address"
+		}
+
+		if protocol == "OS" {
+			if reason := checkMachineName(parts[0]); reason != "" {
+				return reason
+			}
+		}
+
+		queue := parts[1:]
+		if strings.EqualFold(queue[0], "PRIVATE$") {
+			queue = queue[1:]
+		}
+
+		if len(queue) != 1 || queue[0] == "" {
+			return "queue name is missing or misplaced"
+		}
+
+	case "HTTP", "HTTPS":
+		u, err := url.Parse(strings.ToLower(protocol) + ":" + address)
+		if err != nil || u.Host == "" {
+			return "DIRECT=HTTP:// and DIRECT=HTTPS:// must be followed by a host"
+		}
+
+		if strings.Trim(u.Path, "/") == "" {
+			return "queue name is missing"
+		}
+
+	default:
+		return fmt.Sprintf("unknown protocol %q, must be OS, TCP, HTTP or HTTPS", value[:i])
+	}
+
+	return ""
+}
+
+// checkMachineName checks the syntax of a machine name and returns the reason
+// it is invalid, if any.
+func checkMachineName(machine string) string {
+	if machine == "" {
+		return "machine name is missing"
+	}
+
+	if machine == "." {
+		return ""
+	}
+
+	for _, r := range machine {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '-', r == '_', r == '.':
+		default:
+			return fmt.Sprintf("machine name contains invalid character %q", r)
+		}
+	}
+
+	return ""
+}
+
+// checkQueueName checks the syntax of the name of a queue.
+func checkQueueName(queue string, invalid func(string) error) error {
+	if len([]rune(queue)) > maxQueueNameLength {
+		return invalid(fmt.Sprintf("queue name exceeds %d characters", maxQueueNameLength))
+	}
+
+	if strings.ContainsAny(queue, ";+\"\r\n") {
+		return invalid(`queue name must not contain ';', '+', '"' or line breaks`)
+	}
+
+	return nil
+}
+
+// splitSuffix splits the ";" suffix, such as JOURNAL or the name of a
+// subqueue, from a format name.
+func splitSuffix(s string) (string, string) {
+	i := strings.Index(s, ";")
+	if i < 0 {
+		return s, ""
+	}
+
+	return s[:i], s[i+1:]
+}
+
+// isGUID returns whether s is a GUID in the form
+// 12345678-1234-1234-1234-123456789ABC, optionally enclosed in braces.
+func isGUID(s string) bool {
+	if strings.HasPrefix(s, "{") && strings.HasSuffix(s, "}") {
+		s = s[1 : len(s)-1]
+	}
+
+	if len(s) != 36 {
+		return false
+	}
+
+	for i, r := range s {
+		switch i {
+		case 8, 13, 18, 23:
+			if r != '-' {
+				return false
+			}
+		default:
+			if !strings.ContainsRune("0123456789abcdefABCDEF", r) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,105 @@
+package msmq
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/go-ole/go-ole"
+)
+
+// VariantTypeError is returned when MSMQ returns a value whose type differs
+// from the one the package expects, for example VT_EMPTY for a property that
+// is not set, instead of panicking on the conversion.
+type VariantTypeError struct {
+	// Name is the name of the property or method that returned the value.
+	Name string
+
+	// VT is the type of the returned value.
+	VT ole.VT
+
+	// Want describes the expected type.
+	Want string
+}
+
+// Error implements the error interface.
+func (e *VariantTypeError) Error() string {
+	return fmt.Sprintf("go-msmq: %s returned a value of type %s, want %s", e.Name, e.VT, e.Want)
+}
+
+// variantString returns v as a string. VT_EMPTY and VT_NULL, which MSMQ
+// returns for unset strings, are returned as an empty string.
+func variantString(v *ole.VARIANT, name string) (string, error) {
+	if v == nil || v.VT == ole.VT_EMPTY || v.VT == ole.VT_NULL {
+		return "", nil
+	}
+
+	s, ok := v.Value().(string)
+	if !ok {
+		return "", &VariantTypeError{Name: name, VT: v.VT, Want: "string"}
+	}
+
+	return s, nil
+}
+
+// variantBytes returns v, an array of bytes, as a byte slice.
+func variantBytes(v *ole.VARIANT, name string) ([]byte, error) {
+	if v == nil || v.VT&ole.VT_ARRAY == 0 {
+		return nil, &VariantTypeError{Name: name, VT: vt(v), Want: "byte array"}
+	}
+
+	return v.ToArray().ToByteArray(), nil
+}
+
+// variantInt32 returns v as an int32. Values of any integer type that fits
+// are accepted.
+func variantInt32(v *ole.VARIANT, name string) (int32, error) {
+	if v != nil {
+		switch n := v.Value().(type) {
+		case int32:
+			return n, nil
+		case int16:
+			return int32(n), nil
+		case int8:
+			return int32(n), nil
+		case uint16:
+			return int32(n), nil
+		case uint8:
+			return int32(n), nil
+		case uint32:
+			return int32(n), nil
+		}
+	}
+
+	return 0, &VariantTypeError{Name: name, VT: vt(v), Want: "int32"}
+}
+
+// variantBool returns v as a bool.
+func variantBool(v *ole.VARIANT, name string) (bool, error) {
+	if v != nil {
+		if b, ok := v.Value().(bool); ok {
+			return b, nil
+		}
+	}
+
+	return false, &VariantTypeError{Name: name, VT: vt(v), Want: "bool"}
+}
+
+// variantTime returns v as a time.Time.
+func variantTime(v *ole.VARIANT, name string) (time.Time, error) {
+	if v != nil {
+		if t, ok := v.Value().(time.Time); ok {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, &VariantTypeError{Name: name, VT: vt(v), Want: "time.Time"}
+}
+
+// vt returns the type of v, treating nil as VT_EMPTY.
+func vt(v *ole.VARIANT) ole.VT {
+	if v == nil {
+		return ole.VT_EMPTY
+	}
+
+	return v.VT
+}
@@ -0,0 +1,53 @@
+package wcf
+
+// staticDictionary holds the strings of the static dictionary of the .NET
+// Binary Format that appear in the envelopes of NetMsmqBinding: the SOAP 1.2
+// envelope, WS-Addressing 1.0 and WS-ReliableMessaging headers. The full
+// dictionary also covers the WS-Security and WS-Trust vocabulary, which
+// NetMsmqBinding does not use with transport security.
+//
+// See: https://docs.microsoft.com/en-us/openspecs/windows_protocols/mc-nbfs
+var staticDictionary = map[int]string{
+	0x00: "mustUnderstand",
+	0x02: "Envelope",
+	0x04: "http://www.w3.org/2003/05/soap-envelope",
+	0x06: "http://www.w3.org/2005/08/addressing",
+	0x08: "Header",
+	0x0A: "Action",
+	0x0C: "To",
+	0x0E: "Body",
+	0x10: "Algorithm",
+	0x12: "RelatesTo",
+	0x14: "http://www.w3.org/2005/08/addressing/anonymous",
+	0x16: "URI",
+	0x18: "Reference",
+	0x1A: "MessageID",
+	0x1C: "Id",
+	0x1E: "Identifier",
+	0x20: "http://schemas.xmlsoap.org/ws/2005/02/rm",
+	0x22: "Transforms",
+	0x24: "Transform",
+	0x26: "DigestMethod",
+	0x28: "DigestValue",
+	0x2A: "Address",
+	0x2C: "ReplyTo",
+	0x2E: "SequenceAcknowledgement",
+	0x30: "AcknowledgementRange",
+	0x32: "Upper",
+	0x34: "Lower",
+	0x36: "BufferRemaining",
+	0x38: "http://schemas.microsoft.com/ws/2006/05/rm",
+	0x3A: "http://schemas.xmlsoap.org/ws/2005/02/rm/SequenceAcknowledgement",
+	0x3C: "SecurityTokenReference",
+	0x3E: "Sequence",
+	0x40: "MessageNumber",
+}
+
+// staticIDs maps the strings of staticDictionary to their identifiers.
+var staticIDs = func() map[string]int {
+	ids := make(map[string]int, len(staticDictionary))
+	for id, s := range staticDictionary {
+		ids[s] = id
+	}
+	return ids
+}()
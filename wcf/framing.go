@@ -0,0 +1,265 @@
+package wcf
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"unicode/utf8"
+)
+
+// Record types of the .NET Message Framing Protocol.
+//
+// See: https://docs.microsoft.com/en-us/openspecs/windows_protocols/mc-nmf
+const (
+	recordVersion            = 0x00
+	recordMode               = 0x01
+	recordVia                = 0x02
+	recordKnownEncoding      = 0x03
+	recordExtensibleEncoding = 0x04
+	recordSizedEnvelope      = 0x06
+	recordEnd                = 0x07
+	recordFault              = 0x08
+	recordPreambleEnd        = 0x0C
+)
+
+// Mode is the communication mode of a framed message.
+type Mode byte
+
+// The modes used by NetMsmqBinding, which are the modes supported by Frame.
+const (
+	// ModeSimplex is a one-way session of several envelopes, which is how
+	// sessionful NetMsmqBinding channels send their messages.
+	ModeSimplex Mode = 0x03
+
+	// ModeSingletonSized is a single envelope of known size, which is how
+	// datagram NetMsmqBinding channels send their messages.
+	ModeSingletonSized Mode = 0x04
+)
+
+// Encoding is the encoding of the envelopes of a framed message.
+type Encoding byte
+
+// The known encodings. SOAP 1.1 envelopes are only used by custom bindings.
+const (
+	EncodingSOAP11UTF8      Encoding = 0x00
+	EncodingSOAP11UTF16     Encoding = 0x01
+	EncodingSOAP11UnicodeLE Encoding = 0x02
+	EncodingSOAP12UTF8      Encoding = 0x03
+	EncodingSOAP12UTF16     Encoding = 0x04
+	EncodingSOAP12UnicodeLE Encoding = 0x05
+	EncodingSOAP12MTOM      Encoding = 0x06
+
+	// EncodingBinary is the binary XML encoding used by NetMsmqBinding for
+	// datagrams.
+	EncodingBinary Encoding = 0x07
+
+	// EncodingBinarySession is the binary XML encoding with an in-band
+	// dictionary used by NetMsmqBinding for sessions.
+	EncodingBinarySession Encoding = 0x08
+)
+
+// Frame is a message framed with the .NET Message Framing Protocol, which is
+// the body of the MSMQ messages sent by NetMsmqBinding.
+type Frame struct {
+	// Mode is the communication mode.
+	Mode Mode
+
+	// Via is the URI of the endpoint, such as
+	// net.msmq://localhost/private/orders.
+	Via string
+
+	// Encoding is the encoding of the envelopes. Envelopes sent with an
+	// extensible encoding have the encoding 0xFF and ContentType set.
+	Encoding Encoding
+
+	// ContentType is the content type of an extensible encoding.
+	ContentType string
+
+	// Envelopes are the encoded SOAP envelopes. A singleton has a single
+	// envelope.
+	Envelopes [][]byte
+}
+
+// ErrFault is returned when a frame holds a fault record instead of
+// envelopes.
+var ErrFault = errors.New("wcf: frame is a fault")
+
+// MarshalBinary returns the frame f encoded with the .NET Message Framing
+// Protocol. Singletons must have exactly one envelope.
+func (f *Frame) MarshalBinary() ([]byte, error) {
+	switch {
+	case f.Mode != ModeSingletonSized && f.Mode != ModeSimplex:
+		return nil, fmt.Errorf("wcf: MarshalBinary() failed to encode frame: unsupported mode %d", f.Mode)
+	case f.Mode == ModeSingletonSized && len(f.Envelopes) != 1:
+		return nil, fmt.Errorf("wcf: MarshalBinary() failed to encode frame: singleton has %d envelopes", len(f.Envelopes))
+	}
+
+	var b bytes.Buffer
+	b.Write([]byte{recordVersion, 1, 0})
+	b.Write([]byte{recordMode, byte(f.Mode)})
+	b.WriteByte(recordVia)
+	writeString(&b, f.Via)
+	if f.ContentType != "" {
+		b.WriteByte(recordExtensibleEncoding)
+		writeString(&b, f.ContentType)
+	} else {
+		b.Write([]byte{recordKnownEncoding, byte(f.Encoding)})
+	}
+
+	if f.Mode == ModeSingletonSized {
+		b.WriteByte(recordSizedEnvelope)
+		writeBytes(&b, f.Envelopes[0])
+		return b.Bytes(), nil
+	}
+
+	for _, e := range f.Envelopes {
+		b.WriteByte(recordSizedEnvelope)
+		writeBytes(&b, e)
+	}
+	b.WriteByte(recordEnd)
+
+	return b.Bytes(), nil
+}
+
+// UnmarshalBinary decodes data encoded with the .NET Message Framing Protocol
+// into f. It returns ErrFault if data holds a fault.
+func (f *Frame) UnmarshalBinary(data []byte) error {
+	*f = Frame{}
+	r := bytes.NewReader(data)
+	for {
+		t, err := r.ReadByte()
+		if err != nil {
+			// A sized singleton ends with its envelope.
+			if f.Mode == ModeSingletonSized && len(f.Envelopes) == 1 {
+				return nil
+			}
+			return fmt.Errorf("wcf: UnmarshalBinary() failed to decode frame: %w", errTruncated)
+		}
+
+		switch t {
+		case recordVersion:
+			var v [2]byte
+			if _, err := io.ReadFull(r, v[:]); err != nil {
+				return fmt.Errorf("wcf: UnmarshalBinary() failed to decode version: %w", errTruncated)
+			}
+			if v[0] != 1 {
+				return fmt.Errorf("wcf: UnmarshalBinary() failed to decode version: unsupported version %d.%d", v[0], v[1])
+			}
+		case recordMode:
+			m, err := r.ReadByte()
+			if err != nil {
+				return fmt.Errorf("wcf: UnmarshalBinary() failed to decode mode: %w", errTruncated)
+			}
+			f.Mode = Mode(m)
+			if f.Mode != ModeSingletonSized && f.Mode != ModeSimplex {
+				return fmt.Errorf("wcf: UnmarshalBinary() failed to decode mode: unsupported mode %d", f.Mode)
+			}
+		case recordVia:
+			if f.Via, err = readString(r); err != nil {
+				return fmt.Errorf("wcf: UnmarshalBinary() failed to decode via: %w", err)
+			}
+		case recordKnownEncoding:
+			e, err := r.ReadByte()
+			if err != nil {
+				return fmt.Errorf("wcf: UnmarshalBinary() failed to decode encoding: %w", errTruncated)
+			}
+			f.Encoding = Encoding(e)
+		case recordExtensibleEncoding:
+			f.Encoding = 0xFF
+			if f.ContentType, err = readString(r); err != nil {
+				return fmt.Errorf("wcf: UnmarshalBinary() failed to decode encoding: %w", err)
+			}
+		case recordPreambleEnd:
+			// Only meaningful to duplex sessions.
+		case recordSizedEnvelope:
+			e, err := readBytes(r)
+			if err != nil {
+				return fmt.Errorf("wcf: UnmarshalBinary() failed to decode envelope %d: %w", len(f.Envelopes)+1, err)
+			}
+			f.Envelopes = append(f.Envelopes, e)
+			if f.Mode == ModeSingletonSized {
+				return nil
+			}
+		case recordEnd:
+			return nil
+		case recordFault:
+			fault, err := readString(r)
+			if err != nil {
+				return fmt.Errorf("wcf: UnmarshalBinary() failed to decode fault: %w", err)
+			}
+			return fmt.Errorf("%w: %s", ErrFault, fault)
+		default:
+			return fmt.Errorf("wcf: UnmarshalBinary() failed to decode frame: unknown record type 0x%02X", t)
+		}
+	}
+}
+
+// errTruncated is returned when encoded data ends unexpectedly.
+var errTruncated = errors.New("unexpected end of data")
+
+// writeInt31 writes n to b as a variable-length integer of 7 bits per byte.
+func writeInt31(b *bytes.Buffer, n int) {
+	for n >= 0x80 {
+		b.WriteByte(byte(n) | 0x80)
+		n >>= 7
+	}
+	b.WriteByte(byte(n))
+}
+
+// readInt31 reads a variable-length integer of 7 bits per byte.
+func readInt31(r *bytes.Reader) (int, error) {
+	n := 0
+	for i := 0; i < 5; i++ {
+		c, err := r.ReadByte()
+		if err != nil {
+			return 0, errTruncated
+		}
+		n |= int(c&0x7F) << (7 * i)
+		if c&0x80 == 0 {
+			return n, nil
+		}
+	}
+
+	return 0, errors.New("invalid integer")
+}
+
+// writeBytes writes p to b prefixed with its length.
+func writeBytes(b *bytes.Buffer, p []byte) {
+	writeInt31(b, len(p))
+	b.Write(p)
+}
+
+// readBytes reads bytes prefixed with their length.
+func readBytes(r *bytes.Reader) ([]byte, error) {
+	n, err := readInt31(r)
+	if err != nil {
+		return nil, err
+	}
+	if n > r.Len() {
+		return nil, errTruncated
+	}
+
+	p := make([]byte, n)
+	r.Read(p)
+	return p, nil
+}
+
+// writeString writes s to b as UTF-8 prefixed with its length.
+func writeString(b *bytes.Buffer, s string) {
+	writeInt31(b, len(s))
+	b.WriteString(s)
+}
+
+// readString reads a UTF-8 string prefixed with its length.
+func readString(r *bytes.Reader) (string, error) {
+	p, err := readBytes(r)
+	if err != nil {
+		return "", err
+	}
+	if !utf8.Valid(p) {
+		return "", errors.New("invalid UTF-8 string")
+	}
+
+	return string(p), nil
+}
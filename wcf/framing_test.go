@@ -0,0 +1,257 @@
+package wcf
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// The examples of the multi-byte integers of MC-NMF section 2.2.2.
+func TestInt31(t *testing.T) {
+	tests := []struct {
+		n    int
+		want []byte
+	}{
+		{0x00, []byte{0x00}},
+		{0x7F, []byte{0x7F}},
+		{0x80, []byte{0x80, 0x01}},
+		{0x3FFF, []byte{0xFF, 0x7F}},
+		{0x4000, []byte{0x80, 0x80, 0x01}},
+		{0x1FFFFF, []byte{0xFF, 0xFF, 0x7F}},
+		{0x200000, []byte{0x80, 0x80, 0x80, 0x01}},
+		{0xFFFFFFF, []byte{0xFF, 0xFF, 0xFF, 0x7F}},
+		{0x10000000, []byte{0x80, 0x80, 0x80, 0x80, 0x01}},
+		{0x7FFFFFFF, []byte{0xFF, 0xFF, 0xFF, 0xFF, 0x07}},
+	}
+	for _, tt := range tests {
+		var b bytes.Buffer
+		writeInt31(&b, tt.n)
+		if !bytes.Equal(b.Bytes(), tt.want) {
+			t.Errorf("writeInt31(0x%X) = % X, want % X", tt.n, b.Bytes(), tt.want)
+		}
+
+		n, err := readInt31(bytes.NewReader(tt.want))
+		if err != nil || n != tt.n {
+			t.Errorf("readInt31(% X) = 0x%X, %v, want 0x%X", tt.want, n, err, tt.n)
+		}
+	}
+}
+
+func TestReadInt31Invalid(t *testing.T) {
+	for _, p := range [][]byte{{}, {0x80}, {0x80, 0x80, 0x80, 0x80, 0x80, 0x01}} {
+		if n, err := readInt31(bytes.NewReader(p)); err == nil {
+			t.Errorf("readInt31(% X) = 0x%X, want an error", p, n)
+		}
+	}
+}
+
+// via is the via record of net.msmq://h/q.
+var via = []byte{0x02, 0x0E, 'n', 'e', 't', '.', 'm', 's', 'm', 'q', ':', '/', '/', 'h', '/', 'q'}
+
+func TestFrameMarshalBinary(t *testing.T) {
+	tests := []struct {
+		name  string
+		frame Frame
+		want  [][]byte
+	}{
+		{
+			name: "singleton",
+			frame: Frame{
+				Mode:      ModeSingletonSized,
+				Via:       "net.msmq://h/q",
+				Encoding:  EncodingBinary,
+				Envelopes: [][]byte{{0x56, 0x02}},
+			},
+			want: [][]byte{
+				{0x00, 0x01, 0x00}, // Version 1.0
+				{0x01, 0x04},       // Mode singleton-sized
+				via,
+				{0x03, 0x07},             // Known encoding binary
+				{0x06, 0x02, 0x56, 0x02}, // Sized envelope
+			},
+		},
+		{
+			name: "simplex",
+			frame: Frame{
+				Mode:      ModeSimplex,
+				Via:       "net.msmq://h/q",
+				Encoding:  EncodingBinarySession,
+				Envelopes: [][]byte{{0x01}, {0x02, 0x03}},
+			},
+			want: [][]byte{
+				{0x00, 0x01, 0x00}, // Version 1.0
+				{0x01, 0x03},       // Mode simplex
+				via,
+				{0x03, 0x08},             // Known encoding binary session
+				{0x06, 0x01, 0x01},       // Sized envelope
+				{0x06, 0x02, 0x02, 0x03}, // Sized envelope
+				{0x07},                   // End
+			},
+		},
+		{
+			name: "extensible encoding",
+			frame: Frame{
+				Mode:        ModeSingletonSized,
+				Via:         "net.msmq://h/q",
+				Encoding:    0xFF,
+				ContentType: "text/xml",
+				Envelopes:   [][]byte{{}},
+			},
+			want: [][]byte{
+				{0x00, 0x01, 0x00}, // Version 1.0
+				{0x01, 0x04},       // Mode singleton-sized
+				via,
+				{0x04, 0x08, 't', 'e', 'x', 't', '/', 'x', 'm', 'l'}, // Extensible encoding
+				{0x06, 0x00}, // Sized envelope
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := bytes.Join(tt.want, nil)
+			got, err := tt.frame.MarshalBinary()
+			if err != nil {
+				t.Fatalf("MarshalBinary() failed: %v", err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("MarshalBinary() = % X, want % X", got, want)
+			}
+
+			var f Frame
+			if err := f.UnmarshalBinary(want); err != nil {
+				t.Fatalf("UnmarshalBinary() failed: %v", err)
+			}
+			if !reflect.DeepEqual(f, tt.frame) {
+				t.Errorf("UnmarshalBinary() = %+v, want %+v", f, tt.frame)
+			}
+		})
+	}
+}
+
+func TestFrameMarshalBinaryInvalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		frame Frame
+	}{
+		{"duplex", Frame{Mode: 0x02, Envelopes: [][]byte{{}}}},
+		{"singleton without envelope", Frame{Mode: ModeSingletonSized}},
+		{"singleton with envelopes", Frame{Mode: ModeSingletonSized, Envelopes: [][]byte{{}, {}}}},
+	}
+	for _, tt := range tests {
+		if _, err := tt.frame.MarshalBinary(); err == nil {
+			t.Errorf("%s: MarshalBinary() succeeded, want an error", tt.name)
+		}
+	}
+}
+
+func TestFrameRoundTrip(t *testing.T) {
+	frames := []Frame{
+		{
+			Mode:      ModeSingletonSized,
+			Via:       "net.msmq://localhost/private/orders",
+			Encoding:  EncodingBinary,
+			Envelopes: [][]byte{bytes.Repeat([]byte{0xAB}, 0x4000)},
+		},
+		{
+			Mode:     ModeSimplex,
+			Via:      "net.msmq://server/private/é",
+			Encoding: EncodingBinarySession,
+			Envelopes: [][]byte{
+				[]byte("first"),
+				bytes.Repeat([]byte("second"), 100),
+				[]byte("third"),
+			},
+		},
+	}
+	for _, want := range frames {
+		data, err := want.MarshalBinary()
+		if err != nil {
+			t.Fatalf("MarshalBinary() failed: %v", err)
+		}
+
+		var got Frame
+		if err := got.UnmarshalBinary(data); err != nil {
+			t.Fatalf("UnmarshalBinary() failed: %v", err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("UnmarshalBinary(MarshalBinary()) = %+v, want %+v", got, want)
+		}
+	}
+}
+
+func TestFrameUnmarshalBinaryPreambleEnd(t *testing.T) {
+	data := bytes.Join([][]byte{
+		{0x00, 0x01, 0x00},
+		{0x01, 0x03},
+		via,
+		{0x03, 0x08},
+		{0x0C}, // Preamble end
+		{0x06, 0x01, 0x01},
+		{0x07},
+	}, nil)
+
+	var f Frame
+	if err := f.UnmarshalBinary(data); err != nil {
+		t.Fatalf("UnmarshalBinary() failed: %v", err)
+	}
+	if len(f.Envelopes) != 1 || !bytes.Equal(f.Envelopes[0], []byte{0x01}) {
+		t.Errorf("UnmarshalBinary() envelopes = %v, want [[1]]", f.Envelopes)
+	}
+}
+
+func TestFrameUnmarshalBinaryFault(t *testing.T) {
+	fault := "http://schemas.microsoft.com/ws/2006/05/framing/faults/UnsupportedMode"
+	data := bytes.Join([][]byte{
+		{0x00, 0x01, 0x00},
+		{0x08, byte(len(fault))},
+		[]byte(fault),
+	}, nil)
+
+	var f Frame
+	err := f.UnmarshalBinary(data)
+	if !errors.Is(err, ErrFault) {
+		t.Fatalf("UnmarshalBinary() = %v, want ErrFault", err)
+	}
+	if !bytes.Contains([]byte(err.Error()), []byte(fault)) {
+		t.Errorf("UnmarshalBinary() = %v, want the fault %s", err, fault)
+	}
+}
+
+func TestFrameUnmarshalBinaryInvalid(t *testing.T) {
+	valid, err := (&Frame{
+		Mode:      ModeSimplex,
+		Via:       "net.msmq://h/q",
+		Encoding:  EncodingBinarySession,
+		Envelopes: [][]byte{{0x01, 0x02}},
+	}).MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary() failed: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"version 2", []byte{0x00, 0x02, 0x00}},
+		{"duplex", []byte{0x00, 0x01, 0x00, 0x01, 0x02}},
+		{"unknown record", []byte{0x00, 0x01, 0x00, 0x0F}},
+		{"invalid via", []byte{0x02, 0x01, 0xFF}},
+		{"envelope too long", []byte{0x01, 0x04, 0x06, 0x03, 0x01}},
+	}
+	// Every prefix of a simplex frame misses its end record.
+	for i := 1; i < len(valid); i++ {
+		tests = append(tests, struct {
+			name string
+			data []byte
+		}{"truncated", valid[:i]})
+	}
+
+	for _, tt := range tests {
+		var f Frame
+		if err := f.UnmarshalBinary(tt.data); err == nil {
+			t.Errorf("%s: UnmarshalBinary(% X) succeeded, want an error", tt.name, tt.data)
+		}
+	}
+}
@@ -0,0 +1,767 @@
+package wcf
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// Record types of the .NET Binary Format: XML Data Structure. Text records
+// come in pairs: the odd record type of a pair also ends the element.
+//
+// See: https://docs.microsoft.com/en-us/openspecs/windows_protocols/mc-nbfx
+const (
+	nbfxEndElement = 0x01
+	nbfxComment    = 0x02
+	nbfxArray      = 0x03
+
+	nbfxShortAttribute                = 0x04
+	nbfxAttribute                     = 0x05
+	nbfxShortDictionaryAttribute      = 0x06
+	nbfxDictionaryAttribute           = 0x07
+	nbfxShortXmlnsAttribute           = 0x08
+	nbfxXmlnsAttribute                = 0x09
+	nbfxShortDictionaryXmlnsAttribute = 0x0A
+	nbfxDictionaryXmlnsAttribute      = 0x0B
+	nbfxPrefixDictionaryAttributeA    = 0x0C
+	nbfxPrefixAttributeA              = 0x26
+	nbfxShortElement                  = 0x40
+	nbfxElement                       = 0x41
+	nbfxShortDictionaryElement        = 0x42
+	nbfxDictionaryElement             = 0x43
+	nbfxPrefixDictionaryElementA      = 0x44
+	nbfxPrefixElementA                = 0x5E
+	nbfxPrefixElementZ                = 0x77
+
+	nbfxZeroText            = 0x80
+	nbfxOneText             = 0x82
+	nbfxFalseText           = 0x84
+	nbfxTrueText            = 0x86
+	nbfxInt8Text            = 0x88
+	nbfxInt16Text           = 0x8A
+	nbfxInt32Text           = 0x8C
+	nbfxInt64Text           = 0x8E
+	nbfxFloatText           = 0x90
+	nbfxDoubleText          = 0x92
+	nbfxDecimalText         = 0x94
+	nbfxDateTimeText        = 0x96
+	nbfxChars8Text          = 0x98
+	nbfxChars16Text         = 0x9A
+	nbfxChars32Text         = 0x9C
+	nbfxBytes8Text          = 0x9E
+	nbfxBytes16Text         = 0xA0
+	nbfxBytes32Text         = 0xA2
+	nbfxStartListText       = 0xA4
+	nbfxEndListText         = 0xA6
+	nbfxEmptyText           = 0xA8
+	nbfxDictionaryText      = 0xAA
+	nbfxUniqueIDText        = 0xAC
+	nbfxTimeSpanText        = 0xAE
+	nbfxUUIDText            = 0xB0
+	nbfxUInt64Text          = 0xB2
+	nbfxBoolText            = 0xB4
+	nbfxUnicodeChars8Text   = 0xB6
+	nbfxUnicodeChars16Text  = 0xB8
+	nbfxUnicodeChars32Text  = 0xBA
+	nbfxQNameDictionaryText = 0xBC
+	nbfxLastText            = 0xBD
+)
+
+// encodeBinaryXML transcodes the textual XML document doc to the .NET Binary
+// Format. Names and namespaces found in the static dictionary are written as
+// dictionary strings, and all text as UTF-8 characters.
+func encodeBinaryXML(doc []byte) ([]byte, error) {
+	var b bytes.Buffer
+	dec := xml.NewDecoder(bytes.NewReader(doc))
+	for {
+		tok, err := dec.RawToken()
+		if errors.Is(err, io.EOF) {
+			return b.Bytes(), nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			writeElement(&b, t.Name)
+			for _, a := range t.Attr {
+				writeAttribute(&b, a)
+			}
+		case xml.EndElement:
+			b.WriteByte(nbfxEndElement)
+		case xml.CharData:
+			writeText(&b, string(t))
+		case xml.Comment:
+			b.WriteByte(nbfxComment)
+			writeString(&b, string(t))
+		case xml.Directive:
+			return nil, errors.New("directives are not supported")
+		}
+	}
+}
+
+// prefixIndex returns the index of a single lowercase letter prefix, which
+// have dedicated record types, or -1.
+func prefixIndex(prefix string) int {
+	if len(prefix) == 1 && prefix[0] >= 'a' && prefix[0] <= 'z' {
+		return int(prefix[0] - 'a')
+	}
+	return -1
+}
+
+// writeElement writes the start of the element name, whose Space is the
+// prefix.
+func writeElement(b *bytes.Buffer, name xml.Name) {
+	id, dict := staticIDs[name.Local]
+	i := prefixIndex(name.Space)
+	switch {
+	case name.Space == "" && dict:
+		b.WriteByte(nbfxShortDictionaryElement)
+	case name.Space == "":
+		b.WriteByte(nbfxShortElement)
+	case i >= 0 && dict:
+		b.WriteByte(nbfxPrefixDictionaryElementA + byte(i))
+	case i >= 0:
+		b.WriteByte(nbfxPrefixElementA + byte(i))
+	case dict:
+		b.WriteByte(nbfxDictionaryElement)
+		writeString(b, name.Space)
+	default:
+		b.WriteByte(nbfxElement)
+		writeString(b, name.Space)
+	}
+
+	if dict {
+		writeInt31(b, id)
+	} else {
+		writeString(b, name.Local)
+	}
+}
+
+// writeAttribute writes the attribute a, whose Name.Space is the prefix.
+func writeAttribute(b *bytes.Buffer, a xml.Attr) {
+	// Namespace declarations.
+	if a.Name.Space == "" && a.Name.Local == "xmlns" || a.Name.Space == "xmlns" {
+		id, dict := staticIDs[a.Value]
+		switch {
+		case a.Name.Space == "" && dict:
+			b.WriteByte(nbfxShortDictionaryXmlnsAttribute)
+		case a.Name.Space == "":
+			b.WriteByte(nbfxShortXmlnsAttribute)
+		case dict:
+			b.WriteByte(nbfxDictionaryXmlnsAttribute)
+			writeString(b, a.Name.Local)
+		default:
+			b.WriteByte(nbfxXmlnsAttribute)
+			writeString(b, a.Name.Local)
+		}
+
+		if dict {
+			writeInt31(b, id)
+		} else {
+			writeString(b, a.Value)
+		}
+		return
+	}
+
+	id, dict := staticIDs[a.Name.Local]
+	i := prefixIndex(a.Name.Space)
+	switch {
+	case a.Name.Space == "" && dict:
+		b.WriteByte(nbfxShortDictionaryAttribute)
+	case a.Name.Space == "":
+		b.WriteByte(nbfxShortAttribute)
+	case i >= 0 && dict:
+		b.WriteByte(nbfxPrefixDictionaryAttributeA + byte(i))
+	case i >= 0:
+		b.WriteByte(nbfxPrefixAttributeA + byte(i))
+	case dict:
+		b.WriteByte(nbfxDictionaryAttribute)
+		writeString(b, a.Name.Space)
+	default:
+		b.WriteByte(nbfxAttribute)
+		writeString(b, a.Name.Space)
+	}
+
+	if dict {
+		writeInt31(b, id)
+	} else {
+		writeString(b, a.Name.Local)
+	}
+	writeText(b, a.Value)
+}
+
+// writeText writes s as a text record.
+func writeText(b *bytes.Buffer, s string) {
+	switch n := len(s); {
+	case n == 0:
+		b.WriteByte(nbfxEmptyText)
+		return
+	case n <= math.MaxUint8:
+		b.Write([]byte{nbfxChars8Text, byte(n)})
+	case n <= math.MaxUint16:
+		b.WriteByte(nbfxChars16Text)
+		binary.Write(b, binary.LittleEndian, uint16(n))
+	default:
+		b.WriteByte(nbfxChars32Text)
+		binary.Write(b, binary.LittleEndian, int32(n))
+	}
+	b.WriteString(s)
+}
+
+// binaryXMLDecoder transcodes the .NET Binary Format to textual XML.
+type binaryXMLDecoder struct {
+	r *bytes.Reader
+
+	// session holds the strings of the in-band dictionary of a session.
+	session []string
+
+	out   bytes.Buffer
+	stack []string
+
+	// open reports whether the start tag of the current element is still
+	// open to attributes.
+	open bool
+}
+
+// decodeBinaryXML transcodes the .NET Binary Format document data to textual
+// XML, resolving dictionary strings with the static dictionary and session.
+func decodeBinaryXML(data []byte, session []string) ([]byte, error) {
+	d := &binaryXMLDecoder{
+		r:       bytes.NewReader(data),
+		session: session,
+	}
+	for d.r.Len() > 0 {
+		if err := d.record(); err != nil {
+			return nil, err
+		}
+	}
+	if len(d.stack) > 0 {
+		return nil, fmt.Errorf("element %s is not closed", d.stack[len(d.stack)-1])
+	}
+
+	return d.out.Bytes(), nil
+}
+
+// record transcodes the next record.
+func (d *binaryXMLDecoder) record() error {
+	t, _ := d.r.ReadByte()
+	switch {
+	case t == nbfxEndElement:
+		return d.endElement()
+	case t == nbfxComment:
+		s, err := readString(d.r)
+		if err != nil {
+			return err
+		}
+		d.closeStartTag()
+		fmt.Fprintf(&d.out, "<!--%s-->", s)
+	case t == nbfxArray:
+		return d.array()
+	case t >= nbfxShortAttribute && t < nbfxShortElement:
+		if !d.open {
+			return fmt.Errorf("attribute record 0x%02X outside of a start tag", t)
+		}
+		a, err := d.attribute(t)
+		if err != nil {
+			return err
+		}
+		d.out.WriteString(a)
+	case t >= nbfxShortElement && t <= nbfxPrefixElementZ:
+		name, err := d.element(t)
+		if err != nil {
+			return err
+		}
+		d.closeStartTag()
+		d.out.WriteString("<" + name)
+		d.stack = append(d.stack, name)
+		d.open = true
+	case t >= nbfxZeroText && t <= nbfxLastText:
+		s, err := d.text(t &^ 1)
+		if err != nil {
+			return err
+		}
+		d.closeStartTag()
+		xml.EscapeText(&d.out, []byte(s))
+		if t&1 == 1 {
+			return d.endElement()
+		}
+	default:
+		return fmt.Errorf("unknown record type 0x%02X", t)
+	}
+
+	return nil
+}
+
+// closeStartTag closes the start tag of the current element if it is open.
+func (d *binaryXMLDecoder) closeStartTag() {
+	if d.open {
+		d.out.WriteByte('>')
+		d.open = false
+	}
+}
+
+// endElement writes the end tag of the current element.
+func (d *binaryXMLDecoder) endElement() error {
+	if len(d.stack) == 0 {
+		return errors.New("end element outside of an element")
+	}
+
+	d.closeStartTag()
+	d.out.WriteString("</" + d.stack[len(d.stack)-1] + ">")
+	d.stack = d.stack[:len(d.stack)-1]
+	return nil
+}
+
+// array writes the elements of an array record, which holds an element
+// record, its attributes and an end element record followed by the value
+// type and values of the repeated element.
+func (d *binaryXMLDecoder) array() error {
+	t, err := d.r.ReadByte()
+	if err != nil || t < nbfxShortElement || t > nbfxPrefixElementZ {
+		return errors.New("array record without an element")
+	}
+	name, err := d.element(t)
+	if err != nil {
+		return err
+	}
+
+	var attrs strings.Builder
+	for {
+		t, err := d.r.ReadByte()
+		if err != nil {
+			return errTruncated
+		}
+		if t == nbfxEndElement {
+			break
+		}
+		a, err := d.attribute(t)
+		if err != nil {
+			return err
+		}
+		attrs.WriteString(a)
+	}
+
+	t, err = d.r.ReadByte()
+	if err != nil {
+		return errTruncated
+	}
+	n, err := readInt31(d.r)
+	if err != nil {
+		return err
+	}
+
+	d.closeStartTag()
+	for i := 0; i < n; i++ {
+		s, err := d.text(t &^ 1)
+		if err != nil {
+			return err
+		}
+		d.out.WriteString("<" + name + attrs.String() + ">")
+		xml.EscapeText(&d.out, []byte(s))
+		d.out.WriteString("</" + name + ">")
+	}
+
+	return nil
+}
+
+// dictionaryString reads a dictionary string. Even identifiers are in the
+// static dictionary, and odd ones in the session.
+func (d *binaryXMLDecoder) dictionaryString() (string, error) {
+	id, err := readInt31(d.r)
+	if err != nil {
+		return "", err
+	}
+
+	if id%2 == 1 {
+		if i := id / 2; i < len(d.session) {
+			return d.session[i], nil
+		}
+		return "", fmt.Errorf("unknown session dictionary string %d", id)
+	}
+	if s, ok := staticDictionary[id]; ok {
+		return s, nil
+	}
+
+	return "", fmt.Errorf("unsupported static dictionary string %d", id)
+}
+
+// element reads the name of the element of record type t.
+func (d *binaryXMLDecoder) element(t byte) (string, error) {
+	prefix := ""
+	var err error
+	switch {
+	case t == nbfxElement || t == nbfxDictionaryElement:
+		if prefix, err = readString(d.r); err != nil {
+			return "", err
+		}
+	case t >= nbfxPrefixElementA:
+		prefix = string(rune('a' + t - nbfxPrefixElementA))
+	case t >= nbfxPrefixDictionaryElementA:
+		prefix = string(rune('a' + t - nbfxPrefixDictionaryElementA))
+	}
+
+	var local string
+	switch {
+	case t == nbfxShortDictionaryElement || t == nbfxDictionaryElement ||
+		t >= nbfxPrefixDictionaryElementA && t < nbfxPrefixElementA:
+		local, err = d.dictionaryString()
+	default:
+		local, err = readString(d.r)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return qualified(prefix, local), nil
+}
+
+// attribute reads the attribute of record type t and its value, and returns
+// it formatted as in a start tag.
+func (d *binaryXMLDecoder) attribute(t byte) (string, error) {
+	prefix := ""
+	var err error
+	switch {
+	case t == nbfxAttribute || t == nbfxDictionaryAttribute || t == nbfxXmlnsAttribute || t == nbfxDictionaryXmlnsAttribute:
+		if prefix, err = readString(d.r); err != nil {
+			return "", err
+		}
+	case t >= nbfxPrefixAttributeA:
+		prefix = string(rune('a' + t - nbfxPrefixAttributeA))
+	case t >= nbfxPrefixDictionaryAttributeA:
+		prefix = string(rune('a' + t - nbfxPrefixDictionaryAttributeA))
+	}
+
+	var name, value string
+	switch t {
+	case nbfxShortXmlnsAttribute, nbfxXmlnsAttribute:
+		name = xmlnsName(prefix)
+		value, err = readString(d.r)
+	case nbfxShortDictionaryXmlnsAttribute, nbfxDictionaryXmlnsAttribute:
+		name = xmlnsName(prefix)
+		value, err = d.dictionaryString()
+	default:
+		var local string
+		if t == nbfxShortDictionaryAttribute || t == nbfxDictionaryAttribute ||
+			t >= nbfxPrefixDictionaryAttributeA && t < nbfxPrefixAttributeA {
+			local, err = d.dictionaryString()
+		} else {
+			local, err = readString(d.r)
+		}
+		if err != nil {
+			return "", err
+		}
+		name = qualified(prefix, local)
+
+		var vt byte
+		if vt, err = d.r.ReadByte(); err != nil {
+			return "", errTruncated
+		}
+		if vt < nbfxZeroText || vt > nbfxLastText {
+			return "", fmt.Errorf("attribute %s without a value", name)
+		}
+		value, err = d.text(vt &^ 1)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	var b bytes.Buffer
+	xml.EscapeText(&b, []byte(value))
+	return fmt.Sprintf(" %s=\"%s\"", name, b.String()), nil
+}
+
+// qualified returns the qualified name of local with prefix.
+func qualified(prefix, local string) string {
+	if prefix == "" {
+		return local
+	}
+	return prefix + ":" + local
+}
+
+// xmlnsName returns the name of the attribute declaring the namespace of
+// prefix, or the default namespace if prefix is empty.
+func xmlnsName(prefix string) string {
+	if prefix == "" {
+		return "xmlns"
+	}
+	return "xmlns:" + prefix
+}
+
+// text reads the value of the text record type t, the even record type of
+// its pair, and returns it in its XML Schema lexical form.
+func (d *binaryXMLDecoder) text(t byte) (string, error) {
+	fixed := func(n int) ([]byte, error) {
+		p := make([]byte, n)
+		if _, err := io.ReadFull(d.r, p); err != nil {
+			return nil, errTruncated
+		}
+		return p, nil
+	}
+	sized := func(n int) ([]byte, error) {
+		p, err := fixed(n)
+		if err != nil {
+			return nil, err
+		}
+		var size uint64
+		for i := n - 1; i >= 0; i-- {
+			size = size<<8 | uint64(p[i])
+		}
+		if size > uint64(d.r.Len()) {
+			return nil, errTruncated
+		}
+		return fixed(int(size))
+	}
+
+	switch t {
+	case nbfxZeroText:
+		return "0", nil
+	case nbfxOneText:
+		return "1", nil
+	case nbfxFalseText:
+		return "false", nil
+	case nbfxTrueText:
+		return "true", nil
+	case nbfxEmptyText:
+		return "", nil
+	case nbfxInt8Text:
+		p, err := fixed(1)
+		if err != nil {
+			return "", err
+		}
+		return strconv.Itoa(int(int8(p[0]))), nil
+	case nbfxInt16Text:
+		p, err := fixed(2)
+		if err != nil {
+			return "", err
+		}
+		return strconv.Itoa(int(int16(binary.LittleEndian.Uint16(p)))), nil
+	case nbfxInt32Text:
+		p, err := fixed(4)
+		if err != nil {
+			return "", err
+		}
+		return strconv.Itoa(int(int32(binary.LittleEndian.Uint32(p)))), nil
+	case nbfxInt64Text:
+		p, err := fixed(8)
+		if err != nil {
+			return "", err
+		}
+		return strconv.FormatInt(int64(binary.LittleEndian.Uint64(p)), 10), nil
+	case nbfxUInt64Text:
+		p, err := fixed(8)
+		if err != nil {
+			return "", err
+		}
+		return strconv.FormatUint(binary.LittleEndian.Uint64(p), 10), nil
+	case nbfxBoolText:
+		p, err := fixed(1)
+		if err != nil {
+			return "", err
+		}
+		return strconv.FormatBool(p[0] != 0), nil
+	case nbfxFloatText:
+		p, err := fixed(4)
+		if err != nil {
+			return "", err
+		}
+		return formatFloat(float64(math.Float32frombits(binary.LittleEndian.Uint32(p))), 32), nil
+	case nbfxDoubleText:
+		p, err := fixed(8)
+		if err != nil {
+			return "", err
+		}
+		return formatFloat(math.Float64frombits(binary.LittleEndian.Uint64(p)), 64), nil
+	case nbfxDecimalText:
+		p, err := fixed(16)
+		if err != nil {
+			return "", err
+		}
+		return formatDecimal(p), nil
+	case nbfxDateTimeText:
+		p, err := fixed(8)
+		if err != nil {
+			return "", err
+		}
+		return formatDateTime(binary.LittleEndian.Uint64(p)), nil
+	case nbfxTimeSpanText:
+		p, err := fixed(8)
+		if err != nil {
+			return "", err
+		}
+		return formatTimeSpan(int64(binary.LittleEndian.Uint64(p))), nil
+	case nbfxUniqueIDText:
+		p, err := fixed(16)
+		if err != nil {
+			return "", err
+		}
+		return "urn:uuid:" + formatGUID(p), nil
+	case nbfxUUIDText:
+		p, err := fixed(16)
+		if err != nil {
+			return "", err
+		}
+		return formatGUID(p), nil
+	case nbfxChars8Text, nbfxChars16Text, nbfxChars32Text:
+		p, err := sized(map[byte]int{nbfxChars8Text: 1, nbfxChars16Text: 2, nbfxChars32Text: 4}[t])
+		return string(p), err
+	case nbfxUnicodeChars8Text, nbfxUnicodeChars16Text, nbfxUnicodeChars32Text:
+		p, err := sized(map[byte]int{nbfxUnicodeChars8Text: 1, nbfxUnicodeChars16Text: 2, nbfxUnicodeChars32Text: 4}[t])
+		if err != nil {
+			return "", err
+		}
+		u := make([]uint16, len(p)/2)
+		for i := range u {
+			u[i] = binary.LittleEndian.Uint16(p[2*i:])
+		}
+		return string(utf16.Decode(u)), nil
+	case nbfxBytes8Text, nbfxBytes16Text, nbfxBytes32Text:
+		p, err := sized(map[byte]int{nbfxBytes8Text: 1, nbfxBytes16Text: 2, nbfxBytes32Text: 4}[t])
+		return base64.StdEncoding.EncodeToString(p), err
+	case nbfxDictionaryText:
+		return d.dictionaryString()
+	case nbfxQNameDictionaryText:
+		p, err := fixed(4)
+		if err != nil {
+			return "", err
+		}
+		id := int(p[1]) | int(p[2])<<8 | int(p[3])<<16
+		local, ok := staticDictionary[id]
+		if !ok {
+			return "", fmt.Errorf("unsupported static dictionary string %d", id)
+		}
+		return qualified(string(rune('a'+p[0])), local), nil
+	case nbfxStartListText:
+		var items []string
+		for {
+			vt, err := d.r.ReadByte()
+			if err != nil {
+				return "", errTruncated
+			}
+			if vt == nbfxEndListText {
+				return strings.Join(items, " "), nil
+			}
+			s, err := d.text(vt &^ 1)
+			if err != nil {
+				return "", err
+			}
+			items = append(items, s)
+		}
+	default:
+		return "", fmt.Errorf("unknown text record type 0x%02X", t)
+	}
+}
+
+// formatFloat formats f like XML Schema float and double values.
+func formatFloat(f float64, bits int) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "INF"
+	case math.IsInf(f, -1):
+		return "-INF"
+	case math.IsNaN(f):
+		return "NaN"
+	}
+	return strings.ToUpper(strconv.FormatFloat(f, 'g', -1, bits))
+}
+
+// formatDecimal formats the .NET decimal p: two reserved bytes, the scale,
+// the sign, then the high 32 bits and low 64 bits of the integer value.
+func formatDecimal(p []byte) string {
+	scale := int(p[2])
+	v := new(big.Int).SetUint64(uint64(binary.LittleEndian.Uint32(p[4:8])))
+	v.Lsh(v, 64)
+	v.Or(v, new(big.Int).SetUint64(binary.LittleEndian.Uint64(p[8:16])))
+
+	s := v.String()
+	if scale > 0 {
+		if len(s) <= scale {
+			s = strings.Repeat("0", scale-len(s)+1) + s
+		}
+		s = s[:len(s)-scale] + "." + s[len(s)-scale:]
+	}
+	if p[3]&0x80 != 0 {
+		s = "-" + s
+	}
+
+	return s
+}
+
+// ticksToUnix is the number of 100 nanosecond ticks between the .NET epoch,
+// January 1 of year 1, and the Unix epoch.
+const ticksToUnix = 621355968000000000
+
+// formatDateTime formats the .NET DateTime v: 62 bits of ticks and 2 bits of
+// kind, which is unspecified, UTC or local.
+func formatDateTime(v uint64) string {
+	ticks := int64(v&0x3FFFFFFFFFFFFFFF) - ticksToUnix
+	t := time.Unix(ticks/1e7, ticks%1e7*100).UTC()
+
+	switch v >> 62 {
+	case 1:
+		return t.Format("2006-01-02T15:04:05.9999999Z")
+	case 2:
+		return t.In(time.Local).Format("2006-01-02T15:04:05.9999999-07:00")
+	default:
+		return t.Format("2006-01-02T15:04:05.9999999")
+	}
+}
+
+// formatTimeSpan formats the .NET TimeSpan of ticks as an XML Schema
+// duration, like XmlConvert.
+func formatTimeSpan(ticks int64) string {
+	var b strings.Builder
+	if ticks < 0 {
+		b.WriteByte('-')
+		ticks = -ticks
+	}
+	b.WriteByte('P')
+
+	const ticksPerSecond = 1e7
+	days := ticks / (86400 * ticksPerSecond)
+	ticks %= 86400 * ticksPerSecond
+	if days > 0 {
+		fmt.Fprintf(&b, "%dD", days)
+	}
+	if ticks == 0 && days > 0 {
+		return b.String()
+	}
+
+	b.WriteByte('T')
+	if h := ticks / (3600 * ticksPerSecond); h > 0 {
+		fmt.Fprintf(&b, "%dH", h)
+	}
+	if m := ticks / (60 * ticksPerSecond) % 60; m > 0 {
+		fmt.Fprintf(&b, "%dM", m)
+	}
+	if s := ticks % (60 * ticksPerSecond); s > 0 || ticks == 0 {
+		sec := strconv.FormatInt(s/ticksPerSecond, 10)
+		if frac := s % ticksPerSecond; frac > 0 {
+			sec += strings.TrimRight(fmt.Sprintf(".%07d", frac), "0")
+		}
+		b.WriteString(sec + "S")
+	}
+
+	return b.String()
+}
+
+// formatGUID formats the .NET GUID p, whose first three fields are little
+// endian.
+func formatGUID(p []byte) string {
+	return fmt.Sprintf("%08x-%04x-%04x-%x-%x",
+		binary.LittleEndian.Uint32(p[0:4]),
+		binary.LittleEndian.Uint16(p[4:6]),
+		binary.LittleEndian.Uint16(p[6:8]),
+		p[8:10], p[10:16])
+}
@@ -0,0 +1,380 @@
+package wcf
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// A record of each type of MC-NBFX section 2.2, mostly the examples of the
+// specification with their dictionary strings replaced by strings of the
+// static dictionary.
+func TestDecodeBinaryXML(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{
+			name: "EndElement and ShortElement",
+			data: []byte{0x40, 0x03, 'd', 'o', 'c', 0x01},
+			want: `<doc></doc>`,
+		},
+		{
+			name: "Comment",
+			data: []byte{0x02, 0x07, 'c', 'o', 'm', 'm', 'e', 'n', 't'},
+			want: `<!--comment-->`,
+		},
+		{
+			name: "Array",
+			data: []byte{0x03, 0x40, 0x03, 'a', 'r', 'r', 0x01, 0x8B, 0x03, 0x33, 0x33, 0x88, 0x88, 0xDD, 0xDD},
+			want: `<arr>13107</arr><arr>-30584</arr><arr>-8739</arr>`,
+		},
+		{
+			name: "ShortAttribute",
+			data: []byte{0x40, 0x03, 'd', 'o', 'c', 0x04, 0x04, 'a', 't', 't', 'r', 0x84, 0x01},
+			want: `<doc attr="false"></doc>`,
+		},
+		{
+			name: "Attribute and Element",
+			data: []byte{
+				0x41, 0x03, 'p', 'r', 'e', 0x03, 'd', 'o', 'c',
+				0x09, 0x03, 'p', 'r', 'e', 0x0A, 'h', 't', 't', 'p', ':', '/', '/', 'a', 'b', 'c',
+				0x09, 0x01, 'x', 0x0A, 'h', 't', 't', 'p', ':', '/', '/', 'x', 'y', 'z',
+				0x05, 0x01, 'x', 0x04, 'a', 't', 't', 'r', 0x84,
+				0x01,
+			},
+			want: `<pre:doc xmlns:pre="http://abc" xmlns:x="http://xyz" x:attr="false"></pre:doc>`,
+		},
+		{
+			name: "ShortDictionaryElement",
+			data: []byte{0x42, 0x0E, 0x01},
+			want: `<Body></Body>`,
+		},
+		{
+			name: "DictionaryElement",
+			data: []byte{0x43, 0x03, 'p', 'r', 'e', 0x0E, 0x0B, 0x03, 'p', 'r', 'e', 0x04, 0x01},
+			want: `<pre:Body xmlns:pre="http://www.w3.org/2003/05/soap-envelope"></pre:Body>`,
+		},
+		{
+			name: "PrefixDictionaryElementS and DictionaryXmlnsAttribute",
+			data: []byte{0x56, 0x02, 0x0B, 0x01, 's', 0x04, 0x01},
+			want: `<s:Envelope xmlns:s="http://www.w3.org/2003/05/soap-envelope"></s:Envelope>`,
+		},
+		{
+			name: "PrefixElementB and PrefixAttributeB",
+			data: []byte{0x5F, 0x03, 'd', 'o', 'c', 0x27, 0x04, 'a', 't', 't', 'r', 0x86, 0x01},
+			want: `<b:doc b:attr="true"></b:doc>`,
+		},
+		{
+			name: "PrefixDictionaryAttributeS",
+			data: []byte{0x40, 0x03, 'd', 'o', 'c', 0x1E, 0x00, 0x82, 0x01},
+			want: `<doc s:mustUnderstand="1"></doc>`,
+		},
+		{
+			name: "ShortXmlnsAttribute and ShortDictionaryXmlnsAttribute",
+			data: []byte{0x40, 0x01, 'a', 0x08, 0x0A, 'h', 't', 't', 'p', ':', '/', '/', 'a', 'b', 'c', 0x01, 0x40, 0x01, 'b', 0x0A, 0x06, 0x01},
+			want: `<a xmlns="http://abc"></a><b xmlns="http://www.w3.org/2005/08/addressing"></b>`,
+		},
+		{
+			name: "Chars8TextWithEndElement",
+			data: []byte{0x40, 0x03, 'a', 'b', 'c', 0x99, 0x05, 'h', 'e', 'l', 'l', 'o'},
+			want: `<abc>hello</abc>`,
+		},
+		{
+			name: "Chars16Text",
+			data: []byte{0x40, 0x03, 'a', 'b', 'c', 0x9A, 0x05, 0x00, 'h', 'e', 'l', 'l', 'o', 0x01},
+			want: `<abc>hello</abc>`,
+		},
+		{
+			name: "Chars32Text escaped",
+			data: []byte{0x40, 0x03, 'a', 'b', 'c', 0x9C, 0x03, 0x00, 0x00, 0x00, '<', '&', '>', 0x01},
+			want: `<abc>&lt;&amp;&gt;</abc>`,
+		},
+		{
+			name: "UnicodeChars8Text",
+			data: []byte{0x40, 0x03, 'a', 'b', 'c', 0xB7, 0x04, 'h', 0x00, 0xE9, 0x00},
+			want: `<abc>hé</abc>`,
+		},
+		{
+			name: "Int8Text",
+			data: []byte{0x40, 0x03, 'a', 'b', 'c', 0x89, 0xDE},
+			want: `<abc>-34</abc>`,
+		},
+		{
+			name: "Int16Text",
+			data: []byte{0x40, 0x03, 'a', 'b', 'c', 0x8B, 0x00, 0x80},
+			want: `<abc>-32768</abc>`,
+		},
+		{
+			name: "Int32Text",
+			data: []byte{0x40, 0x03, 'a', 'b', 'c', 0x8D, 0x15, 0xCD, 0x5B, 0x07},
+			want: `<abc>123456789</abc>`,
+		},
+		{
+			name: "Int64Text",
+			data: []byte{0x40, 0x03, 'a', 'b', 'c', 0x8F, 0x00, 0x00, 0x00, 0x80, 0x00, 0x00, 0x00, 0x00},
+			want: `<abc>2147483648</abc>`,
+		},
+		{
+			name: "UInt64Text",
+			data: []byte{0x40, 0x03, 'a', 'b', 'c', 0xB3, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF},
+			want: `<abc>18446744073709551615</abc>`,
+		},
+		{
+			name: "BoolText",
+			data: []byte{0x40, 0x03, 'a', 'b', 'c', 0xB5, 0x01},
+			want: `<abc>true</abc>`,
+		},
+		{
+			name: "ZeroText, OneText and EmptyText",
+			data: []byte{0x40, 0x01, 'a', 0x81, 0x40, 0x01, 'b', 0x83, 0x40, 0x01, 'c', 0xA9},
+			want: `<a>0</a><b>1</b><c></c>`,
+		},
+		{
+			name: "FloatText",
+			data: []byte{0x40, 0x03, 'a', 'b', 'c', 0x91, 0xCD, 0xCC, 0x8C, 0x3F},
+			want: `<abc>1.1</abc>`,
+		},
+		{
+			name: "FloatText infinity",
+			data: []byte{0x40, 0x03, 'a', 'b', 'c', 0x91, 0x00, 0x00, 0x80, 0xFF},
+			want: `<abc>-INF</abc>`,
+		},
+		{
+			name: "DoubleText",
+			data: []byte{0x40, 0x03, 'a', 'b', 'c', 0x93, 0x74, 0x57, 0x14, 0x8B, 0x0A, 0xBF, 0x05, 0x40},
+			want: `<abc>2.71828182845905</abc>`,
+		},
+		{
+			name: "DecimalText",
+			data: []byte{
+				0x40, 0x03, 'a', 'b', 'c', 0x95,
+				0x00, 0x00, 0x06, 0x00, 0x00, 0x00, 0x00, 0x00,
+				0x53, 0x84, 0x5A, 0x00, 0x00, 0x00, 0x00, 0x00,
+			},
+			want: `<abc>5.932115</abc>`,
+		},
+		{
+			name: "DecimalText negative",
+			data: []byte{
+				0x40, 0x03, 'a', 'b', 'c', 0x95,
+				0x00, 0x00, 0x03, 0x80, 0x00, 0x00, 0x00, 0x00,
+				0x05, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+			},
+			want: `<abc>-0.005</abc>`,
+		},
+		{
+			name: "DateTimeText UTC",
+			data: []byte{0x40, 0x03, 'a', 'b', 'c', 0x97, 0x00, 0x40, 0xE4, 0x47, 0x02, 0x22, 0xC1, 0x48},
+			want: `<abc>2000-01-01T00:00:00Z</abc>`,
+		},
+		{
+			name: "DateTimeText",
+			data: []byte{0x40, 0x03, 'a', 'b', 'c', 0x97, 0xFF, 0x3F, 0x37, 0xF4, 0x75, 0x28, 0xCA, 0x2B},
+			want: `<abc>9999-12-31T23:59:59.9999999</abc>`,
+		},
+		{
+			name: "DateTimeText unspecified",
+			data: []byte{0x40, 0x03, 'a', 'b', 'c', 0x97, 0x01, 0x40, 0xE4, 0x47, 0x02, 0x22, 0xC1, 0x08},
+			want: `<abc>2000-01-01T00:00:00.0000001</abc>`,
+		},
+		{
+			name: "TimeSpanText",
+			data: []byte{0x40, 0x03, 'a', 'b', 'c', 0xAF, 0x00, 0xC4, 0xF5, 0x32, 0xFF, 0xFF, 0xFF, 0xFF},
+			want: `<abc>-PT5M44S</abc>`,
+		},
+		{
+			name: "TimeSpanText days",
+			data: []byte{0x40, 0x03, 'a', 'b', 'c', 0xAF, 0x00, 0x00, 0xA7, 0xA9, 0x24, 0x03, 0x00, 0x00},
+			want: `<abc>P4D</abc>`,
+		},
+		{
+			name: "UniqueIdText",
+			data: []byte{
+				0x40, 0x03, 'a', 'b', 'c', 0xAD,
+				0x33, 0x22, 0x11, 0x00, 0x55, 0x44, 0x77, 0x66,
+				0x88, 0x99, 0xAA, 0xBB, 0xCC, 0xDD, 0xEE, 0xFF,
+			},
+			want: `<abc>urn:uuid:00112233-4455-6677-8899-aabbccddeeff</abc>`,
+		},
+		{
+			name: "UuidText",
+			data: []byte{
+				0x40, 0x03, 'a', 'b', 'c', 0xB1,
+				0x33, 0x22, 0x11, 0x00, 0x55, 0x44, 0x77, 0x66,
+				0x88, 0x99, 0xAA, 0xBB, 0xCC, 0xDD, 0xEE, 0xFF,
+			},
+			want: `<abc>00112233-4455-6677-8899-aabbccddeeff</abc>`,
+		},
+		{
+			name: "Bytes8Text",
+			data: []byte{0x40, 0x03, 'a', 'b', 'c', 0x9F, 0x03, 0x00, 0x01, 0x02},
+			want: `<abc>AAEC</abc>`,
+		},
+		{
+			name: "DictionaryText",
+			data: []byte{0x40, 0x03, 'a', 'b', 'c', 0xAB, 0x14},
+			want: `<abc>http://www.w3.org/2005/08/addressing/anonymous</abc>`,
+		},
+		{
+			name: "QNameDictionaryText",
+			data: []byte{0x40, 0x03, 'a', 'b', 'c', 0xBD, 0x12, 0x08, 0x00, 0x00},
+			want: `<abc>s:Header</abc>`,
+		},
+		{
+			name: "StartListText",
+			data: []byte{0x40, 0x03, 'a', 'b', 'c', 0xA4, 0x80, 0x82, 0x98, 0x01, 'x', 0xA6, 0x01},
+			want: `<abc>0 1 x</abc>`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeBinaryXML(tt.data, nil)
+			if err != nil {
+				t.Fatalf("decodeBinaryXML() failed: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("decodeBinaryXML() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeBinaryXMLSession(t *testing.T) {
+	data := []byte{0x42, 0x01, 0x99, 0x01, 'x', 0x42, 0x03, 0xAB, 0x01}
+	got, err := decodeBinaryXML(data, []string{"first", "second"})
+	if err != nil {
+		t.Fatalf("decodeBinaryXML() failed: %v", err)
+	}
+	if want := `<first>x</first><second>first</second>`; string(got) != want {
+		t.Errorf("decodeBinaryXML() = %s, want %s", got, want)
+	}
+}
+
+func TestDecodeBinaryXMLInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"unclosed element", []byte{0x40, 0x03, 'd', 'o', 'c'}},
+		{"end element outside of an element", []byte{0x01}},
+		{"attribute outside of a start tag", []byte{0x04, 0x01, 'a', 0x80}},
+		{"attribute without a value", []byte{0x40, 0x01, 'a', 0x04, 0x01, 'b', 0x01}},
+		{"unknown record", []byte{0x00}},
+		{"unknown session string", []byte{0x42, 0x01, 0x01}},
+		{"unsupported static string", []byte{0x42, 0x7E, 0x01}},
+		{"truncated text", []byte{0x40, 0x01, 'a', 0x8D, 0x01, 0x02}},
+		{"text longer than the data", []byte{0x40, 0x01, 'a', 0x99, 0x05, 'a'}},
+		{"array without an element", []byte{0x03, 0x80}},
+	}
+	for _, tt := range tests {
+		if got, err := decodeBinaryXML(tt.data, nil); err == nil {
+			t.Errorf("%s: decodeBinaryXML(% X) = %s, want an error", tt.name, tt.data, got)
+		}
+	}
+}
+
+func TestEncodeBinaryXML(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  string
+		want []byte
+	}{
+		{
+			name: "ShortElement",
+			doc:  `<doc></doc>`,
+			want: []byte{0x40, 0x03, 'd', 'o', 'c', 0x01},
+		},
+		{
+			name: "Element and XmlnsAttribute",
+			doc:  `<pre:doc xmlns:pre="http://abc"></pre:doc>`,
+			want: []byte{
+				0x41, 0x03, 'p', 'r', 'e', 0x03, 'd', 'o', 'c',
+				0x09, 0x03, 'p', 'r', 'e', 0x0A, 'h', 't', 't', 'p', ':', '/', '/', 'a', 'b', 'c',
+				0x01,
+			},
+		},
+		{
+			name: "ShortAttribute and Chars8Text",
+			doc:  `<doc attr="x">hello</doc>`,
+			want: []byte{0x40, 0x03, 'd', 'o', 'c', 0x04, 0x04, 'a', 't', 't', 'r', 0x98, 0x01, 'x', 0x98, 0x05, 'h', 'e', 'l', 'l', 'o', 0x01},
+		},
+		{
+			name: "PrefixDictionaryElementS and DictionaryXmlnsAttribute",
+			doc:  `<s:Envelope xmlns:s="http://www.w3.org/2003/05/soap-envelope"></s:Envelope>`,
+			want: []byte{0x56, 0x02, 0x0B, 0x01, 's', 0x04, 0x01},
+		},
+		{
+			name: "PrefixDictionaryAttributeS",
+			doc:  `<a:Action s:mustUnderstand="1"></a:Action>`,
+			want: []byte{0x44, 0x0A, 0x1E, 0x00, 0x98, 0x01, '1', 0x01},
+		},
+		{
+			name: "ShortDictionaryXmlnsAttribute",
+			doc:  `<Body xmlns="http://www.w3.org/2005/08/addressing"></Body>`,
+			want: []byte{0x42, 0x0E, 0x0A, 0x06, 0x01},
+		},
+		{
+			name: "EmptyText",
+			doc:  `<doc attr=""></doc>`,
+			want: []byte{0x40, 0x03, 'd', 'o', 'c', 0x04, 0x04, 'a', 't', 't', 'r', 0xA8, 0x01},
+		},
+		{
+			name: "Comment",
+			doc:  `<!--comment-->`,
+			want: []byte{0x02, 0x07, 'c', 'o', 'm', 'm', 'e', 'n', 't'},
+		},
+		{
+			name: "Chars16Text",
+			doc:  `<a>` + strings.Repeat("x", 256) + `</a>`,
+			want: append(append([]byte{0x40, 0x01, 'a', 0x9A, 0x00, 0x01}, strings.Repeat("x", 256)...), 0x01),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := encodeBinaryXML([]byte(tt.doc))
+			if err != nil {
+				t.Fatalf("encodeBinaryXML() failed: %v", err)
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("encodeBinaryXML() = % X, want % X", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEncodeBinaryXMLDirective(t *testing.T) {
+	if _, err := encodeBinaryXML([]byte(`<!DOCTYPE doc><doc></doc>`)); err == nil {
+		t.Error("encodeBinaryXML() succeeded, want an error")
+	}
+}
+
+func TestBinaryXMLRoundTrip(t *testing.T) {
+	docs := []string{
+		`<doc></doc>`,
+		`<s:Envelope xmlns:s="http://www.w3.org/2003/05/soap-envelope" xmlns:a="http://www.w3.org/2005/08/addressing">` +
+			`<s:Header><a:Action s:mustUnderstand="1">urn:submit</a:Action>` +
+			`<a:To s:mustUnderstand="1">net.msmq://localhost/private/orders</a:To></s:Header>` +
+			`<s:Body><Order xmlns="urn:orders" id="42"><Item>a &amp; b &lt;c&gt;</Item><Note></Note></Order></s:Body>` +
+			`</s:Envelope>`,
+		`<r:Sequence xmlns:r="http://schemas.xmlsoap.org/ws/2005/02/rm"><r:Identifier>urn:uuid:1</r:Identifier>` +
+			`<r:MessageNumber>1</r:MessageNumber></r:Sequence>`,
+		`<long:element xmlns:long="urn:long">` + strings.Repeat("é", 40000) + `</long:element>`,
+		`<!--before--><doc><!--inside--></doc>`,
+	}
+	for _, doc := range docs {
+		data, err := encodeBinaryXML([]byte(doc))
+		if err != nil {
+			t.Fatalf("encodeBinaryXML() failed: %v", err)
+		}
+
+		got, err := decodeBinaryXML(data, nil)
+		if err != nil {
+			t.Fatalf("decodeBinaryXML() failed: %v", err)
+		}
+		if string(got) != doc {
+			t.Errorf("decodeBinaryXML(encodeBinaryXML(%.80s)) = %.80s", doc, got)
+		}
+	}
+}
@@ -0,0 +1,231 @@
+// Package wcf exchanges messages with WCF services bound to MSMQ queues with
+// NetMsmqBinding, without a .NET intermediary. NetMsmqBinding frames its SOAP
+// 1.2 envelopes with the .NET Message Framing Protocol and encodes them with
+// the .NET Binary Format; Message hides both, so that a Go service only
+// deals with the XML of the message body:
+//   body, err := xml.Marshal(SubmitOrder{ID: 42})
+//   ...
+//   m := &wcf.Message{
+//       Action: "http://tempuri.org/IOrderService/SubmitOrder",
+//       To:     "net.msmq://localhost/private/orders",
+//       Body:   body,
+//   }
+//   err = wcf.Send(queue, m, msmq.SendWithTransaction(msmq.SingleMessage))
+//
+// Messages sent by WCF are decoded from the received MSMQ messages:
+//   msgs, err := wcf.Decode(&msg)
+//   ...
+//   var order SubmitOrder
+//   err = xml.Unmarshal(msgs[0].Body, &order)
+//
+// The body must match what the DataContractSerializer of the service expects:
+// an operation element named after the operation, in the namespace of the
+// service contract, wrapping an element per parameter. NetMsmqBinding
+// requires transactional queues unless ExactlyOnce is disabled.
+package wcf
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"strings"
+
+	"github.com/jandauz/go-msmq"
+)
+
+// The namespaces of the envelopes of NetMsmqBinding.
+const (
+	soapNamespace       = "http://www.w3.org/2003/05/soap-envelope"
+	addressingNamespace = "http://www.w3.org/2005/08/addressing"
+)
+
+// Message is a SOAP message exchanged with a WCF endpoint.
+type Message struct {
+	// Action is the WS-Addressing action of the message, which selects the
+	// operation of the service, such as
+	// http://tempuri.org/IOrderService/SubmitOrder.
+	Action string
+
+	// To is the address of the endpoint, such as
+	// net.msmq://localhost/private/orders. See URI.
+	To string
+
+	// Body is the content of the SOAP body as XML.
+	Body []byte
+}
+
+// envelope is the textual form of the SOAP envelope of a Message.
+type envelope struct {
+	XMLName xml.Name `xml:"http://www.w3.org/2003/05/soap-envelope Envelope"`
+	Header  struct {
+		Action string `xml:"http://www.w3.org/2005/08/addressing Action"`
+		To     string `xml:"http://www.w3.org/2005/08/addressing To"`
+	} `xml:"http://www.w3.org/2003/05/soap-envelope Header"`
+	Body struct {
+		Content []byte `xml:",innerxml"`
+	} `xml:"http://www.w3.org/2003/05/soap-envelope Body"`
+}
+
+// MarshalBinary returns m framed and encoded as a datagram of
+// NetMsmqBinding, which is the body of the MSMQ message to send.
+func (m *Message) MarshalBinary() ([]byte, error) {
+	var doc bytes.Buffer
+	doc.WriteString(`<s:Envelope xmlns:s="` + soapNamespace + `" xmlns:a="` + addressingNamespace + `"><s:Header><a:Action s:mustUnderstand="1">`)
+	xml.EscapeText(&doc, []byte(m.Action))
+	doc.WriteString(`</a:Action><a:To s:mustUnderstand="1">`)
+	xml.EscapeText(&doc, []byte(m.To))
+	doc.WriteString(`</a:To></s:Header><s:Body>`)
+	doc.Write(m.Body)
+	doc.WriteString(`</s:Body></s:Envelope>`)
+
+	e, err := encodeBinaryXML(doc.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("wcf: MarshalBinary() failed to encode envelope: %w", err)
+	}
+
+	f := &Frame{
+		Mode:      ModeSingletonSized,
+		Via:       m.To,
+		Encoding:  EncodingBinary,
+		Envelopes: [][]byte{e},
+	}
+	return f.MarshalBinary()
+}
+
+// UnmarshalBinary decodes the body of an MSMQ message sent by NetMsmqBinding
+// into m. The body must hold a single message; use Unmarshal for the
+// messages of a session.
+func (m *Message) UnmarshalBinary(data []byte) error {
+	msgs, err := Unmarshal(data)
+	if err != nil {
+		return err
+	}
+	if len(msgs) != 1 {
+		return fmt.Errorf("wcf: UnmarshalBinary() failed to decode message: frame holds %d messages", len(msgs))
+	}
+
+	*m = msgs[0]
+	return nil
+}
+
+// Unmarshal decodes the messages of the body of an MSMQ message sent by
+// NetMsmqBinding: a single message for a datagram, or the messages of a
+// session.
+func Unmarshal(data []byte) ([]Message, error) {
+	var f Frame
+	if err := f.UnmarshalBinary(data); err != nil {
+		return nil, err
+	}
+	if f.Encoding != EncodingBinary && f.Encoding != EncodingBinarySession {
+		return nil, fmt.Errorf("wcf: Unmarshal() failed to decode frame: unsupported encoding 0x%02X", byte(f.Encoding))
+	}
+
+	var session []string
+	msgs := make([]Message, 0, len(f.Envelopes))
+	for i, e := range f.Envelopes {
+		if f.Encoding == EncodingBinarySession {
+			// Every envelope of a session starts with the strings it adds
+			// to the in-band dictionary.
+			var err error
+			if e, session, err = readSessionStrings(e, session); err != nil {
+				return nil, fmt.Errorf("wcf: Unmarshal() failed to decode dictionary of envelope %d: %w", i+1, err)
+			}
+		}
+
+		doc, err := decodeBinaryXML(e, session)
+		if err != nil {
+			return nil, fmt.Errorf("wcf: Unmarshal() failed to decode envelope %d: %w", i+1, err)
+		}
+
+		var env envelope
+		if err := xml.Unmarshal(doc, &env); err != nil {
+			return nil, fmt.Errorf("wcf: Unmarshal() failed to decode envelope %d: %w", i+1, err)
+		}
+
+		msgs = append(msgs, Message{
+			Action: env.Header.Action,
+			To:     env.Header.To,
+			Body:   env.Body.Content,
+		})
+	}
+
+	return msgs, nil
+}
+
+// readSessionStrings reads the strings added to the in-band dictionary at the
+// start of the envelope e, appends them to session and returns the rest of e.
+func readSessionStrings(e []byte, session []string) ([]byte, []string, error) {
+	r := bytes.NewReader(e)
+	n, err := readInt31(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	if n > r.Len() {
+		return nil, nil, errTruncated
+	}
+
+	strs := bytes.NewReader(e[len(e)-r.Len() : len(e)-r.Len()+n])
+	for strs.Len() > 0 {
+		s, err := readString(strs)
+		if err != nil {
+			return nil, nil, err
+		}
+		session = append(session, s)
+	}
+
+	return e[len(e)-r.Len()+n:], session, nil
+}
+
+// Send sends m to q, which must be opened with Send AccessMode, with the
+// specified SendOption values.
+func Send(q *msmq.Queue, m *Message, opts ...msmq.SendOption) error {
+	body, err := m.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	msg, err := msmq.NewMessage()
+	if err != nil {
+		return err
+	}
+	defer msg.Close()
+
+	if err := msg.SetBodyBytes(body); err != nil {
+		return err
+	}
+
+	return msg.Send(q, opts...)
+}
+
+// Decode decodes the messages sent by NetMsmqBinding in msg.
+func Decode(msg *msmq.Message) ([]Message, error) {
+	body, err := msg.BodyBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	return Unmarshal(body)
+}
+
+// URI returns the net.msmq URI of the queue with the path name pathName, such
+// as net.msmq://localhost/private/orders for .\private$\orders, which is the
+// address of the WCF endpoints bound to the queue.
+func URI(pathName string) (string, error) {
+	parts := strings.Split(pathName, `\`)
+	if len(parts) < 2 || len(parts) > 3 || parts[0] == "" {
+		return "", fmt.Errorf("wcf: URI(%s) failed to convert path name: invalid path name", pathName)
+	}
+
+	host := parts[0]
+	if host == "." {
+		host = "localhost"
+	}
+	if len(parts) == 3 {
+		if !strings.EqualFold(parts[1], "private$") {
+			return "", fmt.Errorf("wcf: URI(%s) failed to convert path name: invalid path name", pathName)
+		}
+		return "net.msmq://" + host + "/private/" + parts[2], nil
+	}
+
+	return "net.msmq://" + host + "/" + parts[1], nil
+}
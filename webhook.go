@@ -0,0 +1,235 @@
+package msmq
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// WebhookStatusError is the error of a webhook request that the endpoint
+// answered with a status code other than 2xx.
+type WebhookStatusError struct {
+	StatusCode int
+}
+
+// Error implements the error interface.
+func (e *WebhookStatusError) Error() string {
+	return fmt.Sprintf("go-msmq: webhook responded %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+// Temporary reports whether the endpoint may accept the request later: a
+// 408, 429 or 5xx response.
+func (e *WebhookStatusError) Temporary() bool {
+	return e.StatusCode == http.StatusRequestTimeout ||
+		e.StatusCode == http.StatusTooManyRequests ||
+		e.StatusCode >= 500
+}
+
+// WebhookSink is a Sink that POSTs each message to an HTTP endpoint, so that
+// legacy MSMQ producers can feed HTTP services:
+//   sink := msmq.NewWebhookSink("https://orders.example.com/hooks/msmq",
+//       msmq.WebhookWithHeader("Authorization", "Bearer "+token),
+//       msmq.WebhookWithDeadLetterQueue(deadLetters),
+//   )
+//   bridge := msmq.NewSinkBridge(src, sink)
+//   err := bridge.Run(ctx)
+//
+// The body of the request is the body of the message, and its Label, ID and
+// CorrelationID are sent in the X-Msmq-Label, X-Msmq-Id and
+// X-Msmq-Correlation-Id headers, the IDs in hexadecimal. Any 2xx response
+// accepts the message.
+//
+// Network errors and 408, 429 and 5xx responses are retried by the retry
+// policy of the WebhookSink; once the attempts are exhausted, the error is
+// returned and the message stays in the source queue. Other responses are
+// permanent failures: the message is sent to the dead-letter queue, if one
+// is configured, and removed from the source queue.
+type WebhookSink struct {
+	url     string
+	options *webhookOptions
+}
+
+// NewWebhookSink returns a pointer to a WebhookSink that POSTs messages to
+// url, configured by the options.
+func NewWebhookSink(url string, opts ...WebhookOption) *WebhookSink {
+	options := &webhookOptions{
+		client: http.DefaultClient,
+		header: http.Header{
+			"Content-Type": []string{"application/octet-stream"},
+		},
+		retry: &RetryPolicy{MaxAttempts: 5},
+	}
+	for _, o := range opts {
+		o.set(options)
+	}
+
+	return &WebhookSink{
+		url:     url,
+		options: options,
+	}
+}
+
+// Forward POSTs msg to the endpoint, retrying temporary failures, and sends
+// it to the dead-letter queue on a permanent failure.
+func (s *WebhookSink) Forward(ctx context.Context, msg *Message) error {
+	req, err := s.newRequest(ctx, msg)
+	if err != nil {
+		return fmt.Errorf("go-msmq: Forward() failed to create request: %w", err)
+	}
+
+	// A nil policy posts once.
+	retry := RetryPolicy{MaxAttempts: 1}
+	if s.options.retry != nil {
+		retry = *s.options.retry
+	}
+	retry.Retryable = func(err error) bool {
+		var statusErr *WebhookStatusError
+		if errors.As(err, &statusErr) {
+			return statusErr.Temporary()
+		}
+		return ctx.Err() == nil
+	}
+	err = retry.Do(func() error {
+		return s.post(req)
+	})
+	if err == nil {
+		return nil
+	}
+
+	var statusErr *WebhookStatusError
+	if !errors.As(err, &statusErr) || statusErr.Temporary() || s.options.deadLetter == nil {
+		return fmt.Errorf("go-msmq: Forward() failed to post message: %w", err)
+	}
+
+	if err := msg.SetExtension([]byte(err.Error())); err != nil {
+		return fmt.Errorf("go-msmq: Forward() failed to dead-letter message: %w", err)
+	}
+	if err := msg.Send(s.options.deadLetter, s.options.deadLetterOpts...); err != nil {
+		return fmt.Errorf("go-msmq: Forward() failed to dead-letter message: %w", err)
+	}
+
+	return nil
+}
+
+// newRequest returns the request that posts msg to the endpoint.
+func (s *WebhookSink) newRequest(ctx context.Context, msg *Message) (*http.Request, error) {
+	body, err := msg.BodyBytes()
+	if err != nil {
+		return nil, err
+	}
+	label, err := msg.Label()
+	if err != nil {
+		return nil, err
+	}
+	id, err := msg.ID()
+	if err != nil {
+		return nil, err
+	}
+	correlationID, err := msg.CorrelationID()
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header = s.options.header.Clone()
+	req.Header.Set("X-Msmq-Label", label)
+	req.Header.Set("X-Msmq-Id", hex.EncodeToString(id))
+	req.Header.Set("X-Msmq-Correlation-Id", hex.EncodeToString(correlationID))
+
+	return req, nil
+}
+
+// post sends req once and returns a *WebhookStatusError for a response other
+// than 2xx.
+func (s *WebhookSink) post(req *http.Request) error {
+	// Rewind the body for retries.
+	body, err := req.GetBody()
+	if err != nil {
+		return err
+	}
+	req.Body = body
+
+	resp, err := s.options.client.Do(req)
+	if err != nil {
+		return err
+	}
+	// Drain the body so that the connection can be reused.
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return &WebhookStatusError{StatusCode: resp.StatusCode}
+	}
+
+	return nil
+}
+
+// WebhookOption represents an option to configure a WebhookSink.
+type WebhookOption struct {
+	set func(opts *webhookOptions)
+}
+
+// webhookOptions contains all the options to configure a WebhookSink.
+type webhookOptions struct {
+	client         *http.Client
+	header         http.Header
+	retry          *RetryPolicy
+	deadLetter     *Queue
+	deadLetterOpts []SendOption
+}
+
+// WebhookWithClient returns a WebhookOption that configures the HTTP client
+// used to post messages, for example to set a timeout or TLS configuration.
+// The default is http.DefaultClient.
+func WebhookWithClient(client *http.Client) WebhookOption {
+	return WebhookOption{
+		set: func(opts *webhookOptions) {
+			opts.client = client
+		},
+	}
+}
+
+// WebhookWithHeader returns a WebhookOption that adds a header to every
+// request, such as Authorization, or replaces the default Content-Type of
+// application/octet-stream.
+func WebhookWithHeader(key, value string) WebhookOption {
+	return WebhookOption{
+		set: func(opts *webhookOptions) {
+			opts.header.Set(key, value)
+		},
+	}
+}
+
+// WebhookWithRetry returns a WebhookOption that configures how temporary
+// failures are retried, or not retried if nil. The Retryable field of policy
+// is ignored. The default is 5 attempts with the default backoff.
+func WebhookWithRetry(policy *RetryPolicy) WebhookOption {
+	return WebhookOption{
+		set: func(opts *webhookOptions) {
+			opts.retry = policy
+		},
+	}
+}
+
+// WebhookWithDeadLetterQueue returns a WebhookOption that configures the
+// queue, opened with Send AccessMode, that messages are sent to with the
+// send options when the endpoint rejects them permanently. The error is set
+// as the Extension of the message. A transactional queue needs
+// SendWithTransaction(SingleMessage), since a Sink cannot take part in the
+// transaction of the Bridge. Without a dead-letter queue, a permanent
+// failure stops the Bridge.
+func WebhookWithDeadLetterQueue(queue *Queue, sendOpts ...SendOption) WebhookOption {
+	return WebhookOption{
+		set: func(opts *webhookOptions) {
+			opts.deadLetter = queue
+			opts.deadLetterOpts = sendOpts
+		},
+	}
+}
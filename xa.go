@@ -0,0 +1,210 @@
+package msmq
+
+import (
+	"context"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+// XID identifies a branch of an XA transaction.
+type XID struct {
+	// FormatID identifies the format of GlobalTransactionID and
+	// BranchQualifier.
+	FormatID int32
+
+	// GlobalTransactionID identifies the global transaction. It must not
+	// exceed 64 bytes.
+	GlobalTransactionID []byte
+
+	// BranchQualifier identifies the branch of the global transaction. It
+	// must not exceed 64 bytes.
+	BranchQualifier []byte
+}
+
+// XAResource is a resource manager, such as a database accessed with
+// database/sql, that takes part in an XA transaction with MSMQ. The methods
+// correspond to the xa_start, xa_end, xa_prepare, xa_commit and xa_rollback
+// functions of the XA specification.
+type XAResource interface {
+	Start(ctx context.Context, xid XID) error
+	End(ctx context.Context, xid XID) error
+	Prepare(ctx context.Context, xid XID) error
+	Commit(ctx context.Context, xid XID) error
+	Rollback(ctx context.Context, xid XID) error
+}
+
+// XATransaction makes MSMQ operations and the work done through one or more
+// XAResource atomic, so that "update the database and send a message" either
+// happens completely or not at all:
+//   conn, err := db.Conn(ctx)
+//   ...
+//   xa, err := msmq.BeginXA(ctx, xid, msmq.NewMySQLXAResource(conn))
+//   ...
+//   _, err = conn.ExecContext(ctx, "UPDATE orders SET state = 'shipped' WHERE id = ?", id)
+//   ...
+//   err = msg.Send(queue, msmq.SendInTransaction(xa.Transaction()))
+//   ...
+//   err = xa.Commit(ctx)
+//
+// The MSMQ operations are part of a coordinated transaction. When committing,
+// every XAResource is prepared first and the MSMQ transaction is committed
+// last, before the prepared XAResource are committed. If the MSMQ transaction
+// fails to commit, every XAResource is rolled back. If the process stops
+// after the MSMQ transaction is committed, the XAResource remain prepared and
+// must be committed with the same XID when the process recovers.
+//
+// The XA TransactionLevel is only meaningful when an external transaction
+// manager has enlisted the current thread with MSMQ, which the Go runtime does
+// not guarantee since goroutines move between threads. XATransaction should
+// be used instead.
+type XATransaction struct {
+	tx        *Transaction
+	xid       XID
+	resources []XAResource
+}
+
+// ErrXAHeuristic is wrapped by the error returned by XATransaction.Commit when
+// the MSMQ transaction is committed but an XAResource fails to commit. The
+// XAResource is prepared and must be committed with the same XID.
+var ErrXAHeuristic = errors.New("go-msmq: MSMQ transaction committed but an XA resource failed to commit")
+
+// BeginXA starts a coordinated MSMQ transaction and starts the xid branch on
+// every specified resource.
+func BeginXA(ctx context.Context, xid XID, resources ...XAResource) (*XATransaction, error) {
+	tx, err := BeginCoordinatedTransaction()
+	if err != nil {
+		return nil, fmt.Errorf("go-msmq: BeginXA() failed to begin transaction: %w", err)
+	}
+
+	for i, r := range resources {
+		err = r.Start(ctx, xid)
+		if err != nil {
+			for _, started := range resources[:i] {
+				started.End(ctx, xid)
+				started.Rollback(ctx, xid)
+			}
+			tx.Abort()
+			return nil, fmt.Errorf("go-msmq: BeginXA() failed to start XA resource: %w", err)
+		}
+	}
+
+	return &XATransaction{
+		tx:        tx,
+		xid:       xid,
+		resources: resources,
+	}, nil
+}
+
+// Transaction returns the MSMQ transaction to use with SendInTransaction and
+// ReceiveInTransaction.
+func (x *XATransaction) Transaction() *Transaction {
+	return x.tx
+}
+
+// Commit commits the XA transaction. See XATransaction for the order in which
+// the MSMQ transaction and the XAResource are committed.
+func (x *XATransaction) Commit(ctx context.Context) error {
+	for _, r := range x.resources {
+		err := r.End(ctx, x.xid)
+		if err == nil {
+			err = r.Prepare(ctx, x.xid)
+		}
+		if err != nil {
+			x.Rollback(ctx)
+			return fmt.Errorf("go-msmq: Commit() failed to prepare XA resource: %w", err)
+		}
+	}
+
+	err := x.tx.Commit()
+	if err != nil {
+		x.rollbackResources(ctx)
+		return fmt.Errorf("go-msmq: Commit() failed to commit transaction: %w", err)
+	}
+
+	var errs []error
+	for _, r := range x.resources {
+		if err := r.Commit(ctx, x.xid); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("go-msmq: Commit() failed to commit XA resource %x: %w", x.xid.GlobalTransactionID, errors.Join(append([]error{ErrXAHeuristic}, errs...)...))
+	}
+
+	return nil
+}
+
+// Rollback aborts the MSMQ transaction and rolls back every XAResource.
+func (x *XATransaction) Rollback(ctx context.Context) error {
+	err := x.tx.Abort()
+	rerr := x.rollbackResources(ctx)
+	if err != nil {
+		return fmt.Errorf("go-msmq: Rollback() failed to abort transaction: %w", err)
+	}
+
+	if rerr != nil {
+		return fmt.Errorf("go-msmq: Rollback() failed to roll back XA resource: %w", rerr)
+	}
+
+	return nil
+}
+
+// rollbackResources rolls back every XAResource and returns the first error.
+func (x *XATransaction) rollbackResources(ctx context.Context) error {
+	var first error
+	for _, r := range x.resources {
+		// End fails if the branch was already ended by Commit, which is
+		// expected.
+		r.End(ctx, x.xid)
+		if err := r.Rollback(ctx, x.xid); err != nil && first == nil {
+			first = err
+		}
+	}
+
+	return first
+}
+
+// NewMySQLXAResource returns an XAResource that uses the XA statements of
+// MySQL and MariaDB on conn. The work done through conn between BeginXA and
+// XATransaction.Commit is part of the XA transaction.
+func NewMySQLXAResource(conn *sql.Conn) XAResource {
+	return &mysqlXAResource{conn: conn}
+}
+
+// mysqlXAResource implements XAResource with the XA statements of MySQL.
+type mysqlXAResource struct {
+	conn *sql.Conn
+}
+
+// xid formats xid as an XA statement argument.
+func (r *mysqlXAResource) xid(xid XID) string {
+	return fmt.Sprintf("X'%s',X'%s',%d", hex.EncodeToString(xid.GlobalTransactionID), hex.EncodeToString(xid.BranchQualifier), xid.FormatID)
+}
+
+func (r *mysqlXAResource) exec(ctx context.Context, statement string, xid XID) error {
+	_, err := r.conn.ExecContext(ctx, statement+" "+r.xid(xid))
+	return err
+}
+
+func (r *mysqlXAResource) Start(ctx context.Context, xid XID) error {
+	return r.exec(ctx, "XA START", xid)
+}
+
+func (r *mysqlXAResource) End(ctx context.Context, xid XID) error {
+	return r.exec(ctx, "XA END", xid)
+}
+
+func (r *mysqlXAResource) Prepare(ctx context.Context, xid XID) error {
+	return r.exec(ctx, "XA PREPARE", xid)
+}
+
+func (r *mysqlXAResource) Commit(ctx context.Context, xid XID) error {
+	return r.exec(ctx, "XA COMMIT", xid)
+}
+
+func (r *mysqlXAResource) Rollback(ctx context.Context, xid XID) error {
+	return r.exec(ctx, "XA ROLLBACK", xid)
+}